@@ -0,0 +1,91 @@
+package goraygen
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// ActorHandle is an actor struct annotated with `// rayactor`, for which a typed handle type is generated.
+// It's a parallel pipeline to the rayactors factories: the handle exposes the actor methods as methods
+// instead of `Actor_Method` functions.
+type ActorHandle struct {
+	StructName string  // also the prefix of the generated handle type and spawn function
+	ActorName  string  // the registered actor name: the factory name if found, otherwise StructName
+	Factory    *Method // the factory in the rayactors struct creating this actor, nil if not found
+	Methods    []Method
+}
+
+/*
+	type CounterActorHandle struct {
+		ray.ActorHandle
+	}
+
+func SpawnCounter(n int) *RemoteActor[CounterActorHandle]
+func (_actor *CounterActorHandle) Incr(n int) *RemoteFunc[*Future1[int]]
+*/
+const actorHandleDefTpl = `
+// {{.ActorName}}ActorHandle is the typed handle of a {{.ActorName}} actor.
+type {{.ActorName}}ActorHandle struct {
+	ray.ActorHandle
+}
+
+// Spawn{{.ActorName}} creates a new {{.ActorName}} actor, call Remote(opts...) on the result to start it.
+{{- if .ReceiverType}}
+// original actor constructor: [{{.DocLink}}]
+{{- end}}
+func Spawn{{.ActorName}}({{.ParamList}}) *RemoteActor[{{.ActorName}}ActorHandle] {
+	return NewRemoteActor[{{.ActorName}}ActorHandle]("{{.FuncName}}", {{.ArgsStatement}})
+}
+`
+
+const actorHandleMethodDefTpl = `
+{{.Doc}}
+// original actor method: [{{.DocLink}}]
+func (_actor *{{.ActorName}}ActorHandle) {{.FuncName}}({{.ParamList}}) *RemoteFunc[*Future{{.ResLen}}{{.ResTypes}}] {
+	return NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]("{{.TaskName}}", {{.ArgsStatement}}, &_actor.ActorHandle)
+}
+`
+
+// collectActorHandles finds the `// rayactor` structs and their methods.
+// The actor factory (with the constructor parameters) is looked up in the rayactors struct by its result type.
+func (g *Generator) collectActorHandles() {
+	for _, s := range analysis.FindStructs(g.pkg, g.opts.actorMatcher()) {
+		h := ActorHandle{StructName: s.Name.Name, ActorName: s.Name.Name}
+		for i, factory := range g.actorFactories {
+			resType := strings.TrimPrefix(factory.Results[0].Type, "*")
+			if resType == g.sourceQualifier()+s.Name.Name {
+				h.Factory = &g.actorFactories[i]
+				h.ActorName = factory.Name
+				break
+			}
+		}
+		if h.Factory == nil {
+			g.warnAt(g.typePos(h.StructName), "no-actor-factory", "No factory of actor %s found in rayactors struct, spawn it by name '%s' without arguments", h.StructName, h.ActorName)
+		}
+		h.Methods = g.filterMethods(analysis.Methods(g.pkg, h.StructName, g.outputPkgPath, g.importStore))
+		h.Methods = append(h.Methods, g.checkpointMethods(h.StructName)...)
+		g.logger.Debug("Found actor handle", "struct", h.StructName)
+		for _, m := range h.Methods {
+			g.trace("Found actor method", "actor", h.StructName, "method", m.String())
+		}
+		g.actorHandles = append(g.actorHandles, h)
+	}
+}
+
+func generateActorHandle(buf *bytes.Buffer, h ActorHandle, docQualifier string) error {
+	factory := Method{Name: h.ActorName} // no ReceiverType: no link to the original constructor
+	if h.Factory != nil {
+		factory = *h.Factory
+	}
+	if err := generateWrapperFunction(actorHandleDefTpl, buf, factory, nil, h.StructName, docQualifier); err != nil {
+		return err
+	}
+	for _, m := range h.Methods {
+		if err := generateWrapperFunction(actorHandleMethodDefTpl, buf, m, nil, h.StructName, docQualifier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateActorHandle(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"actors": `package mypkg
+
+// rayactors
+type Actors struct{}
+
+func (Actors) NewCounter(start int) *Counter { return &Counter{start} }
+
+// rayactor
+type Counter struct{ n int }
+
+func (c *Counter) Add(n int, more ...int) int { return c.n }
+
+// rayactor
+type Greeter struct{}
+
+func (Greeter) Hi() string { return "hi" }
+`}, Options{})
+
+	require.Contains(t, code, "type CounterActorHandle struct {")
+	require.Contains(t, code, "// original actor constructor: [Actors.NewCounter]\n")
+	require.Contains(t, code, `return NewRemoteActor[CounterActorHandle]("NewCounter", []any{start})`)
+	require.Contains(t, code, "func (_actor *CounterActorHandle) Add(n int, more ...int) *RemoteFunc[*Future1[int]] {")
+	require.Contains(t, code, "func SpawnGreeter() *RemoteActor[GreeterActorHandle] {")
+	require.Contains(t, code, `return NewRemoteFunc[*Future1[string]]("Hi", []any{}, &_actor.ActorHandle)`)
+}
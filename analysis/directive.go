@@ -0,0 +1,188 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirectivePrefix starts the directive comment lines.
+const DirectivePrefix = "//goray:"
+
+// Directive is a `//goray:name arg key=value ...` comment in the doc of a method, configuring the generated code.
+type Directive struct {
+	Name   string            `json:"name"`
+	Args   []string          `json:"args,omitempty"`   // positional args
+	Params map[string]string `json:"params,omitempty"` // key=value args
+}
+
+// ParseDirectives parses the directives in the doc comment lines, e.g. the Doc of a Method.
+func ParseDirectives(doc string) []Directive {
+	var directives []Directive
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, DirectivePrefix) {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, DirectivePrefix))
+		if len(fields) == 0 {
+			continue
+		}
+		d := Directive{Name: fields[0], Params: make(map[string]string)}
+		for _, field := range fields[1:] {
+			if key, value, ok := strings.Cut(field, "="); ok {
+				d.Params[key] = value
+			} else {
+				d.Args = append(d.Args, strings.Split(strings.Trim(field, ","), ",")...)
+			}
+		}
+		directives = append(directives, d)
+	}
+	return directives
+}
+
+// Directive returns the first directive with the name in the doc of the method.
+func (m Method) Directive(name string) (Directive, bool) {
+	for _, d := range ParseDirectives(m.Doc) {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Directive{}, false
+}
+
+// directiveValidators validate the args of the directives by name, see Directive.Validate.
+var directiveValidators = map[string]func(d Directive) error{
+	"timeout": func(d Directive) error {
+		if len(d.Args) != 1 || len(d.Params) > 0 {
+			return fmt.Errorf("expect a duration like `//goray:timeout 30s`")
+		}
+		if timeout, err := time.ParseDuration(d.Args[0]); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q, expect a positive duration", d.Args[0])
+		}
+		return nil
+	},
+	"retry": func(d Directive) error {
+		if len(d.Args) > 0 {
+			return fmt.Errorf("expect key=value args like `//goray:retry max=5 backoff=exponential base=100ms`")
+		}
+		for _, key := range slices.Sorted(maps.Keys(d.Params)) { // the first invalid one
+			value := d.Params[key]
+			var valid bool
+			switch key {
+			case "max":
+				n, err := strconv.Atoi(value)
+				valid = err == nil && n >= 0
+			case "backoff":
+				valid = value == "constant" || value == "linear" || value == "exponential"
+			case "base":
+				base, err := time.ParseDuration(value)
+				valid = err == nil && base >= 0
+			default:
+				return fmt.Errorf("unknown arg %s, expect max, backoff or base", key)
+			}
+			if !valid {
+				return fmt.Errorf("invalid %s=%s", key, value)
+			}
+		}
+		return nil
+	},
+	"retryable": func(d Directive) error {
+		if len(d.Args) == 0 || len(d.Params) > 0 {
+			return fmt.Errorf("expect error variables or types like `//goray:retryable ErrBusy *BusyError`")
+		}
+		return nil
+	},
+	"idempotent": func(d Directive) error {
+		if len(d.Args) > 0 || len(d.Params) > 0 {
+			return fmt.Errorf("expect no args")
+		}
+		return nil
+	},
+	"resources": func(d Directive) error {
+		if len(d.Args) > 0 || len(d.Params) == 0 {
+			return fmt.Errorf("expect key=value args like `//goray:resources cpu=2 gpu=1 memory=4Gi`")
+		}
+		for _, key := range slices.Sorted(maps.Keys(d.Params)) {
+			value := d.Params[key]
+			var err error
+			if key == "memory" {
+				_, err = ParseMemory(value)
+			} else {
+				_, err = ParseAmount(value)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+	},
+	"put-threshold": func(d Directive) error {
+		if len(d.Args) != 1 || len(d.Params) > 0 {
+			return fmt.Errorf("expect a size like `//goray:put-threshold 1Mi`")
+		}
+		if _, err := ParseMemory(d.Args[0]); err != nil {
+			return fmt.Errorf("invalid size %q, expect a number of bytes with an optional unit like 1Mi or 500K", d.Args[0])
+		}
+		return nil
+	},
+	"codec": func(d Directive) error {
+		if len(d.Args) != 1 || len(d.Params) > 0 {
+			return fmt.Errorf("expect a codec like `//goray:codec gob`")
+		}
+		if c := d.Args[0]; c != "msgpack" && c != "gob" && c != "json" {
+			return fmt.Errorf("invalid codec %q, expect msgpack, gob or json", c)
+		}
+		return nil
+	},
+}
+
+// Validate reports whether the directive is known and its args are valid.
+// The generator ignores the invalid directives (or their invalid args) with a warning.
+func (d Directive) Validate() error {
+	validate, ok := directiveValidators[d.Name]
+	if !ok {
+		return fmt.Errorf("unknown directive %s%s", DirectivePrefix, d.Name)
+	}
+	if err := validate(d); err != nil {
+		return fmt.Errorf("invalid %s%s: %w", DirectivePrefix, d.Name, err)
+	}
+	return nil
+}
+
+// memoryUnits are the suffixes of memory quantities, e.g. "4Gi" or "500M".
+var memoryUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+}
+
+// ParseMemory parses a memory quantity in bytes, with an optional binary (Ki, Mi, Gi, Ti) or decimal (K, M, G, T) unit.
+func ParseMemory(s string) (int64, error) {
+	factor := int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, factor = strings.TrimSuffix(s, u.suffix), u.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid memory %q", s)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+// ParseAmount parses a positive resource amount, e.g. "1" or "0.5".
+func ParseAmount(s string) (string, error) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("invalid amount %q", s)
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64), nil
+}
@@ -0,0 +1,45 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectiveValidate(t *testing.T) {
+	for _, doc := range []string{
+		"//goray:timeout 30s",
+		"//goray:retry max=5 backoff=linear base=100ms",
+		"//goray:retryable ErrBusy *BusyError",
+		"//goray:idempotent",
+		"//goray:resources cpu=2 gpu=0.5 memory=4Gi ssd=1",
+		"//goray:codec json",
+		"//goray:put-threshold 1Mi",
+	} {
+		require.NoError(t, ParseDirectives(doc)[0].Validate(), doc)
+	}
+	for doc, msg := range map[string]string{
+		"//goray:timeuot 30s":         "unknown directive //goray:timeuot",
+		"//goray:timeout -1s":         `invalid timeout "-1s"`,
+		"//goray:retry backoff=fast":  "invalid backoff=fast",
+		"//goray:retry delay=1s":      "unknown arg delay",
+		"//goray:idempotent true":     "expect no args",
+		"//goray:resources memory=4X": "memory: invalid memory",
+		"//goray:codec xml":           `invalid codec "xml"`,
+		"//goray:put-threshold 0":     `invalid size "0"`,
+	} {
+		require.ErrorContains(t, ParseDirectives(doc)[0].Validate(), msg, doc)
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	for s, want := range map[string]int64{"1024": 1024, "4Gi": 4 << 30, "1.5Ki": 1536, "2G": 2e9, "100M": 1e8} {
+		got, err := ParseMemory(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"", "Gi", "-1Mi", "4GB"} {
+		_, err := ParseMemory(s)
+		require.Error(t, err, s)
+	}
+}
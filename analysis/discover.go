@@ -0,0 +1,181 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/gg/gslice"
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadMode is the packages.LoadMode needed by the analysis, see Load.
+const LoadMode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedModule
+
+const (
+	TasksMarker  = "// raytasks"  // the doc comment of the struct whose methods are the tasks
+	ActorsMarker = "// rayactors" // the doc comment of the struct whose methods are the actor factories
+	ActorMarker  = "// rayactor"  // the doc comment of the actor handle structs
+
+	// GeneratedFilePrefix is the file name prefix of the goraygen outputs, e.g. "ray_workload_wrappers_test.go".
+	GeneratedFilePrefix = "ray_workload_wrappers"
+)
+
+// Load loads the packages matching the pattern, with LoadMode added to cfg.Mode.
+// It returns one package per package path: with cfg.Tests, the in-package test variant
+// (which includes the _test.go files) instead of the package itself.
+func Load(cfg *packages.Config, pattern string) ([]*packages.Package, error) {
+	c := *cfg
+	c.Mode |= LoadMode
+	pkgs, err := packages.Load(&c, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", pattern, err)
+	}
+	return preferTestVariants(pkgs), nil
+}
+
+// preferTestVariants returns one package per package path: the in-package test variant
+// (which includes the _test.go files) if loaded with tests, otherwise the package itself.
+// External test packages (package xxx_test) and test main packages are dropped.
+func preferTestVariants(pkgs []*packages.Package) []*packages.Package {
+	testVariants := make(map[string]*packages.Package)
+	for _, pkg := range pkgs {
+		if strings.HasSuffix(pkg.ID, ".test]") && !strings.HasSuffix(pkg.Name, "_test") {
+			testVariants[pkg.PkgPath] = pkg
+		}
+	}
+	var result []*packages.Package
+	for _, pkg := range pkgs {
+		if pkg.ID != pkg.PkgPath || strings.HasSuffix(pkg.ID, ".test") {
+			continue // test variant, external test or test main
+		}
+		if v, ok := testVariants[pkg.PkgPath]; ok {
+			pkg = v
+		}
+		result = append(result, pkg)
+	}
+	if len(result) == 0 {
+		return pkgs
+	}
+	return result
+}
+
+// Matchers select the target structs of DiscoverTargets, a nil matcher is the CommentMatcher of the default marker.
+type Matchers struct {
+	Tasks  StructMatcher // default TasksMarker
+	Actors StructMatcher // default ActorsMarker
+	Actor  StructMatcher // default ActorMarker
+}
+
+// Targets are the target structs of a package.
+type Targets struct {
+	Tasks        *ast.TypeSpec   // the tasks struct, nil if none
+	Actors       *ast.TypeSpec   // the actor factories struct, nil if none
+	ActorHandles []*ast.TypeSpec // the actor handle structs, in source order
+}
+
+// DiscoverTargets finds the target structs of the package. Like FindStruct, only the first tasks/actors struct counts.
+func DiscoverTargets(pkg *packages.Package, m Matchers) Targets {
+	matcher := func(m StructMatcher, marker string) StructMatcher {
+		if m != nil {
+			return m
+		}
+		return CommentMatcher(marker)
+	}
+	return Targets{
+		Tasks:        FindStruct(pkg, matcher(m.Tasks, TasksMarker)),
+		Actors:       FindStruct(pkg, matcher(m.Actors, ActorsMarker)),
+		ActorHandles: FindStructs(pkg, matcher(m.Actor, ActorMarker)),
+	}
+}
+
+// FindStruct finds the first struct type in the package that matches the matcher.
+// Generated files are skipped, see IsGeneratedFile.
+func FindStruct(pkg *packages.Package, matcher StructMatcher) *ast.TypeSpec {
+	if structs := FindStructs(pkg, matcher); len(structs) > 0 {
+		return structs[0]
+	}
+	return nil
+}
+
+// FindStructs finds all struct types in the package that match the matcher, in source order.
+func FindStructs(pkg *packages.Package, matcher StructMatcher) []*ast.TypeSpec {
+	var targetStructs []*ast.TypeSpec
+	for _, file := range SourceFiles(pkg) {
+		ast.Inspect(file, func(n ast.Node) bool {
+			genDecl, ok := n.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				return true
+			}
+
+			for _, spec := range genDecl.Specs {
+				if typeSpec, ok := spec.(*ast.TypeSpec); ok {
+					if _, ok := typeSpec.Type.(*ast.StructType); ok && matcher.MatchStruct(pkg, genDecl, typeSpec) {
+						targetStructs = append(targetStructs, typeSpec)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return targetStructs
+}
+
+// IsGeneratedFile reports whether the file is generated code: the goraygen output files
+// (including the variants for tag sets and tests) or any file with the standard
+// "// Code generated ... DO NOT EDIT." header. Such files are not scanned for tasks/actors,
+// so re-running the tool never picks up its own output as input.
+func IsGeneratedFile(pkg *packages.Package, file *ast.File) bool {
+	name := filepath.Base(pkg.Fset.Position(file.Pos()).Filename)
+	return strings.HasPrefix(name, GeneratedFilePrefix) || ast.IsGenerated(file)
+}
+
+// IsGeneratedPos reports whether the position is in a generated file of the package, see IsGeneratedFile.
+func IsGeneratedPos(pkg *packages.Package, pos token.Pos) bool {
+	return gslice.Any(pkg.Syntax, func(file *ast.File) bool {
+		return file.Pos() <= pos && pos < file.End() && IsGeneratedFile(pkg, file)
+	})
+}
+
+// SourceFiles returns the syntax of the non-generated files in the package.
+func SourceFiles(pkg *packages.Package) []*ast.File {
+	files := gslice.Filter(pkg.Syntax, func(file *ast.File) bool {
+		return !IsGeneratedFile(pkg, file)
+	})
+	slices.SortStableFunc(files, func(a, b *ast.File) int { // whatever the order of the loader
+		return strings.Compare(pkg.Fset.Position(a.Pos()).Filename, pkg.Fset.Position(b.Pos()).Filename)
+	})
+	return files
+}
+
+// TypeDoc returns the doc comment lines of the type declared with the name in the package, empty if none.
+// The doc of a grouped declaration `type ( ... )` is attached to the spec instead of the decl.
+func TypeDoc(pkg *packages.Package, name string) string {
+	var comments []string
+	for _, file := range SourceFiles(pkg) {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.Name.Name != name {
+					continue
+				}
+				for _, doc := range []*ast.CommentGroup{genDecl.Doc, typeSpec.Doc} {
+					if doc == nil {
+						continue
+					}
+					for _, c := range doc.List {
+						comments = append(comments, c.Text)
+					}
+				}
+			}
+		}
+	}
+	return strings.Join(comments, "\n")
+}
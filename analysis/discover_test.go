@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestPreferTestVariants(t *testing.T) {
+	pkgs := []*packages.Package{
+		{ID: "example.com/p", PkgPath: "example.com/p", Name: "p"},
+		{ID: "example.com/p [example.com/p.test]", PkgPath: "example.com/p", Name: "p"},
+		{ID: "example.com/p_test [example.com/p.test]", PkgPath: "example.com/p_test", Name: "p_test"},
+		{ID: "example.com/p.test", PkgPath: "example.com/p.test", Name: "main"},
+		{ID: "example.com/q", PkgPath: "example.com/q", Name: "q"},
+	}
+	result := preferTestVariants(pkgs)
+	require.Len(t, result, 2)
+	require.Equal(t, "example.com/p [example.com/p.test]", result[0].ID)
+	require.Equal(t, "example.com/q", result[1].ID)
+}
+
+func TestDiscoverySkipsGeneratedFiles(t *testing.T) {
+	sources := map[string]string{
+		"tasks": `package mypkg
+
+// raytasks
+type MyTasks struct{}
+
+func (t *MyTasks) Foo() {}
+`,
+		"tasks_string": `// Code generated by stringer. DO NOT EDIT.
+
+package mypkg
+
+func (t *MyTasks) String() string { return "" }
+`,
+		"ray_workload_wrappers": `package mypkg
+
+// raytasks
+type Old struct{}
+`,
+	}
+	pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+
+	s := FindStruct(pkg, CommentMatcher(TasksMarker))
+	require.NotNil(t, s)
+	require.Equal(t, "MyTasks", s.Name.Name)
+	require.Len(t, SourceFiles(pkg), 1)
+
+	methods := FindMethods(pkg, "MyTasks", NewImportStore())
+	require.Len(t, methods, 1)
+	require.Equal(t, "Foo", methods[0].Name)
+}
+
+func TestDiscoverTargets(t *testing.T) {
+	code := `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// rayactor
+type Counter struct{}
+
+// rayactor
+type Store struct{}
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+
+	targets := DiscoverTargets(pkg, Matchers{})
+	require.Equal(t, "Tasks", targets.Tasks.Name.Name)
+	require.Nil(t, targets.Actors)
+	require.Len(t, targets.ActorHandles, 2)
+
+	targets = DiscoverTargets(pkg, Matchers{Actors: NameMatcher("Store"), Actor: NameMatcher("Tasks")})
+	require.Equal(t, "Store", targets.Actors.Name.Name)
+	require.Equal(t, "Tasks", targets.ActorHandles[0].Name.Name)
+}
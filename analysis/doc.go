@@ -0,0 +1,20 @@
+/*
+Package analysis extracts the Ray tasks and actors from a Go package, as goraygen does before generating the wrappers.
+It's for the other tools working on the same annotated structs, e.g. linters, doc generators or build systems:
+
+	pkgs, err := analysis.Load(&packages.Config{Dir: dir}, "./")
+	if err != nil {
+		return err
+	}
+	pkg := pkgs[0]
+	targets := analysis.DiscoverTargets(pkg, analysis.Matchers{})
+	if targets.Tasks != nil {
+		for _, m := range analysis.FindMethods(pkg, targets.Tasks.Name.Name, analysis.NewImportStore()) {
+			fmt.Println(m.Name, m.CanonicalSignature())
+		}
+	}
+
+The types of the methods are rendered as Go code of the scanned package (FindMethods) or of another package (Methods),
+the packages to import are added to the ImportStore. Generated files, including the goraygen outputs, are skipped.
+*/
+package analysis
@@ -0,0 +1,182 @@
+package analysis
+
+import (
+	"fmt"
+	"go/types"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+/*
+IdentifiableTypeName encodes a type as an identifier, e.g. for the type parameters of the wrappers, from its structure:
+
+	int64                  -> int64
+	mypkg.Point            -> mypkg_Point
+	[]*pkg.Point           -> slice_ptr_pkg_Point
+	map[string][4]int      -> map_string_to_arr4_int
+	lib.Pair[K, V]         -> lib_Pair_of_K_and_V
+	map[Pair[int, int]]T   -> map_Pair_of_int_and_int_end_to_T
+	func(int) string       -> func_int_returns_string
+
+The encoding is injective: the tokens are separated by "_", the underscores of the identifiers are escaped as "_0",
+and an identifier spelled like a keyword, e.g. a type named ptr, is marked with a "_1" suffix. The trailing end
+keywords are left out. Two types get the same name only if they're rendered identically, e.g. a type and its mapping,
+or two struct types differing only in their tags.
+*/
+
+// identifiableKeywords are the tokens of the constructed types in the names of IdentifiableTypeName, with arrN.
+var identifiableKeywords = map[string]bool{
+	"ptr": true, "slice": true, "map": true, "to": true, "chan": true, "sendChan": true, "recvChan": true,
+	"func": true, "variadic": true, "returns": true, "of": true, "and": true, "end": true,
+	"struct": true, "embed": true, "iface": true,
+}
+
+var arrayKeyword = regexp.MustCompile(`^arr\d+$`)
+
+// IdentifiableTypeName returns the type as an identifier, rendered like TypeName in the code of the package
+// currentPkgPath: qualified by the names of the packages in importStore, as its mapping if it's mapped.
+func IdentifiableTypeName(typ types.Type, currentPkgPath string, importStore *ImportStore) string {
+	if importStore != nil {
+		if m, ok := importStore.Mapping(typ); ok {
+			return identifiableRendered(m.Render(importStore))
+		}
+	}
+	name := identifiableType(typ, currentPkgPath, importStore)
+	for strings.HasSuffix(name, "_end") {
+		name = strings.TrimSuffix(name, "_end")
+	}
+	return name
+}
+
+// IdentifiableIdent returns the identifier as a token of the names of IdentifiableTypeName, e.g. for a type generated
+// by name: its underscores escaped, and marked if it's spelled like a keyword.
+func IdentifiableIdent(name string) string {
+	name = strings.ReplaceAll(name, "_", "_0")
+	if identifiableKeywords[name] || arrayKeyword.MatchString(name) {
+		name += "_1"
+	}
+	return name
+}
+
+// identifiableRendered encodes the rendered name of a mapped type, predeclared or qualified, e.g. "ids.UserID".
+func identifiableRendered(name string) string {
+	if pkg, typeName, ok := strings.Cut(name, "."); ok {
+		return IdentifiableIdent(pkg) + "_" + IdentifiableIdent(typeName)
+	}
+	return IdentifiableIdent(name)
+}
+
+// identifiableType is IdentifiableTypeName without the type mappings, keeping the end keywords.
+func identifiableType(typ types.Type, currentPkgPath string, importStore *ImportStore) string {
+	encode := func(typ types.Type) string {
+		return identifiableType(typ, currentPkgPath, importStore)
+	}
+	list := func(typs []types.Type) string {
+		names := make([]string, len(typs))
+		for i, t := range typs {
+			names[i] = encode(t)
+		}
+		return strings.Join(names, "_and_")
+	}
+	qualified := func(obj *types.TypeName, args *types.TypeList) string {
+		name := IdentifiableIdent(obj.Name())
+		if obj.Pkg() != nil && obj.Pkg().Path() != currentPkgPath {
+			name = IdentifiableIdent(importStore.AddPackage(obj.Pkg())) + "_" + name
+		}
+		if args.Len() > 0 {
+			name += "_of_" + list(slices.Collect(args.Types())) + "_end"
+		}
+		return name
+	}
+	switch t := typ.(type) {
+	case *types.Alias:
+		if importStore.resolveAliases && t.Obj().Pkg() != nil {
+			return encode(types.Unalias(t))
+		}
+		return qualified(t.Obj(), t.TypeArgs())
+	case *types.Named:
+		return qualified(t.Obj(), t.TypeArgs())
+	case *types.TypeParam:
+		return IdentifiableIdent(t.Obj().Name())
+	case *types.Basic:
+		if t.Kind() == types.UnsafePointer {
+			return IdentifiableIdent(importStore.AddImport("unsafe")) + "_Pointer"
+		}
+		return IdentifiableIdent(t.Name())
+	case *types.Pointer:
+		return "ptr_" + encode(t.Elem())
+	case *types.Slice:
+		return "slice_" + encode(t.Elem())
+	case *types.Array:
+		return fmt.Sprintf("arr%d_%s", t.Len(), encode(t.Elem()))
+	case *types.Map:
+		return "map_" + encode(t.Key()) + "_to_" + encode(t.Elem())
+	case *types.Chan:
+		switch t.Dir() {
+		case types.SendOnly:
+			return "sendChan_" + encode(t.Elem())
+		case types.RecvOnly:
+			return "recvChan_" + encode(t.Elem())
+		}
+		return "chan_" + encode(t.Elem())
+	case *types.Signature:
+		params := make([]string, t.Params().Len())
+		for i := range params {
+			param := t.Params().At(i).Type()
+			if s, ok := param.(*types.Slice); ok && t.Variadic() && i == len(params)-1 {
+				params[i] = "variadic_" + encode(s.Elem())
+			} else {
+				params[i] = encode(param)
+			}
+		}
+		name := "func"
+		if len(params) > 0 {
+			name += "_" + strings.Join(params, "_and_")
+		}
+		if t.Results().Len() > 0 {
+			results := make([]types.Type, t.Results().Len())
+			for i := range results {
+				results[i] = t.Results().At(i).Type()
+			}
+			name += "_returns_" + list(results)
+		}
+		return name + "_end"
+	case *types.Struct:
+		fields := make([]string, t.NumFields())
+		for i := range fields {
+			field := t.Field(i)
+			if field.Embedded() {
+				fields[i] = "embed_" + encode(field.Type())
+			} else {
+				fields[i] = IdentifiableIdent(field.Name()) + "_" + encode(field.Type())
+			}
+		}
+		return "struct_" + strings.Join(append(fields, "end"), "_")
+	case *types.Interface:
+		if t.Empty() {
+			return "any"
+		}
+		var elems []string
+		for i := range t.NumEmbeddeds() {
+			elems = append(elems, "embed_"+encode(t.EmbeddedType(i)))
+		}
+		for i := range t.NumExplicitMethods() {
+			m := t.ExplicitMethod(i)
+			elems = append(elems, IdentifiableIdent(m.Name())+"_"+encode(m.Type()))
+		}
+		return "iface_" + strings.Join(append(elems, "end"), "_")
+	}
+	return IdentifiableIdent(Identifier(typ.String()))
+}
+
+// Identifier returns s without the characters not allowed in identifiers, e.g. a package name from a directory name.
+func Identifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,221 @@
+package analysis
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/gg/gmap"
+	"github.com/bytedance/gg/gslice"
+)
+
+type ImportStore struct {
+	importPath2pkgName map[string]string // the pkgName may be renamed (import alias)
+	pkgName2importPath map[string]string
+	dotImports         map[string]bool   // the import paths imported with `. "path"`
+	blankImports       map[string]bool   // the import paths imported with `_ "path"`, for their side effects
+	names              map[string]string // import path -> name of the package clause, known from the type information
+	aliases            map[string]string // import path -> preferred name, see SetAliases
+	reserved           map[string]bool   // the names of the generated identifiers, see Reserve
+	typeMappings       TypeMappings
+	resolveAliases     bool // render the type aliases as the types they alias, see ResolveAliases
+}
+
+func NewImportStore() *ImportStore {
+	return &ImportStore{
+		importPath2pkgName: make(map[string]string),
+		pkgName2importPath: make(map[string]string),
+		dotImports:         make(map[string]bool),
+		blankImports:       make(map[string]bool),
+		names:              make(map[string]string),
+		reserved:           make(map[string]bool),
+	}
+}
+
+// Clone returns a copy of the store, e.g. to add the imports of a single file.
+func (store *ImportStore) Clone() *ImportStore {
+	return &ImportStore{
+		importPath2pkgName: maps.Clone(store.importPath2pkgName),
+		pkgName2importPath: maps.Clone(store.pkgName2importPath),
+		dotImports:         maps.Clone(store.dotImports),
+		blankImports:       maps.Clone(store.blankImports),
+		names:              maps.Clone(store.names),
+		aliases:            store.aliases,
+		reserved:           maps.Clone(store.reserved),
+		typeMappings:       store.typeMappings,
+		resolveAliases:     store.resolveAliases,
+	}
+}
+
+// AddImport adds an import path to the store and returns the package name to be used in code.
+func (store *ImportStore) AddImport(importPath string) string {
+	if pkgName, ok := store.importPath2pkgName[importPath]; ok {
+		return pkgName
+	}
+	pkgName := store.packageName(importPath)
+	if alias, ok := store.aliases[importPath]; ok {
+		pkgName = alias
+	}
+	name := pkgName
+	for i := 2; store.taken(name); i++ { // name conflict
+		name = fmt.Sprintf("%s%d", pkgName, i)
+	}
+	store.pkgName2importPath[name] = importPath
+	store.importPath2pkgName[importPath] = name
+	return store.importPath2pkgName[importPath]
+}
+
+// taken reports whether an import can't be named name: another import is, or it would shadow a keyword,
+// a predeclared identifier or a reserved name.
+func (store *ImportStore) taken(name string) bool {
+	_, ok := store.pkgName2importPath[name]
+	return ok || store.reserved[name] || token.IsKeyword(name) || types.Universe.Lookup(name) != nil
+}
+
+// Reserve reserves the names of the identifiers of the generated code, e.g. the params of the methods
+// and the locals of the templates, so no import shadows them. The import already named after one is renamed,
+// so it must be called before rendering code with the store.
+func (store *ImportStore) Reserve(names ...string) {
+	for _, name := range names {
+		if store.reserved[name] {
+			continue
+		}
+		store.reserved[name] = true
+		if path, ok := store.pkgName2importPath[name]; ok {
+			delete(store.pkgName2importPath, name)
+			delete(store.importPath2pkgName, path)
+			store.AddImport(path)
+		}
+	}
+}
+
+// SetAliases sets the preferred names of the imports by import path, e.g. "goray" for "github.com/ray4go/go-ray/ray",
+// used instead of the package names. A preferred name taken by another import is still suffixed with a number.
+func (store *ImportStore) SetAliases(aliases map[string]string) {
+	store.aliases = aliases
+}
+
+// AddPackage adds the import of the package like AddImport, named after its package clause,
+// e.g. "bar" for "github.com/foo/go-bar" instead of the last element of the path.
+func (store *ImportStore) AddPackage(pkg *types.Package) string {
+	if _, ok := store.names[pkg.Path()]; !ok && pkg.Name() != "" {
+		store.names[pkg.Path()] = pkg.Name()
+	}
+	return store.AddImport(pkg.Path())
+}
+
+// packageName returns the name of the package at the import path: the name of its package clause if known,
+// see AddPackage, otherwise guessed from the path.
+func (store *ImportStore) packageName(importPath string) string {
+	if name, ok := store.names[importPath]; ok {
+		return name
+	}
+	return getPackageName(importPath)
+}
+
+// AddDotImport adds an import path imported with `. "path"`, its identifiers being used unqualified.
+func (store *ImportStore) AddDotImport(importPath string) {
+	store.dotImports[importPath] = true
+}
+
+// AddBlankImport adds an import path imported with `_ "path"` for the side effects of its init functions,
+// e.g. registering a driver or a codec. It's left out if the path is imported otherwise too.
+func (store *ImportStore) AddBlankImport(importPath string) {
+	store.blankImports[importPath] = true
+}
+
+// MapTypes makes TypeName render the types of the mappings as their mapped types in the code importing the store.
+func (store *ImportStore) MapTypes(mappings TypeMappings) {
+	store.typeMappings = mappings
+}
+
+// ResolveAliases makes TypeName render the type aliases as the types they alias, e.g. time.Duration
+// for `type Timeout = time.Duration`, instead of the aliases, qualified by their packages.
+func (store *ImportStore) ResolveAliases(resolve bool) {
+	store.resolveAliases = resolve
+}
+
+// Mapping returns the mapping of the type by the store, false if it isn't mapped.
+func (store *ImportStore) Mapping(typ types.Type) (TypeMapping, bool) {
+	return store.typeMappings.Lookup(typ)
+}
+
+// DumpImportExprs returns the import specs of the store sorted by path, e.g. `"fmt"` or `fmt2 "example.com/fmt"`:
+// with an alias only if the package name differs from the one of the path.
+func (store *ImportStore) DumpImportExprs() []string {
+	return gslice.Map(store.sortedPaths(), store.importSpec)
+}
+
+// ImportBlock renders the import declaration of the store, with the specs grouped like goimports does:
+// the standard library, the third-party packages, then the packages of the module modulePath (if not empty),
+// separated by blank lines. It's empty if there is no import.
+func (store *ImportStore) ImportBlock(modulePath string) string {
+	var groups [3][]string
+	for _, path := range store.sortedPaths() {
+		group := 1
+		if first, _, _ := strings.Cut(path, "/"); !strings.Contains(first, ".") {
+			group = 0
+		} else if modulePath != "" && (path == modulePath || strings.HasPrefix(path, modulePath+"/")) {
+			group = 2
+		}
+		groups[group] = append(groups[group], "\t"+store.importSpec(path)+"\n")
+	}
+	var blocks []string
+	for _, specs := range groups {
+		if len(specs) > 0 {
+			blocks = append(blocks, strings.Join(specs, ""))
+		}
+	}
+	if len(blocks) == 0 {
+		return ""
+	}
+	return "import (\n" + strings.Join(blocks, "\n") + ")\n"
+}
+
+// sortedPaths returns the import paths of the store, the dot and blank imports included, sorted.
+func (store *ImportStore) sortedPaths() []string {
+	paths := append(gmap.Keys(store.importPath2pkgName), gmap.Keys(store.dotImports)...)
+	paths = append(paths, gmap.Keys(store.blankImports)...)
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// importSpec returns the import spec of the path: `"path"`, `alias "path"`, `. "path"` or `_ "path"`,
+// with an alias if the package isn't named after the last element of the path, like goimports does.
+func (store *ImportStore) importSpec(path string) string {
+	name, named := store.importPath2pkgName[path]
+	switch {
+	case store.dotImports[path]:
+		return fmt.Sprintf(`. %q`, path)
+	case !named:
+		return fmt.Sprintf(`_ %q`, path)
+	case name != getPackageName(path):
+		return fmt.Sprintf(`%s %q`, name, path)
+	}
+	return fmt.Sprintf("%q", path)
+}
+
+// getPackageName guesses the name of the package at the import path when it's unknown, like goimports does:
+// the last element of the path, without a "go-" prefix nor a ".v3" suffix, e.g. "bar" for "github.com/foo/go-bar.v3",
+// and the one before a major version suffix, e.g. "lib" for "github.com/foo/lib/v2".
+func getPackageName(importPath string) string {
+	dir, name := path.Split(importPath)
+	if dir != "" && isMajorVersion(name) {
+		name = path.Base(dir)
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// isMajorVersion reports whether the element of an import path is a major version suffix like "v2".
+func isMajorVersion(elem string) bool {
+	digits, ok := strings.CutPrefix(elem, "v")
+	return ok && digits != "" && strings.Trim(digits, "0123456789") == ""
+}
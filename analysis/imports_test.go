@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportBlock(t *testing.T) {
+	store := NewImportStore()
+	require.Empty(t, store.ImportBlock("example.com/mymod"))
+	for _, path := range []string{"example.com/mymod/types", "text/template", "github.com/ray4go/go-ray/ray", "fmt", "html/template"} {
+		store.AddImport(path)
+	}
+	store.AddDotImport("github.com/ray4go/go-ray/ray/generic")
+
+	require.Equal(t, `import (
+	"fmt"
+	template2 "html/template"
+	"text/template"
+
+	"github.com/ray4go/go-ray/ray"
+	. "github.com/ray4go/go-ray/ray/generic"
+
+	"example.com/mymod/types"
+)
+`, store.ImportBlock("example.com/mymod"))
+	// without the module path, its packages are third-party ones
+	require.Contains(t, store.ImportBlock(""), "\n\t\"example.com/mymod/types\"\n\t\"github.com/ray4go/go-ray/ray\"\n")
+
+	clone := store.Clone()
+	clone.AddImport("strings")
+	require.NotContains(t, store.DumpImportExprs(), `"strings"`)
+}
+
+func TestPackageNames(t *testing.T) {
+	store := NewImportStore()
+	bar := types.NewPackage("github.com/foo/go-bar", "bar")
+	thing := types.NewNamed(types.NewTypeName(token.NoPos, bar, "Thing", nil), types.NewStruct(nil, nil), nil)
+	require.Equal(t, "[]bar.Thing", TypeName(types.NewSlice(thing), "example.com/mypkg", store))
+	// a custom package clause
+	custom := types.NewPackage("example.com/lib", "libimpl")
+	require.Equal(t, "libimpl", store.AddPackage(custom))
+	// the name of the package clause is kept for the later imports of the path
+	require.Equal(t, "bar", store.AddImport("github.com/foo/go-bar"))
+	// guessed from the path if unknown
+	require.Equal(t, "yaml", store.AddImport("gopkg.in/yaml.v3"))
+
+	// an alias only if the package name isn't the one guessed from the path
+	require.Equal(t, []string{`libimpl "example.com/lib"`, `"github.com/foo/go-bar"`, `"gopkg.in/yaml.v3"`}, store.DumpImportExprs())
+}
+
+func TestMajorVersionSuffixes(t *testing.T) {
+	store := NewImportStore()
+	require.Equal(t, "lib", store.AddImport("github.com/foo/lib/v2"))
+	require.Equal(t, "rand", store.AddImport("math/rand"))
+	require.Equal(t, "rand2", store.AddImport("math/rand/v2"))
+	// the packages named after their version, e.g. k8s.io/api/core/v1, are aliased explicitly
+	require.Equal(t, "v1", store.AddPackage(types.NewPackage("k8s.io/api/core/v1", "v1")))
+	require.Equal(t, []string{`"github.com/foo/lib/v2"`, `v1 "k8s.io/api/core/v1"`, `"math/rand"`, `rand2 "math/rand/v2"`}, store.DumpImportExprs())
+}
+
+func TestImportAliases(t *testing.T) {
+	store := NewImportStore()
+	store.SetAliases(map[string]string{"github.com/ray4go/go-ray/ray": "goray", "math/rand/v2": "rand"})
+	require.Equal(t, "goray", store.AddImport("github.com/ray4go/go-ray/ray"))
+	require.Equal(t, "rand", store.AddImport("math/rand/v2"))
+	// the conflicts with a preferred name are still suffixed
+	require.Equal(t, "rand2", store.AddImport("math/rand"))
+	require.Equal(t, "goray2", store.AddImport("example.com/goray"))
+	require.Equal(t, []string{`goray2 "example.com/goray"`, `goray "github.com/ray4go/go-ray/ray"`, `rand2 "math/rand"`, `"math/rand/v2"`}, store.DumpImportExprs())
+}
+
+func TestReservedNames(t *testing.T) {
+	store := NewImportStore()
+	require.Equal(t, "ray", store.AddImport("github.com/ray4go/go-ray/ray"))
+	store.Reserve("bytes", "ray", "ctx")
+	// the imports don't shadow the reserved names, the keywords nor the predeclared identifiers
+	require.Equal(t, "bytes2", store.AddImport("bytes"))
+	require.Equal(t, "type2", store.AddImport("example.com/go-type"))
+	require.Equal(t, "error2", store.AddImport("example.com/error"))
+	// the import already named after a reserved name is renamed
+	require.Equal(t, "ray2", store.AddImport("github.com/ray4go/go-ray/ray"))
+	require.Contains(t, store.Clone().DumpImportExprs(), `bytes2 "bytes"`)
+}
+
+func TestBlankImports(t *testing.T) {
+	store := NewImportStore()
+	store.AddBlankImport("github.com/lib/pq")
+	store.AddBlankImport("image/png")
+	store.AddImport("image/png") // imported by name too, the blank import is redundant
+	store.AddImport("fmt")
+	require.Equal(t, `import (
+	"fmt"
+	"image/png"
+
+	_ "github.com/lib/pq"
+)
+`, store.ImportBlock(""))
+	require.NotContains(t, NewImportStore().ImportBlock(""), "_")
+}
+
+func TestImportable(t *testing.T) {
+	for _, c := range []struct {
+		path, from string
+		want       bool
+	}{
+		{"example.com/mod/ids", "example.com/other", true},
+		{"example.com/mod/internal/ids", "example.com/mod", true},
+		{"example.com/mod/internal/ids", "example.com/mod/client", true},
+		{"example.com/mod/internal/ids", "example.com/modx/client", false},
+		{"example.com/mod/internal", "example.com/other", false},
+		{"example.com/mod/internal/a/internal/b", "example.com/mod/client", false},
+		{"internal/poll", "os", true},
+		{"internal/poll", "example.com/mod", false},
+	} {
+		require.Equal(t, c.want, Importable(c.path, c.from), "%s from %s", c.path, c.from)
+	}
+}
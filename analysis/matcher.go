@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	regexpMarkerPrefix = "re:"
+	embedMarkerPrefix  = "embed:"
+)
+
+// StructMatcher decides whether a struct type declaration is a task/actor register struct.
+type StructMatcher interface {
+	// MatchStruct reports whether the struct spec (declared in decl of pkg) is a target.
+	MatchStruct(pkg *packages.Package, decl *ast.GenDecl, spec *ast.TypeSpec) bool
+	String() string
+}
+
+// CommentMatcher matches structs whose doc comment contains a line equal to the marker, e.g. "// raytasks".
+type CommentMatcher string
+
+func (m CommentMatcher) MatchStruct(_ *packages.Package, decl *ast.GenDecl, spec *ast.TypeSpec) bool {
+	return hasDocComment(decl, spec, func(text string) bool {
+		return text == string(m)
+	})
+}
+
+func (m CommentMatcher) String() string {
+	return fmt.Sprintf("'%s' comment", string(m))
+}
+
+// RegexpCommentMatcher matches structs whose doc comment contains a line matching the regexp.
+type RegexpCommentMatcher struct {
+	*regexp.Regexp
+}
+
+func (m RegexpCommentMatcher) MatchStruct(_ *packages.Package, decl *ast.GenDecl, spec *ast.TypeSpec) bool {
+	return hasDocComment(decl, spec, m.MatchString)
+}
+
+func (m RegexpCommentMatcher) String() string {
+	return fmt.Sprintf("comment matching /%s/", m.Regexp)
+}
+
+// NameMatcher matches the struct with the given name, regardless of its comments.
+type NameMatcher string
+
+func (m NameMatcher) MatchStruct(_ *packages.Package, _ *ast.GenDecl, spec *ast.TypeSpec) bool {
+	return spec.Name.Name == string(m)
+}
+
+func (m NameMatcher) String() string {
+	return fmt.Sprintf("name '%s'", string(m))
+}
+
+// EmbedMatcher matches structs embedding the marker type (directly or by pointer),
+// given as "$importPath.$typeName", e.g. "github.com/ray4go/go-ray/ray.TaskSet".
+// It's based on type information, so no magic comment is needed.
+type EmbedMatcher string
+
+func (m EmbedMatcher) MatchStruct(pkg *packages.Package, _ *ast.GenDecl, spec *ast.TypeSpec) bool {
+	obj := pkg.Types.Scope().Lookup(spec.Name.Name)
+	if obj == nil {
+		return false
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if !field.Embedded() {
+			continue
+		}
+		typ := field.Type()
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		named, ok := typ.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil {
+			continue
+		}
+		if named.Obj().Pkg().Path()+"."+named.Obj().Name() == string(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m EmbedMatcher) String() string {
+	return fmt.Sprintf("embedded '%s'", string(m))
+}
+
+// ParseMarker parses a marker: markers with "re:" prefix are regular expressions of the comment,
+// markers with "embed:" prefix are marker types to embed, others are literal comment text.
+func ParseMarker(marker string) (StructMatcher, error) {
+	if typeName, ok := strings.CutPrefix(marker, embedMarkerPrefix); ok {
+		if i := strings.LastIndex(typeName, "."); i <= 0 || i == len(typeName)-1 {
+			return nil, fmt.Errorf("invalid marker type %q, expect $importPath.$typeName", typeName)
+		}
+		return EmbedMatcher(typeName), nil
+	}
+	if expr, ok := strings.CutPrefix(marker, regexpMarkerPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid marker regexp %q: %w", expr, err)
+		}
+		return RegexpCommentMatcher{re}, nil
+	}
+	return CommentMatcher(strings.TrimSpace(marker)), nil
+}
+
+// hasDocComment reports whether any doc comment line of the type declaration satisfies match.
+// The doc of a grouped declaration `type ( ... )` is attached to the spec instead of the decl.
+func hasDocComment(decl *ast.GenDecl, spec *ast.TypeSpec, match func(text string) bool) bool {
+	for _, doc := range []*ast.CommentGroup{decl.Doc, spec.Doc} {
+		if doc == nil {
+			continue
+		}
+		for _, comment := range doc.List {
+			if match(strings.TrimSpace(comment.Text)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindStructWithMatchers(t *testing.T) {
+	code := `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type (
+	// ray:actors
+	Actors struct{}
+
+	Other struct{}
+)
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+
+	s := FindStruct(pkg, CommentMatcher(TasksMarker))
+	require.NotNil(t, s)
+	require.Equal(t, "Tasks", s.Name.Name)
+
+	require.Nil(t, FindStruct(pkg, CommentMatcher(ActorsMarker)))
+
+	m, err := ParseMarker(`re:^//\s*ray:actors$`)
+	require.NoError(t, err)
+	s = FindStruct(pkg, m)
+	require.NotNil(t, s)
+	require.Equal(t, "Actors", s.Name.Name)
+
+	s = FindStruct(pkg, NameMatcher("Other"))
+	require.NotNil(t, s)
+	require.Equal(t, "Other", s.Name.Name)
+
+	_, err = ParseMarker("re:(")
+	require.Error(t, err)
+}
+
+func TestFindStructWithEmbedMatcher(t *testing.T) {
+	code := `package mypkg
+
+import "sync"
+
+type TaskSet struct{}
+
+type Tasks struct {
+	TaskSet
+}
+
+type Actors struct {
+	*sync.Mutex
+}
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+
+	m, err := ParseMarker("embed:example.com/mypkg.TaskSet")
+	require.NoError(t, err)
+	s := FindStruct(pkg, m)
+	require.NotNil(t, s)
+	require.Equal(t, "Tasks", s.Name.Name)
+
+	s = FindStruct(pkg, EmbedMatcher("sync.Mutex"))
+	require.NotNil(t, s)
+	require.Equal(t, "Actors", s.Name.Name)
+
+	require.Nil(t, FindStruct(pkg, EmbedMatcher("sync.RWMutex")))
+
+	_, err = ParseMarker("embed:TaskSet")
+	require.Error(t, err)
+}
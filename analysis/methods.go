@@ -0,0 +1,329 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/gg/gslice"
+	"golang.org/x/tools/go/packages"
+)
+
+// Method represents an exported method of a struct.
+// for non-variadic method:
+// - ($ReceiverType) $Name ($Param[0].Name $Param[0].Type, , $Param[-1].Name $Param[-1].Type) ($Result[0].Type, , $Result[-1].Type)
+// for variadic method:
+// - ($ReceiverType) $Name ($Param[0].Name $Param[0].Type, , $Param[-1].Name ...$Param[-1].Type) ($Result[0].Type, , $Result[-1].Type)
+type Method struct {
+	ReceiverType string
+	Name         string
+	Params       []Param // for variadic, the last param.Type will be the slice element type (i.e. "int" for "...int")
+	Results      []Result
+	IsVariadic   bool
+	Doc          string
+}
+
+type Param struct {
+	Name string
+	Type string // in "$packageName.$typeName" or built-in type like "int", "string" or composite type like "[]int", "map[string]pkg.MyType"
+	// CanonicalType is the type with full package paths (e.g. "[]example.com/pkg.MyType"),
+	// independent of import aliases. For variadic param, it's the slice type.
+	CanonicalType string
+	GoType        types.Type `json:"-"` // the type checked type, the slice type for variadic param, nil for generated methods
+	// IdentifiableType is Type as an identifier, e.g. "slice_ptr_pkg_Point" for "[]*pkg.Point", see IdentifiableTypeName.
+	IdentifiableType string
+}
+
+type Result struct {
+	Name          string     // the name of a named result, empty if unnamed or blank
+	Type          string     // format same as Param.Type
+	CanonicalType string     // format same as Param.CanonicalType
+	GoType        types.Type `json:"-"` // same as Param.GoType
+	IsError       bool       // the last result is of the built-in error type, i.e. the error-last convention
+}
+
+func (m Method) String() string {
+	params := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		if m.IsVariadic && i == len(m.Params)-1 {
+			params[i] = fmt.Sprintf("%s ...%s", p.Name, p.Type)
+		} else {
+			params[i] = fmt.Sprintf("%s %s", p.Name, p.Type)
+		}
+	}
+	retruns := gslice.Map(m.Results, func(r Result) string {
+		return r.Type
+	})
+	return fmt.Sprintf("%s(%s) (%s)", m.Name, strings.Join(params, ", "), strings.Join(retruns, ", "))
+}
+
+// CanonicalSignature returns a deterministic serialization of the method signature:
+// name, param types, result types and the variadic flag, without param names or import aliases,
+// e.g. "Echo(string,...[]int)([]int)".
+func (m Method) CanonicalSignature() string {
+	params := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		params[i] = p.CanonicalType
+		if m.IsVariadic && i == len(m.Params)-1 {
+			params[i] = "..." + params[i]
+		}
+	}
+	results := gslice.Map(m.Results, func(r Result) string {
+		return r.CanonicalType
+	})
+	return fmt.Sprintf("%s(%s)(%s)", m.Name, strings.Join(params, ","), strings.Join(results, ","))
+}
+
+// ReturnsError reports whether the method follows the error-last convention, i.e. its last result is an error.
+func (m Method) ReturnsError() bool {
+	return len(m.Results) > 0 && m.Results[len(m.Results)-1].IsError
+}
+
+// TakesContext reports whether the first parameter of the method is a context.Context.
+func (m Method) TakesContext() bool {
+	return len(m.Params) > 0 && m.Params[0].CanonicalType == "context.Context"
+}
+
+// CanonicalTypes returns the canonical types of the params and results of the method.
+func (m Method) CanonicalTypes() []string {
+	types := gslice.Map(m.Params, func(p Param) string { return p.CanonicalType })
+	return append(types, gslice.Map(m.Results, func(r Result) string { return r.CanonicalType })...)
+}
+
+// HasPointerReceiver reports whether the method is declared with a pointer receiver.
+func (m Method) HasPointerReceiver() bool {
+	return strings.HasPrefix(m.ReceiverType, "*")
+}
+
+// FindMethods finds all exported methods of the given struct name in the package.
+// Methods declared in different files, with pointer or value receivers, are all included.
+func FindMethods(pkg *packages.Package, structName string, importStore *ImportStore) []Method {
+	return Methods(pkg, structName, pkg.Types.Path(), importStore)
+}
+
+// Methods is like FindMethods, but renders types as seen from the package outputPkgPath,
+// so types of pkg are qualified if the generated code lives in another package.
+// Methods declared in generated files are skipped, see IsGeneratedFile.
+func Methods(pkg *packages.Package, structName, outputPkgPath string, importStore *ImportStore) []Method {
+	var methods []Method
+
+	// Get the struct type
+	obj := pkg.Types.Scope().Lookup(structName)
+	if obj == nil {
+		return methods
+	}
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return methods
+	}
+
+	generated := make(map[string]bool)
+	for _, file := range pkg.Syntax {
+		if IsGeneratedFile(pkg, file) {
+			generated[pkg.Fset.Position(file.Pos()).Filename] = true
+		}
+	}
+
+	for _, method := range sourceOrder(pkg, named) {
+		if !method.Exported() || generated[pkg.Fset.Position(method.Pos()).Filename] {
+			continue
+		}
+
+		sig := method.Type().(*types.Signature)
+		m := Method{
+			Name: method.Name(),
+			Doc:  findFuncDoc(pkg, method.Pos()),
+		}
+		// fmt.Printf("method: %v Name: %v\n", method, method.Pkg())
+		// fmt.Printf("sig.Recv: %v \n", sig.Recv())
+		receiverType := sig.Recv().Type()
+
+		// To get just the "*MyTask" part:
+		// If it's a pointer, dereference it to get the named type
+		receiverTypeStr := ""
+		if ptr, ok := receiverType.(*types.Pointer); ok {
+			if named, ok := ptr.Elem().(*types.Named); ok {
+				receiverTypeStr = fmt.Sprintf("*%s", named.Obj().Name())
+			}
+		} else if named, ok := receiverType.(*types.Named); ok {
+			// If it's not a pointer, it's already the named type
+			receiverTypeStr = named.Obj().Name()
+		}
+		m.ReceiverType = receiverTypeStr
+
+		// Process parameters
+		params := sig.Params()
+		for j := 0; j < params.Len(); j++ {
+			param := params.At(j)
+
+			paramName := param.Name()
+			if paramName == "" {
+				paramName = fmt.Sprintf("arg%d", j)
+			}
+
+			//paramTypeName = types.TypeString(param.Type(), types.RelativeTo(pkg.Types))
+			typeName := TypeName(param.Type(), outputPkgPath, importStore)
+			identifiable := IdentifiableTypeName(param.Type(), outputPkgPath, importStore)
+			if j == params.Len()-1 && sig.Variadic() {
+				// If the last parameter is variadic, remove the [] prefix
+				typeName = strings.TrimPrefix(typeName, "[]")
+				identifiable = strings.TrimPrefix(identifiable, "slice_")
+			}
+			m.Params = append(m.Params, Param{
+				Name:             paramName,
+				Type:             typeName,
+				CanonicalType:    types.TypeString(param.Type(), nil),
+				GoType:           param.Type(),
+				IdentifiableType: identifiable,
+			})
+		}
+
+		// Check if the last parameter is variadic
+		m.IsVariadic = sig.Variadic()
+
+		// Process results
+		results := sig.Results()
+		for j := 0; j < results.Len(); j++ {
+			result := results.At(j)
+			r := Result{
+				Name:          result.Name(),
+				Type:          TypeName(result.Type(), outputPkgPath, importStore),
+				CanonicalType: types.TypeString(result.Type(), nil),
+				GoType:        result.Type(),
+				IsError:       j == results.Len()-1 && types.Identical(result.Type(), errorType),
+			}
+			if r.Name == "_" {
+				r.Name = ""
+			}
+			m.Results = append(m.Results, r)
+		}
+
+		methods = append(methods, m)
+	}
+
+	return methods
+}
+
+// ParamNames returns the names of the receivers, params and results of the funcs declared by the package, sorted,
+// to be reserved in the import stores (see ImportStore.Reserve) as the generated code declares them too.
+func ParamNames(pkg *packages.Package) []string {
+	var names []string
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			for _, fields := range []*ast.FieldList{fd.Recv, fd.Type.Params, fd.Type.Results} {
+				if fields == nil {
+					continue
+				}
+				for _, field := range fields.List {
+					for _, name := range field.Names {
+						if name.Name != "_" {
+							names = append(names, name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// sourceOrder returns the methods of the named type in source order, by file name then offset,
+// so the methods and the import aliases given by the store are the same from one run to the next.
+func sourceOrder(pkg *packages.Package, named *types.Named) []*types.Func {
+	funcs := make([]*types.Func, named.NumMethods())
+	for i := range funcs {
+		funcs[i] = named.Method(i)
+	}
+	slices.SortStableFunc(funcs, func(a, b *types.Func) int {
+		pa, pb := pkg.Fset.Position(a.Pos()), pkg.Fset.Position(b.Pos())
+		return cmp.Or(strings.Compare(pa.Filename, pb.Filename), cmp.Compare(pa.Offset, pb.Offset))
+	})
+	return funcs
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func findFuncDoc(pkg *packages.Package, pos token.Pos) string {
+	for _, file := range pkg.Syntax {
+		if file.Pos() <= pos && pos < file.End() {
+			var doc string
+			found := false
+			ast.Inspect(file, func(n ast.Node) bool {
+				if found {
+					return false
+				}
+				if fd, ok := n.(*ast.FuncDecl); ok {
+					if fd.Name.Pos() == pos {
+						if fd.Doc != nil {
+							var comments []string
+							for _, c := range fd.Doc.List {
+								comments = append(comments, c.Text)
+							}
+							doc = strings.Join(comments, "\n")
+						}
+						found = true
+						return false
+					}
+				}
+				return true
+			})
+			if found {
+				return doc
+			}
+		}
+	}
+	return ""
+}
+
+// StateField is a field of an actor struct making up its checkpointed state.
+type StateField struct {
+	Name string // field name, the type name for embedded fields
+	Type string // format same as Param.Type
+}
+
+// stateTag is the struct tag marking the fields of the checkpointed state of an actor, i.e. `goray:"state"`.
+const stateTag = "state"
+
+// FindStateFields finds the fields of the given struct name in the package making up the actor state:
+// the fields tagged `goray:"state"` (exported or not) if any, otherwise all exported fields.
+// It returns false if structName is not a struct type of the package.
+func FindStateFields(pkg *packages.Package, structName, outputPkgPath string, importStore *ImportStore) ([]StateField, bool) {
+	obj, ok := pkg.Types.Scope().Lookup(structName).(*types.TypeName)
+	if !ok {
+		return nil, false
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return nil, false
+	}
+	var tagged, exported []*types.Var
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if field.Name() == "_" {
+			continue
+		}
+		if reflect.StructTag(st.Tag(i)).Get("goray") == stateTag {
+			tagged = append(tagged, field)
+		}
+		if field.Exported() {
+			exported = append(exported, field)
+		}
+	}
+	if len(tagged) > 0 {
+		exported = tagged
+	}
+	return gslice.Map(exported, func(field *types.Var) StateField {
+		return StateField{Name: field.Name(), Type: TypeName(field.Type(), outputPkgPath, importStore)}
+	}), true
+}
@@ -1,10 +1,11 @@
-package main
+package analysis
 
 import (
 	"fmt"
 	"testing"
 
 	"github.com/bytedance/gg/gmap"
+	"github.com/bytedance/gg/gslice"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/tools/go/packages"
 )
@@ -48,7 +49,7 @@ func makePkgFromSource(t *testing.T, sources map[string]string, pkgPath string)
 	return pkg
 }
 
-var getTypeNameTestCases = []struct {
+var typeNameTestCases = []struct {
 	code           string
 	expectTypeName string
 }{
@@ -71,11 +72,11 @@ var T map[MyDuration][]*bytes.Buffer`, "map[MyDuration][]*bytes.Buffer",
 	},
 }
 
-func TestGetTypeName(t *testing.T) {
+func TestTypeName(t *testing.T) {
 	assert := require.New(t)
 
 	pkgPath := "example.com/mypkg"
-	for _, tc := range getTypeNameTestCases {
+	for _, tc := range typeNameTestCases {
 		code := "package mypkg\n" + tc.code
 		pkg := makePkgFromSource(t, map[string]string{"foo": code}, pkgPath)
 		obj := pkg.Types.Scope().Lookup("T")
@@ -83,8 +84,8 @@ func TestGetTypeName(t *testing.T) {
 		typ := obj.Type()
 
 		importStore := NewImportStore()
-		result := getTypeName(typ, pkgPath, importStore)
-		assert.Equal(tc.expectTypeName, result, "getTypeName code:\n%s", tc.code)
+		result := TypeName(typ, pkgPath, importStore)
+		assert.Equal(tc.expectTypeName, result, "TypeName code:\n%s", tc.code)
 	}
 }
 
@@ -122,3 +123,61 @@ func (t *MyTasks) Bar() {}
 	require.Equal(t, "Bar", bar.Name)
 	require.Equal(t, "// Bar does something else.", bar.Doc)
 }
+
+func TestMethodsForOtherOutputPackage(t *testing.T) {
+	code := `package mypkg
+
+type Input struct{}
+
+// raytasks
+type MyTasks struct{}
+
+func (t *MyTasks) Foo(in Input, n int) *Input { return nil }
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+	importStore := NewImportStore()
+	methods := Methods(pkg, "MyTasks", "example.com/client", importStore)
+
+	require.Len(t, methods, 1)
+	require.Equal(t, "Foo(in mypkg.Input, n int) (*mypkg.Input)", methods[0].String())
+	require.Equal(t, []string{`"example.com/mypkg"`}, importStore.DumpImportExprs())
+}
+
+func TestFindMethodsAcrossFilesWithMixedReceivers(t *testing.T) {
+	sources := map[string]string{
+		"tasks": `package mypkg
+
+// raytasks
+type MyTasks struct{}
+
+func (t *MyTasks) Foo() {}
+`,
+		"more_tasks": `package mypkg
+
+func (t MyTasks) Bar() {}
+`,
+	}
+	pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+	methods := FindMethods(pkg, "MyTasks", NewImportStore())
+	require.Len(t, methods, 2)
+
+	receivers := gslice.ToMap(methods, func(m Method) (string, string) { return m.Name, m.ReceiverType })
+	require.Equal(t, map[string]string{"Foo": "*MyTasks", "Bar": "MyTasks"}, receivers)
+
+}
+
+func TestFindMethodsErrorLast(t *testing.T) {
+	code := `package mypkg
+
+// raytasks
+type MyTasks struct{}
+
+func (MyTasks) Open(name string) (int, error) { return 0, nil }
+
+func (MyTasks) Check() (error, int) { return nil, 0 }
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+	methods := FindMethods(pkg, "MyTasks", NewImportStore())
+	returnsError := gslice.ToMap(methods, func(m Method) (string, bool) { return m.Name, m.ReturnsError() })
+	require.Equal(t, map[string]bool{"Open": true, "Check": false}, returnsError)
+}
@@ -0,0 +1,233 @@
+package analysis
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+
+	"github.com/bytedance/gg/gmap"
+	"github.com/bytedance/gg/gslice"
+	"golang.org/x/tools/go/packages"
+)
+
+// ModelVersion is the version of the Model schema, bumped on incompatible changes.
+const ModelVersion = 1
+
+// The kinds of the structs of a Model.
+const (
+	KindTasks       = "tasks"        // the tasks struct, its methods are the tasks
+	KindActors      = "actors"       // the actors struct, its methods are the actor factories
+	KindActor       = "actor"        // an actor type returned by a factory, its methods are the actor methods
+	KindActorHandle = "actor-handle" // a struct with the ActorMarker comment
+)
+
+// Model is the discovered model of a package: its target structs with their methods, independent of the generation.
+// It's serialized as JSON by `goraygen analyze`, for caching and external tooling.
+type Model struct {
+	Version int           `json:"version"` // ModelVersion
+	Package ModelPackage  `json:"package"`
+	Structs []ModelStruct `json:"structs"` // the tasks, actors, actor and actor-handle structs, in this order
+}
+
+// ModelPackage is the scanned package.
+type ModelPackage struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Dir    string `json:"dir,omitempty"`
+	Module string `json:"module,omitempty"` // the module path
+}
+
+// ModelStruct is a target struct.
+type ModelStruct struct {
+	Name    string        `json:"name"`
+	Kind    string        `json:"kind"`          // KindTasks, KindActors, KindActor or KindActorHandle
+	Pos     string        `json:"pos,omitempty"` // "file.go:line:column", relative to the package dir
+	Doc     string        `json:"doc,omitempty"`
+	Methods []ModelMethod `json:"methods"`
+}
+
+// ModelMethod is an exported method of a target struct, see Method.
+type ModelMethod struct {
+	Name       string      `json:"name"`
+	Receiver   string      `json:"receiver"` // e.g. "*MyTasks"
+	Pos        string      `json:"pos,omitempty"`
+	Doc        string      `json:"doc,omitempty"`
+	Directives []Directive `json:"directives,omitempty"`
+	Variadic   bool        `json:"variadic,omitempty"`
+	Params     []ModelVar  `json:"params"`
+	Results    []ModelVar  `json:"results"`
+	Signature  string      `json:"signature"` // Method.CanonicalSignature
+}
+
+// ModelVar is a param or a result of a method.
+type ModelVar struct {
+	Name          string   `json:"name,omitempty"`    // empty for the unnamed or blank results
+	Type          string   `json:"type"`              // as written in the scanned package, see Param.Type
+	CanonicalType string   `json:"canonicalType"`     // see Param.CanonicalType
+	Imports       []string `json:"imports,omitempty"` // the import paths of the packages referenced by the type, sorted
+}
+
+// Analyze returns the model of the target structs of the package, see DiscoverTargets.
+// The actor types are the ones returned by the actor factories, see ActorTypes.
+func Analyze(pkg *packages.Package, m Matchers) Model {
+	model := Model{
+		Version: ModelVersion,
+		Package: ModelPackage{Name: pkg.Name, Path: pkg.PkgPath},
+		Structs: []ModelStruct{},
+	}
+	if len(pkg.GoFiles) > 0 {
+		model.Package.Dir = filepath.Dir(pkg.GoFiles[0])
+	}
+	if pkg.Module != nil {
+		model.Package.Module = pkg.Module.Path
+	}
+	importStore := NewImportStore()
+	addStruct := func(name, kind string) []Method {
+		methods := FindMethods(pkg, name, importStore)
+		s := ModelStruct{Name: name, Kind: kind, Doc: TypeDoc(pkg, name), Methods: []ModelMethod{}}
+		if obj := pkg.Types.Scope().Lookup(name); obj != nil {
+			s.Pos = model.position(pkg, obj.Pos())
+		}
+		for _, method := range methods {
+			s.Methods = append(s.Methods, model.method(pkg, name, method))
+		}
+		model.Structs = append(model.Structs, s)
+		return methods
+	}
+
+	targets := DiscoverTargets(pkg, m)
+	if targets.Tasks != nil {
+		addStruct(targets.Tasks.Name.Name, KindTasks)
+	}
+	var factories []Method
+	if targets.Actors != nil {
+		factories = addStruct(targets.Actors.Name.Name, KindActors)
+	}
+	for _, name := range ActorTypes(pkg, factories) {
+		addStruct(name, KindActor)
+	}
+	for _, s := range targets.ActorHandles {
+		addStruct(s.Name.Name, KindActorHandle)
+	}
+	return model
+}
+
+func (model Model) method(pkg *packages.Package, structName string, m Method) ModelMethod {
+	mm := ModelMethod{
+		Name:       m.Name,
+		Receiver:   m.ReceiverType,
+		Doc:        m.Doc,
+		Directives: ParseDirectives(m.Doc),
+		Variadic:   m.IsVariadic,
+		Params:     []ModelVar{},
+		Results:    []ModelVar{},
+		Signature:  m.CanonicalSignature(),
+	}
+	if obj, _, _ := types.LookupFieldOrMethod(pkg.Types.Scope().Lookup(structName).Type(), true, pkg.Types, m.Name); obj != nil {
+		mm.Pos = model.position(pkg, obj.Pos())
+	}
+	for _, p := range m.Params {
+		mm.Params = append(mm.Params, ModelVar{Name: p.Name, Type: p.Type, CanonicalType: p.CanonicalType, Imports: typeImports(p.GoType)})
+	}
+	for _, r := range m.Results {
+		mm.Results = append(mm.Results, ModelVar{Name: r.Name, Type: r.Type, CanonicalType: r.CanonicalType, Imports: typeImports(r.GoType)})
+	}
+	return mm
+}
+
+// position returns the position as "file.go:line:column", with the file relative to the package dir.
+func (model Model) position(pkg *packages.Package, pos token.Pos) string {
+	p := pkg.Fset.Position(pos)
+	if !p.IsValid() {
+		return ""
+	}
+	if rel, err := filepath.Rel(model.Package.Dir, p.Filename); err == nil && model.Package.Dir != "" {
+		p.Filename = filepath.ToSlash(rel)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// ActorTypes returns the names of the actor types returned by the factories, i.e. the named types of the package
+// (or the pointers to them) returned by the factories with one result, in order and without duplicates.
+func ActorTypes(pkg *packages.Package, factories []Method) []string {
+	var names []string
+	for _, factory := range factories {
+		if len(factory.Results) != 1 {
+			continue
+		}
+		typ := factory.Results[0].GoType
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		if named, ok := typ.(*types.Named); ok && named.Obj().Pkg() == pkg.Types && !gslice.Contains(names, named.Obj().Name()) {
+			names = append(names, named.Obj().Name())
+		}
+	}
+	return names
+}
+
+// typeImports returns the sorted import paths of the packages referenced by the type.
+func typeImports(typ types.Type) []string {
+	paths := make(map[string]bool)
+	seen := make(map[types.Type]bool)
+	var visit func(types.Type)
+	visit = func(t types.Type) {
+		if t == nil || seen[t] {
+			return
+		}
+		seen[t] = true
+		switch t := t.(type) {
+		case *types.Named:
+			if pkg := t.Obj().Pkg(); pkg != nil {
+				paths[pkg.Path()] = true
+			}
+			for i := 0; i < t.TypeArgs().Len(); i++ {
+				visit(t.TypeArgs().At(i))
+			}
+		case *types.Alias:
+			visit(types.Unalias(t))
+		case *types.Basic:
+			if t.Kind() == types.UnsafePointer {
+				paths["unsafe"] = true
+			}
+		case *types.Pointer:
+			visit(t.Elem())
+		case *types.Slice:
+			visit(t.Elem())
+		case *types.Array:
+			visit(t.Elem())
+		case *types.Map:
+			visit(t.Key())
+			visit(t.Elem())
+		case *types.Chan:
+			visit(t.Elem())
+		case *types.Signature:
+			visit(t.Params())
+			visit(t.Results())
+		case *types.Tuple:
+			for i := 0; i < t.Len(); i++ {
+				visit(t.At(i).Type())
+			}
+		case *types.Struct:
+			for i := 0; i < t.NumFields(); i++ {
+				visit(t.Field(i).Type())
+			}
+		case *types.Interface:
+			for i := 0; i < t.NumMethods(); i++ {
+				visit(t.Method(i).Type())
+			}
+			for i := 0; i < t.NumEmbeddeds(); i++ {
+				visit(t.EmbeddedType(i))
+			}
+		}
+	}
+	visit(typ)
+	if len(paths) == 0 {
+		return nil
+	}
+	result := gmap.Keys(paths)
+	sort.Strings(result)
+	return result
+}
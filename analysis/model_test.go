@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	code := `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+// Sleep sleeps.
+//
+//goray:timeout 5s
+func (Tasks) Sleep(d time.Duration, names ...string) (times map[string]time.Time, err error) { return nil, nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n} }
+func (Actors) Counter2(n int) *Counter { return &Counter{n} }
+
+// Counter counts.
+type Counter struct{ n int }
+
+func (c *Counter) Incr(n int) <-chan int { return nil }
+
+// rayactor
+type CounterHandle struct{}
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+	model := Analyze(pkg, Matchers{})
+	require.Equal(t, ModelVersion, model.Version)
+	require.Equal(t, "example.com/mypkg", model.Package.Path)
+	require.Equal(t, []string{"Tasks/tasks", "Actors/actors", "Counter/actor", "CounterHandle/actor-handle"},
+		gslice.Map(model.Structs, func(s ModelStruct) string { return s.Name + "/" + s.Kind }))
+
+	tasks := model.Structs[0]
+	require.Equal(t, "tasks.go:6:6", tasks.Pos)
+	require.Len(t, tasks.Methods, 1)
+	sleep := tasks.Methods[0]
+	require.Equal(t, "tasks.go:11:14", sleep.Pos)
+	require.Equal(t, []Directive{{Name: "timeout", Args: []string{"5s"}, Params: map[string]string{}}}, sleep.Directives)
+	require.True(t, sleep.Variadic)
+	require.Equal(t, ModelVar{Name: "d", Type: "time.Duration", CanonicalType: "time.Duration", Imports: []string{"time"}}, sleep.Params[0])
+	require.Equal(t, ModelVar{Name: "names", Type: "string", CanonicalType: "[]string"}, sleep.Params[1])
+	require.Equal(t, ModelVar{Name: "times", Type: "map[string]time.Time", CanonicalType: "map[string]time.Time", Imports: []string{"time"}}, sleep.Results[0])
+	require.Equal(t, ModelVar{Name: "err", Type: "error", CanonicalType: "error"}, sleep.Results[1])
+	require.Equal(t, "Sleep(time.Duration,...[]string)(map[string]time.Time,error)", sleep.Signature)
+
+	counter := model.Structs[2]
+	require.Equal(t, "// Counter counts.", counter.Doc)
+	require.Equal(t, "*Counter", counter.Methods[0].Receiver)
+	require.Equal(t, ModelVar{Type: "<-chan int", CanonicalType: "<-chan int"}, counter.Methods[0].Results[0])
+	require.Empty(t, model.Structs[3].Methods)
+}
@@ -0,0 +1,256 @@
+// Package raycheck defines an Analyzer validating the goraygen annotations of a package:
+// the signatures of the tasks, actor factories and actor methods, and the //goray: directives.
+// It reports the problems the generator would skip with a warning, in the editor or with go vet:
+//
+//	go install github.com/ray4go/goraygen/cmd/raycheck@latest
+//	go vet -vettool=$(which raycheck) ./...
+package raycheck
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/bytedance/gg/gslice"
+	rayanalysis "github.com/ray4go/goraygen/analysis"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "raycheck",
+	Doc:  "check the goraygen tasks and actors: unsupported or unexported types in their signatures and invalid //goray: directives",
+	URL:  "https://github.com/ray4go/goraygen",
+	Run:  run,
+}
+
+// streaming accepts the channel results of the methods streamed by goraygen -streaming, checking their element type.
+var streaming bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&streaming, "streaming", false, "accept the channel results streamed by goraygen -streaming")
+}
+
+// checker reports the diagnostics of a package.
+type checker struct {
+	pass     *analysis.Pass
+	pkg      *packages.Package // the package of the pass, for the rayanalysis functions
+	funcDecl map[token.Pos]*ast.FuncDecl
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	c := &checker{
+		pass: pass,
+		pkg: &packages.Package{
+			Name:      pass.Pkg.Name(),
+			PkgPath:   pass.Pkg.Path(),
+			Fset:      pass.Fset,
+			Syntax:    pass.Files,
+			Types:     pass.Pkg,
+			TypesInfo: pass.TypesInfo,
+		},
+		funcDecl: make(map[token.Pos]*ast.FuncDecl),
+	}
+	for _, file := range rayanalysis.SourceFiles(c.pkg) {
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv != nil {
+				c.funcDecl[fd.Name.Pos()] = fd
+			}
+		}
+		c.checkTypeDirectives(file)
+	}
+
+	targets := rayanalysis.DiscoverTargets(c.pkg, rayanalysis.Matchers{})
+	if targets.Tasks != nil {
+		for _, m := range c.methods(targets.Tasks.Name.Name) {
+			c.checkMethod(m, "task", false)
+		}
+	}
+	if targets.Actors != nil {
+		factories := c.methods(targets.Actors.Name.Name)
+		for _, m := range factories {
+			c.checkMethod(m, "actor factory", false)
+			if len(m.Results) != 1 {
+				c.pass.Reportf(m.pos, "actor factory %s must return the actor, got %d results", m.Name, len(m.Results))
+			}
+		}
+		for _, name := range rayanalysis.ActorTypes(c.pkg, gslice.Map(factories, func(m method) rayanalysis.Method { return m.Method })) {
+			for _, m := range c.methods(name) {
+				c.checkMethod(m, "actor method", true)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// method is a method of a target struct with its position.
+type method struct {
+	rayanalysis.Method
+	pos token.Pos
+}
+
+func (c *checker) methods(structName string) []method {
+	typ := c.pass.Pkg.Scope().Lookup(structName).Type()
+	return gslice.Map(rayanalysis.FindMethods(c.pkg, structName, rayanalysis.NewImportStore()), func(m rayanalysis.Method) method {
+		obj, _, _ := types.LookupFieldOrMethod(typ, true, c.pass.Pkg, m.Name)
+		return method{Method: m, pos: obj.Pos()}
+	})
+}
+
+func (c *checker) checkMethod(m method, kind string, actorMethod bool) {
+	for i, p := range m.Params {
+		if i == 0 && m.TakesContext() {
+			continue
+		}
+		if kind == "task" && isIOType(p.GoType) {
+			continue // passed by the IO adapter of the task
+		}
+		c.checkType(m, kind, "param "+p.Name, p.GoType)
+	}
+	for i, r := range m.Results {
+		typ := r.GoType
+		if ch, ok := typ.Underlying().(*types.Chan); ok && i == 0 && streaming && ch.Dir() != types.SendOnly &&
+			(len(m.Results) == 1 || len(m.Results) == 2 && m.ReturnsError()) {
+			typ = ch.Elem() // the values are streamed
+		}
+		if !r.IsError {
+			c.checkType(m, kind, "result "+strconv.Itoa(i), typ)
+		}
+	}
+	c.checkMethodDirectives(m, actorMethod)
+}
+
+// checkType reports the types of the signature which can't be passed to or returned from a remote call,
+// and the unexported types, which the wrappers generated into another package can't reference.
+func (c *checker) checkType(m method, kind, what string, typ types.Type) {
+	if bad := unsupportedType(typ); bad != nil {
+		c.pass.Reportf(m.pos, "%s %s: %s has unsupported type %s, it can't be serialized", kind, m.Name, what, types.TypeString(bad, types.RelativeTo(c.pass.Pkg)))
+	}
+	if unexported := unexportedType(c.pass.Pkg, typ); unexported != nil {
+		c.pass.Reportf(m.pos, "%s %s: %s has unexported type %s, the wrappers can't reference it from another package", kind, m.Name, what, unexported.Obj().Name())
+	}
+}
+
+// checkMethodDirectives reports the invalid directives in the doc of the method.
+func (c *checker) checkMethodDirectives(m method, actorMethod bool) {
+	fd := c.funcDecl[m.pos]
+	if fd == nil || fd.Doc == nil {
+		return
+	}
+	for _, comment := range fd.Doc.List {
+		for _, d := range rayanalysis.ParseDirectives(comment.Text) {
+			switch err := d.Validate(); {
+			case err != nil:
+				c.pass.Reportf(comment.Pos(), "%s: %v", m.Name, err)
+			case d.Name == "codec":
+				c.pass.Reportf(comment.Pos(), "%s: %s%s applies to type declarations", m.Name, rayanalysis.DirectivePrefix, d.Name)
+			case d.Name == "resources" && actorMethod:
+				c.pass.Reportf(comment.Pos(), "%s: %sresources is ignored on actor methods, add it to the actor factory", m.Name, rayanalysis.DirectivePrefix)
+			}
+		}
+	}
+}
+
+// checkTypeDirectives reports the invalid directives in the docs of the type declarations of the file.
+func (c *checker) checkTypeDirectives(file *ast.File) {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			for _, doc := range []*ast.CommentGroup{genDecl.Doc, typeSpec.Doc} {
+				if doc == nil {
+					continue
+				}
+				for _, comment := range doc.List {
+					for _, d := range rayanalysis.ParseDirectives(comment.Text) {
+						if err := d.Validate(); err != nil {
+							c.pass.Reportf(comment.Pos(), "%s: %v", typeSpec.Name.Name, err)
+						} else if d.Name != "codec" {
+							c.pass.Reportf(comment.Pos(), "%s: %s%s applies to methods", typeSpec.Name.Name, rayanalysis.DirectivePrefix, d.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// unsupportedType returns the part of the type which can't be serialized: funcs, channels, unsafe pointers
+// and interfaces with methods (other than error), nil if none.
+func unsupportedType(typ types.Type) types.Type {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Signature, *types.Chan:
+		return typ
+	case *types.Basic:
+		if t.Kind() == types.UnsafePointer {
+			return typ
+		}
+	case *types.Named:
+		if iface, ok := t.Underlying().(*types.Interface); ok && !iface.Empty() && !types.Identical(t, errorType) {
+			return typ
+		}
+		if _, ok := t.Underlying().(*types.Struct); !ok {
+			return unsupportedType(t.Underlying())
+		}
+	case *types.Interface:
+		if !t.Empty() {
+			return typ
+		}
+	case *types.Pointer:
+		return unsupportedType(t.Elem())
+	case *types.Slice:
+		return unsupportedType(t.Elem())
+	case *types.Array:
+		return unsupportedType(t.Elem())
+	case *types.Map:
+		if bad := unsupportedType(t.Key()); bad != nil {
+			return bad
+		}
+		return unsupportedType(t.Elem())
+	}
+	return nil
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// isIOType reports whether the type is io.Reader or io.Writer, which the IO adapters of the tasks stream as chunks.
+func isIOType(typ types.Type) bool {
+	t, ok := types.Unalias(typ).(*types.Named)
+	if !ok || t.Obj().Pkg() == nil || t.Obj().Pkg().Path() != "io" {
+		return false
+	}
+	return t.Obj().Name() == "Reader" || t.Obj().Name() == "Writer"
+}
+
+// unexportedType returns the first unexported named type of the package in the type, nil if none.
+func unexportedType(pkg *types.Package, typ types.Type) *types.Named {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Named:
+		if t.Obj().Pkg() == pkg && !t.Obj().Exported() {
+			return t
+		}
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if u := unexportedType(pkg, t.TypeArgs().At(i)); u != nil {
+				return u
+			}
+		}
+	case *types.Pointer:
+		return unexportedType(pkg, t.Elem())
+	case *types.Slice:
+		return unexportedType(pkg, t.Elem())
+	case *types.Array:
+		return unexportedType(pkg, t.Elem())
+	case *types.Chan:
+		return unexportedType(pkg, t.Elem())
+	case *types.Map:
+		if u := unexportedType(pkg, t.Key()); u != nil {
+			return u
+		}
+		return unexportedType(pkg, t.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package raycheck
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func TestAnalyzerStreaming(t *testing.T) {
+	if err := Analyzer.Flags.Set("streaming", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("streaming", "false")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "stream")
+}
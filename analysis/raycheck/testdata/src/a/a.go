@@ -0,0 +1,60 @@
+package a
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// raytasks
+type Tasks struct{}
+
+// Sleep sleeps.
+//
+// want +2 `Sleep: invalid //goray:retry: invalid backoff=fast`
+//
+//goray:retry max=3 backoff=fast
+//goray:timeout 30s
+func (Tasks) Sleep(ctx context.Context, d time.Duration) error { return nil }
+
+func (Tasks) Read(r io.Reader, w io.Writer) []byte { return nil }
+
+func (Tasks) Each(fn func(int)) {} // want `task Each: param fn has unsupported type func\(int\), it can't be serialized`
+
+func (Tasks) Stream(n int) <-chan int { return nil } // want `task Stream: result 0 has unsupported type <-chan int, it can't be serialized`
+
+func (Tasks) Point(p point) []*point { return nil } // want `task Point: param p has unexported type point` `task Point: result 0 has unexported type point`
+
+// want +2 `Typo: unknown directive //goray:timeuot`
+//
+//goray:timeuot 30s
+func (Tasks) Typo() {}
+
+// rayactors
+type Actors struct{}
+
+//goray:resources cpu=2 memory=4Gi
+func (Actors) Counter(n int) *Counter { return &Counter{n} }
+
+func (Actors) Broken() (*Counter, error) { return nil, nil } // want `actor factory Broken must return the actor, got 2 results`
+
+type Counter struct{ n int }
+
+// want +2 `Incr: //goray:resources is ignored on actor methods`
+//
+//goray:resources cpu=1
+func (c *Counter) Incr(n int) int { return c.n }
+
+func (c *Counter) Feed(r io.Reader) {} // want `actor method Feed: param r has unsupported type io.Reader, it can't be serialized`
+
+type point struct{ X, Y int }
+
+// want +2 `Payload: invalid //goray:codec: invalid codec "xml"`
+//
+//goray:codec xml
+type Payload struct{}
+
+// want +2 `Config: //goray:idempotent applies to methods`
+//
+//goray:idempotent
+type Config struct{}
@@ -0,0 +1,12 @@
+package stream
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Count(n int) <-chan int { return nil }
+
+func (Tasks) Scan(dir string) (<-chan string, error) { return nil, nil }
+
+func (Tasks) Callbacks() <-chan func() { return nil } // want `task Callbacks: result 0 has unsupported type func\(\), it can't be serialized`
+
+func (Tasks) Sink() chan<- int { return nil } // want `task Sink: result 0 has unsupported type chan<- int, it can't be serialized`
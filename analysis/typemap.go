@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// TypeMapping overrides how a type is rendered in the generated code, e.g. a proprietary ID type as string,
+// or time.Time as an int64 epoch in the fields encoded by the codecs.
+type TypeMapping struct {
+	Type string `json:"type" yaml:"type"` // the mapped type with its package path, e.g. "time.Time" or "example.com/ids.UserID"
+	Name string `json:"name" yaml:"name"` // the type rendered instead, predeclared or with its package path, e.g. "int64"
+	// ToWire and FromWire are the expressions converting a value of Type to Name and back, with %s standing for
+	// the value, e.g. "%s.Unix()" and "time.Unix(%s, 0)". Empty means a conversion, e.g. "int64(%s)".
+	ToWire   string   `json:"toWire,omitempty" yaml:"toWire"`
+	FromWire string   `json:"fromWire,omitempty" yaml:"fromWire"`
+	Imports  []string `json:"imports,omitempty" yaml:"imports"` // the import paths used by ToWire and FromWire
+}
+
+// ParseTypeMapping parses a mapping without conversion expressions, like "example.com/ids.UserID=string".
+func ParseTypeMapping(s string) (TypeMapping, error) {
+	typ, name, ok := strings.Cut(s, "=")
+	if !ok {
+		return TypeMapping{}, fmt.Errorf("invalid type mapping %q, it should be like \"example.com/ids.UserID=string\"", s)
+	}
+	m := TypeMapping{Type: strings.TrimSpace(typ), Name: strings.TrimSpace(name)}
+	return m, m.Validate()
+}
+
+// Validate checks the mapping is complete, with the conversion expressions taking the value once.
+func (m TypeMapping) Validate() error {
+	if _, _, ok := splitQualified(m.Type); !ok {
+		return fmt.Errorf("invalid type mapping of %q: the type should be qualified by its package path, like \"time.Time\"", m.Type)
+	}
+	if m.Name == "" {
+		return fmt.Errorf("invalid type mapping of %s: no type to render instead", m.Type)
+	}
+	for _, expr := range []string{m.ToWire, m.FromWire} {
+		if expr != "" && strings.Count(expr, "%s") != 1 {
+			return fmt.Errorf("invalid type mapping of %s: the conversion %q should take the value as %%s once", m.Type, expr)
+		}
+	}
+	return nil
+}
+
+// Converts reports whether the values are converted by the ToWire and FromWire expressions,
+// otherwise Type and Name have the same representation and they are converted to each other like "T(v)".
+func (m TypeMapping) Converts() bool {
+	return m.ToWire != "" || m.FromWire != ""
+}
+
+// Render returns the rendered type in the code of a package, adding its package to importStore.
+func (m TypeMapping) Render(importStore *ImportStore) string {
+	pkgPath, name, ok := splitQualified(m.Name)
+	if !ok {
+		return m.Name
+	}
+	return importStore.AddImport(pkgPath) + "." + name
+}
+
+// ToWireExpr returns the expression converting the value v of Type to the rendered type.
+func (m TypeMapping) ToWireExpr(v string, importStore *ImportStore) string {
+	m.addImports(importStore)
+	if m.ToWire == "" {
+		return fmt.Sprintf("%s(%s)", m.Render(importStore), v)
+	}
+	return fmt.Sprintf(m.ToWire, v)
+}
+
+// FromWireExpr returns the expression converting the value v of the rendered type back to typ, the mapped type,
+// in the code of the package currentPkgPath.
+func (m TypeMapping) FromWireExpr(v string, typ types.Type, currentPkgPath string, importStore *ImportStore) string {
+	m.addImports(importStore)
+	if m.FromWire == "" {
+		return fmt.Sprintf("%s(%s)", renderType(typ, currentPkgPath, importStore), v)
+	}
+	return fmt.Sprintf(m.FromWire, v)
+}
+
+func (m TypeMapping) addImports(importStore *ImportStore) {
+	for _, path := range m.Imports {
+		importStore.AddImport(path)
+	}
+}
+
+// TypeMappings are the mappings of the types rendered differently, see TypeMapping.
+type TypeMappings []TypeMapping
+
+// Lookup returns the mapping of the type, the first one of its Type, false if it isn't mapped.
+func (ms TypeMappings) Lookup(typ types.Type) (TypeMapping, bool) {
+	if typ == nil || len(ms) == 0 {
+		return TypeMapping{}, false
+	}
+	name := types.TypeString(typ, nil)
+	for _, m := range ms {
+		if m.Type == name {
+			return m, true
+		}
+	}
+	return TypeMapping{}, false
+}
+
+// splitQualified splits a type qualified by its package path, like "example.com/ids.UserID",
+// into the path and the name, false if it isn't qualified, e.g. "int64" or "[]byte".
+func splitQualified(typ string) (pkgPath, name string, ok bool) {
+	i := strings.LastIndex(typ, ".")
+	if i <= 0 || strings.ContainsAny(typ[:i], "[]*(){} ") {
+		return "", "", false
+	}
+	return typ[:i], typ[i+1:], typ[i+1:] != ""
+}
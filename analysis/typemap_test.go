@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTypeMapping(t *testing.T) {
+	m, err := ParseTypeMapping("example.com/ids.UserID = string")
+	require.NoError(t, err)
+	require.Equal(t, TypeMapping{Type: "example.com/ids.UserID", Name: "string"}, m)
+	require.False(t, m.Converts())
+
+	_, err = ParseTypeMapping("example.com/ids.UserID")
+	require.Error(t, err)
+	_, err = ParseTypeMapping("UserID=string")
+	require.Error(t, err)
+	_, err = ParseTypeMapping("[]example.com/ids.UserID=[]string")
+	require.Error(t, err)
+	_, err = ParseTypeMapping("example.com/ids.UserID=")
+	require.Error(t, err)
+
+	require.Error(t, TypeMapping{Type: "time.Time", Name: "int64", ToWire: "v.Unix()"}.Validate())
+	require.NoError(t, TypeMapping{Type: "time.Time", Name: "int64", ToWire: "%s.Unix()", FromWire: "time.Unix(%s, 0)"}.Validate())
+}
+
+func TestTypeNameWithMappings(t *testing.T) {
+	code := `package mypkg
+
+type UserID string
+
+// raytasks
+type MyTasks struct{}
+
+func (t *MyTasks) Foo(id UserID, ids []UserID) map[UserID]bool { return nil }
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+	importStore := NewImportStore()
+	importStore.MapTypes(TypeMappings{{Type: "example.com/mypkg.UserID", Name: "example.com/ids.Raw"}})
+	methods := Methods(pkg, "MyTasks", "example.com/client", importStore)
+
+	require.Len(t, methods, 1)
+	// only the mapped types themselves are rendered as their mappings, not the types composed of them
+	require.Equal(t, "Foo(id ids.Raw, ids []mypkg.UserID) (map[mypkg.UserID]bool)", methods[0].String())
+
+	m, ok := importStore.Mapping(methods[0].Params[0].GoType)
+	require.True(t, ok)
+	require.Equal(t, "ids.Raw(id)", m.ToWireExpr("id", importStore))
+	require.Equal(t, "mypkg.UserID(id)", m.FromWireExpr("id", methods[0].Params[0].GoType, "example.com/client", importStore))
+	_, ok = importStore.Mapping(methods[0].Params[1].GoType)
+	require.False(t, ok)
+}
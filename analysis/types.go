@@ -0,0 +1,266 @@
+package analysis
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// renderSignature renders the params and results of the signature, e.g. "(a int, b ...string) (int, error)".
+func renderSignature(sig *types.Signature, currentPkgPath string, importStore *ImportStore) string {
+	tuple := func(tuple *types.Tuple, variadic bool) string {
+		vars := make([]string, tuple.Len())
+		for i := range vars {
+			v := tuple.At(i)
+			vars[i] = renderType(v.Type(), currentPkgPath, importStore)
+			if variadic && i == len(vars)-1 {
+				vars[i] = "..." + strings.TrimPrefix(vars[i], "[]")
+			}
+			if v.Name() != "" {
+				vars[i] = v.Name() + " " + vars[i]
+			}
+		}
+		return strings.Join(vars, ", ")
+	}
+	s := "(" + tuple(sig.Params(), sig.Variadic()) + ")"
+	switch results := sig.Results(); {
+	case results.Len() == 1 && results.At(0).Name() == "":
+		s += " " + tuple(results, false)
+	case results.Len() > 0:
+		s += " (" + tuple(results, false) + ")"
+	}
+	return s
+}
+
+// quoteTag quotes the tag of a struct field, in a raw string literal unless it contains a backquote.
+func quoteTag(tag string) string {
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
+
+// qualifiedName renders the name of a named type or alias, qualified by its package unless it's currentPkgPath,
+// with the type arguments of an instantiated generic type, e.g. lib.List[int], not mapped like the other nested types.
+func qualifiedName(obj *types.TypeName, args *types.TypeList, currentPkgPath string, importStore *ImportStore) string {
+	// typeName is the name of this type (e.g., "MyStruct", "Reader")
+	typeName := obj.Name()
+	// Pkg() returns the package that defines this type; nil for predeclared types (e.g., error)
+	if obj.Pkg() != nil && obj.Pkg().Path() != currentPkgPath {
+		typeName = importStore.AddPackage(obj.Pkg()) + "." + typeName
+	}
+	if args.Len() > 0 {
+		names := make([]string, args.Len())
+		for i := range names {
+			names[i] = renderType(args.At(i), currentPkgPath, importStore)
+		}
+		typeName += "[" + strings.Join(names, ", ") + "]"
+	}
+	return typeName
+}
+
+// TypeName returns the name of the type as written in Go code of the package currentPkgPath, e.g. "[]pkg.MyType".
+// If the type is defined in currentPkgPath, the package name is omitted, otherwise the package is added to importStore.
+// A type mapped by importStore is rendered as its mapping, not the types it's composed of, see ImportStore.MapTypes.
+func TypeName(typ types.Type, currentPkgPath string, importStore *ImportStore) string {
+	if m, ok := importStore.Mapping(typ); ok {
+		return m.Render(importStore)
+	}
+	return renderType(typ, currentPkgPath, importStore)
+}
+
+// renderType is TypeName without the type mappings.
+func renderType(typ types.Type, currentPkgPath string, importStore *ImportStore) string {
+	var typeName string
+	// Alias type - rendered like a named type, unless resolved; the predeclared any is kept
+	if alias, ok := typ.(*types.Alias); ok {
+		if importStore.resolveAliases && alias.Obj().Pkg() != nil {
+			return TypeName(types.Unalias(alias), currentPkgPath, importStore)
+		}
+		return qualifiedName(alias.Obj(), alias.TypeArgs(), currentPkgPath, importStore)
+	}
+	// Named type - the only case with an explicit package name.
+	if named, ok := typ.(*types.Named); ok {
+		return qualifiedName(named.Obj(), named.TypeArgs(), currentPkgPath, importStore)
+	}
+
+	// Other *types.Type variants that don't have package names but do have type names.
+	// For these types, packagePath will be an empty string.
+	switch t := typ.(type) {
+	case *types.Basic:
+		// Basic types (int, string, bool, etc.)
+		typeName = t.Name()
+		if t.Kind() == types.UnsafePointer {
+			typeName = importStore.AddImport("unsafe") + "." + typeName
+		}
+	case *types.Pointer:
+		// Pointer types (*int, *MyStruct)
+		// Type name is "*" + element type name
+		// For more precise representation, can recursively call getPackageAndTypeName(t.Elem())
+		elemTypeName := renderType(t.Elem(), currentPkgPath, importStore)
+		typeName = "*" + elemTypeName
+	case *types.Slice:
+		// Slice types ([]int, []MyStruct)
+		elemTypeName := renderType(t.Elem(), currentPkgPath, importStore)
+		typeName = "[]" + elemTypeName
+	case *types.Array:
+		// Array types ([N]int, [N]MyStruct)
+		elemTypeName := renderType(t.Elem(), currentPkgPath, importStore)
+		typeName = fmt.Sprintf("[%d]%s", t.Len(), elemTypeName)
+	case *types.Map:
+		// Map types (map[string]int)
+		keyTypeName := renderType(t.Key(), currentPkgPath, importStore)
+		elemTypeName := renderType(t.Elem(), currentPkgPath, importStore)
+		typeName = fmt.Sprintf("map[%s]%s", keyTypeName, elemTypeName)
+	case *types.Chan:
+		// Channel types (chan int, chan<- bool)
+		elemTypeName := renderType(t.Elem(), currentPkgPath, importStore)
+		dir := ""
+		switch t.Dir() {
+		case types.SendRecv:
+			dir = "chan "
+		case types.SendOnly:
+			dir = "chan<- "
+		case types.RecvOnly:
+			dir = "<-chan "
+		}
+		typeName = dir + elemTypeName
+	case *types.Signature:
+		// Function types (func(int) string), their param and result types qualified like the other types
+		typeName = "func" + renderSignature(t, currentPkgPath, importStore)
+	case *types.Struct:
+		// Struct literal types (struct { Field int }), with the embedded fields and the tags
+		fields := make([]string, t.NumFields())
+		for i := range fields {
+			field := t.Field(i)
+			fields[i] = renderType(field.Type(), currentPkgPath, importStore)
+			if !field.Embedded() {
+				fields[i] = field.Name() + " " + fields[i]
+			}
+			if tag := t.Tag(i); tag != "" {
+				fields[i] += " " + quoteTag(tag)
+			}
+		}
+		typeName = "struct{" + strings.Join(fields, "; ") + "}"
+	case *types.Interface:
+		// Interface literal types (interface { Method() }), with the embedded types
+		var elems []string
+		for i := range t.NumEmbeddeds() {
+			elems = append(elems, renderType(t.EmbeddedType(i), currentPkgPath, importStore))
+		}
+		for i := range t.NumExplicitMethods() {
+			m := t.ExplicitMethod(i)
+			elems = append(elems, m.Name()+renderSignature(m.Type().(*types.Signature), currentPkgPath, importStore))
+		}
+		typeName = "interface{" + strings.Join(elems, "; ") + "}"
+	default:
+		// For other unknown or uncommon types, use their String() method as the name
+		typeName = typ.String()
+	}
+
+	return typeName
+}
+
+// ReferencedPackages returns the packages of the named types the type is composed of, e.g. the ones of K and V
+// for map[K]V, and the ones of the type arguments. The types mapped by importStore are left out, their mappings
+// being rendered instead, see TypeName.
+func ReferencedPackages(typ types.Type, importStore *ImportStore) []*types.Package {
+	var pkgs []*types.Package
+	for _, tn := range ReferencedTypeNames(typ, importStore) {
+		if pkg := tn.Pkg(); pkg != nil && !slices.Contains(pkgs, pkg) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// ReferencedTypeNames returns the names of the named types and aliases the type is rendered with by TypeName,
+// in the order they appear, e.g. K and V for map[K]V, and the ones of the type arguments. The types mapped
+// by importStore are left out, their mappings being rendered instead.
+func ReferencedTypeNames(typ types.Type, importStore *ImportStore) []*types.TypeName {
+	var names []*types.TypeName
+	seen := make(map[types.Type]bool)
+	var visit func(types.Type)
+	visitTuple := func(tuple *types.Tuple) {
+		for v := range tuple.Variables() {
+			visit(v.Type())
+		}
+	}
+	visit = func(typ types.Type) {
+		if seen[typ] {
+			return
+		}
+		seen[typ] = true
+		if _, ok := importStore.Mapping(typ); ok {
+			return
+		}
+		switch t := typ.(type) {
+		case *types.Named:
+			if !slices.Contains(names, t.Obj()) {
+				names = append(names, t.Obj())
+			}
+			for arg := range t.TypeArgs().Types() {
+				visit(arg)
+			}
+		case *types.Alias:
+			if importStore.resolveAliases {
+				visit(types.Unalias(t))
+				return
+			}
+			if !slices.Contains(names, t.Obj()) {
+				names = append(names, t.Obj())
+			}
+			for arg := range t.TypeArgs().Types() {
+				visit(arg)
+			}
+		case *types.Pointer:
+			visit(t.Elem())
+		case *types.Slice:
+			visit(t.Elem())
+		case *types.Array:
+			visit(t.Elem())
+		case *types.Map:
+			visit(t.Key())
+			visit(t.Elem())
+		case *types.Chan:
+			visit(t.Elem())
+		case *types.Signature:
+			visitTuple(t.Params())
+			visitTuple(t.Results())
+		case *types.Struct:
+			for field := range t.Fields() {
+				visit(field.Type())
+			}
+		case *types.Interface:
+			for m := range t.Methods() {
+				visit(m.Type())
+			}
+			for embedded := range t.EmbeddedTypes() {
+				visit(embedded)
+			}
+		}
+	}
+	visit(typ)
+	return names
+}
+
+// Importable reports whether the package at importPath can be imported by the one at fromPath: an internal package,
+// e.g. example.com/mod/internal/ids, only by the packages of the tree rooted at the parent of its internal element,
+// example.com/mod, and the internal packages of the standard library only by the standard library.
+func Importable(importPath, fromPath string) bool {
+	elems := strings.Split(importPath, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i] != "internal" {
+			continue
+		}
+		if i == 0 {
+			first, _, _ := strings.Cut(fromPath, "/")
+			return !strings.Contains(first, ".")
+		}
+		parent := strings.Join(elems[:i], "/")
+		return fromPath == parent || strings.HasPrefix(fromPath, parent+"/")
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentifiableTypeName(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"types": `package mypkg
+
+import "bytes"
+
+type (
+	T       struct{}
+	a       int
+	b       int
+	mapaTob int
+	ptr     int
+	my_T    int
+	Pair[K comparable, V any] struct{}
+)
+
+var (
+	v0  []*bytes.Buffer
+	v1  *[]bytes.Buffer
+	v2  map[string][4]int
+	v3  map[a]b
+	v4  mapaTob
+	v5  Pair[string, []Pair[int, T]]
+	v6  map[Pair[int, int]]bool
+	v7  ptr
+	v8  *T
+	v9  my_T
+	v10 func(int, ...string) (T, error)
+	v11 <-chan interface{}
+	v12 struct{ ID int }
+)
+`}, "example.com/mypkg")
+	want := []string{
+		"slice_ptr_bytes_Buffer",
+		"ptr_slice_bytes_Buffer",
+		"map_string_to_arr4_int",
+		"map_a_to_b",
+		"mapaTob",
+		"Pair_of_string_and_slice_Pair_of_int_and_T",
+		"map_Pair_of_int_and_int_end_to_bool",
+		"ptr_1",
+		"ptr_T",
+		"my_0T",
+		"func_int_and_variadic_string_returns_T_and_error",
+		"recvChan_any",
+		"struct_ID_int",
+	}
+	store := NewImportStore()
+	for i, name := range want {
+		typ := pkg.Types.Scope().Lookup(fmt.Sprintf("v%d", i)).Type()
+		require.Equal(t, name, IdentifiableTypeName(typ, "example.com/mypkg", store), typ.String())
+	}
+	require.Equal(t, "Buffer", IdentifiableTypeName(pkg.Types.Scope().Lookup("v1").Type().(*types.Pointer).Elem().(*types.Slice).Elem(), "bytes", store))
+	require.Equal(t, "myclient", Identifier("my-client"))
+}
+
+func TestTypeNameOfInstances(t *testing.T) {
+	lib := types.NewPackage("example.com/lib", "lib")
+	tparams := []*types.TypeParam{
+		types.NewTypeParam(types.NewTypeName(token.NoPos, lib, "K", nil), types.Universe.Lookup("comparable").Type()),
+		types.NewTypeParam(types.NewTypeName(token.NoPos, lib, "V", nil), types.NewInterfaceType(nil, nil)),
+	}
+	pair := types.NewNamed(types.NewTypeName(token.NoPos, lib, "Pair", nil), types.NewStruct(nil, nil), nil)
+	pair.SetTypeParams(tparams)
+	point := types.NewNamed(types.NewTypeName(token.NoPos, types.NewPackage("example.com/geo", "geo"), "Point", nil), types.NewStruct(nil, nil), nil)
+
+	inst, err := types.Instantiate(nil, pair, []types.Type{types.Typ[types.String], types.NewPointer(point)}, true)
+	require.NoError(t, err)
+	store := NewImportStore()
+	require.Equal(t, "lib.Pair[string, *geo.Point]", TypeName(inst, "example.com/mypkg", store))
+	// the packages of the type arguments are imported too
+	require.Equal(t, []string{`"example.com/geo"`, `"example.com/lib"`}, store.DumpImportExprs())
+	require.Equal(t, "Pair[string, *geo.Point]", TypeName(inst, "example.com/lib", store))
+}
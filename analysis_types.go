@@ -0,0 +1,19 @@
+package goraygen
+
+import "github.com/ray4go/goraygen/analysis"
+
+// The types of the scanned package, see the analysis package.
+type (
+	Method               = analysis.Method
+	Param                = analysis.Param
+	Result               = analysis.Result
+	StateField           = analysis.StateField
+	Directive            = analysis.Directive
+	ImportStore          = analysis.ImportStore
+	StructMatcher        = analysis.StructMatcher
+	CommentMatcher       = analysis.CommentMatcher
+	RegexpCommentMatcher = analysis.RegexpCommentMatcher
+	NameMatcher          = analysis.NameMatcher
+	EmbedMatcher         = analysis.EmbedMatcher
+	TypeMapping          = analysis.TypeMapping
+)
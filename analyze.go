@@ -0,0 +1,59 @@
+package goraygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// runAnalyzeCommand runs the analyze subcommand, writing the discovered model of the package as JSON
+// to the -o file, or to w if not set. See analysis.Model for the schema.
+func runAnalyzeCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write the model into, default is stdout")
+	tags := fs.String("tags", "", "comma-separated list of build tags to apply when loading packages")
+	var markers, structs stringsFlag
+	fs.Var(&markers, "marker", "marker of the tasks/actors struct, same as the -marker flag of the generation (repeatable)")
+	fs.Var(&structs, "struct", "explicit tasks/actors struct name, same as the -struct flag of the generation (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goraygen analyze [-o model.json] <package-path>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expect one package path")
+	}
+	opts := Options{BuildTags: splitList(*tags)}
+	if err := opts.applyTargetFlags(markers, structs); err != nil {
+		return err
+	}
+	g := NewGenerator(opts)
+	if err := g.loadPackage(fs.Arg(0)); err != nil {
+		return err
+	}
+	model := analysis.Analyze(g.pkg, analysis.Matchers{Tasks: opts.tasksMatcher(), Actors: opts.actorsMatcher(), Actor: opts.actorMatcher()})
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // e.g. "<-chan int"
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(model); err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err := buf.WriteTo(w)
+		return err
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0o644); err != nil {
+		return withExitCode(exitWrite, err)
+	}
+	opts.logger().Info("Write the model", "path", *output)
+	return nil
+}
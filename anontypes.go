@@ -0,0 +1,140 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+The anonymous struct and interface params are given named types in the generated code, e.g. for
+
+	func (Tasks) Configure(opts struct{ Retries int }) error
+
+	// ConfigureOptsParam is the type of the param opts of [Tasks.Configure].
+	type ConfigureOptsParam struct{ Retries int }
+
+	func Configure[ConfigureOptsParam_0 _T0](opts ConfigureOptsParam_0) *RemoteFunc[*Future1[error]]
+
+A value of the named type is assignable to the anonymous one, its underlying type, so the values convert implicitly
+at the call boundary, e.g. in the local variants. The empty interface, any, isn't named.
+*/
+
+// anonymousType is a named type given to the anonymous type of a param, see nameAnonymousTypes.
+type anonymousType struct {
+	literal string // the type literal, rendered in the output package
+	doc     string // e.g. "the param opts of [Tasks.Configure]"
+}
+
+// nameAnonymousTypes replaces the anonymous struct and interface types of the params of the targets with named types,
+// declared by generateAnonymousTypes, reporting a warning for each (an error with -strict).
+// The struct types with unexported fields are only named in their package, where the fields are the same.
+func (g *Generator) nameAnonymousTypes() {
+	if g.anonymousTypes == nil {
+		g.anonymousTypes = make(map[string]anonymousType)
+	}
+	named := make(map[string]string) // receiver.method.param -> name, the methods of the actor handles being also actor methods
+	name := func(prefix string, m *Method) {
+		for i, p := range m.Params {
+			t, ok := p.GoType.(*types.Struct)
+			switch {
+			case ok && g.outputPkgPath != g.pkg.PkgPath && hasUnexportedFields(t):
+				continue
+			case !ok && !isNonEmptyInterface(p.GoType):
+				continue
+			}
+			key := m.ReceiverType + "." + m.Name + "." + p.Name
+			typeName, ok := named[key]
+			if !ok {
+				typeName = g.anonymousTypeName(prefix + exportedFieldName(p.Name) + "Param")
+				named[key] = typeName
+				g.anonymousTypes[typeName] = anonymousType{
+					literal: p.Type,
+					doc:     fmt.Sprintf("the param %s of [%s.%s]", p.Name, strings.TrimPrefix(m.ReceiverType, "*"), m.Name),
+				}
+				g.warnAt(g.methodPos(*m), "anonymous-type", "param %s of (%s).%s has an anonymous type, generated as %s", p.Name, m.ReceiverType, m.Name, typeName)
+			}
+			m.Params[i].Type = typeName
+			m.Params[i].IdentifiableType = analysis.IdentifiableIdent(typeName)
+		}
+	}
+	for i := range g.tasks {
+		name(g.tasks[i].Name, &g.tasks[i])
+	}
+	for i, factory := range g.actorFactories {
+		name(factory.Name, &g.actorFactories[i])
+		methods := g.actor2Methods[factory.Name]
+		for j := range methods {
+			name(factory.Name+methods[j].Name, &methods[j])
+		}
+	}
+	for _, h := range g.actorHandles {
+		for j := range h.Methods {
+			name(h.ActorName+h.Methods[j].Name, &h.Methods[j])
+		}
+	}
+}
+
+// anonymousTypeName returns the name, or the name suffixed with a number if it's declared by the sources of the package
+// or given to another anonymous type. The declarations of the generated files, e.g. the name given by the previous run,
+// don't count, so the names are stable.
+func (g *Generator) anonymousTypeName(name string) string {
+	taken := func(name string) bool {
+		if _, ok := g.anonymousTypes[name]; ok {
+			return true
+		}
+		obj := g.pkg.Types.Scope().Lookup(name)
+		return obj != nil && !analysis.IsGeneratedPos(g.pkg, obj.Pos())
+	}
+	typeName := name
+	for i := 2; taken(typeName); i++ {
+		typeName = fmt.Sprintf("%s%d", name, i)
+	}
+	return typeName
+}
+
+// generateAnonymousTypes declares the named types of the anonymous params, see nameAnonymousTypes.
+func (g *Generator) generateAnonymousTypes(buf *bytes.Buffer) {
+	for _, name := range slices.Sorted(maps.Keys(g.anonymousTypes)) {
+		t := g.anonymousTypes[name]
+		fmt.Fprintf(buf, "\n// %s is the type of %s.\ntype %s %s\n", name, t.doc, name, t.literal)
+	}
+}
+
+// anonymousLiteral returns the type literal of the param type if it's a named anonymous type, otherwise the type,
+// e.g. for the function types which must be identical to the ones of the methods.
+func (g *Generator) anonymousLiteral(typ string) string {
+	if t, ok := g.anonymousTypes[typ]; ok {
+		return t.literal
+	}
+	return typ
+}
+
+// unexportedAnonymous reports whether the param has a named anonymous struct type with unexported fields,
+// which the other packages can't convert to, their struct literal being another type.
+func (g *Generator) unexportedAnonymous(p Param) bool {
+	t, ok := p.GoType.(*types.Struct)
+	_, named := g.anonymousTypes[p.Type]
+	return ok && named && hasUnexportedFields(t)
+}
+
+// hasUnexportedFields reports whether the struct type has unexported fields.
+func hasUnexportedFields(t *types.Struct) bool {
+	for i := range t.NumFields() {
+		if !t.Field(i).Exported() {
+			return true
+		}
+	}
+	return false
+}
+
+// isNonEmptyInterface reports whether the type is an interface literal with methods or embedded types, not any.
+func isNonEmptyInterface(typ types.Type) bool {
+	t, ok := typ.(*types.Interface)
+	return ok && (t.NumExplicitMethods() > 0 || t.NumEmbeddeds() > 0)
+}
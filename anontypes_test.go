@@ -0,0 +1,80 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAnonymousTypes(t *testing.T) {
+	var warnings []string
+	opts := Options{SignatureChecks: true, LocalVariants: true}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "anonymous-type" {
+			warnings = append(warnings, d.Message)
+		}
+	})
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+type ConfigureOptsParam struct{}
+
+func (Tasks) Configure(opts struct {
+	Retries int ` + "`json:\"retries\"`" + `
+	Backoff time.Duration
+}, logger interface{ Printf(string, ...any) }, extra any) error {
+	return nil
+}
+`}, opts)
+
+	require.Contains(t, code, "// ConfigureOptsParam2 is the type of the param opts of [Tasks.Configure].\ntype ConfigureOptsParam2 struct {\n\tRetries int `json:\"retries\"`\n\tBackoff time.Duration\n}")
+	require.Contains(t, code, "type ConfigureLoggerParam interface{ Printf(string, ...any) }")
+	require.Contains(t, code, "func Configure[ConfigureOptsParam2_0 _T0, ConfigureLoggerParam_1 _T1, any_2 _T2](opts ConfigureOptsParam2_0, logger ConfigureLoggerParam_1, extra any_2)")
+	require.Contains(t, code, "func ConfigureLocal(opts ConfigureOptsParam2, logger ConfigureLoggerParam, extra any)")
+	// the signature assertions keep the type literals, the function types being identical to the ones of the methods
+	require.Contains(t, code, "_ func(Tasks, struct {\n\t\tRetries int `json:\"retries\"`\n\t\tBackoff time.Duration\n\t}, interface{ Printf(string, ...any) }, any) error = Tasks.Configure")
+	require.Equal(t, []string{
+		"param opts of (Tasks).Configure has an anonymous type, generated as ConfigureOptsParam2",
+		"param logger of (Tasks).Configure has an anonymous type, generated as ConfigureLoggerParam",
+	}, warnings)
+}
+
+func TestAnonymousTypeNamesStable(t *testing.T) {
+	// the type declared by the previous run doesn't take the name
+	code := generateFromSource(t, map[string]string{
+		"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Configure(opts struct{ Retries int }) error { return nil }
+`,
+		"ray_workload_wrappers": `// Code generated by goraygen; DO NOT EDIT.
+
+package mypkg
+
+type ConfigureOptsParam struct{ Retries int }
+`}, Options{})
+	require.Contains(t, code, "type ConfigureOptsParam struct{ Retries int }")
+	require.NotContains(t, code, "ConfigureOptsParam2")
+}
+
+func TestAnonymousTypesBuild(t *testing.T) {
+	// the task CLI converts the values of the anonymous types to the named ones
+	dir := buildGenerated(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Configure(opts struct{ Retries int }, name string) error { return nil }
+`}, Options{GenTaskCLI: true})
+	cli, err := os.ReadFile(filepath.Join(dir, taskCLIDir, "main.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(cli), "mypkg.Configure(mypkg.ConfigureOptsParam(_req.Opts), _req.Name)")
+}
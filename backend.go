@@ -0,0 +1,176 @@
+package goraygen
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bytedance/gg/gslice"
+)
+
+// Backend generates an output from the tasks and actors discovered in the scanned package, e.g. a file.
+// The backends run in the order of registration, after the discovery phases (collectWorkloads etc.).
+type Backend interface {
+	// Name is the name of the backend in the -backends flag.
+	Name() string
+	// Enabled reports whether the backend runs by default, i.e. when Options.Backends is empty,
+	// usually depending on the flag of the output.
+	Enabled(opts Options) bool
+	// Generate generates the output of the package into outputDir, or next to the scanned package.
+	Generate(g *Generator, outputDir string) error
+}
+
+// backendFuncs is a Backend implemented by functions.
+type backendFuncs struct {
+	name     string
+	enabled  func(Options) bool
+	generate func(g *Generator, outputDir string) error
+}
+
+func (b backendFuncs) Name() string                                  { return b.name }
+func (b backendFuncs) Enabled(opts Options) bool                     { return b.enabled(opts) }
+func (b backendFuncs) Generate(g *Generator, outputDir string) error { return b.generate(g, outputDir) }
+
+// backends are the registered backends, in the order they run.
+var backends []Backend
+
+// RegisterBackend registers the backend, to run after the registered ones. It panics if the name is already registered.
+func RegisterBackend(b Backend) {
+	if _, found := lookupBackend(b.Name()); found {
+		panic(fmt.Sprintf("backend %s already registered", b.Name()))
+	}
+	backends = append(backends, b)
+}
+
+func lookupBackend(name string) (Backend, bool) {
+	return gslice.Find(backends, func(b Backend) bool { return b.Name() == name }).Get()
+}
+
+// backendNames returns the names of the registered backends.
+func backendNames() []string {
+	return gslice.Map(backends, Backend.Name)
+}
+
+/*
+The tasks, actors and http-gateway backends are sections of the wrappers file, written once by the first of them;
+the other outputs of the wrappers file (e.g. -option-builders or -manifest) are selected by their flags.
+*/
+func init() {
+	isGo := func(o Options) bool { return o.Lang != LangPython }
+	writeWrappers := func(g *Generator, outputDir string) error { return g.writeWrappers(outputDir) }
+	for _, b := range []backendFuncs{
+		{"tasks", isGo, writeWrappers},
+		{"actors", isGo, writeWrappers},
+		{"http-gateway", func(o Options) bool { return o.HTTPGateway }, func(g *Generator, outputDir string) error {
+			if !g.backendSelected("tasks") {
+				return fmt.Errorf("backend http-gateway calls the task wrappers, select the tasks backend")
+			}
+			return g.writeWrappers(outputDir)
+		}},
+		{"python", func(o Options) bool { return o.Lang == LangPython }, func(g *Generator, outputDir string) error {
+			return g.writePythonStubs(outputDir)
+		}},
+		{"gob-register", func(o Options) bool { return o.GobRegister }, func(g *Generator, outputDir string) error {
+			return g.writeGobRegistrations(outputDir)
+		}},
+		{"proto", func(o Options) bool { return o.Proto }, func(g *Generator, _ string) error {
+			if g.splitWorker() {
+				return nil // written by the worker backend, see writeWorker
+			}
+			return g.writeProtoFile()
+		}},
+		{"manifest", func(o Options) bool { return o.DeployManifest != "" }, func(g *Generator, _ string) error {
+			return g.writeDeployManifest()
+		}},
+		{"graph", func(o Options) bool { return o.TaskGraph }, func(g *Generator, _ string) error {
+			return g.writeTaskGraph()
+		}},
+		{"worker", func(o Options) bool { return o.SplitWorker }, func(g *Generator, _ string) error {
+			return g.writeWorker()
+		}},
+		{"worker-main", func(o Options) bool { return o.GenWorkerMain }, func(g *Generator, _ string) error {
+			if !g.splitWorker() {
+				return fmt.Errorf("backend worker-main registers the structs of the worker file, select the worker backend")
+			}
+			return g.writeWorkerMain()
+		}},
+		{"task-cli", func(o Options) bool { return o.GenTaskCLI }, func(g *Generator, _ string) error {
+			return g.writeTaskCLI()
+		}},
+	} {
+		RegisterBackend(b)
+	}
+}
+
+// selectedBackends returns the backends to run: the ones of Options.Backends if set, otherwise the enabled ones,
+// followed by the plugins.
+func (o Options) selectedBackends() ([]Backend, error) {
+	selected := gslice.Filter(backends, func(b Backend) bool { return b.Enabled(o) })
+	if len(o.Backends) > 0 {
+		for _, name := range o.Backends {
+			if _, found := lookupBackend(name); !found {
+				return nil, fmt.Errorf("unknown backend %q, expect some of %s", name, strings.Join(backendNames(), ", "))
+			}
+		}
+		selected = gslice.Filter(backends, func(b Backend) bool { return gslice.Contains(o.Backends, b.Name()) })
+	}
+	for _, p := range o.Plugins {
+		selected = append(selected, parsePlugin(p))
+	}
+	return selected, nil
+}
+
+// backendSelected reports whether the backend runs, see Options.selectedBackends.
+func (g *Generator) backendSelected(name string) bool {
+	if len(g.opts.Backends) > 0 {
+		return gslice.Contains(g.opts.Backends, name)
+	}
+	b, found := lookupBackend(name)
+	return found && b.Enabled(g.opts)
+}
+
+// splitWorker reports whether the worker-side code goes to the worker file instead of the wrappers file.
+func (g *Generator) splitWorker() bool {
+	return g.backendSelected("worker")
+}
+
+// writeWrappers writes the wrappers file into outputDir, once per generator: a file per target struct
+// if their names differ by Options.Output, e.g. with {{.Struct}}.
+func (g *Generator) writeWrappers(outputDir string) error {
+	if g.wrappersWritten {
+		return nil
+	}
+	g.wrappersWritten = true
+	structs := g.outputStructs()
+	if len(structs) == 0 {
+		structs = []string{""}
+	}
+	fileOf := func(s string) string { return g.outputName(cmp.Or(s, structs[0])) }
+	if gslice.All(structs, func(s string) bool { return fileOf(s) == fileOf(structs[0]) }) {
+		path := filepath.Join(outputDir, fileOf(""))
+		if err := g.claimOutput(path, g.pkgPath()); err != nil {
+			return err
+		}
+		return g.writeFile(g.generateCode(), path)
+	}
+	for _, s := range structs {
+		if err := g.claimOutput(filepath.Join(outputDir, fileOf(s)), g.pkgPath()+"."+s); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, f := range g.generateFiles(fileOf) {
+		errs = append(errs, g.writeFile(f.code, filepath.Join(outputDir, f.name)))
+	}
+	return errors.Join(errs...)
+}
+
+// claimOutput claims the wrappers file for the owner, see outputClaims.
+func (g *Generator) claimOutput(path, owner string) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return g.claims.claim(path, owner)
+}
@@ -0,0 +1,49 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectedBackends(t *testing.T) {
+	selected, err := Options{SplitWorker: true, HTTPGateway: true}.selectedBackends()
+	require.NoError(t, err)
+	require.Equal(t, []string{"tasks", "actors", "http-gateway", "worker"}, gslice.Map(selected, Backend.Name))
+
+	selected, err = Options{Lang: LangPython, Backends: []string{"worker", "python"}}.selectedBackends()
+	require.NoError(t, err)
+	require.Equal(t, []string{"python", "worker"}, gslice.Map(selected, Backend.Name)) // in the order of registration
+
+	_, err = Options{Backends: []string{"tasks", "swagger"}}.selectedBackends()
+	require.ErrorContains(t, err, `unknown backend "swagger"`)
+
+	require.Panics(t, func() { RegisterBackend(backendFuncs{name: "tasks"}) })
+}
+
+func TestGenerateSelectedBackends(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n: n} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(by int) int { return 0 }
+`}
+	code := generateFromSource(t, sources, Options{Backends: []string{"actors"}})
+	require.NotContains(t, code, "func Divide")
+	require.Contains(t, code, "func NewCounter")
+
+	code = generateFromSource(t, sources, Options{Backends: []string{"tasks", "worker"}})
+	require.Contains(t, code, "func Divide")
+	require.NotContains(t, code, "func NewCounter")
+}
@@ -0,0 +1,159 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+/*
+	type DivideCaller interface {
+		Call(a int64, b int64) (int64, int64, error)
+	}
+
+type DivideRemoteCaller struct{}
+
+	type DivideCallerMock struct {
+		CallFunc func(a int64, b int64) (int64, int64, error)
+		...
+	}
+*/
+const callerTpl = `
+// {{.Name}}Caller calls the [{{.Name}}] task and waits for the results,
+// implemented by {{.Name}}RemoteCaller, and {{.Name}}CallerMock for tests.
+type {{.Name}}Caller interface {
+	Call({{.Params}}) ({{range .ResultTypes}}{{.}}, {{end}}error)
+}
+
+// {{.Name}}RemoteCaller is the {{.Name}}Caller calling the task remotely.
+type {{.Name}}RemoteCaller struct{}
+
+func ({{.Name}}RemoteCaller) Call({{.Params}}) ({{range .ResultTypes}}{{.}}, {{end}}error) {
+	{{.RemoteBody}}
+}
+
+// {{.Name}}CallerMockCall records the arguments of a call of {{.Name}}CallerMock.
+type {{.Name}}CallerMockCall struct {
+	{{range .Fields}}{{.Name}} {{.Type}}
+	{{end}}
+}
+
+// {{.Name}}CallerMock is the {{.Name}}Caller for tests, with programmable results and recorded calls.
+type {{.Name}}CallerMock struct {
+	// CallFunc returns the results of Call, zero values and nil error are returned if it's nil.
+	CallFunc func({{.Params}}) ({{range .ResultTypes}}{{.}}, {{end}}error)
+
+	mu    sync.Mutex
+	calls []{{.Name}}CallerMockCall
+}
+
+func (_m *{{.Name}}CallerMock) Call({{.Params}}) ({{range .Results}}{{.}}, {{end}}_err error) {
+	_m.mu.Lock()
+	_m.calls = append(_m.calls, {{.Name}}CallerMockCall{ {{- .FieldValues -}} })
+	_m.mu.Unlock()
+	if _m.CallFunc == nil {
+		return
+	}
+	return _m.CallFunc({{.CallArgs}})
+}
+
+// Calls returns the arguments of the calls so far, in order.
+func (_m *{{.Name}}CallerMock) Calls() []{{.Name}}CallerMockCall {
+	_m.mu.Lock()
+	defer _m.mu.Unlock()
+	return append([]{{.Name}}CallerMockCall(nil), _m.calls...)
+}
+
+var _ {{.Name}}Caller = {{.Name}}RemoteCaller{}
+var _ {{.Name}}Caller = (*{{.Name}}CallerMock)(nil)
+`
+
+var callerTmpl = template.Must(template.New("caller").Parse(callerTpl))
+
+// CallerDef is the template data of callerTpl.
+type CallerDef struct {
+	Name        string
+	Params      string // params with concrete types, e.g. "a int64, b int64"
+	CallArgs    string // e.g. "a, b"
+	Fields      []FieldDef
+	FieldValues string   // e.g. "A: a, B: b"
+	RemoteBody  string   // body of the remote implementation, see remoteCallBody
+	Results     []string // named results without error, e.g. "_r0 int64"
+	ResultTypes []string
+}
+
+// generateCaller generates the caller interface of the task, with the remote implementation and the mock.
+func generateCaller(buf *bytes.Buffer, method Method) {
+	def := CallerDef{Name: method.Name}
+	def.Params, def.CallArgs, def.ResultTypes = callSignature(method)
+	def.RemoteBody = remoteCallBody(method, def.CallArgs)
+	var fieldValues []string
+	for i, p := range method.Params {
+		field := FieldDef{Name: exportedFieldName(p.Name), Type: p.Type}
+		if method.IsVariadic && i == len(method.Params)-1 {
+			field.Type = "[]" + p.Type
+		}
+		def.Fields = append(def.Fields, field)
+		fieldValues = append(fieldValues, fmt.Sprintf("%s: %s", field.Name, p.Name))
+	}
+	def.FieldValues = strings.Join(fieldValues, ", ")
+	for i, t := range def.ResultTypes {
+		def.Results = append(def.Results, fmt.Sprintf("_r%d %s", i, t))
+	}
+
+	if err := callerTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// callSignature returns the params of the method with concrete types (e.g. "a int64, args ...int"),
+// the args to pass them on (e.g. "a, args...") and the result types of calling and waiting for the method,
+// without the error, as the error of the call and the error-last result are merged.
+func callSignature(method Method) (params, callArgs string, resultTypes []string) {
+	var paramList, argList []string
+	for i, p := range method.Params {
+		if method.IsVariadic && i == len(method.Params)-1 {
+			paramList = append(paramList, fmt.Sprintf("%s ...%s", p.Name, p.Type))
+			argList = append(argList, p.Name+"...")
+		} else {
+			paramList = append(paramList, fmt.Sprintf("%s %s", p.Name, p.Type))
+			argList = append(argList, p.Name)
+		}
+	}
+	for _, r := range method.Results {
+		if !r.IsError {
+			resultTypes = append(resultTypes, r.Type)
+		}
+	}
+	return strings.Join(paramList, ", "), strings.Join(argList, ", "), resultTypes
+}
+
+// remoteCallBody returns the statements calling the method remotely and returning the results,
+// with the error-last result merged into the error of the call.
+func remoteCallBody(method Method, callArgs string) string {
+	return futureGetBody(method, fmt.Sprintf("%s(%s).Remote()", method.Name, callArgs))
+}
+
+// futureGetBody returns the statements waiting for the future of a remote call of the method, e.g. "Divide(a, b).Remote()",
+// and returning the results like remoteCallBody.
+func futureGetBody(method Method, future string) string {
+	call := future + ".Get()"
+	if !method.ReturnsError() {
+		return "return " + call
+	}
+	var vars string
+	for i := range method.Results[:len(method.Results)-1] {
+		vars += fmt.Sprintf("_r%d, ", i)
+	}
+	return fmt.Sprintf("%s_taskErr, _err := %s\n\tif _err == nil {\n\t\t_err = _taskErr\n\t}\n\treturn %s_err", vars, call, vars)
+}
+
+// assignCallResults returns the statements assigning the results of the call expression to the result vars
+// (e.g. "_r0, _r1, ") and _err, with the error-last result merged into _err. indent is the indentation of the statements.
+func assignCallResults(method Method, vars, call, indent string) string {
+	if !method.ReturnsError() {
+		return fmt.Sprintf("%s_err = %s", vars, call)
+	}
+	return fmt.Sprintf("var _taskErr error\n%[1]s%[2]s_taskErr, _err = %[3]s\n%[1]sif _err == nil {\n%[1]s\t_err = _taskErr\n%[1]s}", indent, vars, call)
+}
@@ -0,0 +1,29 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCallerMocks(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Echo(prefix string, args ...int) []int { return args }
+`}, Options{Mocks: true})
+
+	require.Contains(t, code, "type DivideCaller interface {\n\tCall(a int64, b int64) (int64, int64, error)\n}")
+	require.Contains(t, code, "return Divide(a, b).Remote().Get()")
+	require.Contains(t, code, "CallFunc func(a int64, b int64) (int64, int64, error)")
+	require.Contains(t, code, "func (_m *DivideCallerMock) Call(a int64, b int64) (_r0 int64, _r1 int64, _err error) {")
+	require.Contains(t, code, "_m.calls = append(_m.calls, DivideCallerMockCall{A: a, B: b})")
+
+	require.Contains(t, code, "type EchoCallerMockCall struct {\n\tPrefix string\n\tArgs   []int\n}")
+	require.Contains(t, code, "return _m.CallFunc(prefix, args...)")
+	require.Contains(t, code, "var _ EchoCaller = (*EchoCallerMock)(nil)")
+}
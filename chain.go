@@ -0,0 +1,64 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// chainHelpers is shared by the chaining wrappers, generated once per file.
+const chainHelpers = `
+// _resultRef is implemented by the typed result references which can be passed as arguments of remote calls.
+type _resultRef interface {
+	rayFuture() any
+}
+
+// _refArgs replaces the typed result references in args with their futures,
+// so the results are passed to the remote call without being fetched by the caller.
+func _refArgs(args []any) []any {
+	for i, arg := range args {
+		if ref, ok := arg.(_resultRef); ok {
+			args[i] = ref.rayFuture()
+		}
+	}
+	return args
+}
+`
+
+// isChainable reports whether the result reference of the method can be passed as an argument of other remote calls,
+// i.e. the method has a single result which is not an error.
+func isChainable(m Method) bool {
+	return len(m.Results) == 1 && !m.Results[0].IsError
+}
+
+// collectRefTypes registers the result references of the chainable tasks and actor methods to the type constraints,
+// so the wrapper parameters of their result type accept them.
+func (g *Generator) collectRefTypes() {
+	g.typeConstraints.refTypes = make(map[string][]string)
+	register := func(name string, m Method) {
+		if isChainable(m) {
+			resType := m.Results[0].Type
+			g.typeConstraints.refTypes[resType] = append(g.typeConstraints.refTypes[resType], name+"ResultRef")
+		}
+	}
+	for _, m := range g.tasks {
+		register(m.Name, m)
+	}
+	for _, factory := range g.actorFactories {
+		for _, am := range g.actor2Methods[factory.Name] {
+			register(factory.Name+"_"+am.Name, am)
+		}
+	}
+}
+
+// generateChainableRef makes the result reference of the chainable method, whose wrapper function is named name,
+// passable as an argument of other remote calls.
+func generateChainableRef(buf *bytes.Buffer, name string, method Method) {
+	if !isChainable(method) {
+		return
+	}
+	fmt.Fprintf(buf, `
+func (r %sResultRef) rayFuture() any {
+	return r.Future1
+}
+`, name)
+}
@@ -0,0 +1,39 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateChaining(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Double(n int64) int64 { return 2 * n }
+
+func (Tasks) Divide(a, b int64) (int64, error) { return a / b, nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int64 }
+
+func (c *Counter) Get() int64 { return c.n }
+`}
+	code := generateFromSource(t, sources, Options{ResultRefs: true, Chaining: true})
+
+	require.Contains(t, code, "int64 | *Future1[int64] | ray.SharedObject[int64] | DoubleResultRef | Counter_GetResultRef\n")
+	require.Contains(t, code, `return NewRemoteFunc[*Future2[int64, error]]("Divide", _refArgs([]any{a, b}))`)
+	require.Contains(t, code, "func (r DoubleResultRef) rayFuture() any {")
+	require.NotContains(t, code, "func (r DivideResultRef) rayFuture() any {")
+	require.Contains(t, code, "func _refArgs(args []any) []any {")
+
+	code = generateFromSource(t, sources, Options{ResultRefs: true})
+	require.Contains(t, code, "int64 | *Future1[int64] | ray.SharedObject[int64]\n")
+	require.Contains(t, code, `return NewRemoteFunc[*Future2[int64, error]]("Divide", []any{a, b})`)
+}
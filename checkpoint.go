@@ -0,0 +1,173 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+	type _CounterState struct {
+		N int
+	}
+
+func (_a *Counter) Snapshot() ([]byte, error)
+func (_a *Counter) Restore(data []byte) error
+*/
+const checkpointTpl = `
+// _{{.StructName}}State is the checkpointed state of a [{{.StructName}}] actor.
+type _{{.StructName}}State struct {
+	{{- range .Fields}}
+	{{.Key}} {{.Type}}
+	{{- end}}
+}
+
+// Snapshot serializes the state of the actor, to recover it with Restore after a failure.
+func (_a *{{.StructName}}) Snapshot() ([]byte, error) {
+	return json.Marshal(_{{.StructName}}State{
+		{{- range .Fields}}
+		{{.Key}}: _a.{{.Name}},
+		{{- end}}
+	})
+}
+
+// Restore replaces the state of the actor with the state serialized by Snapshot.
+func (_a *{{.StructName}}) Restore(data []byte) error {
+	var _state _{{.StructName}}State
+	if err := json.Unmarshal(data, &_state); err != nil {
+		return fmt.Errorf("restore {{.StructName}}: %w", err)
+	}
+	{{- range .Fields}}
+	_a.{{.Name}} = _state.{{.Key}}
+	{{- end}}
+	return nil
+}
+`
+
+var checkpointTmpl = template.Must(template.New("checkpoint").Parse(checkpointTpl))
+
+// CheckpointDef is the template data of checkpointTpl.
+type CheckpointDef struct {
+	StructName string
+	Fields     []CheckpointField
+}
+
+// CheckpointField is a field of the actor state.
+type CheckpointField struct {
+	Name string // field name in the actor struct
+	Key  string // field name in the state struct, exported so it's serialized
+	Type string
+}
+
+// checkpointMethodNames are the names of the generated checkpoint methods.
+var checkpointMethodNames = []string{"Snapshot", "Restore"}
+
+// collectCheckpointActors sets the actor structs to generate the checkpoint methods for, once per generator.
+func (g *Generator) collectCheckpointActors() {
+	if g.checkpointStructs != nil {
+		return
+	}
+	g.checkpointStructs = []string{}
+	if !g.opts.Checkpoints {
+		return
+	}
+	if !g.inPackageCode() {
+		g.warnOutsidePackage("skip-checkpoint", "Skip checkpoints: the methods must be generated into package %s", g.pkg.PkgPath)
+		return
+	}
+	g.checkpointStructs = g.checkpointActors()
+}
+
+// checkpointActors returns the actor structs of the package to generate the checkpoint methods for:
+// the structs created by the rayactors factories and the `// rayactor` structs.
+// Actors created by value or already declaring a Snapshot or Restore method are skipped with a warning.
+func (g *Generator) checkpointActors() []string {
+	var names []string
+	add := func(name string) {
+		if gslice.Contains(names, name) {
+			return
+		}
+		for _, m := range analysis.Methods(g.pkg, name, g.pkg.PkgPath, g.importStore) {
+			if gslice.Contains(checkpointMethodNames, m.Name) {
+				g.warnAt(g.methodPos(m), "skip-checkpoint", "Skip checkpoints of actor %s: it already declares the %s method", name, m.Name)
+				return
+			}
+		}
+		names = append(names, name)
+	}
+	for _, factory := range g.actorFactories {
+		resType := factory.Results[0].Type
+		name := strings.TrimPrefix(strings.TrimPrefix(resType, "*"), g.sourceQualifier())
+		if _, ok := analysis.FindStateFields(g.pkg, name, g.pkg.PkgPath, g.importStore); !ok {
+			continue // not a struct of the package
+		}
+		if !strings.HasPrefix(resType, "*") {
+			g.report(g.at(g.methodPos(factory), Diagnostic{
+				Severity:   SeverityWarning,
+				Code:       "skip-checkpoint",
+				Message:    fmt.Sprintf("Skip checkpoints of actor %s: factory %s returns it by value", name, factory.Name),
+				Suggestion: "return a pointer to restore its state",
+			}))
+			continue
+		}
+		add(name)
+	}
+	for _, s := range analysis.FindStructs(g.pkg, g.opts.actorMatcher()) {
+		add(s.Name.Name)
+	}
+	return names
+}
+
+// generateCheckpoints generates the Snapshot and Restore methods of the actors, it must be called before dumping imports.
+// The generated code lives in the scanned package, as methods can't be declared on types of another package.
+func (g *Generator) generateCheckpoints(buf *bytes.Buffer) {
+	actors := g.checkpointStructs
+	if len(actors) > 0 {
+		g.importStore.AddImport("encoding/json")
+		g.importStore.AddImport("fmt")
+	}
+	for _, name := range actors {
+		fields, _ := analysis.FindStateFields(g.pkg, name, g.outputPkgPath, g.importStore)
+		def := CheckpointDef{StructName: name}
+		for _, f := range fields {
+			key := exportedFieldName(f.Name)
+			if gslice.Any(def.Fields, func(cf CheckpointField) bool { return cf.Key == key }) {
+				g.warnAt(g.fieldPos(name, f.Name), "skip-checkpoint", "Skip state field %s.%s: conflicts with another state field", name, f.Name)
+				continue
+			}
+			def.Fields = append(def.Fields, CheckpointField{Name: f.Name, Key: key, Type: f.Type})
+		}
+		if err := checkpointTmpl.Execute(buf, def); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// checkpointMethods returns the checkpoint methods of the actor struct as seen by the driver,
+// so the remote callers are generated like for the declared actor methods.
+func (g *Generator) checkpointMethods(structName string) []Method {
+	if !gslice.Contains(g.checkpointStructs, structName) {
+		return nil
+	}
+	bytesRes := Result{Type: "[]byte", CanonicalType: "[]byte"}
+	errRes := Result{Type: "error", CanonicalType: "error", IsError: true}
+	return []Method{
+		{
+			ReceiverType: "*" + structName,
+			Name:         "Snapshot",
+			Results:      []Result{bytesRes, errRes},
+			Doc:          "// Snapshot serializes the state of the actor, to recover it with Restore after a failure.",
+		},
+		{
+			ReceiverType: "*" + structName,
+			Name:         "Restore",
+			Params:       []Param{{Name: "data", Type: "[]byte", CanonicalType: "[]byte", IdentifiableType: "slice_byte"}},
+			Results:      []Result{errRes},
+			Doc:          "// Restore replaces the state of the actor with the state serialized by Snapshot.",
+		},
+	}
+}
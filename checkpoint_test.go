@@ -0,0 +1,58 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCheckpoints(t *testing.T) {
+	sources := map[string]string{"actors": `package mypkg
+
+import "time"
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n: n} }
+
+func (Actors) Timer() Timer { return Timer{} }
+
+type Counter struct {
+	n     int             ` + "`goray:\"state\"`" + `
+	Seen  map[string]bool ` + "`goray:\"state\"`" + `
+	Cache []int
+}
+
+func (c *Counter) Incr(n int) int { c.n += n; return c.n }
+
+type Timer struct{ Start time.Time }
+
+// rayactor
+type Cache struct {
+	Items map[string]string
+	hits  int
+}
+
+// rayactor
+type Store struct{ Data []byte }
+
+func (s *Store) Snapshot() ([]byte, error) { return s.Data, nil }
+`}
+	code := generateFromSource(t, sources, Options{Checkpoints: true})
+
+	require.Contains(t, code, "type _CounterState struct {\n\tN    int\n\tSeen map[string]bool\n}")
+	require.Contains(t, code, "func (_a *Counter) Snapshot() ([]byte, error) {")
+	require.Contains(t, code, "return json.Marshal(_CounterState{\n\t\tN:    _a.n,\n\t\tSeen: _a.Seen,\n\t})")
+	require.Contains(t, code, "func (_a *Counter) Restore(data []byte) error {")
+	require.Contains(t, code, "_a.n = _state.N\n\t_a.Seen = _state.Seen\n\treturn nil")
+	require.Contains(t, code, "func Counter_Snapshot(_actor *ActorCounter) *RemoteFunc[*Future2[[]byte, error]] {")
+	require.Contains(t, code, "(_actor *ActorCounter, data slice_byte_0) *RemoteFunc[*Future1[error]] {")
+	require.NotContains(t, code, "_TimerState") // created by value
+
+	require.Contains(t, code, "type _CacheState struct {\n\tItems map[string]string\n}")
+	require.Contains(t, code, "func (_actor *CacheActorHandle) Snapshot() *RemoteFunc[*Future2[[]byte, error]] {")
+	require.NotContains(t, code, "_StoreState") // declares its own Snapshot
+
+	require.NotContains(t, generateFromSource(t, sources, Options{}), "_CounterState")
+}
@@ -0,0 +1,69 @@
+package goraygen
+
+import (
+	"bytes"
+	"text/template"
+)
+
+/*
+	type TasksClient interface {
+		Divide(a int64, b int64) (int64, int64, error)
+	}
+
+	type TasksRemoteClient struct{}
+
+func (TasksRemoteClient) Divide(a int64, b int64) (int64, int64, error)
+*/
+const clientTpl = `
+// {{.Name}}Client covers the calls of the tasks in [{{.DocLink}}], each calls the task and waits for the results.
+// It's implemented by {{.Name}}RemoteClient, and can be implemented by fakes in tests.
+type {{.Name}}Client interface {
+	{{- range .Methods}}
+	{{.Name}}({{.Params}}) ({{range .ResultTypes}}{{.}}, {{end}}error)
+	{{- end}}
+}
+
+// {{.Name}}RemoteClient is the {{.Name}}Client calling the tasks remotely.
+type {{.Name}}RemoteClient struct{}
+{{range .Methods}}
+func ({{$.Name}}RemoteClient) {{.Name}}({{.Params}}) ({{range .ResultTypes}}{{.}}, {{end}}error) {
+	{{.RemoteBody}}
+}
+{{end}}
+var _ {{.Name}}Client = {{.Name}}RemoteClient{}
+`
+
+var clientTmpl = template.Must(template.New("client").Parse(clientTpl))
+
+// ClientDef is the template data of clientTpl.
+type ClientDef struct {
+	Name    string // name of the tasks struct
+	DocLink string
+	Methods []ClientMethodDef
+}
+
+// ClientMethodDef is a method of the client interface.
+type ClientMethodDef struct {
+	Name        string
+	Params      string
+	CallArgs    string
+	ResultTypes []string
+	RemoteBody  string
+}
+
+// generateClient generates the client interface covering all tasks, and its remote implementation.
+func (g *Generator) generateClient(buf *bytes.Buffer, docQualifier string) {
+	if g.tasksStruct == "" || len(g.tasks) == 0 {
+		return
+	}
+	def := ClientDef{Name: g.tasksStruct, DocLink: docQualifier + g.tasksStruct}
+	for _, m := range g.tasks {
+		md := ClientMethodDef{Name: m.Name}
+		md.Params, md.CallArgs, md.ResultTypes = callSignature(m)
+		md.RemoteBody = remoteCallBody(m, md.CallArgs)
+		def.Methods = append(def.Methods, md)
+	}
+	if err := clientTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
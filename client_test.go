@@ -0,0 +1,33 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateClient(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type MyTasks struct{}
+
+func (MyTasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (MyTasks) Open(name string) (int, error) { return 0, nil }
+
+func (MyTasks) Echo(args ...int) []int { return args }
+`}, Options{Client: true})
+
+	require.Contains(t, code, `type MyTasksClient interface {
+	Divide(a int64, b int64) (int64, int64, error)
+	Open(name string) (int, error)
+	Echo(args ...int) ([]int, error)
+}`)
+	require.Contains(t, code, "func (MyTasksRemoteClient) Divide(a int64, b int64) (int64, int64, error) {\n\treturn Divide(a, b).Remote().Get()\n}")
+	require.Contains(t, code, "return Echo(args...).Remote().Get()")
+	// the error-last result is merged into the error of the call
+	require.Contains(t, code, "_r0, _taskErr, _err := Open(name).Remote().Get()")
+	require.Contains(t, code, "return _r0, _err")
+	require.Contains(t, code, "var _ MyTasksClient = MyTasksRemoteClient{}")
+}
@@ -0,0 +1,10 @@
+// Command goraygen generates the type-safe wrappers of the Ray tasks and actors of a package:
+//
+//	goraygen [flags] <package-path>
+//
+// See github.com/ray4go/goraygen for the flags and the library API.
+package main
+
+import "github.com/ray4go/goraygen"
+
+func main() { goraygen.Main() }
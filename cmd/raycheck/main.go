@@ -0,0 +1,12 @@
+// Command raycheck runs the raycheck analyzer, standalone or as a vet tool:
+//
+//	raycheck ./...
+//	go vet -vettool=$(which raycheck) ./...
+package main
+
+import (
+	"github.com/ray4go/goraygen/analysis/raycheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(raycheck.Analyzer) }
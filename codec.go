@@ -0,0 +1,443 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// Codec is the serialization of the values of a type in the remote calls.
+type Codec string
+
+const (
+	CodecMsgpack Codec = "msgpack" // the native serialization of go-ray, nothing is generated
+	CodecGob     Codec = "gob"
+	CodecJSON    Codec = "json"
+)
+
+// ParseCodec parses the -codec flag value and the //goray:codec directive argument, empty means CodecMsgpack.
+func ParseCodec(s string) (Codec, error) {
+	switch c := Codec(s); c {
+	case "":
+		return CodecMsgpack, nil
+	case CodecMsgpack, CodecGob, CodecJSON:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid codec %q, expect %s, %s or %s", s, CodecMsgpack, CodecGob, CodecJSON)
+}
+
+/*
+With -codec gob, or the directive on the type:
+
+	//goray:codec gob
+	type Point struct{ X, Y int }
+
+the types of the package in the task and actor signatures are encoded with their codec,
+as go-ray carries the values implementing encoding.BinaryMarshaler as their bytes:
+
+	func (_v Point) MarshalBinary() ([]byte, error)
+	func (_v *Point) UnmarshalBinary(data []byte) error
+
+The types implementing a custom marshaler are encoded with it instead, see Marshaler. The types of underlying type []byte
+are carried as their bytes, without a gob or json round-trip (e.g. base64 strings).
+The structs with fields of mapped types (see TypeMapping) are encoded as a _PointWire struct with the mapped fields.
+*/
+const codecTpl = `
+{{- if eq .Marshaler "proto.Message"}}
+// MarshalBinary encodes the message with proto.Marshal, instead of the {{.Codec}} codec of [{{.TypeName}}].
+func (_v *{{.TypeName}}) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(_v)
+}
+
+// UnmarshalBinary decodes the message encoded by MarshalBinary.
+func (_v *{{.TypeName}}) UnmarshalBinary(data []byte) error {
+	return proto.Unmarshal(data, _v)
+}
+{{- else if eq .Marshaler "json.Marshaler"}}
+// MarshalBinary encodes the value with its MarshalJSON method, instead of the {{.Codec}} codec of [{{.TypeName}}].
+func (_v {{.TypeName}}) MarshalBinary() ([]byte, error) {
+	return _v.MarshalJSON()
+}
+
+// UnmarshalBinary decodes the value encoded by MarshalBinary with encoding/json.
+func (_v *{{.TypeName}}) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, _v)
+}
+{{- else if .Bytes}}
+// MarshalBinary returns the bytes as is, carried without the {{.Codec}} codec of [{{.TypeName}}].
+func (_v {{.TypeName}}) MarshalBinary() ([]byte, error) {
+	return _v, nil
+}
+
+// UnmarshalBinary sets the value to a copy of the bytes.
+func (_v *{{.TypeName}}) UnmarshalBinary(data []byte) error {
+	*_v = append((*_v)[:0], data...)
+	return nil
+}
+{{- else if .WireFields}}
+// _{{.TypeName}}Wire has the exported fields of [{{.TypeName}}], the ones of the mapped types as their mappings.
+type _{{.TypeName}}Wire struct {
+	{{- range .WireFields}}
+	{{if not .Embedded}}{{.Name}} {{end}}{{.Type}}{{if .Tag}} {{.Tag}}{{end}}
+	{{- end}}
+}
+
+// MarshalBinary encodes the value with encoding/{{.Codec}}, the codec of [{{.TypeName}}] in the remote calls.
+func (_v {{.TypeName}}) MarshalBinary() ([]byte, error) {
+	_w := _{{.TypeName}}Wire{
+		{{- range .WireFields}}
+		{{.Name}}: {{.ToWire}},
+		{{- end}}
+	}
+	{{- if eq .Codec "gob"}}
+	var _buf bytes.Buffer
+	if err := gob.NewEncoder(&_buf).Encode(_w); err != nil {
+		return nil, fmt.Errorf("encode {{.TypeName}}: %w", err)
+	}
+	return _buf.Bytes(), nil
+	{{- else}}
+	return json.Marshal(_w)
+	{{- end}}
+}
+
+// UnmarshalBinary decodes the value encoded by MarshalBinary.
+func (_v *{{.TypeName}}) UnmarshalBinary(data []byte) error {
+	var _w _{{.TypeName}}Wire
+	{{- if eq .Codec "gob"}}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&_w); err != nil {
+		return fmt.Errorf("decode {{.TypeName}}: %w", err)
+	}
+	{{- else}}
+	if err := json.Unmarshal(data, &_w); err != nil {
+		return err
+	}
+	{{- end}}
+	{{- range .WireFields}}
+	_v.{{.Name}} = {{.FromWire}}
+	{{- end}}
+	return nil
+}
+{{- else if eq .Codec "gob"}}
+// _{{.TypeName}}Codec has the underlying type of [{{.TypeName}}] without its methods, so gob doesn't call MarshalBinary again.
+type _{{.TypeName}}Codec {{.TypeName}}
+
+// MarshalBinary encodes the value with encoding/gob, the codec of [{{.TypeName}}] in the remote calls.
+func (_v {{.TypeName}}) MarshalBinary() ([]byte, error) {
+	var _buf bytes.Buffer
+	if err := gob.NewEncoder(&_buf).Encode(_{{.TypeName}}Codec(_v)); err != nil {
+		return nil, fmt.Errorf("encode {{.TypeName}}: %w", err)
+	}
+	return _buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the value encoded by MarshalBinary.
+func (_v *{{.TypeName}}) UnmarshalBinary(data []byte) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode((*_{{.TypeName}}Codec)(_v)); err != nil {
+		return fmt.Errorf("decode {{.TypeName}}: %w", err)
+	}
+	return nil
+}
+{{- else}}
+// MarshalBinary encodes the value with encoding/json, the codec of [{{.TypeName}}] in the remote calls.
+func (_v {{.TypeName}}) MarshalBinary() ([]byte, error) {
+	return json.Marshal(_v)
+}
+
+// UnmarshalBinary decodes the value encoded by MarshalBinary.
+func (_v *{{.TypeName}}) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, _v)
+}
+{{- end}}
+`
+
+// gobRegisterTpl registers the gob types, so their values can be encoded in interface values, e.g. `any` fields.
+const gobRegisterTpl = `
+func init() {
+	{{- range .}}
+	gob.Register({{.Zero}})
+	{{- end}}
+}
+`
+
+var (
+	codecTmpl       = template.Must(template.New("codec").Parse(codecTpl))
+	gobRegisterTmpl = template.Must(template.New("gobRegister").Parse(gobRegisterTpl))
+)
+
+const codecDirective = "codec"
+
+// CodecType is a type of the package encoded with a codec other than CodecMsgpack, the template data of codecTpl.
+type CodecType struct {
+	TypeName  string
+	Codec     Codec
+	Marshaler Marshaler // the custom marshaler used instead of the codec, if any
+	Zero      string    // the zero value expression, e.g. "Point{}"
+	Bytes     bool      // the underlying type is []byte, carried as is
+	// WireFields are the exported fields of the struct encoded instead of it, if some are of mapped types.
+	WireFields []CodecField
+}
+
+// CodecField is a field of the struct encoded by a codec, see CodecType.WireFields.
+type CodecField struct {
+	Name     string
+	Type     string // the mapping of the field type if mapped, see TypeMapping
+	Tag      string // the tag literal, e.g. "`json:\"at\"`"
+	Embedded bool
+	ToWire   string // the expression of the field value to encode, e.g. "_v.At.Unix()"
+	FromWire string // the expression of the decoded field value, e.g. "time.Unix(_w.At, 0)"
+}
+
+// Marshaler is a custom serialization implemented by a type, used instead of the reflection-based encoding of the codecs.
+type Marshaler string
+
+const (
+	MarshalerBinary Marshaler = "encoding.BinaryMarshaler" // used by go-ray as is, nothing is generated
+	MarshalerJSON   Marshaler = "json.Marshaler"
+	MarshalerProto  Marshaler = "proto.Message"
+)
+
+// marshalerMethods are the methods declared by the types implementing the marshalers,
+// with their signatures rendered by types.TypeString without parameter names.
+var marshalerMethods = []struct {
+	marshaler Marshaler
+	methods   map[string]string // name -> signature
+}{
+	{MarshalerBinary, map[string]string{"MarshalBinary": "func() ([]byte, error)", "UnmarshalBinary": "func([]byte) error"}},
+	{MarshalerProto, map[string]string{"ProtoReflect": "func() google.golang.org/protobuf/reflect/protoreflect.Message"}},
+	{MarshalerJSON, map[string]string{"MarshalJSON": "func() ([]byte, error)"}},
+}
+
+// customMarshaler detects the custom marshaler implemented by the type of the package, from the methods declared
+// in its source files (the generated methods don't count), empty if none.
+func (g *Generator) customMarshaler(named *types.Named) Marshaler {
+	for _, mm := range marshalerMethods {
+		implements := true
+		for name, signature := range mm.methods {
+			obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, g.pkg.Types, name)
+			fn, ok := obj.(*types.Func)
+			if !ok || analysis.IsGeneratedPos(g.pkg, fn.Pos()) || !sameSignature(fn, signature) {
+				implements = false
+				break
+			}
+		}
+		if implements {
+			return mm.marshaler
+		}
+	}
+	return ""
+}
+
+// sameSignature reports whether the signature of the function is the signature, rendered without parameter names.
+func sameSignature(fn *types.Func, signature string) bool {
+	sig := fn.Type().(*types.Signature)
+	params := make([]*types.Var, sig.Params().Len())
+	for i := range params {
+		params[i] = types.NewParam(token.NoPos, nil, "", sig.Params().At(i).Type())
+	}
+	unnamed := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), sig.Results(), sig.Variadic())
+	return types.TypeString(unnamed, nil) == signature
+}
+
+// prepareCodecs collects the types encoded with a codec into g.codecTypes, once per generator:
+// the types of the package in the task and actor signatures with Options.Codec,
+// and the types with the //goray:codec directive, which overrides Options.Codec.
+func (g *Generator) prepareCodecs() {
+	if g.codecTypes != nil {
+		return
+	}
+	g.codecTypes = []CodecType{}
+	var sigTypes []string // canonical types of the params and results
+	if g.opts.Codec != "" && g.opts.Codec != CodecMsgpack {
+		for _, m := range g.signatureMethods() {
+			sigTypes = append(sigTypes, m.CanonicalTypes()...)
+		}
+	}
+	scope := g.pkg.Types.Scope()
+	for _, name := range scope.Names() { // sorted
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		codec, ok := g.typeCodec(name, sigTypes)
+		if !ok || codec == CodecMsgpack {
+			continue
+		}
+		if !g.inPackageCode() {
+			g.warnOutsidePackage("skip-codec", "Skip codecs: the MarshalBinary methods must be generated into package %s", g.pkg.PkgPath)
+			g.codecTypes = []CodecType{}
+			return
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || tn.IsAlias() {
+			g.warnAt(g.typePos(name), "skip-codec", "Skip codec of %s: it's not a defined type", name)
+			continue
+		}
+		if named.TypeParams().Len() > 0 {
+			g.warnAt(g.typePos(name), "skip-codec", "Skip codec of %s: generic types are not supported", name)
+			continue
+		}
+		if types.IsInterface(named) {
+			g.warnAt(g.typePos(name), "skip-codec", "Skip codec of %s: interface values are encoded with the codec of their dynamic type", name)
+			continue
+		}
+		if m, ok := g.opts.signatureTypeMappings().Lookup(named); ok {
+			g.warnAt(g.typePos(name), "skip-codec", "Skip codec of %s: it's carried as %s, its type mapping", name, m.Name)
+			continue
+		}
+		marshaler := g.customMarshaler(named)
+		switch marshaler {
+		case MarshalerBinary:
+			g.logger.Debug("Type encoded with its MarshalBinary method", "type", name)
+			continue
+		case MarshalerJSON, MarshalerProto:
+			g.logger.Debug("Type encoded with its marshaler instead of the codec", "type", name, "marshaler", marshaler, "codec", codec)
+		}
+		declared, found := gslice.Find([]string{"MarshalBinary", "UnmarshalBinary"}, func(method string) bool {
+			obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, g.pkg.Types, method)
+			return obj != nil && !analysis.IsGeneratedPos(g.pkg, obj.Pos())
+		}).Get()
+		if found {
+			g.warnAt(g.typePos(name), "skip-codec", "Skip codec of %s: it already declares the %s method", name, declared)
+			continue
+		}
+		zero := fmt.Sprintf("*new(%s)", name)
+		if _, ok := named.Underlying().(*types.Struct); ok {
+			zero = name + "{}"
+		}
+		g.codecTypes = append(g.codecTypes, CodecType{TypeName: name, Codec: codec, Marshaler: marshaler, Zero: zero,
+			Bytes: marshaler == "" && isByteSlice(named)})
+	}
+}
+
+// typeCodec returns the codec of the type of the package: the codec of its //goray:codec directive if any,
+// otherwise Options.Codec if the type is in sigTypes, the canonical types of the task and actor signatures.
+// It returns false if the type has no codec.
+func (g *Generator) typeCodec(name string, sigTypes []string) (Codec, bool) {
+	for _, d := range analysis.ParseDirectives(analysis.TypeDoc(g.pkg, name)) {
+		if d.Name != codecDirective {
+			continue
+		}
+		if len(d.Args) != 1 {
+			g.warnAt(g.typePos(name), "invalid-directive", "%s: invalid //goray:codec, it should be like `//goray:codec gob`", name)
+			return "", false
+		}
+		codec, err := ParseCodec(d.Args[0])
+		if err != nil {
+			g.warnAt(g.typePos(name), "invalid-directive", "%s: invalid //goray:codec: %v", name, err)
+			return "", false
+		}
+		return codec, true
+	}
+	typeRe := regexp.MustCompile(`(^|[^\w./])` + regexp.QuoteMeta(g.pkg.PkgPath+"."+name) + `\b`)
+	if gslice.Any(sigTypes, typeRe.MatchString) {
+		return g.opts.Codec, true
+	}
+	return "", false
+}
+
+// signatureMethods returns the methods whose params and results are carried by the remote calls:
+// the tasks, the actor factories without their results (the actors stay on the worker) and the methods of the actors.
+func (g *Generator) signatureMethods() []Method {
+	methods := append([]Method{}, g.tasks...)
+	for _, factory := range g.actorFactories {
+		factory.Results = nil
+		methods = append(methods, factory)
+	}
+	actors := make([]string, 0, len(g.actor2Methods))
+	for actor := range g.actor2Methods {
+		actors = append(actors, actor)
+	}
+	sort.Strings(actors)
+	for _, actor := range actors {
+		methods = append(methods, g.actor2Methods[actor]...)
+	}
+	for _, s := range analysis.FindStructs(g.pkg, g.opts.actorMatcher()) {
+		methods = append(methods, analysis.Methods(g.pkg, s.Name.Name, g.outputPkgPath, g.importStore)...)
+	}
+	return methods
+}
+
+// generateCodecs generates the MarshalBinary and UnmarshalBinary methods of the types encoded with a codec,
+// it must be called before dumping imports. Like the checkpoint methods, they are generated in the scanned package.
+func (g *Generator) generateCodecs(buf *bytes.Buffer) {
+	var gobTypes []CodecType
+	for _, t := range g.codecTypes {
+		switch {
+		case t.Bytes: // no imports
+		case t.Marshaler == MarshalerProto:
+			g.importStore.AddImport("google.golang.org/protobuf/proto")
+		case t.Marshaler == MarshalerJSON || t.Codec == CodecJSON:
+			g.importStore.AddImport("encoding/json")
+		default: // the gob codec
+			g.importStore.AddImport("bytes")
+			g.importStore.AddImport("fmt")
+		}
+		if t.Codec == CodecGob {
+			g.importStore.AddImport("encoding/gob")
+			gobTypes = append(gobTypes, t)
+		}
+		if t.Marshaler == "" {
+			t.WireFields = g.codecWireFields(t.TypeName)
+		}
+		if err := codecTmpl.Execute(buf, t); err != nil {
+			panic(err)
+		}
+	}
+	if len(gobTypes) == 0 {
+		return
+	}
+	if err := gobRegisterTmpl.Execute(buf, gobTypes); err != nil {
+		panic(err)
+	}
+}
+
+// codecWireFields returns the exported fields of the struct of the package encoded instead of it by its codec,
+// with the fields of the mapped types converted to their mappings, nil if none is mapped or it's not a struct.
+func (g *Generator) codecWireFields(name string) []CodecField {
+	st, ok := g.pkg.Types.Scope().Lookup(name).Type().Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	mappings := g.opts.typeMappings()
+	var fields []CodecField
+	mapped := false
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Exported() {
+			continue
+		}
+		field := CodecField{
+			Name:     f.Name(),
+			Type:     analysis.TypeName(f.Type(), g.pkg.PkgPath, g.importStore),
+			Embedded: f.Embedded(),
+			ToWire:   "_v." + f.Name(),
+			FromWire: "_w." + f.Name(),
+		}
+		if tag := st.Tag(i); tag != "" {
+			field.Tag = "`" + tag + "`"
+			if strings.Contains(tag, "`") {
+				field.Tag = strconv.Quote(tag)
+			}
+		}
+		if m, ok := mappings.Lookup(f.Type()); ok && !f.Embedded() {
+			field.Type = m.Render(g.importStore)
+			field.ToWire = m.ToWireExpr(field.ToWire, g.importStore)
+			field.FromWire = m.FromWireExpr(field.FromWire, f.Type(), g.pkg.PkgPath, g.importStore)
+			mapped = true
+		}
+		fields = append(fields, field)
+	}
+	if !mapped {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,113 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCodec(t *testing.T) {
+	codec, err := ParseCodec("")
+	require.NoError(t, err)
+	require.Equal(t, CodecMsgpack, codec)
+
+	codec, err = ParseCodec("gob")
+	require.NoError(t, err)
+	require.Equal(t, CodecGob, codec)
+
+	_, err = ParseCodec("xml")
+	require.Error(t, err)
+}
+
+func TestGenerateCodecs(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Move(p Point, path []Point) IDs { return nil }
+
+func (Tasks) Load(id string) (*Blob, error) { return nil, nil }
+
+func (Tasks) Hash(c Chunk) Digest { return Digest{} }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(cfg Config) *Counter { return &Counter{} }
+
+type Point struct{ X, Y int }
+
+type IDs []string
+
+type Chunk []byte
+
+type Digest [32]byte
+
+//goray:codec json
+type Config struct{ Name string }
+
+//goray:codec msgpack
+type Blob struct{ Data []byte }
+
+//goray:codec json
+type Header struct{ Key string }
+
+type Counter struct{ n int }
+`}
+	code := generateFromSource(t, sources, Options{Codec: CodecGob})
+
+	require.Contains(t, code, "type _PointCodec Point")
+	require.Contains(t, code, "func (_v Point) MarshalBinary() ([]byte, error) {")
+	require.Contains(t, code, "gob.NewEncoder(&_buf).Encode(_PointCodec(_v))")
+	require.Contains(t, code, "func (_v *Point) UnmarshalBinary(data []byte) error {")
+	require.Contains(t, code, "func (_v IDs) MarshalBinary() ([]byte, error) {")
+	require.Contains(t, code, "func (_v Chunk) MarshalBinary() ([]byte, error) {\n\treturn _v, nil\n}") // as is
+	require.Contains(t, code, "*_v = append((*_v)[:0], data...)")
+	require.Contains(t, code, "func (_v Digest) MarshalBinary() ([]byte, error) {\n\tvar _buf bytes.Buffer")
+	require.Contains(t, code, "func init() {\n\tgob.Register(*new(Chunk))\n\tgob.Register(*new(Digest))\n\tgob.Register(*new(IDs))\n\tgob.Register(Point{})\n}")
+	require.Contains(t, code, "func (_v Config) MarshalBinary() ([]byte, error) {\n\treturn json.Marshal(_v)\n}")
+	require.Contains(t, code, "func (_v Header) MarshalBinary() ([]byte, error) {") // by directive only
+	require.NotContains(t, code, "func (_v Blob) MarshalBinary()")
+	require.NotContains(t, code, "func (_v Counter) MarshalBinary()") // actors stay on the worker
+
+	code = generateFromSource(t, sources, Options{})
+	require.NotContains(t, code, "func (_v Point) MarshalBinary()")
+	require.Contains(t, code, "func (_v Config) MarshalBinary() ([]byte, error) {")
+}
+
+func TestGenerateCodecsCustomMarshalers(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Convert(a Amount, d Digest, v Version) Half { return Half{} }
+
+type Amount struct{ cents int64 }
+
+func (a Amount) MarshalJSON() ([]byte, error) { return nil, nil }
+
+type Digest [32]byte
+
+func (d Digest) MarshalBinary() ([]byte, error) { return d[:], nil }
+
+func (d *Digest) UnmarshalBinary(data []byte) error { return nil }
+
+type Version string
+
+func (v Version) MarshalJSON(indent bool) ([]byte, error) { return nil, nil }
+
+type Half struct{ Data []byte }
+
+func (h Half) MarshalBinary() ([]byte, error) { return h.Data, nil }
+`}, Options{Codec: CodecGob})
+
+	require.Contains(t, code, "func (_v Amount) MarshalBinary() ([]byte, error) {\n\treturn _v.MarshalJSON()\n}")
+	require.Contains(t, code, "func (_v *Amount) UnmarshalBinary(data []byte) error {\n\treturn json.Unmarshal(data, _v)\n}")
+	require.NotContains(t, code, "_AmountCodec")
+	require.Contains(t, code, "gob.Register(Amount{})")
+	require.NotContains(t, code, "func (_v Digest) MarshalBinary()") // used as is
+	require.Contains(t, code, "type _VersionCodec Version")          // not a json.Marshaler
+	require.NotContains(t, code, "func (_v Half) MarshalBinary()")   // declares MarshalBinary only
+}
@@ -0,0 +1,566 @@
+package goraygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/ray4go/goraygen/analysis/raycheck"
+	goanalysis "golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// modulePath is the path of the goraygen module, to find its version in the build info.
+const modulePath = "github.com/ray4go/goraygen"
+
+// command is a subcommand of goraygen, run with the arguments after its name.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, w io.Writer) error
+}
+
+// commands are the subcommands, "generate" is the default one when the first argument isn't a command.
+var commands = []command{
+	{"generate", "generate the wrappers of the tasks and actors of a package (the default command)", runGenerateCommand},
+	{"list", "list the discovered tasks and actors with their methods", runListCommand},
+	{"check", "validate the annotations and the signatures of the tasks and actors, without generating", runCheckCommand},
+	{"analyze", "write the discovered model of a package as JSON", runAnalyzeCommand},
+	{"graph", "write the dependency graph of the tasks as DOT or JSON", runGraphCommand},
+	{"clean", "remove the generated files", runCleanCommand},
+	{"version", "print the version of goraygen", runVersionCommand},
+}
+
+// Main runs the goraygen command with the command-line arguments, see cmd/goraygen.
+func Main() {
+	log.SetFlags(0)
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		printUsage(os.Stdout)
+		return
+	}
+	cmd := commands[0]
+	if len(args) > 0 {
+		if i := slices.IndexFunc(commands, func(c command) bool { return c.name == args[0] }); i >= 0 {
+			cmd, args = commands[i], args[1:]
+		}
+	}
+	err := cmd.run(args, os.Stdout)
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return
+	case !errors.Is(err, errReported):
+		log.Print(err)
+	}
+	os.Exit(exitCode(err))
+}
+
+// The exit codes of the goraygen command by class of failure, for the scripts wrapping it.
+const (
+	exitUsage    = 1 // invalid flags or arguments, or a failure of no other class
+	exitLoad     = 2 // the package can't be loaded, parsed or type-checked
+	exitAnalysis = 3 // error diagnostics, e.g. invalid annotations or warnings with -strict
+	exitStale    = 4 // generated files out of date, see the check command
+	exitWrite    = 5 // an output can't be written
+)
+
+// exitError is an error with the exit code of its class.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode classifies the error with the exit code, nil stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code, err}
+}
+
+// exitCode returns the exit code of the error of a command: the lowest code of the classified errors in its tree,
+// i.e. the earliest failing phase of the joined errors, exitUsage if none is classified.
+func exitCode(err error) int {
+	if code := classifiedExitCode(err); code > 0 {
+		return code
+	}
+	return exitUsage
+}
+
+func classifiedExitCode(err error) int {
+	var code int
+	lower := func(c int) {
+		if c > 0 && (code == 0 || c < code) {
+			code = c
+		}
+	}
+	if e, ok := err.(*exitError); ok {
+		lower(e.code)
+	}
+	switch err := err.(type) {
+	case interface{ Unwrap() error }:
+		lower(classifiedExitCode(err.Unwrap()))
+	case interface{ Unwrap() []error }:
+		for _, e := range err.Unwrap() {
+			lower(classifiedExitCode(e))
+		}
+	}
+	return code
+}
+
+// parseFlags parses the flags of a command. The flag package prints the invalid flags with the usage,
+// so they are an already reported usage error.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return withExitCode(exitUsage, errReported)
+	}
+	return err
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: goraygen <command> [flags] <package-path>")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w, "\nRun `goraygen <command> -h` for the flags of a command.")
+}
+
+// Version returns the version of the goraygen module in the build info of the binary, "(devel)" if unknown.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+func runVersionCommand(args []string, w io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("version takes no arguments")
+	}
+	_, err := fmt.Fprintf(w, "goraygen %s\n", Version())
+	return err
+}
+
+// discover loads the package and collects its tasks and actors with the options, without generating.
+func discover(packagePath string, opts Options) (*Generator, error) {
+	g := NewGenerator(opts)
+	if err := g.loadPackage(packagePath); err != nil {
+		return nil, err
+	}
+	pkgOpts, err := g.opts.forPackage(g.pkg)
+	if err != nil {
+		return nil, err
+	}
+	g.opts = pkgOpts
+	g.outputPkgName, g.outputPkgPath = g.pkg.Name, g.pkg.PkgPath
+	leave := g.phase(phaseDiscovery)
+	g.collectWorkloads()
+	g.collectActorMethods()
+	g.collectActorHandles()
+	leave()
+	g.logPhases()
+	return g, nil
+}
+
+// targetList is the output of the list command.
+type targetList struct {
+	Package string         `json:"package"`
+	Structs []targetStruct `json:"structs"`
+}
+
+// targetStruct is a target struct of the list command, with its methods.
+type targetStruct struct {
+	Kind    string         `json:"kind"` // tasks, actors, actor (the methods of an actor factory) or actor handle
+	Name    string         `json:"name"`
+	Methods []targetMethod `json:"methods"`
+}
+
+// targetMethod is a method of a target struct, with the reason it's skipped if it is.
+type targetMethod struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"` // see Method.String
+	Skipped   string `json:"skipped,omitempty"`
+	IOAdapter string `json:"ioAdapter,omitempty"` // the FooIO method of the IO adapter of a task with IO params
+	Stream    string `json:"stream,omitempty"`    // the FooStream iterator of a method with a channel result, see -streaming
+}
+
+// targetList lists the discovered tasks and actors, with the methods dropped by the discovery.
+func (g *Generator) targetList() targetList {
+	list := targetList{Package: g.pkg.PkgPath, Structs: []targetStruct{}}
+	add := func(kind, name, structName string, methods []Method) {
+		s := targetStruct{Kind: kind, Name: name, Methods: []targetMethod{}}
+		for _, m := range methods {
+			s.Methods = append(s.Methods, targetMethod{Name: m.Name, Signature: m.String()})
+		}
+		if kind == "tasks" {
+			for _, m := range g.ioTasks {
+				s.Methods = append(s.Methods, targetMethod{Name: m.Name, Signature: m.String(), IOAdapter: m.Name + "IO"})
+			}
+		}
+		if kind != "actors" {
+			streamPrefix := ""
+			if kind == "actor" {
+				streamPrefix = name + "_"
+			}
+			for _, m := range g.streamsOf(structName) {
+				s.Methods = append(s.Methods, targetMethod{Name: m.Name, Signature: m.String(), Stream: streamPrefix + m.Name + "Stream"})
+			}
+		}
+		for i, m := range g.skipped {
+			// the methods of an actor struct with a factory and a handle are discovered twice
+			duplicate := slices.ContainsFunc(g.skipped[:i], func(s skippedMethod) bool {
+				return s.ReceiverType == m.ReceiverType && s.Name == m.Name
+			})
+			if !duplicate && strings.TrimPrefix(m.ReceiverType, "*") == structName {
+				s.Methods = append(s.Methods, targetMethod{Name: m.Name, Signature: m.String(), Skipped: m.Reason})
+			}
+		}
+		list.Structs = append(list.Structs, s)
+	}
+	if g.tasksStruct != "" {
+		add("tasks", g.tasksStruct, g.tasksStruct, g.tasks)
+	}
+	if g.actorsStruct != "" {
+		add("actors", g.actorsStruct, g.actorsStruct, g.actorFactories)
+		for _, factory := range g.actorFactories {
+			add("actor", factory.Name, strings.TrimPrefix(factory.Results[0].Type, "*"), g.actor2Methods[factory.Name])
+		}
+	}
+	for _, h := range g.actorHandles {
+		add("actor handle", h.StructName, h.StructName, h.Methods)
+	}
+	return list
+}
+
+// runListCommand runs the list command, writing the discovered tasks and actors of the package with their methods,
+// including the ones skipped and why, with the flags of the generation.
+func runListCommand(args []string, w io.Writer) (err error) {
+	fs, options := generateFlagSet("list")
+	asJSON := fs.Bool("json", false, "write the list as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goraygen list [-json] [flags of generate] <package-path>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expect one package path")
+	}
+	opts, _, err := options(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	finish, err := startDiagnostics(&opts)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+	g, err := discover(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	list := g.targetList()
+	if *asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, list.Package)
+	for _, s := range list.Structs {
+		fmt.Fprintf(&buf, "  %s %s\n", s.Kind, s.Name)
+		for _, m := range s.Methods {
+			if m.Skipped != "" {
+				fmt.Fprintf(&buf, "    %s (skipped: %s)\n", m.Signature, m.Skipped)
+			} else if m.IOAdapter != "" {
+				fmt.Fprintf(&buf, "    %s (IO adapter: %s)\n", m.Signature, m.IOAdapter)
+			} else if m.Stream != "" {
+				fmt.Fprintf(&buf, "    %s (stream: %s)\n", m.Signature, m.Stream)
+			} else {
+				fmt.Fprintf(&buf, "    %s\n", m.Signature)
+			}
+		}
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// runCheckCommand runs the check command, validating the annotations of the package with the raycheck analyzer,
+// and regenerating it in memory to compare with the generated files, with the flags of the generation.
+// It reports the problems like go vet and the stale files as unified diffs, and fails if there are some,
+// e.g. to enforce fresh generated files in CI or in a pre-commit hook.
+func runCheckCommand(args []string, w io.Writer) (err error) {
+	fs, options := generateFlagSet("check")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goraygen check [flags of generate] <package-path>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expect one package path")
+	}
+	packagePath := fs.Arg(0)
+	opts, matrix, err := options(packagePath)
+	if err != nil {
+		return err
+	}
+	finish, err := startDiagnostics(&opts)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	var problems int
+	checked := make(map[string]bool) // the targets of the worker file are discovered again in the same package
+	opts.Hooks.TargetDiscovered = append(opts.Hooks.TargetDiscovered, func(t *Targets) error {
+		if checked[t.Package.PkgPath] {
+			return nil
+		}
+		checked[t.Package.PkgPath] = true
+		diagnostics, err := checkAnnotations(t.Package, opts.Streaming)
+		for _, d := range diagnostics {
+			pos := t.Package.Fset.Position(d.Pos)
+			opts.reportDiagnostic(Diagnostic{
+				Severity: SeverityError,
+				Code:     raycheck.Analyzer.Name,
+				File:     pos.Filename,
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Message:  d.Message,
+			})
+		}
+		problems += len(diagnostics)
+		return err
+	})
+	var outputs []generatedOutput
+	opts.Hooks.Write = append(opts.Hooks.Write, func(path string, content []byte) error {
+		outputs = append(outputs, generatedOutput{path, content})
+		return nil
+	})
+	opts.inMemory = true
+	if err := runMatrix(packagePath, opts, matrix); err != nil {
+		return err
+	}
+
+	stale, err := diffOutputs(w, opts.reportDiagnostic, outputs)
+	if err != nil {
+		return err
+	}
+	switch {
+	case problems > 0 && stale > 0:
+		return withExitCode(exitAnalysis, fmt.Errorf("%d problems in the annotations, %d generated files out of date, run goraygen generate", problems, stale))
+	case problems > 0:
+		return withExitCode(exitAnalysis, fmt.Errorf("%d problems in the annotations", problems))
+	case stale > 0:
+		return withExitCode(exitStale, fmt.Errorf("%d generated files out of date, run goraygen generate", stale))
+	}
+	return nil
+}
+
+// checkAnnotations runs the raycheck analyzer on the loaded package, accepting the streamed channel results if streaming.
+func checkAnnotations(pkg *packages.Package, streaming bool) ([]goanalysis.Diagnostic, error) {
+	if err := raycheck.Analyzer.Flags.Set("streaming", strconv.FormatBool(streaming)); err != nil {
+		return nil, err
+	}
+	var diagnostics []goanalysis.Diagnostic
+	pass := &goanalysis.Pass{
+		Analyzer:  raycheck.Analyzer,
+		Fset:      pkg.Fset,
+		Files:     pkg.Syntax,
+		Pkg:       pkg.Types,
+		TypesInfo: pkg.TypesInfo,
+		Report:    func(d goanalysis.Diagnostic) { diagnostics = append(diagnostics, d) },
+	}
+	if _, err := raycheck.Analyzer.Run(pass); err != nil {
+		return nil, err
+	}
+	return diagnostics, nil
+}
+
+// generatedOutput is an output file rendered in memory.
+type generatedOutput struct {
+	path    string
+	content []byte
+}
+
+// diffOutputs compares the outputs with the files on disk, writing the unified diff of the stale ones to w,
+// and returns their number, reported as diagnostics. A missing file is diffed with /dev/null.
+func diffOutputs(w io.Writer, report func(Diagnostic), outputs []generatedOutput) (int, error) {
+	var stale int
+	for _, out := range outputs {
+		path := out.path
+		if rel, ok := localPath(path); ok {
+			path = rel
+		}
+		fromFile := "a/" + filepath.ToSlash(path)
+		current, err := os.ReadFile(out.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			fromFile = os.DevNull
+		} else if err != nil {
+			return stale, err
+		}
+		if bytes.Equal(current, out.content) {
+			continue
+		}
+		stale++
+		report(Diagnostic{
+			Severity:   SeverityError,
+			Code:       "stale",
+			File:       path,
+			Message:    "the generated file is out of date with the source",
+			Suggestion: "run goraygen generate",
+		})
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(current)),
+			B:        difflib.SplitLines(string(out.content)),
+			FromFile: fromFile,
+			ToFile:   "b/" + filepath.ToSlash(path),
+			Context:  3,
+		})
+		if err != nil {
+			return stale, err
+		}
+		if _, err := io.WriteString(w, diff); err != nil {
+			return stale, err
+		}
+	}
+	return stale, nil
+}
+
+// generatedHeaderPrefix starts the header of the files generated by goraygen, in the comment syntax of the file:
+// "Code generated by goraygen v1.2.0 -split-worker; DO NOT EDIT.", see Options.generatedHeader,
+// or "Code generated by goray. DO NOT EDIT." in the files of the versions before it.
+const generatedHeaderPrefix = "Code generated by goray"
+
+// isGeneratedHeader reports whether the comment text is the header of a file generated by goraygen.
+func isGeneratedHeader(comment string) bool {
+	return strings.HasPrefix(comment, generatedHeaderPrefix) && strings.HasSuffix(comment, "DO NOT EDIT.")
+}
+
+// runCleanCommand runs the clean command, removing the generated files from the dirs, recursively with a "/..." suffix.
+func runCleanCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("clean", flag.ContinueOnError)
+	dryRun := fs.Bool("n", false, "print the files to remove without removing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goraygen clean [-n] <dir>... (e.g. ./... for the module)")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expect the dirs to clean")
+	}
+	for _, arg := range fs.Args() {
+		files, err := generatedFiles(arg)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if !*dryRun {
+				if err := os.Remove(file); err != nil {
+					return withExitCode(exitWrite, err)
+				}
+			}
+			fmt.Fprintln(w, file)
+		}
+	}
+	return nil
+}
+
+// generatedFiles returns the files generated by goraygen in dir, or in dir and its subdirs with a "/..." suffix:
+// the files with the generated header, the task graph and the JSON deployment manifest.
+func generatedFiles(dir string) ([]string, error) {
+	root, recursive := strings.CutSuffix(filepath.ToSlash(dir), "/...")
+	if root == "..." || root == "" {
+		root, recursive = ".", true
+	}
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (!recursive || name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if name := d.Name(); name == graphFileName || name == deployManifestFileName+"."+string(ManifestJSON) {
+			files = append(files, path)
+			return nil
+		}
+		generated, err := hasGeneratedHeader(path)
+		if generated {
+			files = append(files, path)
+		}
+		return err
+	})
+	return files, err
+}
+
+// hasGeneratedHeader reports whether the file has the generated header in its leading comments,
+// before the package clause of a Go file: a template with the header in its body isn't generated.
+func hasGeneratedHeader(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 4096) // the header has the flags of the generation
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	for _, line := range strings.Split(string(head[:n]), "\n") {
+		line = strings.TrimSpace(line)
+		comment, ok := strings.CutPrefix(line, "//")
+		if !ok {
+			comment, ok = strings.CutPrefix(line, "#")
+		}
+		if !ok && line != "" {
+			return false, nil
+		}
+		if isGeneratedHeader(strings.TrimSpace(comment)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
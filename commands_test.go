@@ -0,0 +1,197 @@
+package goraygen
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanCommand(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"tasks.go":                 "package mypkg\n",
+		"ray_workload_wrappers.go": "// Code generated by goray. DO NOT EDIT.\n\npackage mypkg\n",
+		"ray_tasks.proto":          "// Code generated by goraygen v1.2.0 -proto; DO NOT EDIT.\nsyntax = \"proto3\";\n",
+		"ray_tasks.manifest.yaml":  "# Code generated by goray. DO NOT EDIT.\ntasks: []\n",
+		"ray_tasks.manifest.json":  "{}\n",
+		"ray_tasks.dot":            "digraph tasks {}\n",
+		"ray_tasks.go":             "package mypkg\n", // a user file with a generated-like name
+		// a template of generated code, not generated itself
+		"templates.go":        "package mypkg\n\nconst tpl = `// Code generated by goray. DO NOT EDIT.\n`\n",
+		"client/client.go":    "//go:build ray\n\n// Code generated by goray. DO NOT EDIT.\n\npackage client\n",
+		"testdata/wrapper.go": "// Code generated by goray. DO NOT EDIT.\n\npackage testdata\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, runCleanCommand([]string{"-n", dir}, &out))
+	require.Equal(t, []string{"ray_tasks.dot", "ray_tasks.manifest.json", "ray_tasks.manifest.yaml", "ray_tasks.proto", "ray_workload_wrappers.go"}, cleanedNames(t, dir, out.String()))
+	require.FileExists(t, filepath.Join(dir, "ray_tasks.dot"))
+
+	out.Reset()
+	require.NoError(t, runCleanCommand([]string{dir + "/..."}, &out))
+	require.Equal(t, []string{"client/client.go", "ray_tasks.dot", "ray_tasks.manifest.json", "ray_tasks.manifest.yaml", "ray_tasks.proto", "ray_workload_wrappers.go"}, cleanedNames(t, dir, out.String()))
+	require.NoFileExists(t, filepath.Join(dir, "client", "client.go"))
+	for _, kept := range []string{"tasks.go", "ray_tasks.go", "templates.go", "testdata/wrapper.go"} {
+		require.FileExists(t, filepath.Join(dir, kept))
+	}
+}
+
+// cleanedNames returns the paths printed by the clean command, relative to dir.
+func cleanedNames(t *testing.T, dir, out string) []string {
+	t.Helper()
+	var names []string
+	for _, path := range bytes.Fields([]byte(out)) {
+		rel, err := filepath.Rel(dir, string(path))
+		require.NoError(t, err)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	return names
+}
+
+func TestVersionCommand(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runVersionCommand(nil, &out))
+	require.Equal(t, "goraygen "+Version()+"\n", out.String())
+	require.Error(t, runVersionCommand([]string{"-v"}, &out))
+}
+
+func TestExitCode(t *testing.T) {
+	load := withExitCode(exitLoad, errors.New("no packages found"))
+	write := withExitCode(exitWrite, os.ErrPermission)
+	require.Equal(t, exitUsage, exitCode(errors.New("expect a package path")))
+	require.Equal(t, exitWrite, exitCode(fmt.Errorf("generate for package p error: %w", write)))
+	require.Equal(t, exitLoad, exitCode(errors.Join(write, load))) // the earliest failing phase
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	require.Equal(t, exitUsage, exitCode(parseFlags(fs, []string{"-unknown"})))
+	require.ErrorIs(t, parseFlags(fs, []string{"-h"}), flag.ErrHelp)
+
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	for code, want := range map[string]int{"load": exitLoad, "raycheck": exitAnalysis, "stale": exitStale} {
+		opts := Options{}
+		finish, err := startDiagnostics(&opts)
+		require.NoError(t, err)
+		opts.reportDiagnostic(Diagnostic{Severity: SeverityError, Code: code, Message: "problem"})
+		require.Equal(t, want, exitCode(finish(nil)), code)
+	}
+}
+
+func TestDiffOutputs(t *testing.T) {
+	dir := t.TempDir()
+	fresh, stale := filepath.Join(dir, "fresh.go"), filepath.Join(dir, "stale.go")
+	require.NoError(t, os.WriteFile(fresh, []byte("package a\n"), 0o644))
+	require.NoError(t, os.WriteFile(stale, []byte("package a\n\nfunc Foo() {}\n"), 0o644))
+
+	var out bytes.Buffer
+	n, err := diffOutputs(&out, Options{}.reportDiagnostic, []generatedOutput{
+		{fresh, []byte("package a\n")},
+		{stale, []byte("package a\n\nfunc Foo(n int) {}\n")},
+		{filepath.Join(dir, "missing.go"), []byte("package a\n")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	diff := out.String()
+	require.NotContains(t, diff, "fresh.go")
+	require.Contains(t, diff, "-func Foo() {}\n+func Foo(n int) {}\n")
+	require.Contains(t, diff, "--- "+os.DevNull+"\n+++ b/"+filepath.ToSlash(filepath.Join(dir, "missing.go"))+"\n")
+}
+
+func TestWriteOutputInMemory(t *testing.T) {
+	var written []string
+	g := NewGenerator(Options{inMemory: true, Hooks: Hooks{Write: []func(string, []byte) error{
+		func(path string, content []byte) error { written = append(written, path); return nil },
+	}}})
+	outputFile := filepath.Join(t.TempDir(), "out", "ray_tasks.dot")
+	require.NoError(t, g.mkdirAll(filepath.Dir(outputFile)))
+	require.NoError(t, g.writeOutput(outputFile, []byte("digraph tasks {}\n")))
+
+	require.Equal(t, []string{outputFile}, written)
+	require.NoDirExists(t, filepath.Dir(outputFile))
+}
+
+func TestTargetList(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "io"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+
+func (*Tasks) Debug() string { return "" }
+
+func (Tasks) Close() error { return nil }
+
+func (Tasks) Upload(name string, r io.Reader) error { return nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n} }
+
+func (Actors) Broken() (*Counter, error) { return nil, nil }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(n int) int { c.n += n; return c.n }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{ReceiverPolicy: ReceiverValueOnly, ExcludeMethods: []string{"Close"}})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	g.collectActorHandles()
+
+	require.Equal(t, targetList{Package: "example.com/mypkg", Structs: []targetStruct{
+		{Kind: "tasks", Name: "Tasks", Methods: []targetMethod{
+			{Name: "Divide", Signature: "Divide(a int64, b int64) (int64)"},
+			{Name: "Upload", Signature: "Upload(name string, r io.Reader) (error)", IOAdapter: "UploadIO"},
+			{Name: "Debug", Signature: "Debug() (string)", Skipped: "excluded by receiver policy value-only"},
+			{Name: "Close", Signature: "Close() (error)", Skipped: "excluded by -exclude"},
+		}},
+		{Kind: "actors", Name: "Actors", Methods: []targetMethod{
+			{Name: "Counter", Signature: "Counter(n int) (*Counter)"},
+			{Name: "Broken", Signature: "Broken() (*Counter, error)", Skipped: "an actor factory returns the actor only"},
+		}},
+		{Kind: "actor", Name: "Counter", Methods: []targetMethod{
+			{Name: "Incr", Signature: "Incr(n int) (int)", Skipped: "excluded by receiver policy value-only"},
+		}},
+	}}, g.targetList())
+}
+
+func TestGeneratedHeader(t *testing.T) {
+	fs, options := generateFlagSet("generate")
+	args := []string{"-split-worker", "-codec", "gob", "-marker", "tasks=// mytasks", "-v", "-env", "TOKEN=secret", "./mypkg"}
+	require.NoError(t, fs.Parse(args))
+	opts, _, err := options(fs.Arg(0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"-codec=gob", `-marker="tasks=// mytasks"`, "-split-worker"}, opts.headerArgs)
+
+	header := opts.generatedHeader()
+	require.Equal(t, "Code generated by goraygen "+Version()+` -codec=gob -marker="tasks=// mytasks" -split-worker; DO NOT EDIT.`, header)
+	file, err := parser.ParseFile(token.NewFileSet(), "ray_workload_wrappers.go", "// "+header+"\n\npackage mypkg\n", parser.ParseComments)
+	require.NoError(t, err)
+	require.True(t, ast.IsGenerated(file))
+	require.True(t, isGeneratedHeader(header))
+	require.True(t, isGeneratedHeader("Code generated by goray. DO NOT EDIT.")) // before the version was recorded
+}
@@ -0,0 +1,229 @@
+package goraygen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of the project config file, looked up from the package dir up to the module root.
+const ConfigFileName = "goraygen.yaml"
+
+/*
+A goraygen.yaml at the module root keeps the settings of the project in one place:
+
+	markers: ["tasks=// mytasks"]
+	backends: [tasks, actors, worker]
+	codec: gob
+	exclude: [Close, Tasks.Debug]
+	packages:
+	  ./internal/billing:
+	    outputDir: ./internal/billing/client
+	  example.com/app/legacy:
+	    codec: json
+
+so the `//go:generate goraygen .` lines stay short. The flags take precedence over the file.
+*/
+
+// Config is the project config of a goraygen.yaml file, see LoadConfig.
+type Config struct {
+	Settings `yaml:",inline"`
+	// Packages are the settings overriding the ones above for a package,
+	// by its import path or its directory relative to the config file.
+	Packages map[string]Settings `yaml:"packages"`
+
+	dir   string          // the directory of the config file
+	flags map[string]bool // the flags set on the command line, overriding the settings of the same option
+	// flagMappings is the number of the type mappings of the flags, first in Options.TypeMappings
+	flagMappings int
+}
+
+// Settings are the options set by a config file, each one like its flag.
+type Settings struct {
+	Markers    []string `yaml:"markers"`    // like -marker, e.g. "tasks=// mytasks"
+	Structs    []string `yaml:"structs"`    // like -struct, e.g. "tasks=MyTasks"
+	OutputDir  string   `yaml:"outputDir"`  // like -output-dir, relative to the config file
+	OutputPkg  string   `yaml:"outputPkg"`  // like -output-pkg, relative to the package
+	OutputFile string   `yaml:"outputFile"` // the name of the wrappers file, see Options.OutputFileName
+	Output     string   `yaml:"output"`     // like -output, the template of the wrappers file path
+	Backends   []string `yaml:"backends"`   // like -backends
+	Codec      string   `yaml:"codec"`      // like -codec
+	Tags       []string `yaml:"tags"`       // like -tags, only at the top level, as they apply when loading the packages
+	Exclude    []string `yaml:"exclude"`    // like -exclude, e.g. "Close" or "Tasks.Close"
+	// ImportAliases are like -import-alias, by import path, e.g. github.com/ray4go/go-ray/ray: goray
+	ImportAliases map[string]string `yaml:"importAliases"`
+	TypeMappings  []TypeMapping     `yaml:"typeMappings"`
+}
+
+// LoadConfig reads the config file, rejecting the unknown settings.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s error: %w", path, err)
+	}
+	for _, m := range cfg.allTypeMappings() {
+		if err := m.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if cfg.dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FindConfig looks up the config file in dir and its parents, up to the module root (the dir with a go.mod file).
+// It returns false if there is none.
+func FindConfig(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func (c *Config) allTypeMappings() []TypeMapping {
+	mappings := slices.Clone(c.TypeMappings)
+	for _, key := range slices.Sorted(maps.Keys(c.Packages)) {
+		mappings = append(mappings, c.Packages[key].TypeMappings...)
+	}
+	return mappings
+}
+
+// apply sets the options of the top-level settings, and makes the generation of every package apply
+// the settings of the package, see Options.forPackage. The flags, set on the command line, take precedence.
+func (c *Config) apply(opts *Options, flags map[string]bool) error {
+	c.flags = flags
+	c.flagMappings = len(opts.TypeMappings)
+	opts.Config = c
+	if len(c.Tags) > 0 && !flags["tags"] {
+		opts.BuildTags = c.Tags
+	}
+	return c.Settings.apply(opts, c)
+}
+
+// apply sets the options of the settings, except the ones of the flags set on the command line.
+func (s Settings) apply(opts *Options, c *Config) error {
+	if len(s.Markers) > 0 && !c.flags["marker"] {
+		if err := opts.applyTargetFlags(s.Markers, nil); err != nil {
+			return err
+		}
+	}
+	if len(s.Structs) > 0 && !c.flags["struct"] {
+		if err := opts.applyTargetFlags(nil, s.Structs); err != nil {
+			return err
+		}
+	}
+	if s.OutputDir != "" && !c.flags["output-dir"] && !c.flags["output-pkg"] {
+		opts.OutputDir, opts.OutputPkg = s.OutputDir, ""
+		if !filepath.IsAbs(s.OutputDir) {
+			opts.OutputDir = filepath.Join(c.dir, s.OutputDir)
+		}
+	}
+	if s.OutputPkg != "" && !c.flags["output-pkg"] && !c.flags["output-dir"] {
+		opts.OutputDir = ""
+		if err := WithOutputPkg(s.OutputPkg)(opts); err != nil {
+			return err
+		}
+	}
+	if s.OutputFile != "" {
+		if err := WithOutputFile(s.OutputFile)(opts); err != nil {
+			return err
+		}
+	}
+	if s.Output != "" && !c.flags["output"] {
+		if err := WithOutput(s.Output)(opts); err != nil {
+			return err
+		}
+	}
+	if len(s.Backends) > 0 && !c.flags["backends"] {
+		if err := WithBackends(s.Backends...)(opts); err != nil {
+			return err
+		}
+	}
+	if s.Codec != "" && !c.flags["codec"] {
+		codec, err := ParseCodec(s.Codec)
+		if err != nil {
+			return err
+		}
+		opts.Codec = codec
+	}
+	if len(s.Exclude) > 0 && !c.flags["exclude"] {
+		opts.ExcludeMethods = s.Exclude
+	}
+	if len(s.ImportAliases) > 0 && !c.flags["import-alias"] {
+		opts.ImportAliases = maps.Clone(opts.ImportAliases) // the top-level ones are shared by the packages
+		for _, path := range slices.Sorted(maps.Keys(s.ImportAliases)) {
+			if err := WithImportAlias(path, s.ImportAliases[path])(opts); err != nil {
+				return err
+			}
+		}
+	}
+	// after the ones of the flags, which take precedence
+	opts.TypeMappings = append(opts.TypeMappings, s.TypeMappings...)
+	return nil
+}
+
+// packageSettings returns the settings of the package, by its import path or its directory.
+func (c *Config) packageSettings(pkg *packages.Package) (Settings, bool) {
+	if s, ok := c.Packages[pkg.PkgPath]; ok {
+		return s, true
+	}
+	if len(pkg.GoFiles) == 0 {
+		return Settings{}, false
+	}
+	dir := filepath.Dir(pkg.GoFiles[0])
+	for _, key := range slices.Sorted(maps.Keys(c.Packages)) {
+		if filepath.Join(c.dir, key) == dir {
+			return c.Packages[key], true
+		}
+	}
+	return Settings{}, false
+}
+
+// forPackage returns the options to generate the package with: the options overridden by the settings
+// of the package in Options.Config, if any.
+func (o Options) forPackage(pkg *packages.Package) (Options, error) {
+	if o.Config == nil {
+		return o, nil
+	}
+	s, ok := o.Config.packageSettings(pkg)
+	if !ok {
+		return o, nil
+	}
+	// the package mappings come after the ones of the flags, before the top-level ones
+	n := o.Config.flagMappings
+	o.TypeMappings = slices.Concat(o.TypeMappings[:n], s.TypeMappings, o.TypeMappings[n:])
+	s.TypeMappings = nil
+	if err := s.apply(&o, o.Config); err != nil {
+		return o, fmt.Errorf("%s settings of package %s: %w", ConfigFileName, pkg.PkgPath, err)
+	}
+	return o, nil
+}
@@ -0,0 +1,129 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+const configContent = `
+markers: ["tasks=// mytasks"]
+backends: [tasks, worker]
+codec: gob
+tags: [integration]
+exclude: [Close]
+typeMappings:
+  - type: time.Time
+    name: int64
+    toWire: "%s.Unix()"
+    fromWire: "time.Unix(%s, 0)"
+    imports: [time]
+packages:
+  ./billing:
+    outputDir: ./billing/client
+    exclude: [Tasks.Debug]
+  example.com/app/legacy:
+    codec: json
+    typeMappings:
+      - {type: time.Time, name: string, toWire: "%s.String()"}
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(content), 0o644))
+	return dir
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := writeConfig(t, configContent)
+	cfg, err := LoadConfig(filepath.Join(dir, ConfigFileName))
+	require.NoError(t, err)
+	require.Equal(t, []string{"tasks", "worker"}, cfg.Backends)
+	require.Equal(t, "./billing/client", cfg.Packages["./billing"].OutputDir)
+	require.Equal(t, "%s.Unix()", cfg.TypeMappings[0].ToWire)
+
+	_, err = LoadConfig(filepath.Join(writeConfig(t, "codecs: gob\n"), ConfigFileName))
+	require.ErrorContains(t, err, "field codecs not found")
+	_, err = LoadConfig(filepath.Join(writeConfig(t, "typeMappings: [{type: Time, name: int64}]\n"), ConfigFileName))
+	require.ErrorContains(t, err, "invalid type mapping")
+
+	cfg, err = LoadConfig(filepath.Join(writeConfig(t, ""), ConfigFileName))
+	require.NoError(t, err)
+	require.Empty(t, cfg.Packages)
+}
+
+func TestFindConfig(t *testing.T) {
+	dir := writeConfig(t, configContent)
+	pkgDir := filepath.Join(dir, "billing", "client")
+	require.NoError(t, os.MkdirAll(pkgDir, 0o755))
+
+	path, found := FindConfig(pkgDir)
+	require.True(t, found)
+	require.Equal(t, filepath.Join(dir, ConfigFileName), path)
+
+	// not above the module root
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "go.mod"), []byte("module example.com/client\n"), 0o644))
+	_, found = FindConfig(pkgDir)
+	require.False(t, found)
+}
+
+func TestConfigApply(t *testing.T) {
+	dir := writeConfig(t, configContent)
+	cfg, err := LoadConfig(filepath.Join(dir, ConfigFileName))
+	require.NoError(t, err)
+
+	// the flags set on the command line take precedence
+	opts := Options{Codec: CodecJSON, TypeMappings: []TypeMapping{{Type: "example.com/ids.UserID", Name: "string"}}}
+	require.NoError(t, cfg.apply(&opts, map[string]bool{"codec": true}))
+	require.Equal(t, CodecJSON, opts.Codec)
+	require.Equal(t, CommentMatcher("// mytasks"), opts.tasksMatcher())
+	require.Equal(t, []string{"tasks", "worker"}, opts.Backends)
+	require.Equal(t, []string{"integration"}, opts.BuildTags)
+	require.Equal(t, []string{"Close"}, opts.ExcludeMethods)
+	require.Len(t, opts.TypeMappings, 2)
+	require.Equal(t, "example.com/ids.UserID", opts.TypeMappings[0].Type)
+
+	// the package settings, by directory
+	billing := &packages.Package{PkgPath: "example.com/app/billing", GoFiles: []string{filepath.Join(dir, "billing", "tasks.go")}}
+	pkgOpts, err := opts.forPackage(billing)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "billing", "client"), pkgOpts.OutputDir)
+	require.Equal(t, []string{"Tasks.Debug"}, pkgOpts.ExcludeMethods)
+	require.Empty(t, opts.OutputDir)
+
+	// by import path, the package mappings after the ones of the flags
+	legacy := &packages.Package{PkgPath: "example.com/app/legacy"}
+	pkgOpts, err = opts.forPackage(legacy)
+	require.NoError(t, err)
+	require.Equal(t, CodecJSON, pkgOpts.Codec)
+	require.Equal(t, []string{"example.com/ids.UserID", "time.Time", "time.Time"}, []string{pkgOpts.TypeMappings[0].Type, pkgOpts.TypeMappings[1].Type, pkgOpts.TypeMappings[2].Type})
+	require.Equal(t, "string", pkgOpts.TypeMappings[1].Name)
+
+	other := &packages.Package{PkgPath: "example.com/app/other"}
+	pkgOpts, err = opts.forPackage(other)
+	require.NoError(t, err)
+	require.Equal(t, opts.ExcludeMethods, pkgOpts.ExcludeMethods)
+}
+
+func TestGenerateExcludedMethods(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+
+func (Tasks) Debug() string { return "" }
+
+func (Tasks) Close() error { return nil }
+`}, Options{ExcludeMethods: []string{"Close", "Tasks.Debug"}})
+
+	require.Contains(t, code, "func Divide[")
+	require.NotContains(t, code, "func Debug(")
+	require.NotContains(t, code, "func Close(")
+}
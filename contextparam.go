@@ -0,0 +1,224 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+	func (Tasks) Fetch(ctx context.Context, url string) (string, error)
+
+isn't called with its ctx, a context can't be serialized. The wrapper takes the ctx as is, passing its metadata instead
+(the deadline, the trace context with -with-otel, and the keys of the functions of RegisterContextInjector):
+
+	func Fetch[string_1 _T0](ctx context.Context, url string_1) *RemoteFunc[*Future2[string, error]] {
+		return NewRemoteFunc[*Future2[string, error]]("FetchWithContext", []any{_contextMetadata(ctx), url})
+	}
+
+to the worker-side variant, calling the task with a ctx rebuilt from the metadata (with the functions of
+RegisterContextExtractor for the other keys, e.g. the baggage):
+
+	func (_t Tasks) FetchWithContext(_md map[string]string, url string) (string, error)
+*/
+const contextTaskTpl = `
+// {{.Name}}WithContext is the variant of [{{.StructName}}.{{.Name}}] called by its wrapper, the ctx of the method is rebuilt
+// from the metadata _md of the caller's context.
+func (_t {{.ReceiverType}}) {{.Name}}WithContext(_md map[string]string{{if .Params}}, {{.Params}}{{end}}) ({{.ResultTypes}}) {
+	_ctx, _cancel := _metadataContext(_md)
+	defer _cancel()
+	{{if .ResultTypes}}return {{end}}_t.{{.Name}}(_ctx{{if .CallArgs}}, {{.CallArgs}}{{end}})
+}
+`
+
+// contextMetadataTpl is the driver-side helper of the methods taking a context.Context, generated once per file.
+const contextMetadataTpl = `
+var _contextInjectors []func(ctx context.Context, md map[string]string)
+
+// RegisterContextInjector adds inject to the functions adding the metadata of the caller's context passed to the methods
+// taking a context.Context, e.g. its baggage or tenant: inject sets the keys of ctx in md, to be restored on the worker
+// by the functions of RegisterContextExtractor. The keys prefixed with "goray-" are reserved. Call it before the calls.
+func RegisterContextInjector(inject func(ctx context.Context, md map[string]string)) {
+	_contextInjectors = append(_contextInjectors, inject)
+}
+
+// _contextMetadata returns the metadata of ctx passed to the methods taking a context.Context in place of the ctx:
+// its deadline{{if .}}, its trace context{{end}} and the keys of the functions of RegisterContextInjector.
+func _contextMetadata(ctx context.Context) map[string]string {
+	md := make(map[string]string)
+	{{- if .}}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(md))
+	{{- end}}
+	for _, inject := range _contextInjectors {
+		inject(ctx, md)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		md["goray-deadline"] = deadline.Format(time.RFC3339Nano)
+	}
+	return md
+}
+`
+
+// metadataContextTpl is the worker-side helper of the methods taking a context.Context, generated once per file.
+const metadataContextTpl = `
+var _contextExtractors []func(ctx context.Context, md map[string]string) context.Context
+
+// RegisterContextExtractor adds extract to the functions restoring the metadata of the caller's context into the context
+// of the methods taking a context.Context: extract returns ctx with the keys of md set by the functions of
+// RegisterContextInjector on the caller. Call it before ray.Init.
+func RegisterContextExtractor(extract func(ctx context.Context, md map[string]string) context.Context) {
+	_contextExtractors = append(_contextExtractors, extract)
+}
+
+// _metadataContext returns the context of a method called with the metadata of the caller's context,
+// see _contextMetadata. It's cancelled at the deadline of the caller's context, if any.
+func _metadataContext(md map[string]string) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	{{- if .}}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(md))
+	{{- end}}
+	for _, extract := range _contextExtractors {
+		ctx = extract(ctx, md)
+	}
+	if deadline, err := time.Parse(time.RFC3339Nano, md["goray-deadline"]); err == nil {
+		return context.WithDeadline(ctx, deadline)
+	}
+	return context.WithCancel(ctx)
+}
+`
+
+var (
+	contextTaskTmpl     = template.Must(template.New("contextTask").Parse(contextTaskTpl))
+	contextMetadataTmpl = template.Must(template.New("contextMetadata").Parse(contextMetadataTpl))
+	metadataContextTmpl = template.Must(template.New("metadataContext").Parse(metadataContextTpl))
+)
+
+const contextTaskSuffix = "WithContext"
+
+// ContextTaskDef is the template data of contextTaskTpl.
+type ContextTaskDef struct {
+	Name         string
+	StructName   string
+	ReceiverType string
+	Params       string // without the ctx
+	CallArgs     string // without the ctx
+	ResultTypes  string // e.g. "string, error"
+}
+
+// contextParamReason returns why the method taking a context.Context can't be called remotely with a suggestion,
+// or empty strings if it can: its worker-side variant must be generated in the scanned package, and not conflict
+// with a declared method.
+func (g *Generator) contextParamReason(m Method) (reason, suggestion string) {
+	structName := strings.TrimPrefix(m.ReceiverType, "*")
+	switch {
+	case g.actorsStruct != "" && structName == g.actorsStruct:
+		return "an actor factory can't take a context.Context", "drop the context.Context param"
+	case !g.inPackageCode():
+		return "a method taking a context.Context must be generated into package " + g.pkg.PkgPath, "use -split-worker with -output-dir"
+	}
+	declared := analysis.Methods(g.pkg, structName, g.pkg.PkgPath, g.importStore)
+	if gslice.Any(declared, func(d Method) bool { return d.Name == m.Name+contextTaskSuffix }) {
+		return fmt.Sprintf("%s already declares the %s%s method called with its context", structName, m.Name, contextTaskSuffix),
+			"rename the method"
+	}
+	return "", ""
+}
+
+// withoutContext returns the method without its leading context.Context param, and whether it had one.
+func withoutContext(m Method) (Method, bool) {
+	if !m.TakesContext() {
+		return m, false
+	}
+	m.Params = m.Params[1:]
+	return m, true
+}
+
+// contextTask returns the worker-side variant of the method taking a context.Context, see generateContextTasks.
+func contextTask(m Method) Method {
+	cm := m
+	cm.Name = m.Name + contextTaskSuffix
+	cm.Params = append([]Param{{Name: "_md", Type: "map[string]string", CanonicalType: "map[string]string", IdentifiableType: "map_string_string"}}, m.Params[1:]...)
+	return cm
+}
+
+// remoteName returns the name the method is called with remotely, the one of its worker-side variant
+// if it takes a context.Context.
+func remoteName(m Method) string {
+	if m.TakesContext() {
+		return m.Name + contextTaskSuffix
+	}
+	return m.Name
+}
+
+// contextMethods returns the tasks, actor methods and methods of the actor handles taking a context.Context.
+func (g *Generator) contextMethods() []Method {
+	methods := slices.Clone(g.tasks)
+	for _, factory := range g.actorFactories {
+		methods = append(methods, g.actor2Methods[factory.Name]...)
+	}
+	for _, h := range g.actorHandles {
+		methods = append(methods, h.Methods...)
+	}
+	seen := make(map[string]bool) // the methods of the actor handles may be the ones of the actors too
+	return gslice.Filter(methods, func(m Method) bool {
+		key := m.ReceiverType + "." + m.Name
+		if !m.TakesContext() || seen[key] {
+			return false
+		}
+		seen[key] = true
+		return true
+	})
+}
+
+// generateContextTasks generates the worker-side variants of the methods taking a context.Context, it must be called
+// before dumping imports. Like the methods, they are methods of the scanned types.
+func (g *Generator) generateContextTasks(buf *bytes.Buffer) {
+	methods := g.contextMethods()
+	if len(methods) == 0 {
+		return
+	}
+	g.addContextImports()
+	for _, m := range methods {
+		params, callArgs, _ := callSignature(Method{Params: m.Params[1:], IsVariadic: m.IsVariadic})
+		def := ContextTaskDef{
+			Name:         m.Name,
+			StructName:   strings.TrimPrefix(m.ReceiverType, "*"),
+			ReceiverType: m.ReceiverType,
+			Params:       params,
+			CallArgs:     callArgs,
+			ResultTypes:  strings.Join(gslice.Map(m.Results, func(r Result) string { return r.Type }), ", "),
+		}
+		if err := contextTaskTmpl.Execute(buf, def); err != nil {
+			panic(err)
+		}
+	}
+	if err := metadataContextTmpl.Execute(buf, g.opts.WithOtel); err != nil {
+		panic(err)
+	}
+}
+
+// generateContextMetadata generates the driver-side helper of the methods taking a context.Context, if any.
+func (g *Generator) generateContextMetadata(buf *bytes.Buffer) {
+	if len(g.contextMethods()) == 0 {
+		return
+	}
+	if err := contextMetadataTmpl.Execute(buf, g.opts.WithOtel); err != nil {
+		panic(err)
+	}
+}
+
+// addContextImports adds the imports of the helpers of the methods taking a context.Context.
+func (g *Generator) addContextImports() {
+	for _, pkg := range []string{"context", "time"} {
+		g.importStore.AddImport(pkg)
+	}
+	if g.opts.WithOtel {
+		g.importStore.AddImport("go.opentelemetry.io/otel")
+		g.importStore.AddImport("go.opentelemetry.io/otel/propagation")
+	}
+}
@@ -0,0 +1,125 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextParams(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+import "context"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Fetch(ctx context.Context, url string) (string, error) { return url, nil }
+
+func (Tasks) Notify(ctx context.Context, ids ...int) {}
+
+func (Tasks) Sync(ctx context.Context) {}
+
+func (Tasks) SyncWithContext(md map[string]string) {}
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+func (Actors) Cache(ctx context.Context) *Cache { return &Cache{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Add(ctx context.Context, n int) int { return c.n + n }
+
+type Cache struct{}
+`}
+	var warnings []string
+	opts := Options{WithOtel: true, TimeoutVariants: true, MapHelpers: true, OptionBuilders: true, ResultRefs: true}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "unsupported-param" || d.Code == "unserializable-type" {
+			warnings = append(warnings, d.Message)
+		}
+	})
+	code := generateFromSource(t, sources, opts)
+
+	// the wrappers take the ctx as is, passing its metadata to the context variants
+	require.Contains(t, code, "func Fetch[string_1 _T0](ctx context.Context, url string_1) *RemoteFunc[*Future2[string, error]] {\n"+
+		"\treturn NewRemoteFunc[*Future2[string, error]](\"FetchWithContext\", []any{_contextMetadata(ctx), url})")
+	require.Contains(t, code, `return NewRemoteFunc[*Future0]("NotifyWithContext", ExpandArgs([]any{_contextMetadata(ctx)}, ids))`)
+	require.Contains(t, code, "func Counter_Add[int_1 _T1](_actor *ActorCounter, ctx context.Context, n int_1) *RemoteFunc[*Future1[int]] {\n"+
+		"\treturn NewRemoteFunc[*Future1[int]](\"AddWithContext\", []any{_contextMetadata(ctx), n}, &_actor.ActorHandle)")
+	require.NotContains(t, code, "context.Context |")
+	// the ctx of the instrumented callers is passed on, with the span
+	require.Contains(t, code, "func FetchInvoke(ctx context.Context, url string) (_r0 string, _err error) {")
+	require.Contains(t, code, `ctx, _done := _startInvocation(ctx, "Fetch", []any{url})`)
+	require.Contains(t, code, "_r0, _taskErr, _err = Fetch(ctx, url).Remote().Get()")
+	require.Contains(t, code, `otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(md))`)
+
+	// the worker-side variants rebuild the ctx
+	require.Contains(t, code, "func (_t Tasks) FetchWithContext(_md map[string]string, url string) (string, error) {\n"+
+		"\t_ctx, _cancel := _metadataContext(_md)\n\tdefer _cancel()\n\treturn _t.Fetch(_ctx, url)\n}")
+	require.Contains(t, code, "func (_t Tasks) NotifyWithContext(_md map[string]string, ids ...int) {")
+	require.Contains(t, code, "\t_t.Notify(_ctx, ids...)\n}")
+	require.Contains(t, code, "func (_t *Counter) AddWithContext(_md map[string]string, n int) int {")
+	require.Contains(t, code, `ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(md))`)
+
+	// the calls pass the keys of the registered propagators on, the deadline last
+	require.Contains(t, code, "\tfor _, inject := range _contextInjectors {\n\t\tinject(ctx, md)\n\t}\n\tif deadline, ok := ctx.Deadline(); ok {")
+	require.Contains(t, code, "\tfor _, extract := range _contextExtractors {\n\t\tctx = extract(ctx, md)\n\t}")
+	require.Contains(t, code, "func RegisterContextInjector(inject func(ctx context.Context, md map[string]string)) {")
+	require.Contains(t, code, "func RegisterContextExtractor(extract func(ctx context.Context, md map[string]string) context.Context) {")
+	// the timeout variants shorten the deadline of the ctx, the map helpers pass it instead of an input field
+	require.Contains(t, code, "\tctx, _cancel := context.WithTimeoutCause(ctx, _timeout, fmt.Errorf(\"Fetch timed out after %s: %w\", _timeout, context.DeadlineExceeded))\n"+
+		"\tdefer _cancel()\n\t_future := Fetch(ctx, url).Remote()")
+	require.Contains(t, code, "\tcase <-ctx.Done():\n\t\t_future.ObjectRef().Cancel()\n\t\tvar res result\n\t\treturn res.r0, context.Cause(ctx)")
+	require.Contains(t, code, "func FetchMap(ctx context.Context, inputs []string, opts ...FetchOption) ([]FetchResultRef, error) {")
+	require.Contains(t, code, "future, err := FetchRemote(ctx, in, opts...)")
+	require.Contains(t, code, "refs, err := FetchMap(ctx, inputs, opts...)")
+	require.NotContains(t, code, "Ctx context.Context")
+
+	require.Equal(t, []string{
+		"Skip method (Tasks).Sync: Tasks already declares the SyncWithContext method called with its context",
+		"Skip method (Actors).Cache: an actor factory can't take a context.Context",
+	}, warnings)
+
+	// the ctx of the tasks isn't serialized
+	warnings = nil
+	pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+	g := NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	g.checkSerializable()
+	require.Len(t, warnings, 2) // the skipped methods only
+
+	// out of the package, the worker-side variants can't be generated
+	warnings = nil
+	g = NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = "out", "example.com/out"
+	g.collectWorkloads()
+	require.Len(t, g.tasks, 1) // SyncWithContext
+	require.Contains(t, warnings, "Skip method (Tasks).Fetch: a method taking a context.Context must be generated into package example.com/mypkg")
+}
+
+func TestContextParamsGateways(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "context"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Fetch(ctx context.Context, url string) (string, error) { return url, nil }
+`}, Options{HTTPGateway: true, TaskVersion: 2})
+
+	// the HTTP handler calls the task with the context of the request
+	require.Contains(t, code, "_r0, _taskErr, _err := Fetch(r.Context(), _req.Url).Remote(options...).Get()")
+	require.NotContains(t, code, "Ctx ")
+	// the versioned names are called with the metadata too
+	require.Contains(t, code, `return NewRemoteFunc[*Future2[string, error]]("Fetch_v2", []any{_contextMetadata(ctx), url})`)
+	require.Contains(t, code, "func (_t Tasks) Fetch_v2(_md map[string]string, url string) (string, error) {\n\treturn _t.FetchWithContext(_md, url)\n}")
+}
@@ -0,0 +1,50 @@
+package goraygen
+
+import (
+	"bytes"
+)
+
+/*
+	func DivideContext[int64_0 _T0, int64_1 _T0](ctx context.Context, a int64_0, b int64_1) *Future2[int64, int64] {
+		_future := Divide(a, b).Remote()
+		_cancelOnDone(ctx, func() { _future.ObjectRef().Cancel() })
+		return _future
+	}
+*/
+const contextVariantTpl = `
+{{- $name := .FuncName}}{{if .ActorName}}{{$name = printf "%s_%s" .ActorName .FuncName}}{{end}}
+// {{$name}}Context calls [{{$name}}] remotely, the remote call is cancelled when ctx is done
+// (cancelled or deadline exceeded) before it finishes.
+func {{$name}}Context{{.TypeConstraints}}(ctx context.Context{{if .ActorName}}, _actor *Actor{{.ActorName}}{{end}}{{if .SliceParamList}}, {{.SliceParamList}}{{end}}) *Future{{.ResLen}}{{.ResTypes}} {
+	_future := {{$name}}({{if .ActorName}}_actor{{if or .PassContext .CallArgs}}, {{end}}{{end}}{{if .PassContext}}ctx{{if .CallArgs}}, {{end}}{{end}}{{.CallArgs}}).Remote()
+	_cancelOnDone(ctx, func() { _future.ObjectRef().Cancel() })
+	return _future
+}
+`
+
+// contextVariantHelpers is shared by the context variants of all methods, generated once per file.
+const contextVariantHelpers = `
+// _cancelOnDone calls cancel when ctx is done, it's a no-op for contexts that are never done.
+func _cancelOnDone(ctx context.Context, cancel func()) {
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+}
+`
+
+// generateContextVariant generates the context-aware call variant of the method.
+// If the method already takes a context.Context as its first parameter, the ctx of the variant is passed on
+// as that argument, instead of being a second context parameter.
+func generateContextVariant(buf *bytes.Buffer, method Method, paramTypeMapper *ParameterTypeConstraints, actorName, docQualifier string) error {
+	passContext := method.TakesContext()
+	if passContext {
+		method.Params = method.Params[1:]
+	}
+	return generateWrapperFunctionWith(contextVariantTpl, buf, method, paramTypeMapper, actorName, docQualifier, func(def *FuncDef) {
+		def.PassContext = passContext
+	})
+}
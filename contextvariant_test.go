@@ -0,0 +1,42 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateContextVariants(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "context"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Echo(prefix string, args ...int) []int { return args }
+
+func (Tasks) Fetch(ctx context.Context, url string) string { return url }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Get() int { return c.n }
+`}, Options{ContextVariants: true})
+
+	require.Contains(t, code, "func EchoContext[string_0 _T0, int_1 _T1](ctx context.Context, prefix string_0, args []int_1) *Future1[[]int] {")
+	require.Contains(t, code, "_future := Echo(prefix, args...).Remote()")
+	require.Contains(t, code, "_cancelOnDone(ctx, func() { _future.ObjectRef().Cancel() })")
+
+	// the ctx of the method is not passed twice
+	require.Contains(t, code, "func FetchContext[string_0 _T0](ctx context.Context, url string_0) *Future1[string] {")
+	require.Contains(t, code, "_future := Fetch(ctx, url).Remote()")
+
+	require.Contains(t, code, "func Counter_GetContext(ctx context.Context, _actor *ActorCounter) *Future1[int] {")
+	require.Contains(t, code, "_future := Counter_Get(_actor).Remote()")
+	require.Contains(t, code, "func _cancelOnDone(ctx context.Context, cancel func()) {")
+}
@@ -0,0 +1,163 @@
+package goraygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ray4go/goraygen/analysis"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFormat is the format of the deployment manifest, see the -deploy-manifest flag.
+type ManifestFormat string
+
+const (
+	ManifestJSON ManifestFormat = "json"
+	ManifestYAML ManifestFormat = "yaml"
+)
+
+// ParseManifestFormat parses the -deploy-manifest flag value, empty means no manifest.
+func ParseManifestFormat(s string) (ManifestFormat, error) {
+	switch f := ManifestFormat(s); f {
+	case "", ManifestJSON, ManifestYAML:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid deploy manifest format %q, expect %s or %s", s, ManifestJSON, ManifestYAML)
+}
+
+// deployManifestFileName is the name of the manifest file written into the scanned package, with the format as extension.
+const deployManifestFileName = "ray_tasks.manifest"
+
+/*
+With -deploy-manifest=yaml, ray_tasks.manifest.yaml describes what the worker of the package registers:
+
+	package: example.com/demo
+	taskVersion: 3
+	tasks:
+	  - name: Divide_v3
+	    method: Divide
+	    aliases: [Divide_v2]
+	    signature: Divide(a int64, b int64) (int64, int64)
+	    signatureHash: 5b0c0b1d9a0e3c51
+	    resources: {cpus: 2, gpus: 1, memory: 4294967296}
+	    directives: [retry max=5 backoff=exponential, resources cpu=2 gpu=1 memory=4Gi]
+	actors:
+	  - name: Counter
+	    signature: Counter(n int) (*Counter)
+	    signatureHash: …
+	    methods:
+	      - name: Incr
+	        signature: Incr(n int) (int)
+	        signatureHash: …
+*/
+
+// DeployManifest describes the tasks and actors registered by the worker of a package, for the deployment tooling.
+type DeployManifest struct {
+	Package     string         `json:"package" yaml:"package"`
+	TaskVersion int            `json:"taskVersion,omitempty" yaml:"taskVersion,omitempty"`
+	Tasks       []DeployMethod `json:"tasks" yaml:"tasks"`
+	Actors      []DeployMethod `json:"actors,omitempty" yaml:"actors,omitempty"`
+}
+
+// DeployMethod is a task, an actor (described by its factory) or an actor method.
+type DeployMethod struct {
+	Name          string           `json:"name" yaml:"name"`                         // the registered name
+	Method        string           `json:"method,omitempty" yaml:"method,omitempty"` // the Go method, if not the registered name
+	Aliases       []string         `json:"aliases,omitempty" yaml:"aliases,omitempty,flow"`
+	Signature     string           `json:"signature" yaml:"signature"`
+	SignatureHash string           `json:"signatureHash" yaml:"signatureHash"`
+	Resources     *DeployResources `json:"resources,omitempty" yaml:"resources,omitempty,flow"`
+	Directives    []string         `json:"directives,omitempty" yaml:"directives,omitempty,flow"` // the //goray: directives, without the prefix
+	Methods       []DeployMethod   `json:"methods,omitempty" yaml:"methods,omitempty"`
+}
+
+// DeployResources are the resource requirements of the //goray:resources directive.
+type DeployResources struct {
+	CPUs   float64            `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+	GPUs   float64            `json:"gpus,omitempty" yaml:"gpus,omitempty"`
+	Memory int64              `json:"memory,omitempty" yaml:"memory,omitempty"` // in bytes
+	Custom map[string]float64 `json:"custom,omitempty" yaml:"custom,omitempty"`
+}
+
+// deployMethod describes the method registered as name.
+func (g *Generator) deployMethod(name string, m Method) DeployMethod {
+	d := DeployMethod{Name: name, Signature: m.String(), SignatureHash: signatureHash(m)}
+	if name != m.Name {
+		d.Method = m.Name
+	}
+	for _, line := range strings.Split(m.Doc, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, analysis.DirectivePrefix) {
+			d.Directives = append(d.Directives, strings.TrimPrefix(line, analysis.DirectivePrefix))
+		}
+	}
+	if def, ok := g.resourcesDef(name, m); ok {
+		r := &DeployResources{Memory: def.Memory}
+		r.CPUs, _ = strconv.ParseFloat(def.CPUs, 64) // validated by resourcesDef, empty if not required
+		r.GPUs, _ = strconv.ParseFloat(def.GPUs, 64)
+		for _, c := range def.Custom {
+			if r.Custom == nil {
+				r.Custom = make(map[string]float64)
+			}
+			r.Custom[c.Name], _ = strconv.ParseFloat(c.Amount, 64)
+		}
+		d.Resources = r
+	}
+	return d
+}
+
+// deployManifest returns the manifest of the tasks and actors, with the task names versioned by -task-version.
+func (g *Generator) deployManifest() DeployManifest {
+	g.prepareTaskVersions()
+	manifest := DeployManifest{Package: g.pkg.PkgPath, Tasks: []DeployMethod{}}
+	if len(g.versionedTasks) > 0 {
+		manifest.TaskVersion = g.opts.TaskVersion
+	}
+	for _, m := range g.tasks {
+		d := g.deployMethod(g.taskName(m), m)
+		if d.Method != "" {
+			for _, v := range g.opts.taskVersions()[1:] {
+				d.Aliases = append(d.Aliases, versionedTaskName(m.Name, v))
+			}
+		}
+		manifest.Tasks = append(manifest.Tasks, d)
+	}
+	for _, factory := range g.actorFactories {
+		d := g.deployMethod(factory.Name, factory)
+		for _, am := range g.actor2Methods[factory.Name] {
+			d.Methods = append(d.Methods, g.deployMethod(am.Name, am))
+		}
+		manifest.Actors = append(manifest.Actors, d)
+	}
+	return manifest
+}
+
+// writeDeployManifest writes the deployment manifest into the scanned package dir.
+func (g *Generator) writeDeployManifest() error {
+	if len(g.pkg.GoFiles) == 0 || (g.tasksStruct == "" && g.actorsStruct == "") {
+		return nil
+	}
+	var buf bytes.Buffer
+	manifest := g.deployManifest()
+	switch g.opts.DeployManifest {
+	case ManifestJSON:
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(manifest); err != nil {
+			return err
+		}
+	case ManifestYAML:
+		buf.WriteString("# " + g.opts.generatedHeader() + "\n")
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(manifest); err != nil {
+			return err
+		}
+	}
+	outputFile := filepath.Join(filepath.Dir(g.pkg.GoFiles[0]), deployManifestFileName+"."+string(g.opts.DeployManifest))
+	return g.writeOutput(outputFile, buf.Bytes())
+}
@@ -0,0 +1,60 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteDeployManifest(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Train trains.
+//
+//goray:resources cpu=2 memory=4Gi ssd=0.5
+func (Tasks) Train(epochs int) float64 { return 0 }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n: n} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(by int) int { return 0 }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{DeployManifest: ManifestYAML, SplitWorker: true, TaskVersion: 3, TaskVersionAliases: 1})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	require.NoError(t, g.writeDeployManifest())
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), "ray_tasks.manifest.yaml"))
+	require.NoError(t, err)
+	var manifest DeployManifest
+	require.NoError(t, yaml.Unmarshal(data, &manifest))
+	require.Equal(t, "example.com/mypkg", manifest.Package)
+	require.Equal(t, 3, manifest.TaskVersion)
+	require.Equal(t, []DeployMethod{{
+		Name:          "Train_v3",
+		Method:        "Train",
+		Aliases:       []string{"Train_v2"},
+		Signature:     "Train(epochs int) (float64)",
+		SignatureHash: signatureHash(g.tasks[0]),
+		Resources:     &DeployResources{CPUs: 2, Memory: 4 << 30, Custom: map[string]float64{"ssd": 0.5}},
+		Directives:    []string{"resources cpu=2 memory=4Gi ssd=0.5"},
+	}}, manifest.Tasks)
+	require.Len(t, manifest.Actors, 1)
+	require.Equal(t, "Counter", manifest.Actors[0].Name)
+	require.Equal(t, "Incr", manifest.Actors[0].Methods[0].Name)
+
+	_, err = ParseManifestFormat("toml")
+	require.Error(t, err)
+}
@@ -0,0 +1,424 @@
+package goraygen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic is a problem found by the generation, e.g. a method skipped or an invalid directive.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Code identifies the kind of problem, e.g. "receiver-policy" or "invalid-directive".
+	Code   string `json:"code"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	// Message describes the problem, and Suggestion how to fix it, if any.
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// String formats the diagnostic like the log lines of the generation, e.g. "[WARN] file.go:3:6: message, suggestion".
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	switch d.Severity {
+	case SeverityError:
+		b.WriteString("[ERROR] ")
+	case SeverityWarning:
+		b.WriteString("[WARN] ")
+	default:
+		b.WriteString("[INFO] ")
+	}
+	if d.File != "" {
+		fmt.Fprintf(&b, "%s: ", token.Position{Filename: d.File, Line: d.Line, Column: d.Column})
+	}
+	b.WriteString(d.Message)
+	if d.Suggestion != "" {
+		b.WriteString(", " + d.Suggestion)
+	}
+	return b.String()
+}
+
+// level returns the log level of the severity.
+func (d Diagnostic) level() slog.Level {
+	switch d.Severity {
+	case SeverityError:
+		return slog.LevelError
+	case SeverityWarning:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// attrs returns the fields of the diagnostic but the message as log attrs, without the empty ones.
+func (d Diagnostic) attrs() []slog.Attr {
+	attrs := []slog.Attr{slog.String("code", d.Code)}
+	if d.File != "" {
+		attrs = append(attrs, slog.String("file", d.File), slog.Int("line", d.Line), slog.Int("column", d.Column))
+	}
+	if d.Suggestion != "" {
+		attrs = append(attrs, slog.String("suggestion", d.Suggestion))
+	}
+	return attrs
+}
+
+// DiagFormat is the output format of the diagnostics.
+type DiagFormat string
+
+const (
+	DiagText  DiagFormat = "text"  // the log lines of Diagnostic.String
+	DiagJSON  DiagFormat = "json"  // one JSON object per line
+	DiagSARIF DiagFormat = "sarif" // a SARIF log, written once the command is done
+)
+
+// ParseDiagFormat parses the value of the -diag-format flag.
+func ParseDiagFormat(s string) (DiagFormat, error) {
+	switch f := DiagFormat(s); f {
+	case DiagText, DiagJSON, DiagSARIF:
+		return f, nil
+	case "":
+		return DiagText, nil
+	}
+	return "", fmt.Errorf("invalid diagnostics format %q, expect text, json or sarif", s)
+}
+
+// localPath returns the path relative to the working directory, false if it's not in it.
+func localPath(path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		return path, filepath.IsLocal(path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path, false
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return path, false
+	}
+	return rel, true
+}
+
+// parsePosition parses a position like "file.go:3:6" or "file.go:3", as in the errors of go/packages.
+func parsePosition(pos string) (file string, line, column int) {
+	file = pos
+	for _, n := range []*int{&column, &line} {
+		i := strings.LastIndexByte(file, ':')
+		if i < 0 {
+			break
+		}
+		v, err := strconv.Atoi(file[i+1:])
+		if err != nil {
+			break
+		}
+		*n, file = v, file[:i]
+	}
+	if line == 0 { // only a line
+		line, column = column, 0
+	}
+	if file == "-" {
+		file = ""
+	}
+	return file, line, column
+}
+
+// diagnosticTally counts the errors and warnings of a command, whatever the package or build tag set reporting them.
+type diagnosticTally struct {
+	mu       sync.Mutex
+	errors   int
+	warnings int
+	dropped  int // over Options.MaxErrors
+	exitCode int // the lowest exit code of the errors, see diagnosticExitCode
+}
+
+// diagnosticExitCode returns the exit code of an error diagnostic by its code.
+func diagnosticExitCode(code string) int {
+	switch code {
+	case "load":
+		return exitLoad
+	case "stale":
+		return exitStale
+	}
+	return exitAnalysis
+}
+
+// add counts the diagnostic, and returns whether it's within the max number of errors and warnings to report.
+func (t *diagnosticTally) add(d Diagnostic, maxErrors int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch d.Severity {
+	case SeverityError:
+		t.errors++
+		if code := diagnosticExitCode(d.Code); t.exitCode == 0 || code < t.exitCode {
+			t.exitCode = code
+		}
+	case SeverityWarning:
+		t.warnings++
+	default:
+		return true
+	}
+	if maxErrors > 0 && t.errors+t.warnings > maxErrors {
+		t.dropped++
+		return false
+	}
+	return true
+}
+
+// String summarizes the counts, e.g. "2 errors, 3 warnings (1 not reported, see -max-errors)".
+func (t *diagnosticTally) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := fmt.Sprintf("%d errors, %d warnings", t.errors, t.warnings)
+	if t.dropped > 0 {
+		s += fmt.Sprintf(" (%d not reported, see -max-errors)", t.dropped)
+	}
+	return s
+}
+
+// reportDiagnostic writes the diagnostic in Options.DiagFormat, and passes it to the Diagnostic hooks.
+// The warnings are errors with Options.Strict. Over Options.MaxErrors, the errors and warnings of a command are only counted.
+func (o Options) reportDiagnostic(d Diagnostic) {
+	if o.Strict && d.Severity == SeverityWarning {
+		d.Severity = SeverityError
+	}
+	if o.tally != nil && !o.tally.add(d, o.MaxErrors) {
+		return
+	}
+	switch o.DiagFormat {
+	case DiagJSON:
+		data, err := json.Marshal(d)
+		if err != nil {
+			panic(err) // only strings and ints
+		}
+		w := o.diagOutput
+		if w == nil {
+			w = log.Writer()
+		}
+		w.Write(append(data, '\n'))
+	case DiagSARIF:
+		// collected by a hook, see startDiagnostics
+	default:
+		if o.LogFormat == LogJSON || o.Logger != nil {
+			o.logger().LogAttrs(context.Background(), d.level(), d.Message, d.attrs()...)
+		} else {
+			log.Print(d)
+		}
+	}
+	for _, hook := range o.Hooks.Diagnostic {
+		hook(d)
+	}
+}
+
+// errReported is the error of a command already reported as a diagnostic.
+var errReported = errors.New("reported")
+
+// startDiagnostics sets up the diagnostics of a command in the options: it opens the file of Options.diagOutputPath,
+// counts the diagnostics and collects them for the SARIF format. The problems don't stop the command,
+// so they are all reported in one run; the returned function, called with the error of the command, decides:
+// the command fails if it returned an error or reported an error diagnostic, with the lowest exit code of both.
+// In the text format, it logs the counts. It reports the error of the command as a diagnostic in the JSON and SARIF
+// formats for the tools parsing them (returning errReported instead), and writes the SARIF log.
+func startDiagnostics(opts *Options) (func(err error) error, error) {
+	var file *os.File
+	if opts.diagOutputPath != "" {
+		var err error
+		if file, err = os.Create(opts.diagOutputPath); err != nil {
+			return nil, withExitCode(exitWrite, err)
+		}
+		opts.diagOutput = file
+	}
+	tally := &diagnosticTally{}
+	opts.tally = tally
+	var diagnostics []Diagnostic
+	if opts.DiagFormat == DiagSARIF {
+		opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) { diagnostics = append(diagnostics, d) })
+	}
+	o := *opts
+	return func(err error) error {
+		code := classifiedExitCode(err)
+		if tally.errors > 0 {
+			if code == 0 || tally.exitCode < code {
+				code = tally.exitCode
+			}
+			if err == nil {
+				err = errReported
+			}
+		}
+		if o.DiagFormat != DiagJSON && o.DiagFormat != DiagSARIF && tally.errors+tally.warnings > 0 {
+			o.logger().Info("Reported " + tally.String())
+		}
+		if err != nil && (o.DiagFormat == DiagJSON || o.DiagFormat == DiagSARIF) && !errors.Is(err, errReported) {
+			o.reportDiagnostic(Diagnostic{Severity: SeverityError, Code: "error", Message: err.Error()})
+			err = errReported
+		}
+		w := o.diagOutput
+		if w == nil {
+			w = log.Writer()
+		}
+		if o.DiagFormat == DiagSARIF {
+			if werr := writeSARIF(w, diagnostics); werr != nil && err == nil {
+				err, code = werr, exitWrite
+			}
+		}
+		if file != nil {
+			if cerr := file.Close(); cerr != nil && err == nil {
+				err, code = cerr, exitWrite
+			}
+		}
+		if code > 0 {
+			return withExitCode(code, err)
+		}
+		return err
+	}, nil
+}
+
+// report reports the diagnostic, see Options.reportDiagnostic.
+func (g *Generator) report(d Diagnostic) {
+	g.opts.reportDiagnostic(d)
+}
+
+// warnf reports a warning without position nor suggestion.
+func (g *Generator) warnf(code, format string, args ...any) {
+	g.report(Diagnostic{Severity: SeverityWarning, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// warnAt reports a warning at the position in the scanned package, without position if it's token.NoPos.
+func (g *Generator) warnAt(pos token.Pos, code, format string, args ...any) {
+	g.report(g.at(pos, Diagnostic{Severity: SeverityWarning, Code: code, Message: fmt.Sprintf(format, args...)}))
+}
+
+// at sets the position of the diagnostic from the position in the scanned package, if valid.
+func (g *Generator) at(pos token.Pos, d Diagnostic) Diagnostic {
+	if !pos.IsValid() || g.pkg == nil || g.pkg.Fset == nil {
+		return d
+	}
+	p := g.pkg.Fset.Position(pos)
+	d.File, d.Line, d.Column = p.Filename, p.Line, p.Column
+	return d
+}
+
+// packagePos returns the position of the package clause of the first source file of the scanned package.
+func (g *Generator) packagePos() token.Pos {
+	if files := analysis.SourceFiles(g.pkg); len(files) > 0 {
+		return files[0].Name.Pos()
+	}
+	return token.NoPos
+}
+
+// typePos returns the position of the name of the type declared in the scanned package, token.NoPos if none.
+func (g *Generator) typePos(name string) token.Pos {
+	if g.pkg == nil || g.pkg.Types == nil {
+		return token.NoPos
+	}
+	if obj := g.pkg.Types.Scope().Lookup(strings.TrimPrefix(name, "*")); obj != nil {
+		return obj.Pos()
+	}
+	return token.NoPos
+}
+
+// methodPos returns the position of the name of the method declared in the scanned package,
+// token.NoPos for the generated methods, e.g. the checkpoint methods.
+func (g *Generator) methodPos(m Method) token.Pos {
+	if m.ReceiverType == "" || g.pkg == nil || g.pkg.Types == nil {
+		return token.NoPos
+	}
+	obj := g.pkg.Types.Scope().Lookup(strings.TrimPrefix(m.ReceiverType, "*"))
+	if obj == nil {
+		return token.NoPos
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return token.NoPos
+	}
+	for i := range named.NumMethods() {
+		if method := named.Method(i); method.Name() == m.Name {
+			return method.Pos()
+		}
+	}
+	return token.NoPos
+}
+
+// directivePos returns the position of the //goray: directive in the doc of the method,
+// the position of the method if it has none.
+func (g *Generator) directivePos(m Method, directive string) token.Pos {
+	pos := g.methodPos(m)
+	if !pos.IsValid() {
+		return pos
+	}
+	for _, file := range g.pkg.Syntax {
+		if pos < file.Pos() || pos >= file.End() {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Name.Pos() != pos || fd.Doc == nil {
+				continue
+			}
+			for _, c := range fd.Doc.List {
+				if d := analysis.ParseDirectives(c.Text); len(d) > 0 && d[0].Name == directive {
+					return c.Pos()
+				}
+			}
+		}
+	}
+	return pos
+}
+
+// fieldPos returns the position of the field of the struct declared in the scanned package, token.NoPos if none.
+func (g *Generator) fieldPos(structName, field string) token.Pos {
+	obj := g.pkg.Types.Scope().Lookup(structName)
+	if obj == nil {
+		return token.NoPos
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return token.NoPos
+	}
+	for i := range st.NumFields() {
+		if f := st.Field(i); f.Name() == field {
+			return f.Pos()
+		}
+	}
+	return token.NoPos
+}
+
+// warnOutsidePackage reports a feature skipped because the code generated into another package can't declare methods
+// on the types of the scanned package.
+func (g *Generator) warnOutsidePackage(code, format string, args ...any) {
+	g.report(Diagnostic{
+		Severity:   SeverityWarning,
+		Code:       code,
+		Message:    fmt.Sprintf(format, args...),
+		Suggestion: "use -split-worker with -output-dir",
+	})
+}
+
+// OnDiagnostic adds a hook called with every diagnostic of the generation, e.g. to show them in an editor.
+func OnDiagnostic(hook func(d Diagnostic)) Option {
+	return func(o *Options) error {
+		o.Hooks.Diagnostic = append(o.Hooks.Diagnostic, hook)
+		return nil
+	}
+}
@@ -0,0 +1,170 @@
+package goraygen
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{
+		Severity:   SeverityWarning,
+		Code:       "near-miss-marker",
+		File:       "tasks.go",
+		Line:       3,
+		Column:     1,
+		Message:    "struct Tasks has comment '//raytask'",
+		Suggestion: "did you mean '// raytasks'?",
+	}
+	require.Equal(t, "[WARN] tasks.go:3:1: struct Tasks has comment '//raytask', did you mean '// raytasks'?", d.String())
+	require.Equal(t, "[ERROR] no package", Diagnostic{Severity: SeverityError, Code: "load", Message: "no package"}.String())
+
+	_, err := ParseDiagFormat("xml")
+	require.Error(t, err)
+	format, err := ParseDiagFormat("")
+	require.NoError(t, err)
+	require.Equal(t, DiagText, format)
+}
+
+func TestParsePosition(t *testing.T) {
+	for pos, want := range map[string]Diagnostic{
+		"/src/tasks.go:3:6": {File: "/src/tasks.go", Line: 3, Column: 6},
+		"/src/tasks.go:3":   {File: "/src/tasks.go", Line: 3},
+		"/src/tasks.go":     {File: "/src/tasks.go"},
+		"-":                 {},
+		"":                  {},
+	} {
+		var got Diagnostic
+		got.File, got.Line, got.Column = parsePosition(pos)
+		require.Equal(t, want, got, pos)
+	}
+}
+
+func TestJSONDiagnostics(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	var reported []Diagnostic
+	generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+//raytask
+type Tasks struct{}
+`}, New(WithOptions(Options{DiagFormat: DiagJSON}), OnDiagnostic(func(d Diagnostic) { reported = append(reported, d) })).Options())
+
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.HasPrefix(line, "{") { // not a progress line
+			var d Diagnostic
+			require.NoError(t, json.Unmarshal([]byte(line), &d))
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	require.Equal(t, reported, diagnostics)
+	i := slices.IndexFunc(diagnostics, func(d Diagnostic) bool { return d.Code == "near-miss-marker" })
+	require.GreaterOrEqual(t, i, 0)
+	require.Equal(t, "tasks.go", filepath.Base(diagnostics[i].File))
+	diagnostics[i].File = ""
+	require.Equal(t, Diagnostic{
+		Severity:   SeverityWarning,
+		Code:       "near-miss-marker",
+		Line:       3,
+		Column:     1,
+		Message:    "struct Tasks has comment '//raytask'",
+		Suggestion: "did you mean '// raytasks'?",
+	}, diagnostics[i])
+}
+
+func TestDiagnosticPositions(t *testing.T) {
+	var reported []Diagnostic
+	generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Slow takes long.
+//goray:timeout forever
+func (Tasks) Slow() {}
+
+func (*Tasks) Ptr() {}
+`}, New(WithOptions(Options{ReceiverPolicy: ReceiverValueOnly}), OnDiagnostic(func(d Diagnostic) { reported = append(reported, d) })).Options())
+
+	positions := make(map[string]string)
+	for _, d := range reported {
+		if d.File != "" {
+			positions[d.Code] = fmt.Sprintf("%s:%d:%d", filepath.Base(d.File), d.Line, d.Column)
+		}
+	}
+	require.Equal(t, map[string]string{
+		"invalid-directive": "tasks.go:7:1",   // the directive line
+		"receiver-policy":   "tasks.go:10:15", // the method name
+		"no-target-struct":  "tasks.go:1:9",   // no rayactors struct, the package clause
+	}, positions)
+}
+
+func TestMaxErrors(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	opts := Options{MaxErrors: 2}
+	finish, err := startDiagnostics(&opts)
+	require.NoError(t, err)
+	for _, code := range []string{"a", "b", "c"} {
+		opts.reportDiagnostic(Diagnostic{Severity: SeverityWarning, Code: code, Message: "warning " + code})
+	}
+	opts.reportDiagnostic(Diagnostic{Severity: SeverityInfo, Code: "d", Message: "info d"})
+	require.NoError(t, finish(nil)) // only warnings
+	require.Contains(t, logs.String(), "warning b")
+	require.NotContains(t, logs.String(), "warning c")
+	require.Contains(t, logs.String(), "info d")
+	require.Contains(t, logs.String(), "Reported 0 errors, 3 warnings (1 not reported, see -max-errors)")
+
+	opts = Options{}
+	finish, err = startDiagnostics(&opts)
+	require.NoError(t, err)
+	opts.reportDiagnostic(Diagnostic{Severity: SeverityError, Code: "load", Message: "undefined: x"})
+	opts.reportDiagnostic(Diagnostic{Severity: SeverityWarning, Code: "format", Message: "unformatted"})
+	require.ErrorIs(t, finish(nil), errReported) // fails once all are reported
+}
+
+func TestStrict(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	opts := Options{Strict: true}
+	finish, err := startDiagnostics(&opts)
+	require.NoError(t, err)
+	opts.reportDiagnostic(Diagnostic{Severity: SeverityWarning, Code: "receiver-policy", Message: "Skip method (Tasks).Add"})
+	opts.reportDiagnostic(Diagnostic{Severity: SeverityInfo, Code: "excluded-method", Message: "Skip method (Tasks).Close"})
+	require.ErrorIs(t, finish(nil), errReported)
+	require.Contains(t, logs.String(), "[ERROR] Skip method (Tasks).Add")
+	require.Contains(t, logs.String(), "[INFO] Skip method (Tasks).Close")
+	require.Contains(t, logs.String(), "Reported 1 errors, 0 warnings")
+}
+
+func TestCommandErrorDiagnostic(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	opts := Options{}
+	finish, err := startDiagnostics(&opts)
+	require.NoError(t, err)
+	require.Equal(t, os.ErrNotExist, finish(os.ErrNotExist))
+	require.Empty(t, logs.String())
+
+	opts = Options{DiagFormat: DiagJSON}
+	finish, err = startDiagnostics(&opts)
+	require.NoError(t, err)
+	require.ErrorIs(t, finish(os.ErrNotExist), errReported)
+	require.Equal(t, `{"severity":"error","code":"error","message":"file does not exist"}`+"\n", logs.String())
+}
@@ -0,0 +1,44 @@
+package goraygen
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// docWithoutDirectives returns the doc comment lines without the directives (and the blank lines before them),
+// which only configure the generated code.
+func docWithoutDirectives(doc string) string {
+	var lines []string
+	for _, line := range strings.Split(doc, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), analysis.DirectivePrefix) {
+			lines = append(lines, line)
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "//" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// durationExpr renders the duration as Go expression with the largest exact unit, e.g. "100 * time.Millisecond".
+func durationExpr(d time.Duration) string {
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+	for _, u := range units {
+		if d != 0 && d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + " * " + u.name
+		}
+	}
+	return "time.Duration(" + strconv.FormatInt(int64(d), 10) + ")"
+}
@@ -0,0 +1,235 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+/*
+With -graceful-shutdown, the worker registers the tasks through a wrapper tracking the calls in flight:
+
+	type _drainTasks struct {
+		tasks *Tasks
+	}
+
+	func (_w _drainTasks) Divide(a int64, b int64) (int64, int64)
+
+	func Shutdown(drainTimeout time.Duration) error
+	func ShutdownOnSignal(drainTimeout time.Duration, signals ...os.Signal)
+*/
+const drainMethodTpl = `
+func (_w _drainTasks) {{.Name}}({{.Params}}) ({{.Results}}) {
+	{{- if .ReturnsError}}
+	if _err = _enterTask(); _err != nil {
+		return
+	}
+	{{- else}}
+	if err := _enterTask(); err != nil {
+		panic(err)
+	}
+	{{- end}}
+	defer _leaveTask()
+	{{if .Results}}return {{end}}_w.tasks.{{.Name}}({{.CallArgs}})
+}
+`
+
+const drainHelpersTpl = `
+// _drainTasks registers the tasks of [{{.StructName}}] on the worker, tracking the calls in flight for Shutdown.
+type _drainTasks struct {
+	tasks *{{.StructName}}
+}
+
+// ErrWorkerDraining is the error of the task calls received after Shutdown started,
+// returned by the tasks following the error-last convention, panicked by the others.
+var ErrWorkerDraining = errors.New("worker is draining")
+
+// _workerDrain tracks the task calls in flight.
+var _workerDrain struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight sync.WaitGroup
+	once     sync.Once
+	err      error
+}
+
+func _enterTask() error {
+	_workerDrain.mu.Lock()
+	defer _workerDrain.mu.Unlock()
+	if _workerDrain.draining {
+		return ErrWorkerDraining
+	}
+	_workerDrain.inFlight.Add(1)
+	return nil
+}
+
+func _leaveTask() {
+	_workerDrain.inFlight.Done()
+}
+
+// Shutdown stops accepting new task calls and waits for the calls in flight until they finish or drainTimeout passes
+{{- if .CloseHook}},
+// then closes the tasks with [{{.StructName}}.Close]{{end}}. Only the first call shuts down, the others return its result.
+func Shutdown(drainTimeout time.Duration) error {
+	_workerDrain.once.Do(func() {
+		_workerDrain.mu.Lock()
+		_workerDrain.draining = true
+		_workerDrain.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			_workerDrain.inFlight.Wait()
+			close(done)
+		}()
+		timer := time.NewTimer(drainTimeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			_workerDrain.err = fmt.Errorf("drain timed out after %s with task calls in flight", drainTimeout)
+		}
+		{{- if .CloseHook}}
+		if err := RayTasks.tasks.Close(); err != nil {
+			_workerDrain.err = errors.Join(_workerDrain.err, fmt.Errorf("close {{.StructName}}: %w", err))
+		}
+		{{- end}}
+	})
+	return _workerDrain.err
+}
+
+// ShutdownOnSignal runs Shutdown with drainTimeout when the process receives one of the signals
+// (SIGTERM and SIGINT by default), then exits the process, with status 1 if Shutdown failed.
+func ShutdownOnSignal(drainTimeout time.Duration, signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		sig := <-ch
+		log.Printf("received %s, draining the task calls in flight", sig)
+		if err := Shutdown(drainTimeout); err != nil {
+			log.Printf("shutdown: %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+}
+`
+
+var (
+	drainMethodTmpl  = template.Must(template.New("drainMethod").Parse(drainMethodTpl))
+	drainHelpersTmpl = template.Must(template.New("drainHelpers").Parse(drainHelpersTpl))
+)
+
+// DrainMethodDef is the template data of drainMethodTpl.
+type DrainMethodDef struct {
+	Name         string
+	Params       string
+	CallArgs     string
+	Results      string // named results if ReturnsError, e.g. "_r0 int, _err error"
+	ReturnsError bool
+}
+
+// isCloseHook reports whether the method is the `Close() error` method of the tasks struct, run by Shutdown.
+func isCloseHook(m Method) bool {
+	return m.CanonicalSignature() == "Close()(error)"
+}
+
+// drainsTasks reports whether the tasks are registered through the _drainTasks wrapper, see drainMethodTpl.
+func (g *Generator) drainsTasks() bool {
+	return g.opts.GracefulShutdown && g.splitWorker() && g.tasksStruct != ""
+}
+
+// registeredTasks returns the methods of the tasks struct registered on the worker:
+// the tasks, and their context, IO, idempotent, versioned and protobuf variants.
+func (g *Generator) registeredTasks() []Method {
+	var methods []Method
+	for _, m := range g.tasks {
+		if m.TakesContext() { // called by its context variant only
+			m = contextTask(m)
+		}
+		methods = append(methods, m)
+	}
+	for _, m := range g.ioTasks {
+		methods = append(methods, ioTask(m))
+	}
+	for _, m := range g.idempotentTasks {
+		im := m
+		im.Name = m.Name + "Idempotent"
+		im.Params = append([]Param{{Name: "_key", Type: "string", CanonicalType: "string", IdentifiableType: "string"}}, m.Params...)
+		methods = append(methods, im)
+	}
+	for _, m := range g.versionedTasks {
+		for _, v := range g.opts.taskVersions() {
+			vm := m
+			if m.TakesContext() {
+				vm = contextTask(m)
+			}
+			vm.Name = versionedTaskName(m.Name, v)
+			methods = append(methods, vm)
+		}
+	}
+	for _, m := range g.protoTasks {
+		pm := m
+		pm.Name = m.Name + "Proto"
+		pm.IsVariadic = false
+		pm.Params = []Param{{Name: "data", Type: "[]byte", CanonicalType: "[]byte", IdentifiableType: "slice_byte"}}
+		pm.Results = []Result{{Type: "[]byte", CanonicalType: "[]byte"}, {Type: "error", CanonicalType: "error", IsError: true}}
+		methods = append(methods, pm)
+	}
+	return methods
+}
+
+// generateDrain generates the worker-side wrapper of the tasks and the shutdown handler,
+// it must be called before dumping imports.
+func (g *Generator) generateDrain(buf *bytes.Buffer) {
+	if !g.drainsTasks() {
+		return
+	}
+	for _, pkg := range []string{"errors", "fmt", "log", "os", "os/signal", "sync", "syscall", "time"} {
+		g.importStore.AddImport(pkg)
+	}
+	err := drainHelpersTmpl.Execute(buf, struct {
+		StructName string
+		CloseHook  bool
+	}{g.tasksStruct, g.closeHook})
+	if err != nil {
+		panic(err)
+	}
+	for _, m := range g.registeredTasks() {
+		params, callArgs, _ := callSignature(m)
+		def := DrainMethodDef{Name: m.Name, Params: params, CallArgs: callArgs, ReturnsError: m.ReturnsError()}
+		results := make([]string, len(m.Results))
+		for i, r := range m.Results {
+			results[i] = r.Type
+			if def.ReturnsError {
+				results[i] = fmt.Sprintf("_r%d %s", i, r.Type)
+			}
+		}
+		if def.ReturnsError {
+			results[len(results)-1] = "_err error"
+		}
+		def.Results = strings.Join(results, ", ")
+		if err := drainMethodTmpl.Execute(buf, def); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// filterCloseHook drops the `Close() error` method from the tasks with -graceful-shutdown, it's run by Shutdown instead.
+func (g *Generator) filterCloseHook() {
+	if !g.opts.GracefulShutdown {
+		return
+	}
+	for i, m := range g.tasks {
+		if isCloseHook(m) {
+			g.logger.Debug("Close is run by Shutdown, it's not a task", "struct", g.tasksStruct)
+			g.skip(m, "run by Shutdown with -graceful-shutdown")
+			g.tasks = append(g.tasks[:i:i], g.tasks[i+1:]...)
+			g.closeHook = true
+			return
+		}
+	}
+}
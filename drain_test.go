@@ -0,0 +1,42 @@
+package goraygen
+
+import (
+	"go/format"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateDrain(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (*Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Open(name string) (int, error) { return 0, nil }
+
+func (Tasks) Close() error { return nil }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{SplitWorker: true, GracefulShutdown: true, TaskVersion: 2, TaskVersionAliases: 1})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+
+	client, err := format.Source([]byte(g.generateCode()))
+	require.NoError(t, err)
+	require.NotContains(t, string(client), "func Close(") // run by Shutdown, not a task
+
+	code, err := format.Source([]byte(g.generateWorkerCode()))
+	require.NoError(t, err)
+	worker := string(code)
+	require.Contains(t, worker, "RayTasks = _drainTasks{&Tasks{}}")
+	require.Contains(t, worker, "func (_w _drainTasks) Divide(a int64, b int64) (int64, int64) {\n\tif err := _enterTask(); err != nil {\n\t\tpanic(err)\n\t}\n\tdefer _leaveTask()\n\treturn _w.tasks.Divide(a, b)\n}")
+	require.Contains(t, worker, "func (_w _drainTasks) Open(name string) (_r0 int, _err error) {\n\tif _err = _enterTask(); _err != nil {\n\t\treturn\n\t}")
+	require.Contains(t, worker, "func (_w _drainTasks) Divide_v1(a int64, b int64) (int64, int64) {")
+	require.NotContains(t, worker, "func (_w _drainTasks) Close(")
+	require.Contains(t, worker, "if err := RayTasks.tasks.Close(); err != nil {")
+	require.Contains(t, worker, "func ShutdownOnSignal(drainTimeout time.Duration, signals ...os.Signal) {")
+}
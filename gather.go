@@ -0,0 +1,167 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+/*
+	type DivideOutput struct {
+		R0 int64
+		R1 int64
+	}
+
+func WaitAllDivide(ctx context.Context, refs []DivideResultRef) ([]DivideOutput, error)
+func WaitAnyDivide(ctx context.Context, refs []DivideResultRef) (int, DivideOutput, error)
+*/
+const outputStructTpl = `
+// {{.Name}}Output holds the results of a remote [{{.Name}}] call.
+type {{.Name}}Output struct {
+	{{range .Fields}}{{.Name}} {{.Type}}
+	{{end}}
+}
+`
+
+const gatherTpl = `
+// WaitAll{{.Name}} waits for the results of the remote [{{.Name}}] calls, in the order of refs.
+// It returns on the first failed call or when ctx is done.
+func WaitAll{{.Name}}(ctx context.Context, refs []{{.Name}}ResultRef) ({{if .OutputType}}[]{{.OutputType}}, {{end}}error) {
+	{{- if .OutputType}}
+	outputs := make([]{{.OutputType}}, len(refs))
+	{{- end}}
+	for i, ref := range refs {
+		var err error
+		{{if .OutputType}}{{.Targets "outputs[i]"}}, err = ref.Get(ctx){{else}}err = ref.Wait(ctx){{end}}
+		if err != nil {
+			return {{if .OutputType}}nil, {{end}}fmt.Errorf("{{.Name}} #%d: %w", i, err)
+		}
+	}
+	return {{if .OutputType}}outputs, {{end}}nil
+}
+
+// WaitAny{{.Name}} waits for the first finished of the remote [{{.Name}}] calls, returning its index in refs{{if .OutputType}} with its results{{end}}.
+// The index is -1 if refs is empty or ctx is done first.
+func WaitAny{{.Name}}(ctx context.Context, refs []{{.Name}}ResultRef) (int, {{if .OutputType}}{{.OutputType}}, {{end}}error) {
+	type result struct {
+		i int
+		{{- if .OutputType}}
+		output {{.OutputType}}
+		{{- end}}
+		err error
+	}
+	if len(refs) == 0 {
+		{{- if .OutputType}}
+		var output {{.OutputType}}
+		{{- end}}
+		return -1, {{if .OutputType}}output, {{end}}fmt.Errorf("WaitAny{{.Name}}: no refs")
+	}
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel() // stop waiting for the other calls
+	ch := make(chan result, len(refs))
+	for i, ref := range refs {
+		go func(i int, ref {{.Name}}ResultRef) {
+			res := result{i: i}
+			{{if .OutputType}}{{.Targets "res.output"}}, res.err = ref.Get(waitCtx){{else}}res.err = ref.Wait(waitCtx){{end}}
+			ch <- res
+		}(i, ref)
+	}
+	res := <-ch
+	if res.err != nil && ctx.Err() != nil {
+		res.i = -1
+	}
+	return res.i, {{if .OutputType}}res.output, {{end}}res.err
+}
+`
+
+var (
+	outputStructTmpl = template.Must(template.New("outputStruct").Parse(outputStructTpl))
+	gatherTmpl       = template.Must(template.New("gather").Parse(gatherTpl))
+)
+
+// OutputDef describes how the results of a remote call are gathered in one value.
+type OutputDef struct {
+	Name       string
+	OutputType string     // the result type for single result method, NameOutput for multiple results, empty for no result
+	Fields     []FieldDef // fields of NameOutput, empty for less than 2 results
+}
+
+// Targets returns the assignment targets of the results of ResultRef.Get into the output value v,
+// e.g. "v.R0, v.R1" for NameOutput, or "v.Quo, v.Rem" with named results.
+func (d OutputDef) Targets(v string) string {
+	if len(d.Fields) == 0 {
+		return v
+	}
+	targets := make([]string, len(d.Fields))
+	for i, f := range d.Fields {
+		targets[i] = v + "." + f.Name
+	}
+	return strings.Join(targets, ", ")
+}
+
+// outputDef returns how the results of the method, whose wrapper function is named name, are gathered.
+// The error of the error-last convention is not part of the output.
+func outputDef(name string, method Method) OutputDef {
+	def := OutputDef{Name: name}
+	results := method.Results
+	if method.ReturnsError() {
+		results = results[:len(results)-1]
+	}
+	switch len(results) {
+	case 0:
+	case 1:
+		def.OutputType = results[0].Type
+	default:
+		def.OutputType = name + "Output"
+		def.Fields = resultFields(results)
+	}
+	return def
+}
+
+// resultFields returns the fields of the output struct holding the results: named after the named results,
+// e.g. Quo and Rem for (quo, rem int64), or after the types of the unnamed ones, e.g. File and Bytes for (*os.File, []byte).
+// The fields whose names would collide, e.g. for (int64, int64), are named by position: R0, R1...
+func resultFields(results []Result) []FieldDef {
+	fields := make([]FieldDef, len(results))
+	count := make(map[string]int, len(results))
+	for i, r := range results {
+		fields[i] = FieldDef{Name: exportedFieldName(r.Name), Type: r.Type}
+		if r.Name == "" && r.GoType != nil {
+			fields[i].Name = typeFieldName(r.GoType)
+		}
+		count[fields[i].Name]++
+	}
+	names := make(map[string]bool, len(results))
+	for i := range fields {
+		if fields[i].Name == "" || count[fields[i].Name] > 1 {
+			fields[i].Name = fmt.Sprintf("R%d", i)
+		}
+		names[fields[i].Name] = true
+	}
+	if len(names) < len(fields) { // e.g. the named result r1 and the unnamed R1
+		for i := range fields {
+			fields[i].Name = fmt.Sprintf("R%d", i)
+		}
+	}
+	return fields
+}
+
+// generateOutputStruct generates the NameOutput struct holding the results of the method, if it has multiple results.
+func generateOutputStruct(buf *bytes.Buffer, name string, method Method) {
+	def := outputDef(name, method)
+	if len(def.Fields) == 0 {
+		return
+	}
+	if err := outputStructTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// generateGather generates the typed WaitAll and WaitAny helpers over the result refs of the method,
+// whose wrapper function is named name. They are built on the result refs and the output struct.
+func generateGather(buf *bytes.Buffer, name string, method Method) {
+	if err := gatherTmpl.Execute(buf, outputDef(name, method)); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,81 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateGatherHelpers(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Open(name string) (int, error) { return 0, nil }
+
+func (Tasks) Ping() error { return nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Get() int { return c.n }
+`}, Options{ResultRefs: true, GatherHelpers: true})
+
+	require.Contains(t, code, "type DivideOutput struct {\n\tR0 int64\n\tR1 int64\n}")
+	require.Contains(t, code, "func WaitAllDivide(ctx context.Context, refs []DivideResultRef) ([]DivideOutput, error) {")
+	require.Contains(t, code, "outputs[i].R0, outputs[i].R1, err = ref.Get(ctx)")
+	require.Contains(t, code, "func WaitAnyDivide(ctx context.Context, refs []DivideResultRef) (int, DivideOutput, error) {")
+	require.Contains(t, code, "res.output.R0, res.output.R1, res.err = ref.Get(waitCtx)")
+
+	require.Contains(t, code, "func WaitAllOpen(ctx context.Context, refs []OpenResultRef) ([]int, error) {")
+	require.Contains(t, code, "func WaitAnyOpen(ctx context.Context, refs []OpenResultRef) (int, int, error) {")
+	require.NotContains(t, code, "OpenOutput")
+
+	require.Contains(t, code, "func WaitAllPing(ctx context.Context, refs []PingResultRef) error {")
+	require.Contains(t, code, "func WaitAnyPing(ctx context.Context, refs []PingResultRef) (int, error) {")
+	require.Contains(t, code, "res.err = ref.Wait(waitCtx)")
+
+	require.Contains(t, code, "func WaitAllCounter_Get(ctx context.Context, refs []Counter_GetResultRef) ([]int, error) {")
+}
+
+func TestNamedResults(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (quo, rem int64) { return a / b, a % b }
+
+func (Tasks) Stat(name string) (size int64, _ bool, err error) { return 0, false, nil }
+
+func (Tasks) Pair() (x int, X string) { return 0, "" }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Get() (n int) { return c.n }
+`}, Options{ResultRefs: true, GatherHelpers: true})
+
+	require.Contains(t, code, "// original task: [Tasks.Divide]\n// results: quo int64, rem int64\nfunc Divide[")
+	require.Contains(t, code, "type DivideOutput struct {\n\tQuo int64\n\tRem int64\n}")
+	require.Contains(t, code, "outputs[i].Quo, outputs[i].Rem, err = ref.Get(ctx)")
+
+	require.Contains(t, code, "// results: size int64, _ bool, err error\n")
+	require.Contains(t, code, "type StatOutput struct {\n\tSize int64\n\tBool bool\n}")
+	// the fields of x and X would collide
+	require.Contains(t, code, "type PairOutput struct {\n\tR0 int\n\tR1 string\n}")
+
+	require.Contains(t, code, "// original actor method: [Counter.Get]\n// results: n int\n")
+	require.NotContains(t, code, "// results: \n")
+}
@@ -0,0 +1,158 @@
+package goraygen
+
+import (
+	"bytes"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+const gobRegistrationFileName = "ray_gob_registration.go"
+
+/*
+	func init() {
+		gob.Register(Point{})
+		gob.Register(*new(IDs))
+		gob.Register(time.Time{})
+	}
+*/
+const gobRegistrationTpl = `
+{{- if .BuildConstraint}}//go:build {{.BuildConstraint}}
+{{end}}
+// {{.Header}}
+//
+// This file was generated by goray.
+// It registers the concrete types of the ray task and actor signatures with encoding/gob,
+// so their values can be carried in interface values, e.g. in ` + "`any`" + ` results.
+//
+// To regenerate this file, run:
+//
+//	goraygen -gob-register <package-path>
+package {{.PkgName}}
+
+{{.Imports}}
+{{- if .Types}}
+
+func init() {
+	{{- range .Types}}
+	gob.Register({{.}})
+	{{- end}}
+}
+{{- end}}
+`
+
+var gobRegistrationTmpl = template.Must(template.New("gobRegistration").Parse(gobRegistrationTpl))
+
+// gobTypeWalker collects the concrete named types reachable from a type: through pointers, slices, arrays,
+// maps and the exported struct fields, i.e. what gob encodes.
+type gobTypeWalker struct {
+	pkgPath     string // the package the registration is generated into
+	importStore *ImportStore
+	visited     map[types.Type]bool
+	zeros       map[string]string // type name -> zero value expression
+}
+
+func (w *gobTypeWalker) walk(typ types.Type) {
+	if typ == nil || w.visited[typ] {
+		return
+	}
+	w.visited[typ] = true
+	switch t := typ.(type) {
+	case *types.Named:
+		w.addNamed(t)
+		w.walk(t.Underlying())
+	case *types.Pointer:
+		w.walk(t.Elem())
+	case *types.Slice:
+		w.walk(t.Elem())
+	case *types.Array:
+		w.walk(t.Elem())
+	case *types.Map:
+		w.walk(t.Key())
+		w.walk(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if t.Field(i).Exported() {
+				w.walk(t.Field(i).Type())
+			}
+		}
+	}
+}
+
+// addNamed adds the named type if it's concrete, gob-encodable and can be referred to from w.pkgPath.
+func (w *gobTypeWalker) addNamed(t *types.Named) {
+	obj := t.Obj()
+	if obj.Pkg() == nil || t.TypeParams().Len() > 0 || t.TypeArgs().Len() > 0 {
+		return // predeclared (e.g. error) or generic
+	}
+	if !obj.Exported() && obj.Pkg().Path() != w.pkgPath {
+		return
+	}
+	switch t.Underlying().(type) {
+	case *types.Interface, *types.Signature, *types.Chan:
+		return
+	}
+	name := analysis.TypeName(t, w.pkgPath, w.importStore)
+	w.zeros[name] = "*new(" + name + ")"
+	if _, ok := t.Underlying().(*types.Struct); ok {
+		w.zeros[name] = name + "{}"
+	}
+}
+
+// gobTypes returns the zero value expressions of the concrete named types in the task and actor signatures,
+// as seen from the package pkgPath, sorted by type name. The imports they need are added to importStore.
+func (g *Generator) gobTypes(pkgPath string, importStore *ImportStore) []string {
+	w := &gobTypeWalker{pkgPath: pkgPath, importStore: importStore, visited: map[types.Type]bool{}, zeros: map[string]string{}}
+	for _, m := range g.signatureMethods() {
+		for _, p := range m.Params {
+			w.walk(p.GoType)
+		}
+		for _, r := range m.Results {
+			w.walk(r.GoType)
+		}
+	}
+	names := make([]string, 0, len(w.zeros))
+	for name := range w.zeros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	zeros := make([]string, len(names))
+	for i, name := range names {
+		zeros[i] = w.zeros[name]
+	}
+	return zeros
+}
+
+// writeGobRegistrations generates the gob registration file into outputDir, the directory of the output package,
+// and into the scanned package if it's another package, so both the driver and the worker register the types.
+func (g *Generator) writeGobRegistrations(outputDir string) error {
+	if err := g.writeGobRegistration(outputDir, g.outputPkgName, g.outputPkgPath); err != nil {
+		return err
+	}
+	if g.outputPkgPath == g.pkg.PkgPath || len(g.pkg.GoFiles) == 0 {
+		return nil
+	}
+	return g.writeGobRegistration(filepath.Dir(g.pkg.GoFiles[0]), g.pkg.Name, g.pkg.PkgPath)
+}
+
+// writeGobRegistration generates the gob registration file into the directory of the package pkgName (at pkgPath).
+func (g *Generator) writeGobRegistration(dir, pkgName, pkgPath string) error {
+	importStore := g.newImportStore()
+	importStore.AddImport("encoding/gob")
+	gobTypes := g.gobTypes(pkgPath, importStore)
+	if len(gobTypes) == 0 {
+		g.logger.Debug("No concrete types to register with gob", "package", pkgPath)
+	}
+	var buf bytes.Buffer
+	err := gobRegistrationTmpl.Execute(&buf, struct {
+		Header, BuildConstraint, PkgName, Imports string
+		Types                                     []string
+	}{g.opts.generatedHeader(), g.opts.BuildConstraint, pkgName, importStore.ImportBlock(g.modulePath()), gobTypes})
+	if err != nil {
+		return err
+	}
+	return g.writeFile(buf.String(), filepath.Join(dir, g.outputFileName(gobRegistrationFileName)))
+}
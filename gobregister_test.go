@@ -0,0 +1,63 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/ray4go/goraygen/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGobTypes(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Move(p *Point, path Path) (map[string][]Label, error) { return nil, nil }
+
+func (Tasks) Process(items ...Item) any { return nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(opts Options) *Counter { return &Counter{} }
+
+type Point struct{ X, Y int }
+
+type Path []Point
+
+type Label string
+
+type Item struct {
+	Meta  Meta
+	inner secret
+}
+
+type Meta struct {
+	Tags   []Label
+	Parent *Meta
+}
+
+type secret struct{}
+
+type Options struct{ Handler Handler }
+
+type Handler interface{ Handle() }
+
+type Counter struct{ n int }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{GobRegister: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+
+	importStore := analysis.NewImportStore()
+	require.Equal(t, []string{"Item{}", "*new(Label)", "Meta{}", "Options{}", "*new(Path)", "Point{}"}, g.gobTypes(pkg.PkgPath, importStore))
+	require.Empty(t, importStore.DumpImportExprs())
+
+	importStore = analysis.NewImportStore()
+	require.Equal(t, []string{"mypkg.Item{}", "*new(mypkg.Label)", "mypkg.Meta{}", "mypkg.Options{}", "*new(mypkg.Path)", "mypkg.Point{}"},
+		g.gobTypes("example.com/client", importStore))
+	require.Equal(t, []string{`"example.com/mypkg"`}, importStore.DumpImportExprs())
+}
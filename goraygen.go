@@ -0,0 +1,237 @@
+// Package goraygen generates the type-safe wrappers of the Ray tasks and actors of Go packages.
+// The goraygen command (see cmd/goraygen) is a thin layer over it, build tools and scripts embed the generation with New:
+//
+//	err := goraygen.New(
+//		goraygen.WithMarker("// raytasks"),
+//		goraygen.WithOutputFile("ray_wrappers.go"),
+//		goraygen.WithBackends("tasks", "actors", "worker"),
+//	).Run(ctx, "./pkg/tasks", "./services/...")
+//
+// The discovery of the tasks and actors is the analysis package.
+package goraygen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// Option configures a Runner.
+type Option func(o *Options) error
+
+// Runner runs the generation with its options, see New.
+type Runner struct {
+	opts Options
+	err  error // the error of the first invalid option, returned by Run
+}
+
+// New returns a Runner with the options applied to the default Options, i.e. the defaults of the goraygen command.
+func New(options ...Option) *Runner {
+	r := &Runner{opts: Options{ReceiverPolicy: ReceiverBoth, Codec: CodecMsgpack, Lang: LangGo, MetricsNamespace: "goray"}}
+	for _, option := range options {
+		if err := option(&r.opts); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	return r
+}
+
+// Options returns the options of the runner.
+func (r *Runner) Options() Options {
+	return r.opts
+}
+
+// Run generates the wrappers of the packages matching the patterns, in order: a package dir, an import path
+// (with WithOutputDir), a Go workspace root (see RunWorkspace), or a pattern with "..." like "./...",
+// generating next to every matched package with annotated structs. A failed package doesn't stop the others,
+// the errors are joined. It stops before the next package once ctx is done.
+func (r *Runner) Run(ctx context.Context, patterns ...string) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(patterns) == 0 {
+		return fmt.Errorf("no package to generate")
+	}
+	opts := r.opts
+	opts.outputClaims = &outputClaims{} // see runMatrix
+	var errs []error
+	for _, pattern := range patterns {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if !strings.Contains(pattern, "...") {
+			if err := run(pattern, opts); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		pkgs, err := analysis.Load(opts.packagesConfig(""), pattern)
+		if err != nil {
+			errs = append(errs, withExitCode(exitLoad, err))
+			continue
+		}
+		if err := generatePackages(ctx, pkgs, opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithOptions replaces the options, for the settings without a dedicated Option. The later options apply on top of it.
+func WithOptions(opts Options) Option {
+	return func(o *Options) error {
+		*o = opts
+		return nil
+	}
+}
+
+// WithMarker sets the marker of a struct like the -marker flag, e.g. "actors=// myactors" or "tasks=re:^//\s*tasks$",
+// a marker without the "tasks=", "actors=" or "actor=" prefix is the marker of the tasks struct, e.g. "// raytasks".
+func WithMarker(marker string) Option {
+	return func(o *Options) error {
+		if kind, _, ok := strings.Cut(marker, "="); !ok || (kind != "tasks" && kind != "actors" && kind != "actor") {
+			marker = "tasks=" + marker
+		}
+		return o.applyTargetFlags([]string{marker}, nil)
+	}
+}
+
+// WithStruct sets the name of a struct like the -struct flag, e.g. "tasks=MyTasks".
+func WithStruct(kindName string) Option {
+	return func(o *Options) error {
+		return o.applyTargetFlags(nil, []string{kindName})
+	}
+}
+
+// WithOutputFile sets the name of the generated wrappers file, default is "ray_workload_wrappers.go".
+func WithOutputFile(name string) Option {
+	return func(o *Options) error {
+		if name == "" || strings.ContainsAny(name, `/\`) || !strings.HasSuffix(name, ".go") {
+			return fmt.Errorf("invalid output file %q, expect a .go file name", name)
+		}
+		o.OutputFileName = name
+		return nil
+	}
+}
+
+// WithOutputPkg sets the directory of the package to generate into relative to the scanned package, like the -output-pkg flag.
+func WithOutputPkg(dir string) Option {
+	return func(o *Options) error {
+		if err := checkOutputPkg(dir, o.OutputDir); err != nil {
+			return err
+		}
+		o.OutputPkg = dir
+		return nil
+	}
+}
+
+// WithOutput sets the template of the path of the generated wrappers file like the -output flag,
+// e.g. "{{.Package}}_ray_gen.go" or "{{.Struct}}_gen.go" for a file per target struct.
+func WithOutput(path string) Option {
+	return func(o *Options) error {
+		if _, err := parseOutput(path); err != nil {
+			return err
+		}
+		o.Output = path
+		return nil
+	}
+}
+
+// WithBuildConstraint sets the `//go:build` constraint of the generated Go files like the -build-tags flag, e.g. "goray".
+func WithBuildConstraint(expr string) Option {
+	return func(o *Options) error {
+		formatted, err := ParseBuildConstraint(expr)
+		if err != nil {
+			return err
+		}
+		o.BuildConstraint = formatted
+		return nil
+	}
+}
+
+// WithImportAlias sets the preferred name of an import of the generated files like the -import-alias flag,
+// e.g. WithImportAlias("github.com/ray4go/go-ray/ray", "goray").
+func WithImportAlias(path, alias string) Option {
+	return func(o *Options) error {
+		path, alias, err := ParseImportAlias(path + "=" + alias)
+		if err != nil {
+			return err
+		}
+		if o.ImportAliases == nil {
+			o.ImportAliases = make(map[string]string)
+		}
+		o.ImportAliases[path] = alias
+		return nil
+	}
+}
+
+// WithOutputDir sets the directory of the package to generate into, like the -output-dir flag.
+func WithOutputDir(dir string) Option {
+	return func(o *Options) error {
+		o.OutputDir = dir
+		return nil
+	}
+}
+
+// WithBackends sets the backends to run instead of the ones selected by the options, like the -backends flag.
+func WithBackends(names ...string) Option {
+	return func(o *Options) error {
+		for _, name := range names {
+			if _, found := lookupBackend(name); !found {
+				return fmt.Errorf("unknown backend %q, expect some of %s", name, strings.Join(backendNames(), ", "))
+			}
+		}
+		o.Backends = names
+		return nil
+	}
+}
+
+// WithBuildTags adds build tags to apply when loading packages, like the -tags flag.
+func WithBuildTags(tags ...string) Option {
+	return func(o *Options) error {
+		o.BuildTags = append(o.BuildTags, tags...)
+		return nil
+	}
+}
+
+// WithTypeMapping adds the mapping of a type, like the -map-type flag with the conversions, see TypeMapping.
+func WithTypeMapping(m TypeMapping) Option {
+	return func(o *Options) error {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		o.TypeMappings = append(o.TypeMappings, m)
+		return nil
+	}
+}
+
+// WithDryRun renders the outputs without writing them, reporting them on the log, see Options.DryRun.
+func WithDryRun() Option {
+	return func(o *Options) error {
+		o.DryRun = true
+		return nil
+	}
+}
+
+// WithStdout writes the content of the outputs to w instead of the files.
+func WithStdout(w io.Writer) Option {
+	return func(o *Options) error {
+		o.Stdout = w
+		return nil
+	}
+}
+
+// WithConfigFile applies the config file, see Config. The options after it override its settings.
+func WithConfigFile(path string) Option {
+	return func(o *Options) error {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		return cfg.apply(o, nil)
+	}
+}
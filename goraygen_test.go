@@ -0,0 +1,44 @@
+package goraygen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	r := New(
+		WithMarker("// mytasks"),
+		WithMarker("actors=re:^//\\s*actors$"),
+		WithStruct("actor=Counter"),
+		WithOutputFile("ray_wrappers.go"),
+		WithBackends("tasks", "worker"),
+		WithBuildTags("integration"),
+	)
+	require.NoError(t, r.err)
+	opts := r.Options()
+	require.Equal(t, CommentMatcher("// mytasks"), opts.tasksMatcher())
+	require.Equal(t, "comment matching /^//\\s*actors$/", opts.actorsMatcher().String())
+	require.Equal(t, NameMatcher("Counter"), opts.actorMatcher())
+	require.Equal(t, "ray_wrappers.go", opts.outputFileName())
+	require.Equal(t, []string{"tasks", "worker"}, opts.Backends)
+	require.Equal(t, []string{"integration"}, opts.BuildTags)
+	require.Equal(t, ReceiverBoth, opts.ReceiverPolicy)
+
+	opts = New(WithOptions(Options{SplitWorker: true}), WithOutputDir("client")).Options()
+	require.True(t, opts.SplitWorker)
+	require.Equal(t, "client", opts.OutputDir)
+
+	ctx := context.Background()
+	require.ErrorContains(t, New(WithBackends("swagger")).Run(ctx, "./"), `unknown backend "swagger"`)
+	require.ErrorContains(t, New(WithOutputFile("out/wrappers.go")).Run(ctx, "./"), "invalid output file")
+	require.ErrorContains(t, New().Run(ctx), "no package")
+	err := New().Run(ctx, "example.com/missing/a", "example.com/missing/b") // both are reported
+	require.ErrorContains(t, err, "example.com/missing/a")
+	require.ErrorContains(t, err, "example.com/missing/b")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, New().Run(cancelled, "./"), context.Canceled)
+}
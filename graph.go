@@ -0,0 +1,208 @@
+package goraygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const graphFileName = "ray_tasks.dot"
+
+/*
+The task graph links the producers to the consumers: the result of a chainable task or actor method (see isChainable)
+can be passed to the params of the same type, e.g. with Square(n int64) int64 and Sum(nums ...int64) int64:
+
+	digraph Tasks {
+		"Square" -> "Square" [label="n int64"];
+		"Square" -> "Sum" [label="nums ...int64"];
+	}
+
+With -task-graph, it is generated as the TasksGraph variable and written into ray_tasks.dot of the scanned package;
+the graph subcommand only prints it:
+
+	goraygen graph [-format dot|json] <package-path>
+*/
+const taskGraphDef = `
+// TaskGraphNode is a task, or an actor method named "<actor>.<method>".
+type TaskGraphNode struct {
+	Name      string
+	Signature string
+}
+
+// TaskGraphEdge links the producer task whose result can be passed to the param of the consumer task.
+type TaskGraphEdge struct {
+	From, To string
+	Param    string // the param of To, with its type
+}
+`
+
+// TaskGraph is the dependency graph of the tasks and actor methods of a package.
+type TaskGraph struct {
+	Name  string          `json:"name"` // the name of the tasks struct
+	Nodes []TaskGraphNode `json:"nodes"`
+	Edges []TaskGraphEdge `json:"edges"`
+}
+
+// TaskGraphNode is a task, or an actor method named "<actor>.<method>".
+type TaskGraphNode struct {
+	Name      string `json:"name"`
+	Actor     string `json:"actor,omitempty"`
+	Signature string `json:"signature"`
+}
+
+// TaskGraphEdge links the producer whose result can be passed to the param of the consumer.
+type TaskGraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Param string `json:"param"` // the param of To, e.g. "nums ...int64"
+}
+
+// taskGraph returns the dependency graph of the tasks and actor methods.
+// The params of the empty interface types are not linked, as any result could be passed to them.
+func (g *Generator) taskGraph() TaskGraph {
+	graph := TaskGraph{Name: g.tasksStruct}
+	var methods []Method
+	for _, m := range g.tasks {
+		graph.Nodes = append(graph.Nodes, TaskGraphNode{Name: m.Name, Signature: m.String()})
+		methods = append(methods, m)
+	}
+	for _, factory := range g.actorFactories {
+		for _, am := range g.actor2Methods[factory.Name] {
+			graph.Nodes = append(graph.Nodes, TaskGraphNode{Name: factory.Name + "." + am.Name, Actor: factory.Name, Signature: am.String()})
+			methods = append(methods, am)
+		}
+	}
+	for i, producer := range methods {
+		if !isChainable(producer) {
+			continue
+		}
+		for j, consumer := range methods {
+			for k, p := range consumer.Params {
+				if p.GoType == nil { // a generated method, e.g. the Restore of -checkpoints
+					continue
+				}
+				typ, param := p.GoType, p.Name+" "+p.Type
+				if consumer.IsVariadic && k == len(consumer.Params)-1 {
+					typ = typ.(*types.Slice).Elem()
+					param = p.Name + " ..." + strings.TrimPrefix(p.Type, "[]")
+				}
+				if iface, ok := typ.Underlying().(*types.Interface); ok && iface.Empty() {
+					continue
+				}
+				if types.Identical(producer.Results[0].GoType, typ) {
+					graph.Edges = append(graph.Edges, TaskGraphEdge{From: graph.Nodes[i].Name, To: graph.Nodes[j].Name, Param: param})
+				}
+			}
+		}
+	}
+	return graph
+}
+
+// DOT returns the graph in the Graphviz DOT language, the actor methods are clustered by actor.
+func (tg TaskGraph) DOT() string {
+	var buf bytes.Buffer
+	name := tg.Name
+	if name == "" {
+		name = "Tasks"
+	}
+	fmt.Fprintf(&buf, "digraph %s {\n\trankdir=LR;\n\tnode [shape=box];\n", strconv.Quote(name))
+	actor := ""
+	for _, n := range tg.Nodes {
+		if n.Actor != actor {
+			if actor != "" {
+				buf.WriteString("\t}\n")
+			}
+			fmt.Fprintf(&buf, "\tsubgraph %s {\n\t\tlabel=%s;\n", strconv.Quote("cluster_"+n.Actor), strconv.Quote("actor "+n.Actor))
+			actor = n.Actor
+		}
+		indent := "\t"
+		if actor != "" {
+			indent = "\t\t"
+		}
+		fmt.Fprintf(&buf, "%s%s [tooltip=%s];\n", indent, strconv.Quote(n.Name), strconv.Quote(n.Signature))
+	}
+	if actor != "" {
+		buf.WriteString("\t}\n")
+	}
+	for _, e := range tg.Edges {
+		fmt.Fprintf(&buf, "\t%s -> %s [label=%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(e.Param))
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// generateTaskGraph generates the TasksGraph variable of the dependency graph.
+func (g *Generator) generateTaskGraph(buf *bytes.Buffer) {
+	if g.tasksStruct == "" && len(g.actorFactories) == 0 {
+		return
+	}
+	graph := g.taskGraph()
+	name := graph.Name
+	if name == "" {
+		name = "Tasks"
+	}
+	buf.WriteString(taskGraphDef)
+	fmt.Fprintf(buf, "\n// %sGraph is the dependency graph of the tasks and actor methods, the DOT version is %s.\n", name, graphFileName)
+	fmt.Fprintf(buf, "var %sGraph = struct {\n\tNodes []TaskGraphNode\n\tEdges []TaskGraphEdge\n}{\n", name)
+	buf.WriteString("\tNodes: []TaskGraphNode{\n")
+	for _, n := range graph.Nodes {
+		fmt.Fprintf(buf, "\t\t{Name: %s, Signature: %s},\n", strconv.Quote(n.Name), strconv.Quote(n.Signature))
+	}
+	buf.WriteString("\t},\n\tEdges: []TaskGraphEdge{\n")
+	for _, e := range graph.Edges {
+		fmt.Fprintf(buf, "\t\t{From: %s, To: %s, Param: %s},\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(e.Param))
+	}
+	buf.WriteString("\t},\n}\n")
+}
+
+// writeTaskGraph writes the DOT graph into the scanned package dir.
+func (g *Generator) writeTaskGraph() error {
+	if (g.tasksStruct == "" && len(g.actorFactories) == 0) || len(g.pkg.GoFiles) == 0 {
+		return nil
+	}
+	outputFile := filepath.Join(filepath.Dir(g.pkg.GoFiles[0]), graphFileName)
+	return g.writeOutput(outputFile, []byte(g.taskGraph().DOT()))
+}
+
+// runGraphCommand runs the graph subcommand, printing the task graph of the package to w.
+func runGraphCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := fs.String("format", "dot", "output format, dot or json")
+	tags := fs.String("tags", "", "comma-separated list of build tags to apply when loading packages")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goraygen graph [-format dot|json] <package-path>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expect one package path")
+	}
+	if *format != "dot" && *format != "json" {
+		return fmt.Errorf("invalid format %q, expect dot or json", *format)
+	}
+	g := NewGenerator(Options{BuildTags: splitList(*tags)})
+	if err := g.loadPackage(fs.Arg(0)); err != nil {
+		return err
+	}
+	g.outputPkgName, g.outputPkgPath = g.pkg.Name, g.pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	graph := g.taskGraph()
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	}
+	_, err := io.WriteString(w, graph.DOT())
+	return err
+}
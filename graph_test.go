@@ -0,0 +1,82 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskGraph(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Square(n int64) int64 { return n * n }
+
+func (Tasks) Sum(nums ...int64) int64 { return 0 }
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Echo(v any) any { return v }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n: n} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(by int64) int64 { return 0 }
+`}
+	pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+	g := NewGenerator(Options{})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+
+	graph := g.taskGraph()
+	require.Equal(t, []TaskGraphNode{
+		{Name: "Square", Signature: "Square(n int64) (int64)"},
+		{Name: "Sum", Signature: "Sum(nums ...int64) (int64)"},
+		{Name: "Divide", Signature: "Divide(a int64, b int64) (int64, int64)"},
+		{Name: "Echo", Signature: "Echo(v any) (any)"},
+		{Name: "Counter.Incr", Actor: "Counter", Signature: "Incr(by int64) (int64)"},
+	}, graph.Nodes)
+	require.Contains(t, graph.Edges, TaskGraphEdge{From: "Square", To: "Sum", Param: "nums ...int64"})
+	require.Contains(t, graph.Edges, TaskGraphEdge{From: "Counter.Incr", To: "Divide", Param: "b int64"})
+	require.Contains(t, graph.Edges, TaskGraphEdge{From: "Sum", To: "Counter.Incr", Param: "by int64"})
+	require.Len(t, graph.Edges, 15) // 3 producers x 5 int64 params, Divide isn't chainable and Echo(v any) isn't linked
+
+	dot := graph.DOT()
+	require.Contains(t, dot, "digraph \"Tasks\" {\n")
+	require.Contains(t, dot, "\tsubgraph \"cluster_Counter\" {\n\t\tlabel=\"actor Counter\";\n\t\t\"Counter.Incr\" [tooltip=\"Incr(by int64) (int64)\"];\n\t}\n")
+	require.Contains(t, dot, "\t\"Square\" -> \"Sum\" [label=\"nums ...int64\"];\n")
+
+	code := generateFromSource(t, sources, Options{TaskGraph: true})
+	require.Contains(t, code, "var TasksGraph = struct {")
+	require.Contains(t, code, `{From: "Square", To: "Sum", Param: "nums ...int64"},`)
+
+	// the generated Snapshot and Restore of the checkpoint actors are nodes without links, e.g. from Load to Restore
+	code = generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Load(key string) []byte { return nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct {
+	n int ` + "`goray:\"state\"`" + `
+}
+
+func (c *Counter) Incr(by int64) int64 { return 0 }
+`}, Options{TaskGraph: true, Checkpoints: true})
+	require.Contains(t, code, `{Name: "Counter.Restore", Signature: "Restore(data []byte) (error)"},`)
+	require.NotContains(t, code, `To: "Counter.Restore"`)
+}
@@ -0,0 +1,132 @@
+package goraygen
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+)
+
+/*
+With -grpc-gateway, the tasks with a protobuf variant are the RPCs of a service in ray_tasks.proto:
+
+	service Tasks {
+	  rpc Divide(DivideRequest) returns (DivideResponse);
+	}
+
+served on the driver side by calling the protobuf variants of the tasks, see protoTaskTpl:
+
+	s := grpc.NewServer()
+	RegisterTasksGRPCGateway(s, ray.Option("num_cpus", 1))
+*/
+const grpcGatewayTpl = `
+// Register{{.Name}}GRPCGateway registers the {{.Service}} gRPC service of {{.ProtoFile}} on s. Every RPC calls the task
+// of [{{.DocLink}}] with the ray options and awaits its result, the messages are passed through without decoding.
+func Register{{.Name}}GRPCGateway(s grpc.ServiceRegistrar, options ...*ray.RayOption) {
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "{{.Service}}",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{{- range .Methods}}
+			{MethodName: "{{.}}", Handler: _grpcGatewayHandler("/{{$.Service}}/{{.}}", "{{.}}Proto")},
+			{{- end}}
+		},
+		Metadata: "{{.ProtoFile}}",
+	}, &_grpcGateway{options: options})
+}
+`
+
+// grpcGatewayHelpers is shared by the gRPC gateway services, generated once per file.
+const grpcGatewayHelpers = `
+// _grpcGateway is the implementation of the gRPC gateway services.
+type _grpcGateway struct {
+	options []*ray.RayOption
+}
+
+// _grpcMessage is a serialized protobuf message. It has the methods of the legacy protobuf messages,
+// so the proto codec of grpc serializes it with Marshal and Unmarshal, i.e. passes it through.
+type _grpcMessage struct {
+	data []byte
+}
+
+func (m *_grpcMessage) Reset()                   { m.data = nil }
+func (m *_grpcMessage) String() string           { return fmt.Sprintf("%x", m.data) }
+func (*_grpcMessage) ProtoMessage()              {}
+func (m *_grpcMessage) Marshal() ([]byte, error) { return m.data, nil }
+func (m *_grpcMessage) Unmarshal(b []byte) error {
+	m.data = append([]byte(nil), b...)
+	return nil
+}
+
+func _grpcGatewayHandler(fullMethod, task string) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := new(_grpcMessage)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		call := func(ctx context.Context, req any) (any, error) {
+			return srv.(*_grpcGateway).call(ctx, task, req.(*_grpcMessage))
+		}
+		if interceptor == nil {
+			return call(ctx, in)
+		}
+		return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, call)
+	}
+}
+
+// call calls the task with the request and awaits the response, or ctx to be done.
+// The error of the task is returned as is, the failure of the remote call with the Internal code.
+func (gw *_grpcGateway) call(ctx context.Context, task string, in *_grpcMessage) (*_grpcMessage, error) {
+	future := NewRemoteFunc[*Future2[[]byte, error]](task, []any{in.data}).Remote(gw.options...)
+	type result struct {
+		out *_grpcMessage
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		data, err, callErr := future.Get()
+		if callErr != nil {
+			err = status.Error(codes.Internal, callErr.Error())
+		}
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		ch <- result{out: &_grpcMessage{data: data}}
+	}()
+	select {
+	case r := <-ch:
+		return r.out, r.err
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+`
+
+var grpcGatewayTmpl = template.Must(template.New("grpcGateway").Parse(grpcGatewayTpl))
+
+// grpcServiceName returns the full name of the gRPC service of the tasks, e.g. "mypkg.Tasks".
+func (g *Generator) grpcServiceName() string {
+	return g.pkg.Name + "." + g.tasksStruct
+}
+
+// generateGRPCGateway generates the driver-side gRPC service of the tasks with a protobuf variant, see prepareProto.
+func (g *Generator) generateGRPCGateway(buf *bytes.Buffer, docQualifier string) {
+	if !g.opts.GRPCGateway || len(g.protoTasks) == 0 {
+		return
+	}
+	err := grpcGatewayTmpl.Execute(buf, struct {
+		Name, Service, DocLink, ProtoFile string
+		Methods                           []string
+	}{
+		Name:      g.tasksStruct,
+		Service:   g.grpcServiceName(),
+		DocLink:   docQualifier + g.tasksStruct,
+		ProtoFile: protoFileName,
+		Methods:   gslice.Map(g.protoTasks, func(m Method) string { return m.Name }),
+	})
+	if err != nil {
+		panic(err)
+	}
+	buf.WriteString(grpcGatewayHelpers)
+}
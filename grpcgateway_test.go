@@ -0,0 +1,43 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateGRPCGateway(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Lookup(m map[float64]int) int { return 0 }
+`}
+	pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+	g := NewGenerator(Options{Proto: true, GRPCGateway: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	g.collectActorHandles()
+	code := g.generateCode()
+
+	require.Contains(t, code, "func RegisterTasksGRPCGateway(s grpc.ServiceRegistrar, options ...*ray.RayOption) {")
+	require.Contains(t, code, `ServiceName: "mypkg.Tasks",`)
+	require.Contains(t, code, `{MethodName: "Divide", Handler: _grpcGatewayHandler("/mypkg.Tasks/Divide", "DivideProto")},`)
+	require.NotContains(t, code, `MethodName: "Lookup"`) // no protobuf variant
+	require.Contains(t, code, "func (gw *_grpcGateway) call(")
+	require.Contains(t, code, `"google.golang.org/grpc/status"`)
+
+	require.NoError(t, g.writeProtoFile())
+	schema, err := os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), protoFileName))
+	require.NoError(t, err)
+	require.Contains(t, string(schema), "service Tasks {\n  rpc Divide(DivideRequest) returns (DivideResponse);\n}")
+
+	require.NotContains(t, generateFromSource(t, sources, Options{Proto: true}), "GRPCGateway")
+}
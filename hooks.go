@@ -0,0 +1,162 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Hooks are the callbacks of the embedders into the phases of the generation, run in the order they are added.
+// An error of a hook fails the generation of the package.
+type Hooks struct {
+	// TargetDiscovered hooks run once the tasks and actors of a package are discovered, before generating anything.
+	// With Options.OutputDir and the worker backend, they run again on the targets of the worker file,
+	// whose types are rendered as seen from the scanned package.
+	TargetDiscovered []func(t *Targets) error
+	// FileRendered hooks run on the content of every output file before it's written (after formatting for the Go files),
+	// and return the content to write, e.g. with a custom header.
+	FileRendered []func(path string, content []byte) ([]byte, error)
+	// Diagnostic hooks run on every diagnostic of the generation, see Diagnostic.
+	Diagnostic []func(d Diagnostic)
+	// Write hooks run after every output file is written or found unchanged, or rendered without writing it (see Options.DryRun).
+	Write []func(path string, content []byte) error
+}
+
+// Targets are the discovered tasks and actors of a package, passed to the TargetDiscovered hooks.
+// The hooks may drop methods, or edit their Doc, e.g. add directives like "//goray:timeout 30s" to inject options.
+// The task names are registered by the worker as the method names, so renaming a method renames its wrapper only.
+type Targets struct {
+	Package        *packages.Package
+	TasksStruct    string // empty if not found
+	ActorsStruct   string // empty if not found
+	Tasks          []Method
+	ActorFactories []Method
+	ActorMethods   map[string][]Method // by the name of the actor factory
+	ActorHandles   []ActorHandle
+}
+
+// OnTargetDiscovered adds a hook mutating the discovered tasks and actors of every package before the generation.
+func OnTargetDiscovered(hook func(t *Targets) error) Option {
+	return func(o *Options) error {
+		o.Hooks.TargetDiscovered = append(o.Hooks.TargetDiscovered, hook)
+		return nil
+	}
+}
+
+// OnFileRendered adds a hook post-processing the content of every output file before it's written.
+func OnFileRendered(hook func(path string, content []byte) ([]byte, error)) Option {
+	return func(o *Options) error {
+		o.Hooks.FileRendered = append(o.Hooks.FileRendered, hook)
+		return nil
+	}
+}
+
+// OnWrite adds a hook called after every output file is written, e.g. to collect the outputs.
+func OnWrite(hook func(path string, content []byte) error) Option {
+	return func(o *Options) error {
+		o.Hooks.Write = append(o.Hooks.Write, hook)
+		return nil
+	}
+}
+
+// writesFiles reports whether the outputs are written, i.e. not only rendered in memory, reported or printed.
+func (o Options) writesFiles() bool {
+	return !o.inMemory && !o.DryRun && o.Stdout == nil
+}
+
+// mkdirAll creates the dir of output files, unless they aren't written.
+func (g *Generator) mkdirAll(dir string) error {
+	if !g.opts.writesFiles() {
+		return nil
+	}
+	return withExitCode(exitWrite, os.MkdirAll(dir, 0o755))
+}
+
+// describeOutput describes an output file for Options.DryRun: its path, whether it changes, and why it's generated.
+func (g *Generator) describeOutput(outputFile string, content []byte) string {
+	status := "unchanged"
+	if current, err := os.ReadFile(outputFile); err != nil {
+		status = "new"
+	} else if !bytes.Equal(current, content) {
+		status = "changed"
+	}
+	lines := bytes.Count(content, []byte("\n"))
+	if g.backend == nil {
+		return fmt.Sprintf("would write %s (%d lines, %s)", outputFile, lines, status)
+	}
+	var why string
+	switch {
+	case strings.HasPrefix(g.backend.Name(), "plugin:"):
+		why = "selected by -plugin"
+	case len(g.opts.Backends) > 0:
+		why = "selected by -backends"
+	default:
+		why = "enabled by the options"
+	}
+	return fmt.Sprintf("would write %s (%d lines, %s) by backend %s, %s", outputFile, lines, status, g.backend.Name(), why)
+}
+
+// runTargetHooks runs the TargetDiscovered hooks on the collected targets, and takes back their changes.
+func (g *Generator) runTargetHooks() error {
+	if len(g.opts.Hooks.TargetDiscovered) == 0 {
+		return nil
+	}
+	t := &Targets{
+		Package:        g.pkg,
+		TasksStruct:    g.tasksStruct,
+		ActorsStruct:   g.actorsStruct,
+		Tasks:          g.tasks,
+		ActorFactories: g.actorFactories,
+		ActorMethods:   g.actor2Methods,
+		ActorHandles:   g.actorHandles,
+	}
+	for _, hook := range g.opts.Hooks.TargetDiscovered {
+		if err := hook(t); err != nil {
+			return err
+		}
+	}
+	g.tasks, g.actorFactories, g.actor2Methods, g.actorHandles = t.Tasks, t.ActorFactories, t.ActorMethods, t.ActorHandles
+	if g.actor2Methods == nil {
+		g.actor2Methods = make(map[string][]Method)
+	}
+	return nil
+}
+
+// writeOutput writes the content of an output file, unless it's unchanged, through the FileRendered and Write hooks.
+func (g *Generator) writeOutput(outputFile string, content []byte) error {
+	defer g.phase(phaseWrite)()
+	for _, hook := range g.opts.Hooks.FileRendered {
+		var err error
+		if content, err = hook(outputFile, content); err != nil {
+			return err
+		}
+	}
+	if g.opts.DryRun {
+		g.logger.Info("Dry run: " + g.describeOutput(outputFile, content))
+	}
+	if g.opts.Stdout != nil {
+		g.logger.Info("Print generated file", "path", outputFile)
+		if _, err := g.opts.Stdout.Write(content); err != nil {
+			return withExitCode(exitWrite, err)
+		}
+	}
+	if g.opts.writesFiles() {
+		// an unchanged file keeps its mtime, so the build systems don't rebuild its dependents
+		if current, err := os.ReadFile(outputFile); err == nil && bytes.Equal(current, content) {
+			g.logger.Info("Generated file unchanged", "path", outputFile)
+		} else if err := os.WriteFile(outputFile, content, 0o644); err != nil {
+			return withExitCode(exitWrite, err)
+		} else {
+			g.logger.Info("Write generated file", "path", outputFile)
+		}
+	}
+	for _, hook := range g.opts.Hooks.Write {
+		if err := hook(outputFile, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
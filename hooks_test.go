@@ -0,0 +1,95 @@
+package goraygen
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	var written []string
+	r := New(
+		OnTargetDiscovered(func(t *Targets) error {
+			t.Tasks = gslice.Filter(t.Tasks, func(m Method) bool { return m.Name != "Internal" })
+			for i := range t.Tasks {
+				t.Tasks[i].Doc += "\n//goray:timeout 30s"
+			}
+			return nil
+		}),
+		OnFileRendered(func(path string, content []byte) ([]byte, error) {
+			return append([]byte("// Owned by the platform team.\n"), content...), nil
+		}),
+		OnWrite(func(path string, content []byte) error {
+			written = append(written, filepath.Base(path))
+			return nil
+		}),
+	)
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Internal() {}
+`}, "example.com/mypkg")
+	g := NewGenerator(r.Options())
+	g.pkg = pkg
+	outputDir := filepath.Join(t.TempDir(), "client")
+	require.NoError(t, g.generate(outputDir))
+
+	code, err := os.ReadFile(filepath.Join(outputDir, generatedFileName))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(code), "// Owned by the platform team.\n"))
+	require.Contains(t, string(code), "func Divide[")
+	require.Contains(t, string(code), "func DivideWithTimeout(")
+	require.NotContains(t, string(code), "func Internal")
+	require.Equal(t, []string{generatedFileName}, written)
+}
+
+func TestDryRunAndStdout(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+`}, "example.com/mypkg")
+	var logs, stdout strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	g := NewGenerator(New(WithDryRun(), WithStdout(&stdout), WithBackends("tasks")).Options())
+	g.pkg = pkg
+	outputDir := filepath.Join(t.TempDir(), "client")
+	require.NoError(t, g.generate(outputDir))
+
+	require.NoDirExists(t, outputDir)
+	require.Contains(t, stdout.String(), "func Divide[")
+	require.Contains(t, logs.String(), "[INFO] Dry run: would write "+filepath.Join(outputDir, generatedFileName))
+	require.Contains(t, logs.String(), "new) by backend tasks, selected by -backends")
+}
+
+func TestWriteOutputUnchanged(t *testing.T) {
+	g := NewGenerator(Options{})
+	outputFile := filepath.Join(t.TempDir(), graphFileName)
+	require.NoError(t, g.writeOutput(outputFile, []byte("digraph tasks {}\n")))
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(outputFile, past, past))
+
+	require.NoError(t, g.writeOutput(outputFile, []byte("digraph tasks {}\n")))
+	info, err := os.Stat(outputFile)
+	require.NoError(t, err)
+	require.Equal(t, past, info.ModTime())
+
+	require.NoError(t, g.writeOutput(outputFile, []byte("digraph tasks {\n\tDivide;\n}\n")))
+	info, err = os.Stat(outputFile)
+	require.NoError(t, err)
+	require.True(t, info.ModTime().After(past))
+}
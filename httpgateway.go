@@ -0,0 +1,316 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+)
+
+/*
+With -http-gateway, the tasks are served over HTTP with JSON payloads:
+
+	http.Handle("/tasks/", NewTasksHTTPHandler(ray.Option("num_cpus", 1)))
+
+	$ curl -d '{"a": 16, "b": 5}' localhost:8080/tasks/Divide
+	{"r0":3,"r1":1}
+*/
+const httpGatewayTpl = `
+// New{{.Name}}HTTPHandler returns the http.Handler calling the tasks of [{{.DocLink}}] with the ray options,
+// for debugging and lightweight integrations: POST /tasks/{name} with the JSON object of the params, keyed by their names,
+// responds with the JSON object of the results keyed by their indexes (r0, r1, ...), or with {"error": "..."} on failure.
+func New{{.Name}}HTTPHandler(options ...*ray.RayOption) http.Handler {
+	return _httpGateway{
+		{{- range .Methods}}
+		"{{.Name}}": func(r *http.Request) (any, error) {
+			var _req struct{ {{- if .Params}}
+				{{- range .Params}}
+				{{.Field}} {{.Type}} ` + "`json:\"{{.Name}}\"`" + `
+				{{- end}}
+			{{end -}} }
+			if err := _decodeHTTPRequest(r, &_req); err != nil {
+				return nil, err
+			}
+			{{.Results}} := {{.Name}}({{.CallArgs}}).Remote(options...).Get()
+			if _err != nil {
+				return nil, &_httpError{http.StatusBadGateway, _err}
+			}
+			{{- if .ReturnsError}}
+			if _taskErr != nil {
+				return nil, _taskErr
+			}
+			{{- end}}
+			return struct{ {{- if .ResultTypes}}
+				{{- range $i, $t := .ResultTypes}}
+				R{{$i}} {{$t}} ` + "`json:\"r{{$i}}\"`" + `
+				{{- end}}
+			{{end -}} }{ {{- .ResultVars -}} }, nil
+		},
+		{{- end}}
+	}
+}
+`
+
+// httpGatewayHelpers is shared by the HTTP handlers of the tasks, generated once per file.
+const httpGatewayHelpers = `
+// _httpGateway serves POST /tasks/{name} by calling the task of the name, the calls are keyed by the task names.
+type _httpGateway map[string]func(r *http.Request) (any, error)
+
+// _httpError is the error responded with the status code, other errors are responded with 500.
+type _httpError struct {
+	code int
+	err  error
+}
+
+func (e *_httpError) Error() string { return e.err.Error() }
+
+func (e *_httpError) Unwrap() error { return e.err }
+
+func (gw _httpGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutPrefix(r.URL.Path, "/tasks/")
+	call, found := gw[name]
+	if !ok || !found {
+		_writeHTTPResponse(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown task %q", name)})
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		_writeHTTPResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "task " + name + " must be called with POST"})
+		return
+	}
+	result, err := call(r)
+	if err != nil {
+		code := http.StatusInternalServerError
+		var httpErr *_httpError
+		if errors.As(err, &httpErr) {
+			code = httpErr.code
+		}
+		_writeHTTPResponse(w, code, map[string]string{"error": err.Error()})
+		return
+	}
+	_writeHTTPResponse(w, http.StatusOK, result)
+}
+
+// _decodeHTTPRequest decodes the JSON object of the params from the request body, an empty body means no params.
+func _decodeHTTPRequest(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return &_httpError{http.StatusBadRequest, fmt.Errorf("decode params: %w", err)}
+	}
+	return nil
+}
+
+func _writeHTTPResponse(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write HTTP response: %v", err)
+	}
+}
+`
+
+// httpTimeHelpers decode the time.Time and time.Duration params of the tasks, and encode their time.Duration results,
+// generated once per file if a task has one. The fields of the structs are encoded by encoding/json.
+const httpTimeHelpers = `
+// _httpTime is a time.Time param, decoded from an RFC 3339 string or from the seconds since the Unix epoch.
+type _httpTime time.Time
+
+func (t *_httpTime) UnmarshalJSON(data []byte) error {
+	var secs float64
+	if string(data) == "null" || json.Unmarshal(data, &secs) != nil {
+		return (*time.Time)(t).UnmarshalJSON(data)
+	}
+	sec, frac := math.Modf(secs)
+	*t = _httpTime(time.Unix(int64(sec), int64(frac*1e9)).UTC())
+	return nil
+}
+
+// _httpDuration is a time.Duration param or result, encoded as a duration string, e.g. "1m30s",
+// and decoded from a duration string or from nanoseconds.
+type _httpDuration time.Duration
+
+func (d _httpDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *_httpDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if string(data) == "null" || json.Unmarshal(data, &s) != nil {
+		return json.Unmarshal(data, (*int64)(d))
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = _httpDuration(v)
+	return nil
+}
+`
+
+var httpGatewayTmpl = template.Must(template.New("httpGateway").Parse(httpGatewayTpl))
+
+// HTTPGatewayDef is the template data of httpGatewayTpl.
+type HTTPGatewayDef struct {
+	Name    string // name of the tasks struct
+	DocLink string
+	Methods []HTTPTaskDef
+}
+
+// HTTPTaskDef is a task served by the HTTP handler.
+type HTTPTaskDef struct {
+	Name         string
+	Params       []HTTPParamDef
+	CallArgs     string
+	Results      string // the result variables, e.g. "_r0, _taskErr, _err"
+	ResultTypes  []string
+	ResultVars   string
+	ReturnsError bool
+}
+
+// HTTPParamDef is a field of the JSON object of the params.
+type HTTPParamDef struct {
+	Name  string
+	Field string
+	Type  string
+}
+
+// prepareHTTPGateway collects the tasks served by the HTTP handler into g.httpTasks, once per generator:
+// the tasks with the params and results encodable in JSON.
+func (g *Generator) prepareHTTPGateway() {
+	if g.httpTasks != nil || !g.backendSelected("http-gateway") {
+		return
+	}
+	g.httpTasks = gslice.Filter(g.tasks, func(m Method) bool {
+		params, _ := withoutContext(m) // the ctx is the one of the request
+		for _, p := range params.Params {
+			if !jsonEncodable(p.GoType) {
+				g.warnAt(g.methodPos(m), "skip-http-handler", "Skip HTTP handler of %s: param %s of type %s is not encodable in JSON", m.Name, p.Name, p.Type)
+				return false
+			}
+		}
+		for _, r := range m.Results {
+			if !r.IsError && !jsonEncodable(r.GoType) {
+				g.warnAt(g.methodPos(m), "skip-http-handler", "Skip HTTP handler of %s: result of type %s is not encodable in JSON", m.Name, r.Type)
+				return false
+			}
+		}
+		fields := gslice.Map(params.Params, func(p Param) string { return exportedFieldName(p.Name) })
+		if len(gslice.Uniq(fields)) != len(fields) {
+			g.warnAt(g.methodPos(m), "skip-http-handler", "Skip HTTP handler of %s: the params differ only in the case of the first letter", m.Name)
+			return false
+		}
+		return true
+	})
+	if len(g.httpTasks) > 0 {
+		for _, pkg := range []string{"encoding/json", "errors", "fmt", "io", "log", "net/http", "strings"} {
+			g.importStore.AddImport(pkg)
+		}
+	}
+	if usesHTTPTimeTypes(g.httpTasks) {
+		g.importStore.AddImport("math")
+		g.importStore.AddImport("time")
+	}
+}
+
+// jsonEncodable reports whether the values of the type can be encoded to and decoded from JSON:
+// channels, functions and the interfaces with methods can't, nor the composite types of them.
+func jsonEncodable(typ types.Type) bool {
+	switch t := typ.Underlying().(type) {
+	case *types.Chan, *types.Signature:
+		return false
+	case *types.Interface:
+		return t.Empty()
+	case *types.Pointer:
+		return jsonEncodable(t.Elem())
+	case *types.Slice:
+		return jsonEncodable(t.Elem())
+	case *types.Array:
+		return jsonEncodable(t.Elem())
+	case *types.Map:
+		return jsonEncodable(t.Elem())
+	}
+	return true
+}
+
+// generateHTTPGateway generates the driver-side http.Handler calling the tasks with its OpenAPI document, see prepareHTTPGateway.
+func (g *Generator) generateHTTPGateway(buf *bytes.Buffer, docQualifier string) {
+	if len(g.httpTasks) == 0 {
+		return
+	}
+	def := HTTPGatewayDef{Name: g.tasksStruct, DocLink: docQualifier + g.tasksStruct}
+	for _, m := range g.httpTasks {
+		td := HTTPTaskDef{Name: m.Name, ReturnsError: m.ReturnsError()}
+		var callArgs []string
+		cm, passContext := withoutContext(m)
+		if passContext {
+			callArgs = append(callArgs, "r.Context()")
+		}
+		for i, p := range cm.Params {
+			pd := HTTPParamDef{Name: p.Name, Field: exportedFieldName(p.Name), Type: p.Type}
+			arg := "_req." + pd.Field
+			if cm.IsVariadic && i == len(cm.Params)-1 {
+				pd.Type = "[]" + p.Type
+				arg += "..."
+			} else if gatewayType := httpTimeType(p.GoType); gatewayType != "" {
+				pd.Type, arg = gatewayType, p.Type+"("+arg+")"
+			}
+			td.Params = append(td.Params, pd)
+			callArgs = append(callArgs, arg)
+		}
+		td.CallArgs = strings.Join(callArgs, ", ")
+		var results, vars []string
+		for i, r := range m.Results {
+			if r.IsError {
+				results = append(results, "_taskErr")
+				continue
+			}
+			results = append(results, fmt.Sprintf("_r%d", i))
+			if httpTimeType(r.GoType) == "_httpDuration" {
+				vars = append(vars, fmt.Sprintf("_httpDuration(_r%d)", i))
+				td.ResultTypes = append(td.ResultTypes, "_httpDuration")
+				continue
+			}
+			vars = append(vars, fmt.Sprintf("_r%d", i))
+			td.ResultTypes = append(td.ResultTypes, r.Type)
+		}
+		td.Results = strings.Join(append(results, "_err"), ", ")
+		td.ResultVars = strings.Join(vars, ", ")
+		def.Methods = append(def.Methods, td)
+	}
+	if err := httpGatewayTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+	buf.WriteString(httpGatewayHelpers)
+	if usesHTTPTimeTypes(g.httpTasks) {
+		buf.WriteString(httpTimeHelpers)
+	}
+	g.generateOpenAPISpec(buf)
+}
+
+// usesHTTPTimeTypes reports whether a task has a time.Time param, or a time.Duration param or result, see httpTimeHelpers.
+func usesHTTPTimeTypes(tasks []Method) bool {
+	return gslice.Any(tasks, func(m Method) bool {
+		return gslice.Any(m.Params, func(p Param) bool { return httpTimeType(p.GoType) != "" }) ||
+			gslice.Any(m.Results, func(r Result) bool { return httpTimeType(r.GoType) == "_httpDuration" })
+	})
+}
+
+// httpTimeType returns the type of the HTTP handler carrying the param or result of the type, "_httpTime" for time.Time and
+// "_httpDuration" for time.Duration, or an empty string for the other types, carried as such, see httpTimeHelpers.
+func httpTimeType(typ types.Type) string {
+	named, ok := types.Unalias(typ).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "time" {
+		return ""
+	}
+	switch named.Obj().Name() {
+	case "Time":
+		return "_httpTime"
+	case "Duration":
+		return "_httpDuration"
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateHTTPGateway(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Notify(users ...string) error { return nil }
+
+func (Tasks) Each(f func(int)) {}
+
+func (Tasks) Watch() <-chan int { return nil }
+`}
+	code := generateFromSource(t, sources, Options{HTTPGateway: true})
+
+	require.Contains(t, code, "func NewTasksHTTPHandler(options ...*ray.RayOption) http.Handler {")
+	require.Contains(t, code, "A int64 `json:\"a\"`")
+	require.Contains(t, code, "_r0, _r1, _err := Divide(_req.A, _req.B).Remote(options...).Get()")
+	require.Contains(t, code, "R1 int64 `json:\"r1\"`")
+	require.Contains(t, code, "Users []string `json:\"users\"`")
+	require.Contains(t, code, "_taskErr, _err := Notify(_req.Users...).Remote(options...).Get()")
+	require.Contains(t, code, "return struct{}{}, nil")
+	require.NotContains(t, code, `"Each": func(`)  // func param
+	require.NotContains(t, code, `"Watch": func(`) // chan result
+	require.Contains(t, code, "func (gw _httpGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {")
+	require.Contains(t, code, "const TasksOpenAPISpec = `{")
+
+	require.NotContains(t, code, "type _httpTime")
+
+	require.NotContains(t, generateFromSource(t, sources, Options{}), "_httpGateway")
+}
+
+func TestHTTPGatewayTimes(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Delay(at time.Time, d time.Duration, ds ...time.Duration) (time.Duration, time.Time) { return d, at }
+`}, Options{HTTPGateway: true})
+
+	require.Contains(t, code, "At _httpTime       `json:\"at\"`")
+	require.Contains(t, code, "D  _httpDuration   `json:\"d\"`")
+	require.Contains(t, code, "Ds []time.Duration `json:\"ds\"`") // as nanoseconds, like the struct fields
+	require.Contains(t, code, "Delay(time.Time(_req.At), time.Duration(_req.D), _req.Ds...)")
+	require.Contains(t, code, "R0 _httpDuration `json:\"r0\"`")
+	require.Contains(t, code, "R1 time.Time     `json:\"r1\"`")
+	require.Contains(t, code, "}{_httpDuration(_r0), _r1}, nil")
+	require.Contains(t, code, "func (t *_httpTime) UnmarshalJSON(data []byte) error {")
+	require.Contains(t, code, "\t\"math\"\n")
+	require.Contains(t, code, `"description": "The seconds since the Unix epoch."`)
+}
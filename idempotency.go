@@ -0,0 +1,208 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+	//goray:idempotent
+	func (Tasks) Charge(account string, amount int64) (string, error)
+
+generates the driver-side caller, passing the key as the first task argument:
+
+	func ChargeIdempotent[string_0 _T0, string_1 _T0, int64_2 _T1](_key string_0, account string_1, amount int64_2) *RemoteFunc[*Future2[string, error]]
+
+and the worker-side task, deduplicating the calls by key with the IdempotencyStore:
+
+	func (_t Tasks) ChargeIdempotent(_key string, account string, amount int64) (string, error)
+*/
+const idempotentTaskTpl = `
+// {{.Name}}Idempotent is the idempotent variant of [{{.StructName}}.{{.Name}}], called by the {{.Name}}Idempotent caller:
+// the results of a call are recorded by _key in the IdempotencyStore, so the calls retried with the same key
+// return them without running the task again.
+func (_t {{.ReceiverType}}) {{.Name}}Idempotent(_key string{{if .Params}}, {{.Params}}{{end}}) ({{.ResultTypes}}) {
+	if {{if .ResultTypes}}_res{{else}}_{{end}}, ok := _idempotencyStore.Load("{{.Name}}", _key); ok {
+		return {{range $i, $t := .Results}}{{if $i}}, {{end}}_idempotentResult[{{$t}}](_res, {{$i}}){{end}}
+	}
+	{{if .ResultTypes}}{{.ResultVars}} := {{end}}_t.{{.Name}}({{.CallArgs}})
+	{{- if .ReturnsError}}
+	if _err == nil {
+		_idempotencyStore.Store("{{.Name}}", _key, []any{ {{- .ResultVars}}})
+	}
+	{{- else}}
+	_idempotencyStore.Store("{{.Name}}", _key, []any{ {{- .ResultVars}}})
+	{{- end}}
+	return {{.ResultVars}}
+}
+`
+
+// idempotencyStoreHelpers is the worker-side store of the idempotent tasks, generated once per file.
+const idempotencyStoreHelpers = `
+// IdempotencyStore records the results of the idempotent task calls by key, so the calls retried with the same key
+// return the recorded results instead of running the task again. It must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Load returns the results recorded for the call of task with key, if any.
+	Load(task, key string) ([]any, bool)
+	// Store records the results of the successful call of task with key.
+	Store(task, key string, results []any)
+}
+
+var _idempotencyStore IdempotencyStore = NewMemoryIdempotencyStore()
+
+// SetIdempotencyStore sets the store of the idempotent tasks on the worker, call it before ray.Init.
+// The default is a MemoryIdempotencyStore, set a shared store to deduplicate the calls across workers.
+func SetIdempotencyStore(store IdempotencyStore) {
+	_idempotencyStore = store
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore in the memory of the worker process,
+// the results are kept until the process exits.
+type MemoryIdempotencyStore struct {
+	results sync.Map
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{}
+}
+
+func (s *MemoryIdempotencyStore) Load(task, key string) ([]any, bool) {
+	results, ok := s.results.Load(task + "\x00" + key)
+	if !ok {
+		return nil, false
+	}
+	return results.([]any), true
+}
+
+func (s *MemoryIdempotencyStore) Store(task, key string, results []any) {
+	s.results.Store(task+"\x00"+key, results)
+}
+
+// _idempotentResult returns the i-th recorded result, the zero value for a nil result of an interface type.
+func _idempotentResult[T any](results []any, i int) T {
+	r, _ := results[i].(T)
+	return r
+}
+`
+
+// idempotencyKeyHelper is the driver-side helper deriving the idempotency keys, generated once per file.
+const idempotencyKeyHelper = `
+// IdempotencyKey derives the idempotency key of the call of task with args, to pass to the FooIdempotent callers.
+// The args are serialized as JSON (or formatted with %#v if they can't be), so they should be plain values.
+func IdempotencyKey(task string, args ...any) string {
+	h := sha256.New()
+	h.Write([]byte(task))
+	for _, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			data = fmt.Appendf(nil, "%#v", arg)
+		}
+		h.Write([]byte{0})
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+`
+
+var idempotentTaskTmpl = template.Must(template.New("idempotentTask").Parse(idempotentTaskTpl))
+
+const idempotentDirective = "idempotent"
+
+// IdempotentTaskDef is the template data of idempotentTaskTpl.
+type IdempotentTaskDef struct {
+	Name         string
+	StructName   string
+	ReceiverType string
+	Params       string
+	CallArgs     string
+	Results      []string
+	ResultTypes  string // e.g. "string, error"
+	ResultVars   string // e.g. "_r0, _err"
+	ReturnsError bool
+}
+
+// prepareIdempotency collects the tasks with the //goray:idempotent directive into g.idempotentTasks, once per generator.
+// The tasks whose idempotent variant conflicts with a declared method are skipped with a warning.
+func (g *Generator) prepareIdempotency() {
+	if g.idempotentTasks != nil {
+		return
+	}
+	g.idempotentTasks = []Method{}
+	declared := gslice.Map(analysis.Methods(g.pkg, g.tasksStruct, g.pkg.PkgPath, g.importStore), func(m Method) string {
+		return m.Name
+	})
+	for _, m := range g.tasks {
+		if _, ok := m.Directive(idempotentDirective); !ok {
+			continue
+		}
+		if !g.inPackageCode() {
+			g.warnOutsidePackage("skip-idempotent", "Skip //goray:idempotent of %s: the idempotent task must be generated into package %s", m.Name, g.pkg.PkgPath)
+			continue
+		}
+		if gslice.Contains(declared, m.Name+"Idempotent") {
+			g.warnAt(g.directivePos(m, idempotentDirective), "skip-idempotent", "Skip //goray:idempotent of %s: %s already declares the %sIdempotent method", m.Name, g.tasksStruct, m.Name)
+			continue
+		}
+		g.idempotentTasks = append(g.idempotentTasks, m)
+	}
+}
+
+// isIdempotent reports whether the idempotent variant of the task is generated.
+func (g *Generator) isIdempotent(m Method) bool {
+	return gslice.Any(g.idempotentTasks, func(t Method) bool { return t.Name == m.Name })
+}
+
+// generateIdempotentCaller generates the driver-side caller of the idempotent variant of the task,
+// taking the idempotency key as the first argument.
+func (g *Generator) generateIdempotentCaller(buf *bytes.Buffer, m Method, docQualifier string) {
+	if !g.isIdempotent(m) {
+		return
+	}
+	caller := m
+	caller.Name = m.Name + "Idempotent"
+	caller.Params = append([]Param{{Name: "_key", Type: "string", CanonicalType: "string", IdentifiableType: "string"}}, m.Params...)
+	caller.Doc = fmt.Sprintf("// %s calls %s remotely with the idempotency key _key, e.g. IdempotencyKey(%q, args...):\n"+
+		"// the calls retried with the same key return the results of the first successful call.", caller.Name, m.Name, m.Name)
+	g.generateArtifact("task", taskDefTpl, buf, caller, g.typeConstraints, "", docQualifier)
+}
+
+// generateIdempotentTasks generates the worker-side idempotent variants of the tasks with the store,
+// it must be called before dumping imports. Like the tasks, they are methods of the tasks struct in the scanned package.
+func (g *Generator) generateIdempotentTasks(buf *bytes.Buffer) {
+	if len(g.idempotentTasks) == 0 {
+		return
+	}
+	g.importStore.AddImport("sync")
+	for _, m := range g.idempotentTasks {
+		params, callArgs, _ := callSignature(m)
+		def := IdempotentTaskDef{
+			Name:         m.Name,
+			StructName:   g.tasksStruct,
+			ReceiverType: m.ReceiverType,
+			Params:       params,
+			CallArgs:     callArgs,
+			ReturnsError: m.ReturnsError(),
+		}
+		var vars []string
+		for i, r := range m.Results {
+			def.Results = append(def.Results, r.Type)
+			if r.IsError {
+				vars = append(vars, "_err")
+			} else {
+				vars = append(vars, fmt.Sprintf("_r%d", i))
+			}
+		}
+		def.ResultTypes = strings.Join(def.Results, ", ")
+		def.ResultVars = strings.Join(vars, ", ")
+		if err := idempotentTaskTmpl.Execute(buf, def); err != nil {
+			panic(err)
+		}
+	}
+	buf.WriteString(idempotencyStoreHelpers)
+}
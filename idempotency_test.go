@@ -0,0 +1,46 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentTasks(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Charge charges.
+//
+//goray:idempotent
+func (Tasks) Charge(account string, amount int64) (string, error) { return "", nil }
+
+//goray:idempotent
+func (*Tasks) Notify(users ...string) {}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+`}
+	code := generateFromSource(t, sources, Options{})
+
+	require.Contains(t, code, "func ChargeIdempotent[string_0 _T0, string_1 _T0, int64_2 _T1](_key string_0, account string_1, amount int64_2) *RemoteFunc[*Future2[string, error]] {")
+	require.Contains(t, code, `NewRemoteFunc[*Future2[string, error]]("ChargeIdempotent", []any{_key, account, amount})`)
+	require.Contains(t, code, "func (_t Tasks) ChargeIdempotent(_key string, account string, amount int64) (string, error) {")
+	require.Contains(t, code, "return _idempotentResult[string](_res, 0), _idempotentResult[error](_res, 1)")
+	require.Contains(t, code, "_r0, _err := _t.Charge(account, amount)\n\tif _err == nil {\n\t\t_idempotencyStore.Store(\"Charge\", _key, []any{_r0, _err})\n\t}")
+	require.Contains(t, code, "func (_t *Tasks) NotifyIdempotent(_key string, users ...string) {")
+	require.Contains(t, code, "if _, ok := _idempotencyStore.Load(\"Notify\", _key); ok {\n\t\treturn\n\t}\n\t_t.Notify(users...)")
+	require.Contains(t, code, "type IdempotencyStore interface {")
+	require.Contains(t, code, "func IdempotencyKey(task string, args ...any) string {")
+	require.NotContains(t, code, "DivideIdempotent")
+
+	code = generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+`}, Options{})
+	require.NotContains(t, code, "IdempotencyStore")
+}
@@ -0,0 +1,111 @@
+package goraygen
+
+import (
+	"fmt"
+	"go/types"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// checkImportable reports an error for every param or result type of the targets from an internal package
+// the output package can't import, e.g. with -output-dir outside of the tree of the internal package,
+// or unexported by another package than the output one, instead of generating code that fails to compile.
+// The scanned package itself is checked too, the wrappers generated into another package importing it.
+func (g *Generator) checkImportable() error {
+	if g.outputPkgPath == "" { // unknown, e.g. outside of the module
+		return nil
+	}
+	if !analysis.Importable(g.pkg.PkgPath, g.outputPkgPath) {
+		g.report(g.at(g.packagePos(), Diagnostic{
+			Severity:   SeverityError,
+			Code:       "internal-import",
+			Message:    fmt.Sprintf("internal package %s can't be imported by the output package %s", g.pkg.PkgPath, g.outputPkgPath),
+			Suggestion: "generate into a package of its tree, see -output-dir",
+		}))
+		return withExitCode(exitAnalysis, fmt.Errorf("the output package %s can't import %s", g.outputPkgPath, g.pkg.PkgPath))
+	}
+	var problems, unexported int
+	checked := make(map[string]bool) // the methods of the actor handles may be the ones of the actors too
+	check := func(m Method, factory bool) {
+		key := m.ReceiverType + "." + m.Name
+		if checked[key] {
+			return
+		}
+		checked[key] = true
+		for _, p := range m.Params {
+			problems += g.checkImportableType(m, "param "+p.Name, p.GoType)
+			unexported += g.checkExportedType(m, "param "+p.Name, p.GoType)
+		}
+		for i, r := range m.Results {
+			problems += g.checkImportableType(m, fmt.Sprintf("result %d", i), r.GoType)
+			if !factory { // the actor stays on the worker, its type isn't rendered
+				unexported += g.checkExportedType(m, fmt.Sprintf("result %d", i), r.GoType)
+			}
+		}
+	}
+	for _, m := range g.tasks {
+		check(m, false)
+	}
+	for _, factory := range g.actorFactories {
+		check(factory, true)
+		for _, m := range g.actor2Methods[factory.Name] {
+			check(m, false)
+		}
+	}
+	for _, h := range g.actorHandles {
+		for _, m := range h.Methods {
+			check(m, false)
+		}
+	}
+	if problems > 0 {
+		return withExitCode(exitAnalysis, fmt.Errorf("%d types of the signatures can't be imported by the output package %s", problems, g.outputPkgPath))
+	}
+	if unexported > 0 {
+		return withExitCode(exitAnalysis, fmt.Errorf("%d unexported types of the signatures can't be referred to by the output package %s", unexported, g.outputPkgPath))
+	}
+	return nil
+}
+
+// checkImportableType reports the internal packages of the type the output package can't import, and returns their number.
+func (g *Generator) checkImportableType(m Method, what string, typ types.Type) int {
+	if typ == nil {
+		return 0
+	}
+	var problems int
+	for _, pkg := range analysis.ReferencedPackages(typ, g.importStore) {
+		if analysis.Importable(pkg.Path(), g.outputPkgPath) {
+			continue
+		}
+		problems++
+		g.report(g.at(g.methodPos(m), Diagnostic{
+			Severity:   SeverityError,
+			Code:       "internal-import",
+			Message:    fmt.Sprintf("%s of (%s).%s: type %s is declared in internal package %s, which the output package %s can't import", what, m.ReceiverType, m.Name, typ, pkg.Path(), g.outputPkgPath),
+			Suggestion: "generate into a package of its tree (see -output-dir), export the type from a public package, or map it with -map-type",
+		}))
+	}
+	return problems
+}
+
+// checkExportedType reports the unexported types the type is composed of, which the output package can't refer to
+// if it's another package, and returns their number.
+func (g *Generator) checkExportedType(m Method, what string, typ types.Type) int {
+	if typ == nil {
+		return 0
+	}
+	var problems int
+	for _, tn := range analysis.ReferencedTypeNames(typ, g.importStore) {
+		if tn.Exported() || tn.Pkg() == nil || tn.Pkg().Path() == g.outputPkgPath {
+			continue
+		}
+		problems++
+		exported := exportedFieldName(tn.Name())
+		g.report(g.at(g.methodPos(m), Diagnostic{
+			Severity:   SeverityError,
+			Code:       "unexported-type",
+			Message:    fmt.Sprintf("%s of (%s).%s: type %s is unexported, the output package %s can't refer to it", what, m.ReceiverType, m.Name, types.TypeString(tn.Type(), types.RelativeTo(g.pkg.Types)), g.outputPkgPath),
+			Suggestion: fmt.Sprintf("export the type as %s, or declare an exported type for the signature, e.g. `type %s %s`", exported, exported, tn.Name()),
+		}))
+	}
+	return problems
+}
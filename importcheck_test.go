@@ -0,0 +1,103 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckImportable(t *testing.T) {
+	var diagnostics []Diagnostic
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "internal-import" {
+			diagnostics = append(diagnostics, d)
+		}
+	})
+	pkg := makePkgFromSource(t, map[string]string{
+		"tasks": `package mypkg
+
+import "example.com/mypkg/internal/ids"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Lookup(id ids.UserID) map[string][]*ids.UserID { return nil }
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+`,
+		"internal/ids/ids": "package ids\n\ntype UserID string\n",
+	}, "example.com/mypkg")
+	check := func(outputPkgPath string) error {
+		diagnostics = nil
+		g := NewGenerator(opts)
+		g.pkg = pkg
+		g.outputPkgName, g.outputPkgPath = "client", outputPkgPath
+		g.collectWorkloads()
+		return g.checkImportable()
+	}
+
+	// in the tree of the internal package
+	require.NoError(t, check("example.com/mypkg"))
+	require.NoError(t, check("example.com/mypkg/client"))
+	require.Empty(t, diagnostics)
+
+	err := check("example.com/other/client")
+	require.ErrorContains(t, err, "2 types of the signatures can't be imported by the output package example.com/other/client")
+	require.Len(t, diagnostics, 2)
+	require.Equal(t, "internal-import", diagnostics[0].Code)
+	require.Equal(t, 8, diagnostics[0].Line)
+	require.Contains(t, diagnostics[0].Message, "param id of (Tasks).Lookup: type example.com/mypkg/internal/ids.UserID is declared in internal package example.com/mypkg/internal/ids")
+	require.Contains(t, diagnostics[1].Message, "result 0 of (Tasks).Lookup")
+}
+
+func TestCheckExported(t *testing.T) {
+	var diagnostics []Diagnostic
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "unexported-type" {
+			diagnostics = append(diagnostics, d)
+		}
+	})
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type point struct{ X, Y int }
+
+func (Tasks) Move(ps []point) map[string]*point { return nil }
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *counter { return &counter{n} }
+
+type counter struct{ n int }
+
+func (c *counter) Incr(n int) int { c.n += n; return c.n }
+`}, "example.com/mypkg")
+	check := func(outputPkgPath string) error {
+		diagnostics = nil
+		g := NewGenerator(opts)
+		g.pkg = pkg
+		g.outputPkgName, g.outputPkgPath = "client", outputPkgPath
+		g.collectWorkloads()
+		g.collectActorMethods()
+		return g.checkImportable()
+	}
+
+	require.NoError(t, check("example.com/mypkg"))
+	require.Empty(t, diagnostics)
+
+	// the actor types aren't rendered in the wrappers
+	err := check("example.com/mypkg/client")
+	require.ErrorContains(t, err, "2 unexported types of the signatures can't be referred to by the output package example.com/mypkg/client")
+	require.Len(t, diagnostics, 2)
+	require.Equal(t, 8, diagnostics[0].Line)
+	require.Equal(t, "param ps of (Tasks).Move: type point is unexported, the output package example.com/mypkg/client can't refer to it", diagnostics[0].Message)
+	require.Equal(t, "export the type as Point, or declare an exported type for the signature, e.g. `type Point point`", diagnostics[0].Suggestion)
+	require.Contains(t, diagnostics[1].Message, "result 0 of (Tasks).Move")
+}
@@ -0,0 +1,296 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+)
+
+/*
+	func DivideInvoke(ctx context.Context, a int64, b int64) (_r0 int64, _r1 int64, _err error) {
+		ctx, _done := _startInvocation(ctx, "Divide", []any{a, b})
+		defer func() { _done(_err) }()
+		_r0, _r1, _err = Divide(a, b).Remote().Get()
+		return
+	}
+
+With -with-otel, the span of the call records the size of the args, and its trace context is passed to the methods
+taking a context.Context with the metadata of the ctx, see contextMetadataTpl. The other methods can't receive it.
+*/
+const invokeTpl = `
+// {{.Name}}Invoke calls [{{.Name}}] remotely and waits for the results, with the instrumentation{{.Instrumentation}}.
+func {{.Name}}Invoke(ctx context.Context, {{if .ActorName}}_actor *Actor{{.ActorName}}{{if .Params}}, {{end}}{{end}}{{.Params}}) ({{range .Results}}{{.}}, {{end}}_err error) {
+	ctx, _done := _startInvocation(ctx, "{{.TaskName}}"{{if .Args}}, {{.Args}}{{end}})
+	defer func() { _done(_err) }()
+	{{.CallBody}}
+	return
+}
+`
+
+// invokeHelpersTpl is shared by the instrumented callers, generated once per file.
+const invokeHelpersTpl = `
+{{- if .Otel}}
+const _tracerName = "{{.TracerName}}"
+{{end}}
+{{- if .Slog}}
+// InvokeConfig configures the instrumentation of the FooInvoke callers, see WithInvokeConfig.
+type InvokeConfig struct {
+	// Logger logs every call with the task name, attempt, duration and error, nothing is logged if it's nil.
+	Logger *slog.Logger
+}
+
+type _invokeConfigKey struct{}
+
+// WithInvokeConfig returns a copy of ctx carrying cfg, which configures the FooInvoke callers called with the ctx.
+func WithInvokeConfig(ctx context.Context, cfg InvokeConfig) context.Context {
+	return context.WithValue(ctx, _invokeConfigKey{}, cfg)
+}
+{{end}}
+type _invokeAttemptKey struct{}
+
+// _withAttempt returns a copy of ctx carrying the attempt (from 1) of the call, set by the retry callers.
+func _withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, _invokeAttemptKey{}, attempt)
+}
+
+{{- if .Metrics}}
+var (
+	_taskInvocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "{{.MetricsNamespace}}",
+		Name:      "task_invocations_total",
+		Help:      "Number of remote calls of the ray tasks and actor methods.",
+	}, []string{"task"})
+	_taskFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "{{.MetricsNamespace}}",
+		Name:      "task_failures_total",
+		Help:      "Number of failed remote calls of the ray tasks and actor methods.",
+	}, []string{"task"})
+	_taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "{{.MetricsNamespace}}",
+		Name:      "task_duration_seconds",
+		Help:      "Duration of the remote calls of the ray tasks and actor methods, until the results are received.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"task"})
+)
+
+// RegisterTaskMetrics registers the metrics of the FooInvoke callers, labeled by the task name, to reg.
+func RegisterTaskMetrics(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{_taskInvocations, _taskFailures, _taskDuration} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+{{end}}
+// _startInvocation starts the instrumentation of a remote call of the task{{if .Otel}} with the args{{end}}, done must be called
+// with the error of the call.
+func _startInvocation(ctx context.Context, task string{{if .Otel}}, args []any{{end}}) (context.Context, func(err error)) {
+	{{- if or .Otel .Slog}}
+	attempt, ok := ctx.Value(_invokeAttemptKey{}).(int)
+	if !ok {
+		attempt = 1
+	}
+	{{- end}}
+	{{- if .Otel}}
+	ctx, span := otel.Tracer(_tracerName).Start(ctx, "goray "+task,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("goray.task", task),
+			attribute.Int("goray.args.size", _argsSize(args)),
+			attribute.Int("goray.attempt", attempt),
+		),
+	)
+	{{- end}}
+	{{- if .Slog}}
+	cfg, _ := ctx.Value(_invokeConfigKey{}).(InvokeConfig)
+	{{- end}}
+	{{- if or .Metrics .Slog}}
+	start := time.Now()
+	{{- end}}
+	{{- if .Metrics}}
+	_taskInvocations.WithLabelValues(task).Inc()
+	{{- end}}
+	return ctx, func(err error) {
+		{{- if .Otel}}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+		span.End()
+		{{- end}}
+		{{- if .Metrics}}
+		if err != nil {
+			_taskFailures.WithLabelValues(task).Inc()
+		}
+		_taskDuration.WithLabelValues(task).Observe(time.Since(start).Seconds())
+		{{- end}}
+		{{- if .Slog}}
+		if cfg.Logger != nil {
+			level, msg := slog.LevelInfo, "ray task call succeeded"
+			if err != nil {
+				level, msg = slog.LevelError, "ray task call failed"
+			}
+			cfg.Logger.LogAttrs(ctx, level, msg,
+				slog.String("task", task),
+				slog.Int("attempt", attempt),
+				slog.Duration("duration", time.Since(start)),
+				slog.Any("error", err),
+			)
+		}
+		{{- end}}
+	}
+}
+
+{{- if .Otel}}
+
+// _argsSize returns the size in bytes of the args encoded in JSON, -1 if they can't be: their size in the remote call
+// with the json codec, an estimate with the others.
+func _argsSize(args []any) int {
+	var size _byteCounter
+	if err := json.NewEncoder(&size).Encode(args); err != nil {
+		return -1
+	}
+	return int(size) - 1 // the newline of Encode
+}
+
+// _byteCounter counts the bytes written to it.
+type _byteCounter int
+
+func (c *_byteCounter) Write(p []byte) (int, error) {
+	*c += _byteCounter(len(p))
+	return len(p), nil
+}
+{{- end}}
+`
+
+var (
+	invokeTmpl        = template.Must(template.New("invoke").Parse(invokeTpl))
+	invokeHelpersTmpl = template.Must(template.New("invokeHelpers").Parse(invokeHelpersTpl))
+)
+
+// InvokeDef is the template data of invokeTpl.
+type InvokeDef struct {
+	Name            string // wrapper function name, e.g. "Divide" or "Counter_Incr"
+	TaskName        string // e.g. "Divide" or "Counter.Incr"
+	ActorName       string
+	Instrumentation string // description of the enabled instrumentation, e.g. " (OpenTelemetry tracing)"
+	Params          string
+	Args            string // the args recorded by the span with -with-otel, e.g. "[]any{a, b}", empty otherwise
+	Results         []string
+	CallBody        string
+}
+
+// InvokeHelpersDef is the template data of invokeHelpersTpl.
+type InvokeHelpersDef struct {
+	Otel             bool
+	TracerName       string
+	Metrics          bool
+	MetricsNamespace string
+	Slog             bool
+}
+
+// instrumented reports whether the instrumented callers are generated.
+func (o Options) instrumented() bool {
+	return o.WithOtel || o.WithMetrics || o.WithSlog
+}
+
+func (o Options) metricsNamespace() string {
+	if o.MetricsNamespace == "" {
+		return "goray"
+	}
+	return o.MetricsNamespace
+}
+
+// prepareInstrumentation adds the imports of the instrumented callers.
+func (g *Generator) prepareInstrumentation() {
+	if !g.opts.instrumented() {
+		return
+	}
+	g.importStore.AddImport("context")
+	g.importStore.AddImport("time")
+	if g.opts.WithSlog {
+		g.importStore.AddImport("log/slog")
+	}
+	if g.opts.WithOtel {
+		for _, pkg := range []string{
+			"encoding/json",
+			"go.opentelemetry.io/otel",
+			"go.opentelemetry.io/otel/attribute",
+			"go.opentelemetry.io/otel/codes",
+			"go.opentelemetry.io/otel/trace",
+		} {
+			g.importStore.AddImport(pkg)
+		}
+	}
+	if g.opts.WithMetrics {
+		g.importStore.AddImport("github.com/prometheus/client_golang/prometheus")
+	}
+}
+
+// generateInvoke generates the instrumented caller of the method, whose wrapper function is named name.
+func (g *Generator) generateInvoke(buf *bytes.Buffer, name, actorName string, m Method) {
+	if !g.opts.instrumented() {
+		return
+	}
+	def := InvokeDef{Name: name, ActorName: actorName, TaskName: m.Name}
+	if actorName != "" {
+		def.TaskName = actorName + "." + m.Name
+	}
+	var kinds []string
+	if g.opts.WithOtel {
+		kinds = append(kinds, "OpenTelemetry tracing")
+	}
+	if g.opts.WithMetrics {
+		kinds = append(kinds, "Prometheus metrics")
+	}
+	if g.opts.WithSlog {
+		kinds = append(kinds, "logging")
+	}
+	def.Instrumentation = " (" + strings.Join(kinds, ", ") + ")"
+
+	cm, passContext := withoutContext(m) // the ctx of the caller is passed on, with the span
+	params, callArgs, resultTypes := callSignature(cm)
+	def.Params = params
+	if g.opts.WithOtel {
+		def.Args = "[]any{" + strings.Join(gslice.Map(cm.Params, func(p Param) string { return p.Name }), ", ") + "}"
+	}
+	var vars string
+	for i, t := range resultTypes {
+		def.Results = append(def.Results, fmt.Sprintf("_r%d %s", i, t))
+		vars += fmt.Sprintf("_r%d, ", i)
+	}
+	if passContext {
+		callArgs = strings.TrimSuffix("ctx, "+callArgs, ", ")
+	}
+	if actorName != "" {
+		callArgs = strings.TrimSuffix("_actor, "+callArgs, ", ")
+	}
+	call := fmt.Sprintf("%s(%s).Remote().Get()", name, callArgs)
+	def.CallBody = assignCallResults(m, vars, call, "\t")
+	if err := invokeTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// generateInvokeHelpers generates the helpers of the instrumented callers.
+func (g *Generator) generateInvokeHelpers(buf *bytes.Buffer) {
+	if !g.opts.instrumented() || (len(g.tasks) == 0 && len(g.actor2Methods) == 0) {
+		return
+	}
+	def := InvokeHelpersDef{
+		Otel:             g.opts.WithOtel,
+		TracerName:       g.outputPkgPath,
+		Metrics:          g.opts.WithMetrics,
+		MetricsNamespace: g.opts.metricsNamespace(),
+		Slog:             g.opts.WithSlog,
+	}
+	if err := invokeHelpersTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
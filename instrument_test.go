@@ -0,0 +1,83 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOtelInvoke(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Open(name string) (int, error) { return 0, nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(n int) int { return c.n }
+`}
+	code := generateFromSource(t, sources, Options{WithOtel: true})
+
+	require.Contains(t, code, "func DivideInvoke(ctx context.Context, a int64, b int64) (_r0 int64, _r1 int64, _err error) {")
+	require.Contains(t, code, `ctx, _done := _startInvocation(ctx, "Divide", []any{a, b})`)
+	require.Contains(t, code, "_r0, _r1, _err = Divide(a, b).Remote().Get()")
+	require.Contains(t, code, "_r0, _taskErr, _err = Open(name).Remote().Get()")
+	require.Contains(t, code, `ctx, _done := _startInvocation(ctx, "Counter.Incr", []any{n})`)
+	require.Contains(t, code, "_r0, _err = Counter_Incr(_actor, n).Remote().Get()")
+
+	// the span records the size of the args, the trace context is only passed to the methods taking a ctx
+	require.Contains(t, code, `const _tracerName = "example.com/mypkg"`)
+	require.Contains(t, code, "func _startInvocation(ctx context.Context, task string, args []any) (context.Context, func(err error)) {")
+	require.Contains(t, code, `attribute.Int("goray.args.size", _argsSize(args)),`)
+	require.Contains(t, code, "if err := json.NewEncoder(&size).Encode(args); err != nil {")
+	require.NotContains(t, code, "propagation")
+	require.NotContains(t, code, "_metadata")
+
+	require.NotContains(t, generateFromSource(t, sources, Options{}), "DivideInvoke")
+}
+
+func TestGenerateMetricsInvoke(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+`}, Options{WithMetrics: true, MetricsNamespace: "myapp"})
+
+	require.Contains(t, code, "func DivideInvoke(ctx context.Context, a int64, b int64) (_r0 int64, _r1 int64, _err error) {")
+	require.Contains(t, code, `ctx, _done := _startInvocation(ctx, "Divide")`)
+	require.Contains(t, code, `Namespace: "myapp",`)
+	require.Contains(t, code, `Name:      "task_duration_seconds",`)
+	require.Contains(t, code, "func RegisterTaskMetrics(reg prometheus.Registerer) error {")
+	require.Contains(t, code, "_taskDuration.WithLabelValues(task).Observe(time.Since(start).Seconds())")
+	require.NotContains(t, code, "otel.")
+	require.NotContains(t, code, "_argsSize")
+}
+
+func TestGenerateSlogInvoke(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+//goray:retry max=2
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+`}, Options{WithSlog: true})
+
+	require.Contains(t, code, "type InvokeConfig struct {")
+	require.Contains(t, code, "func WithInvokeConfig(ctx context.Context, cfg InvokeConfig) context.Context {")
+	require.Contains(t, code, `slog.Int("attempt", attempt),`)
+	// every attempt of the retry caller is instrumented
+	require.Contains(t, code, "_r0, _r1, _err = DivideInvoke(_withAttempt(ctx, _attempt), a, b)")
+	require.NotContains(t, code, "_taskInvocations")
+}
@@ -0,0 +1,345 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+)
+
+/*
+	func (Tasks) Count(name string, r io.Reader) (int, error)
+	func (Tasks) Export(w io.Writer, n int) error
+
+can't be called remotely as is, the streams living in the caller process. They get the driver-side IO adapters,
+reading r in chunks put in the object store and writing the output of the task to w:
+
+	func CountIO(name string, r io.Reader, options ...*ray.RayOption) (_r0 int, _err error)
+	func ExportIO(w io.Writer, n int, options ...*ray.RayOption) (_err error)
+
+calling the worker-side variants, reconstructing r from the chunks and returning the bytes written to w first:
+
+	func (_t Tasks) CountIO(name string, _chunks ...[]byte) (int, error)
+	func (_t Tasks) ExportIO(n int) ([]byte, error)
+*/
+const ioTaskTpl = `
+// {{.Name}}IO is the variant of [{{.StructName}}.{{.Name}}] called by the {{.Name}}IO adapter:
+{{- if .Reader}}
+// {{.Reader}} is reconstructed from the chunks of the stream put in the object store by the caller
+{{- end}}
+{{- if and .Reader .Writer}},{{end}}
+{{- if .Writer}}
+// the bytes written to {{.Writer}} are returned as the first result
+{{- end}}.
+func (_t {{.ReceiverType}}) {{.Name}}IO({{.Params}}) ({{.ResultTypes}}) {
+	{{- if .Writer}}
+	var _w bytes.Buffer
+	{{- end}}
+	{{if .ResultVars}}{{.ResultVars}} := {{end}}_t.{{.Name}}({{.CallArgs}})
+	{{- if .Writer}}
+	return _w.Bytes(){{if .ResultVars}}, {{.ResultVars}}{{end}}
+	{{- else}}
+	return {{.ResultVars}}
+	{{- end}}
+}
+`
+
+const ioAdapterTpl = `
+// {{.Name}}IO calls [{{.DocName}}] remotely and waits for the results:
+{{- if .Reader}}
+// {{.Reader}} is read to the end in chunks put in the object store
+{{- end}}
+{{- if and .Reader .Writer}},{{end}}
+{{- if .Writer}}
+// the output of the task is written to {{.Writer}} once it finishes
+{{- end}}.
+func {{.Name}}IO({{.DriverParams}}) ({{.DriverResults}}) {
+	{{- if .Reader}}
+	_chunks, _err := _putChunks({{.Reader}})
+	if _err != nil {
+		return
+	}
+	{{- end}}
+	_future := NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]("{{.Name}}IO", {{.ArgsStatement}}).Remote(options...)
+	{{- if .Writer}}
+	var _out []byte
+	{{- end}}
+	{{- if .ReturnsError}}
+	var _taskErr error
+	{{- end}}
+	{{.GetVars}}_err = _future.Get()
+	if _err != nil {
+		return
+	}
+	{{- if and .Writer .ReturnsError}}
+	if _, _err = {{.Writer}}.Write(_out); _err != nil {
+		return
+	}
+	{{- else if .Writer}}
+	_, _err = {{.Writer}}.Write(_out)
+	{{- end}}
+	{{- if .ReturnsError}}
+	_err = _taskErr
+	{{- end}}
+	return
+}
+`
+
+// ioWorkerHelpers is shared by the worker-side variants of the IO tasks, generated once per file.
+const ioWorkerHelpers = `
+// _chunkReader returns the reader of the stream put in the object store in chunks by _putChunks.
+func _chunkReader(chunks [][]byte) io.Reader {
+	readers := make([]io.Reader, len(chunks))
+	for i, chunk := range chunks {
+		readers[i] = bytes.NewReader(chunk)
+	}
+	return io.MultiReader(readers...)
+}
+`
+
+// ioAdapterHelpers is shared by the IO adapters, generated once per file.
+const ioAdapterHelpers = `
+// _ioChunkSize is the size of the chunks the streams of the IO adapters are put in the object store in.
+const _ioChunkSize = 1 << 20
+
+// _putChunks reads r to the end in chunks of _ioChunkSize bytes put in the object store.
+func _putChunks(r io.Reader) ([]ray.SharedObject[[]byte], error) {
+	var chunks []ray.SharedObject[[]byte]
+	for {
+		buf := make([]byte, _ioChunkSize)
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			obj, putErr := ray.Put(buf[:n])
+			if putErr != nil {
+				return nil, putErr
+			}
+			chunks = append(chunks, obj)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+`
+
+var (
+	ioTaskTmpl    = template.Must(template.New("ioTask").Parse(ioTaskTpl))
+	ioAdapterTmpl = template.Must(template.New("ioAdapter").Parse(ioAdapterTpl))
+)
+
+// IOTaskDef is the template data of ioTaskTpl and ioAdapterTpl.
+type IOTaskDef struct {
+	Name         string
+	StructName   string
+	ReceiverType string
+	Reader       string // the name of the io.Reader param, empty if there is none
+	Writer       string // the name of the io.Writer param, empty if there is none
+	ReturnsError bool
+	// worker-side variant
+	Params      string // e.g. "name string, _chunks ...[]byte"
+	CallArgs    string // e.g. "name, _chunkReader(_chunks)"
+	ResultTypes string // e.g. "[]byte, int, error"
+	ResultVars  string // e.g. "_r0, _err"
+	// driver-side adapter
+	DocName       string // e.g. "Tasks.Count", qualified out of the package
+	DriverParams  string
+	DriverResults string // e.g. "_r0 int, _err error"
+	ArgsStatement string
+	ResLen        int
+	ResTypes      string // e.g. "[[]byte, int, error]"
+	GetVars       string // e.g. "_out, _r0, _taskErr, "
+}
+
+// ioParam returns "Reader" or "Writer" if the param is an io.Reader or an io.Writer, otherwise "".
+func ioParam(p Param) string {
+	switch p.CanonicalType {
+	case "io.Reader":
+		return "Reader"
+	case "io.Writer":
+		return "Writer"
+	}
+	return ""
+}
+
+// hasIOParam reports whether a param of the method is an io.Reader or an io.Writer.
+func hasIOParam(m Method) bool {
+	return gslice.Any(m.Params, func(p Param) bool { return ioParam(p) != "" })
+}
+
+// collectIOTask collects the task with io.Reader or io.Writer params into g.ioTasks, to generate its IO adapter
+// instead of the wrappers. The tasks the adapter can't be generated for are skipped with a warning.
+func (g *Generator) collectIOTask(m Method) {
+	var readers, writers int
+	for _, p := range m.Params {
+		switch ioParam(p) {
+		case "Reader":
+			readers++
+		case "Writer":
+			writers++
+		}
+	}
+	reason := ""
+	switch {
+	case readers > 1 || writers > 1:
+		reason = "an IO adapter takes one io.Reader and one io.Writer at most"
+	case m.TakesContext():
+		reason = "an IO adapter can't pass a context.Context"
+	case m.IsVariadic && readers > 0:
+		reason = "an IO adapter can't read an io.Reader for a variadic task"
+	case gslice.Contains(g.declaredTasks(), m.Name+"IO"):
+		reason = fmt.Sprintf("%s already declares the %sIO method of its IO adapter", g.tasksStruct, m.Name)
+	}
+	if reason == "" && !g.inPackageCode() {
+		g.report(g.at(g.methodPos(m), Diagnostic{
+			Severity:   SeverityWarning,
+			Code:       "skip-io-adapter",
+			Message:    fmt.Sprintf("Skip method (%s).%s: the IO adapter must be generated into package %s", m.ReceiverType, m.Name, g.pkg.PkgPath),
+			Suggestion: "use -split-worker with -output-dir",
+		}))
+		g.skip(m, "the IO adapter must be generated into the scanned package")
+		return
+	}
+	if reason != "" {
+		g.report(g.at(g.methodPos(m), Diagnostic{
+			Severity:   SeverityWarning,
+			Code:       "skip-io-adapter",
+			Message:    fmt.Sprintf("Skip method (%s).%s: %s", m.ReceiverType, m.Name, reason),
+			Suggestion: "pass the content as []byte",
+		}))
+		g.skip(m, reason)
+		return
+	}
+	g.ioTasks = append(g.ioTasks, m)
+}
+
+// declaredTasks returns the names of the methods declared by the tasks struct, including the filtered out ones.
+func (g *Generator) declaredTasks() []string {
+	return g.declaredMethods(g.tasksStruct)
+}
+
+// ioTask returns the worker-side variant of the IO task, see generateIOTasks.
+func ioTask(m Method) Method {
+	im := m
+	im.Name = m.Name + "IO"
+	im.Params = nil
+	reader := false
+	for _, p := range m.Params {
+		switch ioParam(p) {
+		case "Reader":
+			reader = true
+		case "":
+			im.Params = append(im.Params, p)
+		}
+	}
+	if reader {
+		im.Params = append(im.Params, Param{Name: "_chunks", Type: "[]byte", CanonicalType: "[]byte", IdentifiableType: "slice_byte"})
+		im.IsVariadic = true
+	}
+	if gslice.Any(m.Params, func(p Param) bool { return ioParam(p) == "Writer" }) {
+		im.Results = append([]Result{{Type: "[]byte", CanonicalType: "[]byte"}}, m.Results...)
+	}
+	return im
+}
+
+// ioTaskDef returns the template data of the worker-side variant and the driver-side adapter of the IO task.
+func ioTaskDef(m Method, structName, docQualifier string) IOTaskDef {
+	def := IOTaskDef{
+		Name:         m.Name,
+		StructName:   structName,
+		ReceiverType: m.ReceiverType,
+		ReturnsError: m.ReturnsError(),
+		DocName:      docQualifier + structName + "." + m.Name,
+	}
+	var params, callArgs, driverParams, args []string
+	for i, p := range m.Params {
+		variadic := m.IsVariadic && i == len(m.Params)-1
+		switch {
+		case ioParam(p) == "Reader":
+			def.Reader = p.Name
+			callArgs = append(callArgs, "_chunkReader(_chunks)")
+			driverParams = append(driverParams, p.Name+" "+p.Type)
+		case ioParam(p) == "Writer":
+			def.Writer = p.Name
+			callArgs = append(callArgs, "&_w")
+			driverParams = append(driverParams, p.Name+" "+p.Type)
+		case variadic:
+			params = append(params, fmt.Sprintf("%s ...%s", p.Name, p.Type))
+			callArgs = append(callArgs, p.Name+"...")
+			driverParams = append(driverParams, fmt.Sprintf("%s []%s", p.Name, p.Type))
+			args = append(args, p.Name)
+		default:
+			params = append(params, p.Name+" "+p.Type)
+			callArgs = append(callArgs, p.Name)
+			driverParams = append(driverParams, p.Name+" "+p.Type)
+			args = append(args, p.Name)
+		}
+	}
+	var argsStatement string
+	switch {
+	case def.Reader != "":
+		params = append(params, "_chunks ...[]byte")
+		argsStatement = fmt.Sprintf("ExpandArgs([]any{%s}, _chunks)", strings.Join(args, ", "))
+	case m.IsVariadic:
+		last := args[len(args)-1]
+		argsStatement = fmt.Sprintf("ExpandArgs([]any{%s}, %s)", strings.Join(args[:len(args)-1], ", "), last)
+	default:
+		argsStatement = fmt.Sprintf("[]any{%s}", strings.Join(args, ", "))
+	}
+	def.Params, def.CallArgs, def.ArgsStatement = strings.Join(params, ", "), strings.Join(callArgs, ", "), argsStatement
+	def.DriverParams = strings.Join(append(driverParams, "options ...*ray.RayOption"), ", ")
+
+	var resultTypes, resultVars, driverResults []string
+	getVars := ""
+	if def.Writer != "" {
+		resultTypes = append(resultTypes, "[]byte")
+		getVars = "_out, "
+	}
+	for i, r := range m.Results {
+		resultTypes = append(resultTypes, r.Type)
+		if r.IsError {
+			resultVars = append(resultVars, "_err")
+			getVars += "_taskErr, "
+			continue
+		}
+		v := fmt.Sprintf("_r%d", i)
+		resultVars = append(resultVars, v)
+		driverResults = append(driverResults, v+" "+r.Type)
+		getVars += v + ", "
+	}
+	def.ResultTypes, def.ResultVars = strings.Join(resultTypes, ", "), strings.Join(resultVars, ", ")
+	def.DriverResults = strings.Join(append(driverResults, "_err error"), ", ")
+	def.GetVars = getVars
+	def.ResLen = len(resultTypes)
+	if def.ResLen > 0 {
+		def.ResTypes = "[" + def.ResultTypes + "]"
+	}
+	return def
+}
+
+// generateIOTasks generates the worker-side variants of the IO tasks, it must be called before dumping imports.
+// Like the tasks, they are methods of the tasks struct in the scanned package.
+func (g *Generator) generateIOTasks(buf *bytes.Buffer) {
+	if len(g.ioTasks) == 0 {
+		return
+	}
+	g.importStore.AddImport("bytes")
+	g.importStore.AddImport("io")
+	for _, m := range g.ioTasks {
+		if err := ioTaskTmpl.Execute(buf, ioTaskDef(m, g.tasksStruct, "")); err != nil {
+			panic(err)
+		}
+	}
+	buf.WriteString(ioWorkerHelpers)
+}
+
+// generateIOAdapter generates the driver-side IO adapter of the IO task.
+func (g *Generator) generateIOAdapter(buf *bytes.Buffer, m Method, docQualifier string) {
+	if err := ioAdapterTmpl.Execute(buf, ioTaskDef(m, g.tasksStruct, docQualifier)); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,94 @@
+package goraygen
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIOAdapters(t *testing.T) {
+	var warnings []string
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "skip-io-adapter" || d.Code == "unsupported-param" {
+			warnings = append(warnings, d.Message)
+		}
+	})
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "io"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Count(name string, r io.Reader) (int, error) { return 0, nil }
+
+func (Tasks) Export(w io.Writer, n int) error { return nil }
+
+func (Tasks) Copy(r io.Reader, w io.Writer) {}
+
+func (Tasks) Merge(a, b io.Reader) {}
+
+func (Tasks) Sync(r io.Reader) {}
+
+func (Tasks) SyncIO() {}
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Sink() *Sink { return &Sink{} }
+
+type Sink struct{}
+
+func (s *Sink) Feed(r io.Reader) {}
+`}, opts)
+
+	// the driver-side adapters
+	require.Contains(t, code, "func CountIO(name string, r io.Reader, options ...*ray.RayOption) (_r0 int, _err error) {")
+	require.Contains(t, code, `_future := NewRemoteFunc[*Future2[int, error]]("CountIO", ExpandArgs([]any{name}, _chunks)).Remote(options...)`)
+	require.Contains(t, code, "func ExportIO(w io.Writer, n int, options ...*ray.RayOption) (_err error) {")
+	require.Contains(t, code, `_future := NewRemoteFunc[*Future2[[]byte, error]]("ExportIO", []any{n}).Remote(options...)`)
+	require.Contains(t, code, "_out, _taskErr, _err = _future.Get()")
+	require.Contains(t, code, "_, _err = w.Write(_out)")
+	require.Contains(t, code, "func _putChunks(r io.Reader) ([]ray.SharedObject[[]byte], error) {")
+	// the worker-side variants
+	require.Contains(t, code, "func (_t Tasks) CountIO(name string, _chunks ...[]byte) (int, error) {\n\t_r0, _err := _t.Count(name, _chunkReader(_chunks))\n\treturn _r0, _err\n}")
+	require.Contains(t, code, "func (_t Tasks) ExportIO(n int) ([]byte, error) {\n\tvar _w bytes.Buffer\n\t_err := _t.Export(&_w, n)\n\treturn _w.Bytes(), _err\n}")
+	require.Contains(t, code, "func (_t Tasks) CopyIO(_chunks ...[]byte) []byte {")
+	require.Contains(t, code, "func _chunkReader(chunks [][]byte) io.Reader {")
+	// no generic wrappers for the streams
+	require.NotContains(t, code, "func Count[")
+	require.NotContains(t, code, "io.Reader |")
+	require.Equal(t, []string{
+		"Skip method (Tasks).Merge: an IO adapter takes one io.Reader and one io.Writer at most",
+		"Skip method (Tasks).Sync: Tasks already declares the SyncIO method of its IO adapter",
+		"Skip method (*Sink).Feed: param r of type io.Reader can't be serialized",
+	}, warnings)
+}
+
+func TestIOAdaptersOutsidePackage(t *testing.T) {
+	var warnings []string
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "skip-io-adapter" {
+			warnings = append(warnings, fmt.Sprintf("%d: %s", d.Line, d.Message))
+		}
+	})
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "io"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Count(r io.Reader) int { return 0 }
+`}, "example.com/mypkg")
+	g := NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = "out", "example.com/out"
+	g.collectWorkloads()
+	require.Empty(t, g.ioTasks)
+	require.Empty(t, g.tasks)
+	require.Equal(t, []string{"8: Skip method (Tasks).Count: the IO adapter must be generated into package example.com/mypkg"}, warnings)
+}
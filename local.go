@@ -0,0 +1,180 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+/*
+func DivideLocal(a int64, b int64) (_r0 int64, _r1 int64, _err error)
+func DivideCall(a int64, b int64) (int64, int64, error)
+
+For methods following the error-last convention, the error of the task is merged into the error of the call:
+
+	func OpenLocal(name string) (_r0 *File, _err error)
+	func OpenCall(name string) (*File, error)
+*/
+const localVariantTpl = `
+// {{.Name}}Local calls [{{.DocLink}}] in-process, with the same results as the remote call.
+// A panic of the task is returned as error.
+func {{.Name}}Local({{.Params}}) ({{range .Results}}{{.}}, {{end}}_err error) {
+	defer func() {
+		if _r := recover(); _r != nil {
+			_err = fmt.Errorf("task {{.Name}} panicked: %v", _r)
+		}
+	}()
+	{{- if .MappedResults}}
+	{{.MappedVars}} := new({{.StructType}}).{{.Name}}({{.LocalArgs}})
+	return {{.MappedResults}}
+	{{- else}}
+	{{if .ResultVars}}{{.ResultVars}} = {{end}}new({{.StructType}}).{{.Name}}({{.LocalArgs}})
+	return {{.ReturnValues}}
+	{{- end}}
+}
+
+// {{.Name}}Call calls [{{.Name}}] remotely and waits for the results, or calls {{.Name}}Local in local mode (see SetLocalMode).
+{{- if .CallParams}}
+// The options only apply to the remote call, they are ignored in local mode.
+{{- end}}
+{{- if .CallParams}}
+func {{.Name}}Call({{.CallParams}}) ({{range .Results}}{{.}}, {{end}}_err error) {
+	if LocalMode() {
+		return {{.Name}}Local({{.ForwardArgs}})
+	}
+	_future, _err := {{.Name}}Remote({{.RemoteArgs}})
+	if _err != nil {
+		return
+	}
+	{{.RemoteBody}}
+}
+{{- else}}
+func {{.Name}}Call({{.Params}}) ({{range .ResultTypes}}{{.}}, {{end}}error) {
+	if LocalMode() {
+		return {{.Name}}Local({{.ForwardArgs}})
+	}
+	{{.RemoteBody}}
+}
+{{- end}}
+`
+
+// localModeDef is the runtime mode switch of the FooCall functions, generated once per file.
+const localModeDef = `
+var _localMode atomic.Bool
+
+// SetLocalMode makes the FooCall functions call the tasks in-process (local is true),
+// or remotely (local is false, the default), e.g. for unit tests and single-machine debugging.
+func SetLocalMode(local bool) {
+	_localMode.Store(local)
+}
+
+// LocalMode reports whether the FooCall functions call the tasks in-process, see SetLocalMode.
+func LocalMode() bool {
+	return _localMode.Load()
+}
+`
+
+var localVariantTmpl = template.Must(template.New("localVariant").Parse(localVariantTpl))
+
+// LocalVariantDef is the template data of localVariantTpl.
+type LocalVariantDef struct {
+	Name         string
+	DocLink      string
+	StructType   string   // the tasks struct type, qualified if generated into another package
+	Params       string   // e.g. "a int64, b int64"
+	CallParams   string   // the params of the caller with the typed options, e.g. "a int64, b int64, opts ...DivideOption"
+	LocalArgs    string   // args to call the original method, the params converted from the mapped types, see TypeMapping
+	ForwardArgs  string   // args to forward the params to the local variant, e.g. "a, b"
+	RemoteArgs   string   // args of FooRemote with the typed options, e.g. "a, b, opts..."
+	RemoteBody   string   // statements waiting for the remote call and returning the results, see futureGetBody
+	Results      []string // named results of the local variant without the error, e.g. "_r0 int64"
+	ResultTypes  []string // the types of Results
+	ResultVars   string   // vars assigned the results of the original method, e.g. "_r0, _r1" or "_r0, _err"
+	ReturnValues string   // e.g. "_r0, _r1, nil" or "_r0, _err"
+	// with results of mapped types, the results of the original method, and them converted to the mapped types
+	MappedVars    string // e.g. "_o0, _o1"
+	MappedResults string // e.g. "string(_o0), _o1, nil"
+}
+
+// generateLocalVariant generates the in-process variant of the task, and the caller switching between the remote
+// and in-process calls. With the option builders, the caller takes the typed options of the task like FooRemote,
+// they are ignored in-process. The error of a task following the error-last convention is merged into the error
+// of the call, like in FooRemoteCaller.
+func (g *Generator) generateLocalVariant(buf *bytes.Buffer, method Method, docQualifier string) {
+	def := LocalVariantDef{
+		Name:       method.Name,
+		DocLink:    docQualifier + strings.TrimPrefix(method.ReceiverType, "*") + "." + method.Name,
+		StructType: strings.TrimPrefix(g.qualifiedReceiverType(method), "*"),
+	}
+
+	var params, callArgs, localArgs, forwardArgs, remoteArgs []string
+	for i, p := range method.Params {
+		isVariadic := method.IsVariadic && i == len(method.Params)-1
+		if m, ok := g.importStore.Mapping(p.GoType); ok {
+			localArgs = append(localArgs, m.FromWireExpr(p.Name, p.GoType, g.outputPkgPath, g.importStore))
+		} else if isVariadic {
+			localArgs = append(localArgs, p.Name+"...")
+		} else {
+			localArgs = append(localArgs, p.Name)
+		}
+		if isVariadic {
+			params = append(params, fmt.Sprintf("%s ...%s", p.Name, p.Type))
+			callArgs = append(callArgs, p.Name+"...")
+			remoteArgs = append(remoteArgs, p.Name) // a slice in FooRemote, as the options are variadic
+		} else {
+			params = append(params, fmt.Sprintf("%s %s", p.Name, p.Type))
+			callArgs = append(callArgs, p.Name)
+			remoteArgs = append(remoteArgs, p.Name)
+		}
+		forwardArgs = append(forwardArgs, callArgs[i])
+	}
+	def.Params = strings.Join(params, ", ")
+	def.LocalArgs = strings.Join(localArgs, ", ")
+	def.ForwardArgs = strings.Join(forwardArgs, ", ")
+	call := fmt.Sprintf("%s(%s).Remote()", method.Name, strings.Join(callArgs, ", "))
+	if g.opts.OptionBuilders {
+		if method.IsVariadic { // the variadic param is a slice like in FooRemote, as the options are variadic
+			last := method.Params[len(method.Params)-1]
+			params[len(params)-1] = fmt.Sprintf("%s []%s", last.Name, last.Type)
+		}
+		def.CallParams = strings.Join(append(params, fmt.Sprintf("opts ...%sOption", method.Name)), ", ")
+		def.RemoteArgs = strings.Join(append(remoteArgs, "opts..."), ", ")
+		call = "_future"
+	}
+	def.RemoteBody = futureGetBody(method, call)
+
+	var vars, mappedVars, mappedResults []string
+	mapped := false
+	for i, r := range method.Results {
+		v := fmt.Sprintf("_o%d", i)
+		mappedVars = append(mappedVars, v)
+		if r.IsError && i == len(method.Results)-1 {
+			vars = append(vars, "_err")
+			mappedResults = append(mappedResults, v)
+			continue
+		}
+		vars = append(vars, fmt.Sprintf("_r%d", i))
+		def.Results = append(def.Results, fmt.Sprintf("_r%d %s", i, r.Type))
+		def.ResultTypes = append(def.ResultTypes, r.Type)
+		if m, ok := g.importStore.Mapping(r.GoType); ok {
+			v = m.ToWireExpr(v, g.importStore)
+			mapped = true
+		}
+		mappedResults = append(mappedResults, v)
+	}
+	def.ResultVars = strings.Join(vars, ", ")
+	if !method.ReturnsError() {
+		vars = append(vars, "nil")
+		mappedResults = append(mappedResults, "nil")
+	}
+	def.ReturnValues = strings.Join(vars, ", ")
+	if mapped {
+		def.MappedVars = strings.Join(mappedVars, ", ")
+		def.MappedResults = strings.Join(mappedResults, ", ")
+	}
+
+	if err := localVariantTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
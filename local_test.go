@@ -0,0 +1,55 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateLocalVariants(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (*Tasks) Echo(prefix string, args ...int) []int { return args }
+
+func (Tasks) Ping() {}
+
+type Point struct{ X, Y int }
+
+func (Tasks) Fail(p Point) (Point, error) { return p, nil }
+
+func (Tasks) Check() error { return nil }
+`}
+	code := generateFromSource(t, sources, Options{LocalVariants: true})
+
+	require.Contains(t, code, "func DivideLocal(a int64, b int64) (_r0 int64, _r1 int64, _err error) {")
+	require.Contains(t, code, "_r0, _r1 = new(Tasks).Divide(a, b)")
+	require.Contains(t, code, "func DivideCall(a int64, b int64) (int64, int64, error) {")
+	require.Contains(t, code, "return Divide(a, b).Remote().Get()")
+	require.Contains(t, code, "func EchoCall(prefix string, args ...int) ([]int, error) {")
+	require.Contains(t, code, "return EchoLocal(prefix, args...)")
+	require.Contains(t, code, "func PingLocal() (_err error) {")
+	require.Contains(t, code, "\tnew(Tasks).Ping()\n\treturn nil\n")
+	require.Contains(t, code, "func SetLocalMode(local bool) {")
+	// the error of the task is merged into the error of the call
+	require.Contains(t, code, "func FailLocal(p Point) (_r0 Point, _err error) {")
+	require.Contains(t, code, "\t_r0, _err = new(Tasks).Fail(p)\n\treturn _r0, _err\n")
+	require.Contains(t, code, "func FailCall(p Point) (Point, error) {")
+	require.Contains(t, code, "_r0, _taskErr, _err := Fail(p).Remote().Get()\n\tif _err == nil {\n\t\t_err = _taskErr\n\t}\n\treturn _r0, _err")
+	require.Contains(t, code, "func CheckLocal() (_err error) {")
+	require.Contains(t, code, "\t_err = new(Tasks).Check()\n\treturn _err\n")
+	require.Contains(t, code, "func CheckCall() error {")
+
+	// the typed options are taken like in FooRemote
+	code = generateFromSource(t, sources, Options{LocalVariants: true, OptionBuilders: true})
+	require.Contains(t, code, "// The options only apply to the remote call, they are ignored in local mode.\nfunc EchoCall(")
+	require.Contains(t, code, "return EchoLocal(prefix, args...)")
+	require.Contains(t, code, "func EchoCall(prefix string, args []int, opts ...EchoOption) (_r0 []int, _err error) {")
+	require.Contains(t, code, "_future, _err := EchoRemote(prefix, args, opts...)\n\tif _err != nil {\n\t\treturn\n\t}\n\treturn _future.Get()")
+	require.Contains(t, code, "func EchoLocal(prefix string, args ...int) (_r0 []int, _err error) {")
+	require.Contains(t, code, "_r0 = new(Tasks).Echo(prefix, args...)")
+}
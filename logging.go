@@ -0,0 +1,205 @@
+package goraygen
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogFormat is the format of the progress log of the generation.
+type LogFormat string
+
+const (
+	LogText LogFormat = "text" // "[INFO] message key=value" lines
+	LogJSON LogFormat = "json" // one JSON object per line, see slog.JSONHandler
+)
+
+// ParseLogFormat parses the value of the -log-format flag.
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch f := LogFormat(s); f {
+	case LogText, LogJSON:
+		return f, nil
+	case "":
+		return LogText, nil
+	}
+	return "", fmt.Errorf("invalid log format %q, expect text or json", s)
+}
+
+// LevelTrace is the level of the details logged with -vv, e.g. the methods of the actors and every phase entered.
+const LevelTrace = slog.LevelDebug - 4
+
+// logLevel returns the lowest level logged at the verbosity: info by default, debug with -v and trace with -vv.
+func logLevel(verbosity int) slog.Level {
+	switch {
+	case verbosity >= 2:
+		return LevelTrace
+	case verbosity == 1:
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// levelName returns the name of the level, TRACE for LevelTrace.
+func levelName(level slog.Level) string {
+	if level == LevelTrace {
+		return "TRACE"
+	}
+	return level.String()
+}
+
+// logger returns Options.Logger, or a logger writing to the standard log output in Options.LogFormat
+// at the level of Options.Verbosity.
+func (o Options) logger() *slog.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	level := logLevel(o.Verbosity)
+	if o.LogFormat == LogJSON {
+		return slog.New(slog.NewJSONHandler(log.Writer(), &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if level, ok := a.Value.Any().(slog.Level); ok && a.Key == slog.LevelKey && len(groups) == 0 {
+					a.Value = slog.StringValue(levelName(level))
+				}
+				return a
+			},
+		}))
+	}
+	return slog.New(&textHandler{level: level})
+}
+
+// textHandler writes the records as "[INFO] message key=value" lines with the standard logger, like the diagnostics.
+type textHandler struct {
+	level  slog.Level
+	prefix string // of the keys, the groups joined with dots
+	attrs  string // the formatted attrs of WithAttrs
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString("[" + levelName(r.Level) + "] " + r.Message + h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.writeAttr(&b, h.prefix, a)
+		return true
+	})
+	log.Print(b.String())
+	return nil
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		h.writeAttr(&b, h.prefix, a)
+	}
+	return &textHandler{level: h.level, prefix: h.prefix, attrs: h.attrs + b.String()}
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &textHandler{level: h.level, prefix: h.prefix + name + ".", attrs: h.attrs}
+}
+
+// writeAttr writes the attr as " key=value", quoting the values with spaces.
+func (h *textHandler) writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			h.writeAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	v := a.Value.String()
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		v = strconv.Quote(v)
+	}
+	b.WriteString(" " + prefix + a.Key + "=" + v)
+}
+
+// The phases of the generation of a package timed by phaseTimer.
+const (
+	phaseLoad      = "load"      // load, parse and type-check the package
+	phaseDiscovery = "discovery" // find the tasks and actors, run the TargetDiscovered hooks
+	phaseAnalysis  = "analysis"  // prepare the features of the methods, e.g. retries, codecs and versions
+	phaseRender    = "render"    // render and format the outputs
+	phaseWrite     = "write"     // write the outputs
+)
+
+// phaseTimer times the phases of the generation of a package. The time of a phase excludes the phases nested in it,
+// e.g. the write phase in the render one.
+type phaseTimer struct {
+	current string
+	since   time.Time
+	order   []string
+	totals  map[string]time.Duration
+}
+
+// switchTo adds the time since the last switch to the current phase, and makes the phase current.
+func (t *phaseTimer) switchTo(phase string) {
+	now := time.Now()
+	if t.current != "" {
+		t.totals[t.current] += now.Sub(t.since)
+	}
+	if phase != "" && !slices.Contains(t.order, phase) {
+		t.order = append(t.order, phase)
+	}
+	t.current, t.since = phase, now
+}
+
+// phase enters the phase of the generation, and returns the function leaving it for the enclosing one:
+//
+//	defer g.phase(phaseWrite)()
+func (g *Generator) phase(name string) func() {
+	if g.phases.totals == nil {
+		g.phases.totals = make(map[string]time.Duration)
+	}
+	g.trace("Enter phase", "phase", name)
+	outer := g.phases.current
+	g.phases.switchTo(name)
+	return func() { g.phases.switchTo(outer) }
+}
+
+// trace logs the message at LevelTrace, with -vv.
+func (g *Generator) trace(msg string, args ...any) {
+	g.logger.Log(context.Background(), LevelTrace, msg, args...)
+}
+
+// logPhases logs the time spent in every phase of the generation of the package, with -v.
+func (g *Generator) logPhases() {
+	var total time.Duration
+	for _, name := range g.phases.order {
+		d := g.phases.totals[name].Round(time.Microsecond)
+		total += d
+		g.logger.Debug("Phase done", "package", g.pkgPath(), "phase", name, "duration", d)
+	}
+	g.logger.Debug("Package done", "package", g.pkgPath(), "duration", total)
+}
+
+// pkgPath returns the import path of the scanned package, empty if not loaded.
+func (g *Generator) pkgPath() string {
+	if g.pkg == nil {
+		return ""
+	}
+	return g.pkg.PkgPath
+}
+
+// WithLogger sets the logger of the progress of the generation, instead of the standard log output.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) error {
+		o.Logger = logger
+		return nil
+	}
+}
@@ -0,0 +1,71 @@
+package goraygen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextLogger(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	defer log.SetFlags(log.Flags())
+	log.SetFlags(0)
+
+	logger := Options{Verbosity: 1}.logger()
+	logger.Debug("Phase done", "phase", "load", "duration", 3*time.Millisecond)
+	logger.Log(context.Background(), LevelTrace, "Enter phase", "phase", "load") // with -vv only
+	logger.With("package", "example.com/mypkg").WithGroup("file").Info("Write generated file", "path", "my wrappers.go")
+	require.Equal(t, `[DEBUG] Phase done phase=load duration=3ms
+[INFO] Write generated file package=example.com/mypkg file.path="my wrappers.go"
+`, logs.String())
+
+	_, err := ParseLogFormat("yaml")
+	require.Error(t, err)
+}
+
+func TestJSONLogger(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	logger := Options{Verbosity: 2, LogFormat: LogJSON}.logger()
+	logger.Log(context.Background(), LevelTrace, "Enter phase", "phase", "load")
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(logs.String()), &record))
+	require.Equal(t, "TRACE", record["level"])
+	require.Equal(t, "load", record["phase"])
+}
+
+func TestPhases(t *testing.T) {
+	var logs bytes.Buffer
+	g := NewGenerator(Options{Logger: slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))})
+	leave := g.phase(phaseRender)
+	g.phase(phaseWrite)() // nested, not counted in the render phase
+	leave()
+	g.logPhases()
+
+	var phases []string
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record struct {
+			Msg      string
+			Phase    string
+			Duration time.Duration
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		if record.Msg == "Phase done" {
+			phases = append(phases, record.Phase)
+		}
+	}
+	require.Equal(t, []string{phaseRender, phaseWrite}, phases)
+	require.Empty(t, g.phases.current)
+}
@@ -1,31 +1,45 @@
-package main
+package goraygen
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
+	"flag"
 	"fmt"
+	"go/ast"
 	"go/format"
-	"log"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 
 	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/imports"
 )
 
 const (
 	goRayRepo         = "github.com/ray4go/go-ray/ray"
-	raytasksComment   = "// raytasks"
-	rayactorsComment  = "// rayactors"
-	generatedFileName = "ray_workload_wrappers.go"
+	raytasksComment   = analysis.TasksMarker
+	rayactorsComment  = analysis.ActorsMarker
+	rayactorComment   = analysis.ActorMarker
+	generatedFileName = analysis.GeneratedFilePrefix + ".go"
 )
 
 const packageCommentsTPL = `
-// Code generated by goray. DO NOT EDIT.
+// %s
 //
 // This file was generated by goray.
 // It contains wrappers for the ray tasks and actors in this package.
@@ -34,35 +48,416 @@ const packageCommentsTPL = `
 //	  goraygen <package-path>
 `
 
-func main() {
-	log.SetFlags(0)
-	if len(os.Args) < 2 {
-		fmt.Printf("Usage: goraygen <package-path>\n")
-		os.Exit(1)
+// generatedHeader returns the header of the generated files without the comment syntax, following the convention
+// of go/ast.IsGenerated: the version of goraygen and the flags of the generation, e.g.
+// "Code generated by goraygen v1.2.0 -split-worker; DO NOT EDIT.". The package path is left out, it depends on the working dir.
+func (o Options) generatedHeader() string {
+	return fmt.Sprintf("Code generated by %s; DO NOT EDIT.", strings.Join(append([]string{"goraygen", Version()}, o.headerArgs...), " "))
+}
+
+// nonGenerationFlags are the flags left out of the header of the generated files: the ones not changing the generated code,
+// and -env whose values may be secrets.
+var nonGenerationFlags = []string{"config", "env", "diag-format", "diag-output", "max-errors", "strict", "v", "vv", "log-format", "dry-run", "stdout", "json"}
+
+// headerArgs returns the flags set on the command line for the header of the generated files, see Options.generatedHeader.
+func headerArgs(fs *flag.FlagSet) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if slices.Contains(nonGenerationFlags, f.Name) {
+			return
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && f.Value.String() == "true" {
+			args = append(args, "-"+f.Name)
+			return
+		}
+		values := []string{f.Value.String()}
+		if list, ok := f.Value.(*stringsFlag); ok {
+			values = *list
+		}
+		for _, v := range values {
+			if v == "" || strings.ContainsAny(v, " \t\n\"'\\") {
+				v = strconv.Quote(v)
+			}
+			args = append(args, "-"+f.Name+"="+v)
+		}
+	})
+	return args
+}
+
+// generateFlagSet defines the flags of the generation on a new flag set, for the generate command and the others
+// generating in memory. The returned function, called once the flags are parsed, builds the options of the package
+// with its config file applied (see Config), and the build tag combinations of -tag-matrix.
+func generateFlagSet(name string) (*flag.FlagSet, func(packagePath string) (Options, [][]string, error)) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var (
+		tags           = fs.String("tags", "", "comma-separated list of build tags to apply when loading packages")
+		outputDir      = fs.String("output-dir", "", "directory of the package to generate into, default is the scanned package dir")
+		outputPkg      = fs.String("output-pkg", "", "directory of the package to generate into relative to the scanned package dir, e.g. ./raygen, for every package of ./...")
+		output         = fs.String("output", "", "path of the wrappers file, a template like \"{{.Package}}_ray_gen.go\", an absolute path, or \"{{.Struct}}_gen.go\" for a file per struct, default is "+generatedFileName)
+		buildTags      = fs.String("build-tags", "", "build constraint of the generated Go files, e.g. goray to build them with -tags goray only, or an expression like \"goray && !race\"")
+		tagMatrix      = fs.String("tag-matrix", "", "build tag combinations like \"linux,cgo;darwin\", generates one file per combination")
+		receiverPolicy = fs.String("receiver-policy", string(ReceiverBoth), "which methods to generate by receiver kind: both, pointer-only or value-only")
+		resultRefs     = fs.Bool("result-refs", false, "generate a typed FooResultRef with Get(ctx)/Wait(ctx) for every task and actor method")
+		optionBuilders = fs.Bool("option-builders", false, "generate typed FooOptions and functional options with a FooRemote caller for every task and actor method")
+		manifest       = fs.Bool("manifest", false, "generate task name constants and a TaskManifest registry of the tasks")
+		deployManifest = fs.String("deploy-manifest", "", "also write the deployment manifest of the tasks and actors (names, versions, signature hashes, resources) into the scanned package: json or yaml")
+		taskGraph      = fs.Bool("task-graph", false, "generate the dependency graph of the tasks as a TasksGraph variable and a ray_tasks.dot file, see also the graph subcommand")
+		sigChecks      = fs.Bool("signature-checks", false, "generate compile-time signature assertions and a SignatureHashes registry to detect stale wrappers")
+		splitWorker    = fs.Bool("split-worker", false, "also generate a worker-side registration file into the scanned package, the wrappers file only contains the driver-side client code")
+		genWorkerMain  = fs.Bool("gen-worker-main", false, "generate a worker main package into cmd/worker of the module, implies -split-worker")
+		backendList    = fs.String("backends", "", "comma-separated backends to run instead of the ones selected by the other flags: "+strings.Join(backendNames(), ", "))
+		templatesDir   = fs.String("templates", "", "directory of the templates overriding the built-in ones, as <artifact>.tmpl files: task, actor, actor_method, options, registration")
+		genTaskCLI     = fs.Bool("gen-task-cli", false, "generate a debug CLI calling the tasks into cmd/taskcli of the module, with one subcommand per task")
+		ctxVariants    = fs.Bool("context-variants", false, "generate a FooContext caller for every task and actor method, cancelling the remote call when ctx is done")
+		withOtel       = fs.Bool("with-otel", false, "generate a FooInvoke caller for every task and actor method, tracing the calls with OpenTelemetry")
+		withMetrics    = fs.Bool("with-metrics", false, "generate a FooInvoke caller for every task and actor method, recording Prometheus metrics of the calls")
+		metricsNS      = fs.String("metrics-namespace", "goray", "namespace of the Prometheus metrics of -with-metrics")
+		withSlog       = fs.Bool("with-slog", false, "generate a FooInvoke caller for every task and actor method, logging the calls with the slog logger of InvokeConfig")
+		pools          = fs.Bool("pools", false, "generate a FooPool caller for every task, bounding the number of calls in flight")
+		taskVersion    = fs.Int("task-version", 0, "register the tasks with names suffixed by the version (e.g. Divide_v3) for rolling upgrades, 0 means unversioned")
+		versionAliases = fs.Int("task-version-aliases", 1, "number of previous task versions registered as aliases with -task-version")
+		gracefulStop   = fs.Bool("graceful-shutdown", false, "register the tasks on the worker through a wrapper tracking the calls in flight, with a Shutdown handler draining them, requires -split-worker")
+		checkpoints    = fs.Bool("checkpoints", false, "generate Snapshot/Restore methods serializing the state fields of every actor struct, with their remote callers")
+		lang           = fs.String("lang", string(LangGo), "language of the generated wrappers: go, or python for stubs calling the Go tasks and actors through the cross-language API of go-ray")
+		codec          = fs.String("codec", string(CodecMsgpack), "serialization of the package types in the task and actor signatures: msgpack (native), gob or json, overridden per type by //goray:codec")
+		gobRegister    = fs.Bool("gob-register", false, "generate a file registering the concrete types of the task and actor signatures with gob.Register, next to the wrappers and in the scanned package")
+		httpGateway    = fs.Bool("http-gateway", false, "generate a NewTasksHTTPHandler function serving POST /tasks/{name} with JSON params and results by calling the tasks remotely")
+		grpcGateway    = fs.Bool("grpc-gateway", false, "generate a RegisterTasksGRPCGateway function serving the tasks as the RPCs of a gRPC service by calling them remotely, implies -proto")
+		proto          = fs.Bool("proto", false, "generate ray_tasks.proto with the request/response messages of every task, and a FooProto task variant taking and returning them serialized, for drivers in other languages; tasks with interface, chan, func, array, other-package struct or float-keyed map types are skipped")
+		actorPing      = fs.Bool("actor-ping", false, "generate a trivial Ping method on every actor struct, with the Ping(ctx) error liveness probe on every actor handle")
+		timeoutVars    = fs.Bool("timeout-variants", false, "generate a FooWithTimeout caller for every task and actor method, cancelling the remote call after the timeout")
+		client         = fs.Bool("client", false, "generate a client interface covering all tasks (e.g. MyTasksClient), with the remote implementation")
+		mocks          = fs.Bool("mocks", false, "generate a FooCaller interface for every task, with the remote implementation and the FooCallerMock for tests")
+		localVariants  = fs.Bool("local-variants", false, "generate FooLocal in-process variants of every task, and FooCall switching between remote and local mode")
+		chaining       = fs.Bool("chaining", false, "accept the typed result references of other tasks as arguments of remote calls, implies -result-refs")
+		mapHelpers     = fs.Bool("map-helpers", false, "generate FooMap and FooMapAll batch helpers for every task, implies -result-refs and -option-builders")
+		gatherHelpers  = fs.Bool("gather-helpers", false, "generate typed WaitAllFoo and WaitAnyFoo helpers over the result refs of every task and actor method, implies -result-refs")
+		resultStructs  = fs.Bool("result-structs", false, "generate a FooOutput struct for every task and actor method with multiple results, and GetFooOutput waiting for the results of a call into it")
+		streaming      = fs.Bool("streaming", false, "generate a FooStream iterator for every task and actor method returning a channel, streaming its values from the worker")
+		includeTests   = fs.Bool("include-tests", false, "also scan _test.go files for tasks/actors structs")
+		resolveAliases = fs.Bool("resolve-aliases", false, "render the type aliases of the signatures as the types they alias, e.g. when an alias isn't importable by the output package")
+		env            stringsFlag
+		markers        stringsFlag
+		structs        stringsFlag
+		plugins        stringsFlag
+		typeMaps       stringsFlag
+		importAliases  stringsFlag
+		excludes       stringsFlag
+		diagFormat     = fs.String("diag-format", string(DiagText), "format of the diagnostics: text, json for one object per line with severity, code, file, line, column, message and suggestion, or sarif for code scanning")
+		diagOutput     = fs.String("diag-output", "", "file to write the json or sarif diagnostics to, default is stderr")
+		strict         = fs.Bool("strict", false, "report the warnings as errors, failing the run, e.g. in CI")
+		verbose        = fs.Bool("v", false, "verbose: also log the discovered methods and the time of the phases of the generation")
+		veryVerbose    = fs.Bool("vv", false, "very verbose: also log every method and phase entered")
+		logFormat      = fs.String("log-format", string(LogText), "format of the progress log: text or json for one object per line")
+		maxErrors      = fs.Int("max-errors", 0, "max number of errors and warnings to report, the others are only counted, 0 reports all")
+		configFile     = fs.String("config", "", "path of the config file, default is the "+ConfigFileName+" in the package dir or its parents up to the module root")
+	)
+	fs.Var(&env, "env", "extra KEY=VALUE environment variable used when loading packages (repeatable)")
+	fs.Var(&markers, "marker", "marker of the tasks/actors struct, like \"tasks=// mytasks\", \"actors=re:^//\\s*actors$\" or \"tasks=embed:example.com/pkg.TaskSet\" (repeatable)")
+	fs.Var(&structs, "struct", "explicit name of the tasks/actors struct, like \"tasks=MyTasks\" (repeatable)")
+	fs.Var(&plugins, "plugin", "plugin generator to run after the backends, like \"./my-gen\" or \"./my-gen:key=value\" passing the parameter after the colon (repeatable)")
+	fs.Var(&importAliases, "import-alias", "preferred name of an import of the generated files, like \"github.com/ray4go/go-ray/ray=goray\" (repeatable)")
+	fs.Var(&typeMaps, "map-type", "render a type as another one with the same representation, like \"example.com/ids.UserID=string\" (repeatable)")
+	fs.Var(&excludes, "exclude", "method not to generate, like \"Close\" or \"Tasks.Close\" (repeatable)")
+	return fs, func(packagePath string) (Options, [][]string, error) {
+		policy, err := ParseReceiverPolicy(*receiverPolicy)
+		if err != nil {
+			return Options{}, nil, err
+		}
+		codecValue, err := ParseCodec(*codec)
+		if err != nil {
+			return Options{}, nil, err
+		}
+		langValue, err := ParseLang(*lang)
+		if err != nil {
+			return Options{}, nil, err
+		}
+		manifestFormat, err := ParseManifestFormat(*deployManifest)
+		if err != nil {
+			return Options{}, nil, err
+		}
+		diagFormatValue, err := ParseDiagFormat(*diagFormat)
+		if err != nil {
+			return Options{}, nil, err
+		}
+		logFormatValue, err := ParseLogFormat(*logFormat)
+		if err != nil {
+			return Options{}, nil, err
+		}
+		if *output != "" {
+			if _, err := parseOutput(*output); err != nil {
+				return Options{}, nil, err
+			}
+		}
+		buildConstraint := *buildTags
+		if buildConstraint != "" {
+			if buildConstraint, err = ParseBuildConstraint(buildConstraint); err != nil {
+				return Options{}, nil, err
+			}
+		}
+		if *outputPkg != "" {
+			if err := checkOutputPkg(*outputPkg, *outputDir); err != nil {
+				return Options{}, nil, err
+			}
+		}
+		verbosity := 0
+		if *veryVerbose {
+			verbosity = 2
+		} else if *verbose {
+			verbosity = 1
+		}
+		opts := Options{
+			BuildTags:          splitList(*tags),
+			Env:                env,
+			OutputDir:          *outputDir,
+			OutputPkg:          *outputPkg,
+			Output:             *output,
+			BuildConstraint:    buildConstraint,
+			TemplatesDir:       *templatesDir,
+			Backends:           splitList(*backendList),
+			Plugins:            plugins,
+			ReceiverPolicy:     policy,
+			IncludeTests:       *includeTests,
+			ResolveAliases:     *resolveAliases,
+			ResultRefs:         *resultRefs || *mapHelpers || *chaining || *gatherHelpers,
+			OptionBuilders:     *optionBuilders || *mapHelpers,
+			Manifest:           *manifest,
+			TaskGraph:          *taskGraph,
+			DeployManifest:     manifestFormat,
+			SignatureChecks:    *sigChecks,
+			SplitWorker:        *splitWorker || *genWorkerMain,
+			GenWorkerMain:      *genWorkerMain,
+			GenTaskCLI:         *genTaskCLI,
+			ContextVariants:    *ctxVariants,
+			MapHelpers:         *mapHelpers,
+			GatherHelpers:      *gatherHelpers,
+			ResultStructs:      *resultStructs,
+			Streaming:          *streaming,
+			Chaining:           *chaining,
+			LocalVariants:      *localVariants,
+			Mocks:              *mocks,
+			Client:             *client,
+			TimeoutVariants:    *timeoutVars,
+			WithOtel:           *withOtel,
+			WithMetrics:        *withMetrics,
+			MetricsNamespace:   *metricsNS,
+			WithSlog:           *withSlog,
+			Pools:              *pools,
+			Checkpoints:        *checkpoints,
+			ActorPing:          *actorPing,
+			Codec:              codecValue,
+			Lang:               langValue,
+			GobRegister:        *gobRegister,
+			Proto:              *proto || *grpcGateway,
+			GRPCGateway:        *grpcGateway,
+			HTTPGateway:        *httpGateway,
+			GracefulShutdown:   *gracefulStop,
+			TaskVersion:        *taskVersion,
+			TaskVersionAliases: *versionAliases,
+			DiagFormat:         diagFormatValue,
+			diagOutputPath:     *diagOutput,
+			MaxErrors:          *maxErrors,
+			Strict:             *strict,
+			Verbosity:          verbosity,
+			LogFormat:          logFormatValue,
+			headerArgs:         headerArgs(fs),
+		}
+		if err := opts.applyTargetFlags(markers, structs); err != nil {
+			return Options{}, nil, err
+		}
+		for _, s := range typeMaps {
+			m, err := analysis.ParseTypeMapping(s)
+			if err != nil {
+				return Options{}, nil, err
+			}
+			opts.TypeMappings = append(opts.TypeMappings, m)
+		}
+		for _, s := range importAliases {
+			path, alias, err := ParseImportAlias(s)
+			if err != nil {
+				return Options{}, nil, err
+			}
+			if opts.ImportAliases == nil {
+				opts.ImportAliases = make(map[string]string)
+			}
+			opts.ImportAliases[path] = alias
+		}
+		opts.ExcludeMethods = excludes
+		if err := applyConfigFile(&opts, fs, *configFile, packagePath); err != nil {
+			return Options{}, nil, err
+		}
+		matrix, err := parseTagMatrix(*tagMatrix)
+		return opts, matrix, err
+	}
+}
+
+// runGenerateCommand runs the generate command, the default one: it generates the wrappers of the package.
+func runGenerateCommand(args []string, w io.Writer) (err error) {
+	fs, options := generateFlagSet("generate")
+	dryRun := fs.Bool("dry-run", false, "report the files that would be generated, whether they change and the backend generating them, without writing them")
+	stdout := fs.Bool("stdout", false, "print the generated code instead of writing the files, the file names are logged")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: goraygen [generate] [flags] <package-path>\n")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("expect a package path")
+	}
+	packagePath := fs.Arg(0)
+	opts, matrix, err := options(packagePath)
+	if err != nil {
+		return err
+	}
+	finish, err := startDiagnostics(&opts)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+	opts.DryRun = *dryRun
+	if *stdout {
+		opts.Stdout = w
+	}
+	return runMatrix(packagePath, opts, matrix)
+}
+
+// runMatrix generates the package once per build tag combination of the matrix, or once if there is none.
+// A failed combination doesn't stop the others, the errors are joined. The generated files are claimed once in the run,
+// see outputClaims.
+func runMatrix(packagePath string, opts Options, matrix [][]string) error {
+	if opts.outputClaims == nil {
+		opts.outputClaims = &outputClaims{}
+	}
+	if len(matrix) == 0 {
+		return run(packagePath, opts)
+	}
+	var errs []error
+	for i, tagSet := range matrix {
+		opts.logger().Info("Generating for build tags", "tags", strings.Join(tagSet, ","))
+		others := slices.Delete(slices.Clone(matrix), i, i+1)
+		if err := run(packagePath, opts.ForTagSet(tagSet, others...)); err != nil {
+			errs = append(errs, fmt.Errorf("build tags %s: %w", strings.Join(tagSet, ","), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyConfigFile applies the config file at path, or the one found from the package dir if path is empty,
+// to the options of the flags, which take precedence.
+func applyConfigFile(opts *Options, fs *flag.FlagSet, path, packagePath string) error {
+	if path == "" {
+		dir := packagePath
+		if !isDir(dir) {
+			dir = "."
+		}
+		var found bool
+		if path, found = FindConfig(dir); !found {
+			return nil
+		}
 	}
-	packagePath := os.Args[1]
-	g := NewGenerator()
-	if err := g.Run(packagePath); err != nil {
-		log.Fatal(err)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	flags := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { flags[f.Name] = true })
+	opts.logger().Info("Using config file", "path", path)
+	return cfg.apply(opts, flags)
+}
+
+func run(packagePath string, opts Options) error {
+	if IsWorkspaceRoot(packagePath) {
+		return RunWorkspace(packagePath, opts)
 	}
+	return NewGenerator(opts).Run(packagePath)
 }
 
 // Generator encapsulates state & steps for code generation.
 type Generator struct {
 	pkg *packages.Package
 
+	tasksStruct    string // name of the raytasks struct, empty if not found
+	actorsStruct   string // name of the rayactors struct, empty if not found
 	tasks          []Method
 	actorFactories []Method
 	actor2Methods  map[string][]Method // key is actor type name (Method.Name in actorFactories)
 	importStore    *ImportStore
+	actorHandles   []ActorHandle // actor structs annotated with `// rayactor`
 
 	typeConstraints *ParameterTypeConstraints
+	retryDefs       map[string]RetryDef // wrapper function name -> retry caller, see prepareRetries
+	// checkpointStructs are the actor structs to generate the checkpoint methods for, see collectCheckpointActors
+	checkpointStructs []string
+	// codecTypes are the types of the package encoded with a codec, see prepareCodecs
+	codecTypes []CodecType
+	// pingActors are the actor structs to generate the Ping method for, see collectPingActors
+	pingActors []PingActor
+	// skipped are the methods of the target structs dropped by the discovery, with the reason, see runListCommand
+	skipped []skippedMethod
+	// backend is the backend generating the outputs, reported by Options.DryRun
+	backend Backend
+	// wrappersWritten is set once the wrappers file is written, by the first of the backends generating it, see writeWrappers
+	wrappersWritten bool
+	// templates are the templates overriding the built-in ones by artifact, see loadTemplates
+	templates map[string]string
+	// templateErr is the first error executing the templates, returned by writeFile, see keepTemplateError
+	templateErr error
+	// idempotentTasks are the tasks with the //goray:idempotent directive, see prepareIdempotency
+	idempotentTasks []Method
+	// versionedTasks are the tasks registered with versioned names, see prepareTaskVersions
+	versionedTasks []Method
+	closeHook      bool // the tasks struct has a `Close() error` method run by Shutdown, see filterCloseHook
+	// protoTasks are the tasks with the protobuf variant, and proto their messages, see prepareProto
+	protoTasks []Method
+	proto      *protoSchema
+	// httpTasks are the tasks served by the HTTP handler, see prepareHTTPGateway
+	httpTasks []Method
+	// ioTasks are the tasks with io.Reader or io.Writer params, called by their IO adapters, see collectIOTask
+	ioTasks []Method
+	// streams are the tasks and actor methods with a channel result, streamed with -streaming, see collectStream
+	streams []Method
+
+	opts Options
+	// whether a tasks/actors struct is declared in a _test.go file, then the output is a _test.go file too
+	fromTestFile bool
+	// the package the generated file belongs to, same as pkg unless Options.OutputDir points elsewhere
+	outputPkgName string
+	outputPkgPath string
+
+	logger *slog.Logger // the progress log, see Options.logger
+	phases phaseTimer
+	// anonymousTypes are the named types of the anonymous params by name, see nameAnonymousTypes
+	anonymousTypes map[string]anonymousType
+	// output is the template of the wrappers file path, see resolveOutput, and claims the written ones
+	output *template.Template
+	claims *outputClaims
+}
+
+// templateLocals are the identifiers declared by the templates in the generated functions, e.g. `ctx` or `res`,
+// which the imports must not shadow. The ones starting with an underscore are left out, no package being named so.
+var templateLocals = []string{
+	"args", "attempt", "c", "cancel", "cfg", "ch", "cmd", "ctx", "done", "drainTimeout", "err", "fs", "i", "in",
+	"level", "metadata", "msg", "name", "names", "o", "ok", "opt", "out", "output", "outputs", "r", "ref", "refs",
+	"res", "result", "s", "sig", "span", "start", "taskErr", "timer", "v", "value", "waitCtx",
+}
+
+// newImportStore returns an import store of a generated file, reserving the names of the params of the package too.
+func (g *Generator) newImportStore() *analysis.ImportStore {
+	is := g.opts.newImportStore()
+	is.Reserve(analysis.ParamNames(g.pkg)...)
+	return is
 }
 
-func NewGenerator() *Generator {
-	is := NewImportStore()
+func NewGenerator(opts Options) *Generator {
+	is := opts.newImportStore()
 	is.AddImport(goRayRepo)
 	return &Generator{
+		opts:            opts,
+		logger:          opts.logger(),
+		claims:          cmp.Or(opts.outputClaims, &outputClaims{}),
 		actor2Methods:   make(map[string][]Method),
 		importStore:     is,
 		typeConstraints: &ParameterTypeConstraints{type2ConstraintId: make(map[string]int)},
@@ -73,141 +468,788 @@ func (g *Generator) Run(packagePath string) error { // orchestrates phases
 	if err := g.loadPackage(packagePath); err != nil {
 		return err
 	}
+	opts, err := g.opts.forPackage(g.pkg)
+	if err != nil {
+		return err
+	}
+	g.opts = opts
+	outputDir := g.opts.OutputDir
+	if outputDir == "" {
+		if !isDir(packagePath) && !filepath.IsAbs(g.opts.outputTemplate()) {
+			return fmt.Errorf("output dir is required when loading package by import path: %s", packagePath)
+		}
+		outputDir = packagePath
+	}
+	return g.generate(outputDir)
+}
+
+// generate runs the collect & codegen phases on the loaded package and writes the result into outputDir.
+func (g *Generator) generate(outputDir string) error {
+	defer g.logPhases()
+	if err := g.loadTemplates(); err != nil {
+		return err
+	}
+	if g.opts.OutputPkg != "" {
+		if err := checkOutputPkg(g.opts.OutputPkg, g.opts.OutputDir); err != nil {
+			return err
+		}
+		outputDir = filepath.Join(outputDir, g.opts.OutputPkg)
+	}
+	outputDir, err := g.resolveOutput(outputDir)
+	if err != nil {
+		return err
+	}
+	if err := g.resolveOutputPackage(outputDir); err != nil {
+		return err
+	}
+	leave := g.phase(phaseDiscovery)
 	g.collectWorkloads()
 	g.collectActorMethods()
-	code := g.generateCode()
-	if err := g.write(code, packagePath); err != nil {
+	g.collectActorHandles()
+	err = g.runTargetHooks()
+	if err == nil {
+		err = g.checkImportable()
+	}
+	g.checkSerializable()
+	g.nameAnonymousTypes()
+	if err == nil {
+		err = g.checkIdentifiableTypes()
+	}
+	leave()
+	if err != nil {
+		return err
+	}
+	selected, err := g.opts.selectedBackends()
+	if err != nil {
 		return err
 	}
+	if g.opts.GracefulShutdown && !g.splitWorker() {
+		g.report(Diagnostic{
+			Severity:   SeverityWarning,
+			Code:       "graceful-shutdown",
+			Message:    "-graceful-shutdown generates the shutdown handler into the worker registration file",
+			Suggestion: "use it with -split-worker",
+		})
+	}
+	defer g.phase(phaseRender)()
+	for _, b := range selected {
+		g.backend = b
+		if err := b.Generate(g, outputDir); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// loadPackage loads the package from a local directory, or by import path
+// (e.g. a package in the module cache or a sibling module) if packagePath is not a directory.
 func (g *Generator) loadPackage(packagePath string) error {
-	absTargetDir, err := filepath.Abs(packagePath)
-	if err != nil {
-		return fmt.Errorf("get abs path of package error: %w", err)
+	defer g.phase(phaseLoad)()
+	var cfg *packages.Config
+	pattern := "./"
+	if isDir(packagePath) {
+		absTargetDir, err := filepath.Abs(packagePath)
+		if err != nil {
+			return fmt.Errorf("get abs path of package error: %w", err)
+		}
+		cfg = g.opts.packagesConfig(absTargetDir)
+	} else {
+		cfg = g.opts.packagesConfig("")
+		pattern = packagePath
 	}
 
-	cfg := &packages.Config{
-		Dir:  absTargetDir,
-		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes,
-	}
-	pkgs, err := packages.Load(cfg, "./")
+	pkgs, err := analysis.Load(cfg, pattern)
 	if err != nil {
-		return err
+		return withExitCode(exitLoad, err)
 	}
 	if len(pkgs) == 0 {
-		return errors.New("no packages found in " + packagePath)
+		return withExitCode(exitLoad, errors.New("no packages found in "+packagePath))
 	}
 	g.pkg = pkgs[0]
-	if g.pkg.Errors != nil {
-		log.Printf("[ERROR] %d errors detected when load the package:", len(g.pkg.Errors))
-		for idx, e := range g.pkg.Errors {
-			log.Printf("%d. %v", idx+1, e)
+	g.reportPackageErrors()
+	return nil
+}
+
+// resolveOutputPackage determines the name and import path of the package in outputDir.
+// Types of the source package are qualified in generated code if it's not the output package.
+func (g *Generator) resolveOutputPackage(outputDir string) error {
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("get abs path of output dir error: %w", err)
+	}
+	if len(g.pkg.GoFiles) > 0 && filepath.Dir(g.pkg.GoFiles[0]) == absOutputDir {
+		g.outputPkgName, g.outputPkgPath = g.pkg.Name, g.pkg.PkgPath
+		return nil
+	}
+	g.outputPkgName = analysis.Identifier(filepath.Base(absOutputDir))
+	g.outputPkgPath = ""
+	if isDir(absOutputDir) {
+		pkgs, err := packages.Load(g.opts.packagesConfig(absOutputDir), "./")
+		if err == nil && len(pkgs) > 0 && pkgs[0].Name != "" {
+			g.outputPkgName, g.outputPkgPath = pkgs[0].Name, pkgs[0].PkgPath
 		}
+	} else if err := g.mkdirAll(absOutputDir); err != nil {
+		return fmt.Errorf("create output dir error: %w", err)
 	}
+	if g.outputPkgPath == "" && g.pkg.Module != nil && g.pkg.Module.Dir != "" {
+		// a new package, e.g. the output dir has no Go files yet: its import path is derived from the module
+		if rel, err := filepath.Rel(g.pkg.Module.Dir, absOutputDir); err == nil && !strings.HasPrefix(rel, "..") {
+			g.outputPkgPath = path.Join(g.pkg.Module.Path, filepath.ToSlash(rel))
+		}
+	}
+	g.mapSignatureTypes(g.importStore, g.outputPkgPath)
+	g.logger.Info("Generating into package", "package", g.outputPkgName, "dir", absOutputDir)
 	return nil
 }
 
+// modulePath returns the path of the module of the scanned package, empty if unknown.
+func (g *Generator) modulePath() string {
+	if g.pkg.Module == nil {
+		return ""
+	}
+	return g.pkg.Module.Path
+}
+
+// sourceQualifier returns the prefix to reference identifiers of the source package from generated code.
+func (g *Generator) sourceQualifier() string {
+	if g.outputPkgPath == g.pkg.PkgPath {
+		return ""
+	}
+	return g.importStore.AddPackage(g.pkg.Types) + "."
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// reportPackageErrors reports the errors of loading the package, see Diagnostic.
+func (g *Generator) reportPackageErrors() {
+	for _, e := range g.pkg.Errors {
+		d := Diagnostic{Severity: SeverityError, Code: "load", Message: e.Msg}
+		d.File, d.Line, d.Column = parsePosition(e.Pos)
+		g.report(d)
+	}
+}
+
 func (g *Generator) collectWorkloads() {
+	g.importStore.Reserve(analysis.ParamNames(g.pkg)...)
+	tasksMatcher, actorsMatcher := g.opts.tasksMatcher(), g.opts.actorsMatcher()
+	targets := analysis.DiscoverTargets(g.pkg, analysis.Matchers{Tasks: tasksMatcher, Actors: actorsMatcher})
 	// tasks
-	if s := FindStruct(g.pkg, raytasksComment); s != nil {
-		log.Printf("[INFO] Found raytasks struct: %s", s.Name.Name)
-		g.tasks = FindMethods(g.pkg, s.Name.Name, g.importStore)
+	if s := targets.Tasks; s != nil {
+		g.logger.Info("Found raytasks struct", "name", s.Name.Name)
+		g.tasksStruct = s.Name.Name
+		g.checkTestFile(s)
+		g.tasks = g.filterMethods(analysis.Methods(g.pkg, s.Name.Name, g.outputPkgPath, g.importStore))
+		g.filterCloseHook()
 		for _, m := range g.tasks {
-			log.Printf("+ Task: %s", m)
+			g.logger.Debug("Found task", "method", m.String())
 		}
 	} else {
-		log.Printf("[WARN] No struct with '%s' comment found", raytasksComment)
+		g.warnAt(g.packagePos(), "no-target-struct", "No struct with %s found", tasksMatcher)
+		g.reportNearMisses(tasksMatcher)
 	}
 	// actors
-	if s := FindStruct(g.pkg, rayactorsComment); s != nil {
-		log.Printf("[INFO] Found rayactors struct: %s", s.Name.Name)
-		g.actorFactories = FindMethods(g.pkg, s.Name.Name, g.importStore)
+	if s := targets.Actors; s != nil {
+		g.logger.Info("Found rayactors struct", "name", s.Name.Name)
+		g.actorsStruct = s.Name.Name
+		g.checkTestFile(s)
+		g.actorFactories = g.filterMethods(analysis.Methods(g.pkg, s.Name.Name, g.outputPkgPath, g.importStore))
 		g.actorFactories = gslice.Filter(g.actorFactories, func(m Method) bool {
-			return len(m.Results) == 1 // only keep valid actor factories
+			if len(m.Results) != 1 { // only keep valid actor factories
+				g.skip(m, "an actor factory returns the actor only")
+				return false
+			}
+			return true
 		})
 	} else {
-		log.Printf("[WARN] No struct with '%s' comment found", rayactorsComment)
+		g.warnAt(g.packagePos(), "no-target-struct", "No struct with %s found", actorsMatcher)
+		g.reportNearMisses(actorsMatcher)
 	}
+	g.checkTaskStreams()
 }
 
 func (g *Generator) collectActorMethods() {
+	g.collectCheckpointActors()
+	g.collectPingActors()
 	for _, actorFactory := range g.actorFactories {
 		actorTypeName := actorFactory.Results[0].Type
-		actorName := strings.TrimPrefix(actorTypeName, "*")
-		actorMethods := FindMethods(g.pkg, actorName, g.importStore)
-		log.Printf("+ Actor: %s", actorFactory)
+		actorName := strings.TrimPrefix(strings.TrimPrefix(actorTypeName, "*"), g.sourceQualifier())
+		actorMethods := g.filterMethods(analysis.Methods(g.pkg, actorName, g.outputPkgPath, g.importStore))
+		actorMethods = append(actorMethods, g.checkpointMethods(actorName)...)
+		g.logger.Debug("Found actor factory", "factory", actorFactory.String())
 		g.actor2Methods[actorFactory.Name] = actorMethods
 		for _, m := range actorMethods {
-			log.Printf("   - %s", m)
+			g.trace("Found actor method", "factory", actorFactory.Name, "method", m.String())
 		}
 	}
 }
 
+func (g *Generator) checkTestFile(s *ast.TypeSpec) {
+	if strings.HasSuffix(g.pkg.Fset.Position(s.Pos()).Filename, "_test.go") {
+		g.fromTestFile = true
+	}
+}
+
+// reportNearMisses reports struct comments that look like a mistyped comment marker.
+func (g *Generator) reportNearMisses(matcher StructMatcher) {
+	marker, ok := matcher.(CommentMatcher)
+	if !ok {
+		return
+	}
+	for _, nm := range FindNearMissMarkers(g.pkg, string(marker)) {
+		if gslice.Contains([]string{raytasksComment, rayactorsComment, rayactorComment}, strings.TrimSpace(nm.Comment)) {
+			continue // another valid marker
+		}
+		g.report(Diagnostic{
+			Severity:   SeverityWarning,
+			Code:       "near-miss-marker",
+			File:       nm.Pos.Filename,
+			Line:       nm.Pos.Line,
+			Column:     nm.Pos.Column,
+			Message:    fmt.Sprintf("struct %s has comment '%s'", nm.Struct, nm.Comment),
+			Suggestion: fmt.Sprintf("did you mean '%s'?", string(marker)),
+		})
+	}
+}
+
+// filterMethods drops the methods excluded by Options.ReceiverPolicy and Options.ExcludeMethods,
+// and the ones which can't be called remotely (see unsupportedParam and unsupportedResult), with a diagnostic for each.
+// The tasks with io.Reader or io.Writer params are moved to the IO tasks, see collectIOTask, the methods with a channel
+// result to the streams with -streaming, see collectStream, and the methods taking a context.Context are dropped
+// if their worker-side variant can't be generated, see contextParamReason.
+func (g *Generator) filterMethods(methods []Method) []Method {
+	return gslice.Filter(methods, func(m Method) bool {
+		if !g.opts.ReceiverPolicy.Allows(m) {
+			g.warnAt(g.methodPos(m), "receiver-policy", "Skip method (%s).%s: excluded by receiver policy %s", m.ReceiverType, m.Name, g.opts.ReceiverPolicy)
+			g.skip(m, "excluded by receiver policy "+string(g.opts.ReceiverPolicy))
+			return false
+		}
+		qualified := strings.TrimPrefix(m.ReceiverType, "*") + "." + m.Name
+		if gslice.Contains(g.opts.ExcludeMethods, m.Name) || gslice.Contains(g.opts.ExcludeMethods, qualified) {
+			g.report(g.at(g.methodPos(m), Diagnostic{Severity: SeverityInfo, Code: "excluded-method", Message: fmt.Sprintf("Skip method (%s).%s: excluded", m.ReceiverType, m.Name)}))
+			g.skip(m, "excluded by -exclude")
+			return false
+		}
+		if g.tasksStruct != "" && strings.TrimPrefix(m.ReceiverType, "*") == g.tasksStruct && hasIOParam(m) {
+			g.collectIOTask(m)
+			return false
+		}
+		if m.TakesContext() {
+			if reason, suggestion := g.contextParamReason(m); reason != "" {
+				g.report(g.at(g.methodPos(m), Diagnostic{
+					Severity:   SeverityWarning,
+					Code:       "unsupported-param",
+					Message:    fmt.Sprintf("Skip method (%s).%s: %s", m.ReceiverType, m.Name, reason),
+					Suggestion: suggestion,
+				}))
+				g.skip(m, reason)
+				return false
+			}
+		}
+		if reason, suggestion := unsupportedParam(m); reason != "" {
+			g.report(g.at(g.methodPos(m), Diagnostic{
+				Severity:   SeverityWarning,
+				Code:       "unsupported-param",
+				Message:    fmt.Sprintf("Skip method (%s).%s: %s", m.ReceiverType, m.Name, reason),
+				Suggestion: suggestion,
+			}))
+			g.skip(m, reason)
+			return false
+		}
+		if _, ok := streamElem(m); ok && g.opts.Streaming {
+			g.collectStream(m)
+			return false
+		}
+		if reason, suggestion := unsupportedResult(m); reason != "" {
+			g.report(g.at(g.methodPos(m), Diagnostic{
+				Severity:   SeverityError,
+				Code:       "unsupported-result",
+				Message:    fmt.Sprintf("Skip method (%s).%s: %s", m.ReceiverType, m.Name, reason),
+				Suggestion: suggestion,
+			}))
+			g.skip(m, reason)
+			return false
+		}
+		return true
+	})
+}
+
+// skippedMethod is a method of a target struct dropped by the discovery.
+type skippedMethod struct {
+	Method
+	Reason string
+}
+
+// skip records the method dropped by the discovery, see Generator.skipped.
+func (g *Generator) skip(m Method, reason string) {
+	g.skipped = append(g.skipped, skippedMethod{m, reason})
+}
+
+// generateCode renders the wrappers file of all the target structs.
 func (g *Generator) generateCode() string {
-	var buf bytes.Buffer
-	buf.WriteString(packageCommentsTPL)
-	fmt.Fprintf(&buf, "package %s\n\n", g.pkg.Name)
+	return g.generateFiles(func(string) string { return "" })[0].code
+}
+
+// wrappersFile is a wrappers file rendered by generateFiles.
+type wrappersFile struct {
+	name string // as returned by the fileOf of generateFiles
+	code string
+}
+
+// generateFiles renders the wrappers files, fileOf returning the name of the file of every target struct
+// (see Options.Output). The code shared by the structs, e.g. the helpers and the type constraints, goes to the first file;
+// the files without code of their own are left out, unless there is no other.
+func (g *Generator) generateFiles(fileOf func(structName string) string) []wrappersFile {
+	if g.opts.ResultRefs || g.opts.ContextVariants || len(g.pingActors) > 0 {
+		g.importStore.AddImport("context")
+	}
+	if g.opts.Chaining {
+		g.collectRefTypes()
+	}
+	leave := g.phase(phaseAnalysis)
+	g.prepareRetries()
+	g.prepareInstrumentation()
+	g.prepareIdempotency()
+	g.prepareTaskVersions()
+	g.prepareCodecs()
+	leave()
+	if len(g.idempotentTasks) > 0 {
+		for _, pkg := range []string{"crypto/sha256", "encoding/hex", "encoding/json", "fmt"} {
+			g.importStore.AddImport(pkg)
+		}
+	}
+	if g.opts.Pools {
+		g.importStore.AddImport("context")
+	}
+	if len(g.ioTasks) > 0 {
+		g.importStore.AddImport("io")
+	}
+	if len(g.contextMethods()) > 0 {
+		g.addContextImports()
+	}
+	if g.anyMethod(g.hasTimeoutVariant) {
+		for _, pkg := range []string{"context", "fmt", "time"} {
+			g.importStore.AddImport(pkg)
+		}
+	}
+	if g.opts.MapHelpers || g.opts.LocalVariants || g.opts.OptionBuilders || g.opts.GatherHelpers {
+		g.importStore.AddImport("fmt")
+	}
+	if g.opts.LocalVariants {
+		g.importStore.AddImport("sync/atomic")
+		g.sourceQualifier() // the tasks struct is referred by the local variants
+	}
+	if g.opts.Mocks {
+		g.importStore.AddImport("sync")
+	}
+	if g.opts.GRPCGateway {
+		g.prepareProto()
+		if len(g.protoTasks) > 0 {
+			for _, pkg := range []string{"context", "fmt", "google.golang.org/grpc", "google.golang.org/grpc/codes", "google.golang.org/grpc/status"} {
+				g.importStore.AddImport(pkg)
+			}
+		}
+	}
+	g.prepareHTTPGateway()
+	var checkpointsBuf bytes.Buffer
+	if !g.splitWorker() { // otherwise the checkpoint methods go to the worker file, see generateWorkerCode
+		g.generateCheckpoints(&checkpointsBuf)
+		g.generatePingMethods(&checkpointsBuf)
+		g.generateCodecs(&checkpointsBuf)
+		g.generateIdempotentTasks(&checkpointsBuf)
+		g.generateIOTasks(&checkpointsBuf)
+		g.generateStreamMethods(&checkpointsBuf)
+		g.generateContextTasks(&checkpointsBuf)
+		g.generateVersionedTasks(&checkpointsBuf)
+		g.prepareProto()
+		g.generateProtoTasks(&checkpointsBuf)
+	}
+	var checksBuf bytes.Buffer
+	if g.opts.SignatureChecks {
+		if !g.splitWorker() { // otherwise the assertions go to the worker file, see generateWorkerCode
+			g.generateSignatureAssertions(&checksBuf)
+		}
+		g.generateSignatureHashes(&checksBuf) // before dumping imports, as it may add imports
+	}
+	imports := g.importStore.Clone()
+	imports.AddDotImport(goRayRepo + "/generic")
+	var header bytes.Buffer
+	if g.opts.BuildConstraint != "" {
+		fmt.Fprintf(&header, "//go:build %s\n", g.opts.BuildConstraint)
+	}
+	fmt.Fprintf(&header, packageCommentsTPL, g.opts.generatedHeader())
+	fmt.Fprintf(&header, "package %s\n\n", g.outputPkgName)
+	header.WriteString(imports.ImportBlock(g.modulePath()))
 
-	// deterministic order for stable diffs
-	importList := g.importStore.DumpImportExprs()
-	sort.Strings(importList)
-	fmt.Fprintf(&buf, `import ( 
-		. "%s/generic" 
-		%s
-	)`, goRayRepo, strings.Join(importList, "\n\t"))
+	var names []string
+	bodies := make(map[string]*bytes.Buffer)
+	body := func(structName string) *bytes.Buffer {
+		name := fileOf(structName)
+		if bodies[name] == nil {
+			names = append(names, name)
+			bodies[name] = &bytes.Buffer{}
+		}
+		return bodies[name]
+	}
 
-	for _, m := range g.tasks {
-		generateWrapperFunction(taskDefTpl, &buf, m, g.typeConstraints, "")
+	docQualifier := ""
+	if g.outputPkgPath != g.pkg.PkgPath {
+		docQualifier = g.pkg.Name + "."
+	}
+	// the wrappers of the tasks or actors are left out if their backend isn't selected
+	tasks, actorFactories, actorHandles := g.tasks, g.actorFactories, g.actorHandles
+	if !g.backendSelected("tasks") {
+		tasks = nil
 	}
-	for _, factory := range g.actorFactories {
+	if !g.backendSelected("actors") {
+		actorFactories, actorHandles = nil, nil
+	}
+	buf := body(g.tasksStruct)
+	for _, m := range tasks {
+		g.generateArtifactWith("task", taskDefTpl, buf, m, g.typeConstraints, "", docQualifier, func(d *FuncDef) {
+			d.TaskName = g.taskName(m)
+			g.putLargeArgs(d, m.Name, m)
+		})
+		if g.opts.ResultRefs {
+			generateResultRef(buf, m.Name, m)
+		}
+		if g.opts.MapHelpers || g.opts.GatherHelpers || g.opts.ResultStructs {
+			generateOutputStruct(buf, m.Name, m)
+		}
+		if g.opts.ResultStructs {
+			generateResultStruct(buf, m.Name, m)
+		}
+		if g.opts.GatherHelpers {
+			generateGather(buf, m.Name, m)
+		}
+		if g.opts.Chaining {
+			generateChainableRef(buf, m.Name, m)
+		}
+		if g.opts.OptionBuilders {
+			g.generateArtifact("options", optionBuilderTpl, buf, m, g.typeConstraints, "", docQualifier)
+		}
+		if g.opts.ContextVariants {
+			g.keepTemplateError("", generateContextVariant(buf, m, g.typeConstraints, "", docQualifier))
+		}
+		if g.opts.MapHelpers {
+			generateMapHelper(buf, m)
+		}
+		if g.opts.LocalVariants {
+			g.generateLocalVariant(buf, m, docQualifier)
+		}
+		if g.opts.Mocks {
+			generateCaller(buf, m)
+		}
+		g.generateRetry(buf, m.Name)
+		g.generateTimeoutVariant(buf, m.Name, "", m)
+		g.generateInvoke(buf, m.Name, "", m)
+		g.generateIdempotentCaller(buf, m, docQualifier)
+		g.generateResources(buf, m.Name, m)
+		if g.opts.Pools {
+			g.generatePool(buf, m)
+		}
+	}
+	var streams []Method // the streams generated, see generateStreamHelpers
+	if tasks != nil {
+		for _, m := range g.ioTasks {
+			g.generateIOAdapter(buf, m, docQualifier)
+		}
+		for _, m := range g.streamsOf(g.tasksStruct) {
+			g.generateStream(buf, m, m.Name+"Stream", "ActorTaskStreams", false, docQualifier)
+			streams = append(streams, m)
+		}
+	}
+	if g.opts.Client && tasks != nil {
+		g.generateClient(buf, docQualifier)
+	}
+	g.generateGRPCGateway(buf, docQualifier)
+	g.generateHTTPGateway(buf, docQualifier)
+	if g.opts.Manifest {
+		generateManifest(buf, g.tasks, g.taskName)
+	}
+	if g.opts.TaskGraph {
+		g.generateTaskGraph(buf)
+	}
+	buf = body(g.actorsStruct)
+	for _, factory := range actorFactories {
 		actorName := factory.Name
-		generateWrapperFunction(actorDefTpl, &buf, factory, g.typeConstraints, actorName)
+		g.generateArtifactWith("actor", actorDefTpl, buf, factory, g.typeConstraints, actorName, docQualifier, func(d *FuncDef) {
+			g.putLargeArgs(d, "New"+actorName, factory)
+		})
+		g.generateResources(buf, actorName, factory)
+		g.generatePingProbe(buf, "Actor"+actorName, strings.TrimPrefix(strings.TrimPrefix(factory.Results[0].Type, "*"), g.sourceQualifier()))
 		for _, am := range g.actor2Methods[actorName] {
-			generateWrapperFunction(actorMethodDefTpl, &buf, am, g.typeConstraints, actorName)
+			g.generateArtifactWith("actor_method", actorMethodDefTpl, buf, am, g.typeConstraints, actorName, docQualifier, func(d *FuncDef) {
+				g.putLargeArgs(d, actorName+"_"+am.Name, am)
+			})
+			if g.opts.ResultRefs {
+				generateResultRef(buf, actorName+"_"+am.Name, am)
+			}
+			if g.opts.GatherHelpers || g.opts.ResultStructs {
+				generateOutputStruct(buf, actorName+"_"+am.Name, am)
+			}
+			if g.opts.GatherHelpers {
+				generateGather(buf, actorName+"_"+am.Name, am)
+			}
+			if g.opts.ResultStructs {
+				generateResultStruct(buf, actorName+"_"+am.Name, am)
+			}
+			if g.opts.Chaining {
+				generateChainableRef(buf, actorName+"_"+am.Name, am)
+			}
+			if g.opts.OptionBuilders {
+				g.generateArtifact("options", optionBuilderTpl, buf, am, g.typeConstraints, actorName, docQualifier)
+			}
+			if g.opts.ContextVariants {
+				g.keepTemplateError("", generateContextVariant(buf, am, g.typeConstraints, actorName, docQualifier))
+			}
+			g.generateRetry(buf, actorName+"_"+am.Name)
+			g.generateTimeoutVariant(buf, actorName+"_"+am.Name, actorName, am)
+			g.generateInvoke(buf, actorName+"_"+am.Name, actorName, am)
+			if _, ok := am.Directive(resourcesDirective); ok {
+				g.report(g.at(g.directivePos(am, resourcesDirective), Diagnostic{
+					Severity:   SeverityWarning,
+					Code:       "invalid-directive",
+					Message:    fmt.Sprintf("%s_%s: //goray:resources is ignored on actor methods", actorName, am.Name),
+					Suggestion: "add it to the actor factory",
+				}))
+			}
+		}
+		actorStruct := strings.TrimPrefix(strings.TrimPrefix(factory.Results[0].Type, "*"), g.sourceQualifier())
+		for _, m := range g.streamsOf(actorStruct) {
+			g.generateStream(buf, m, actorName+"_"+m.Name+"Stream", "Actor"+actorName, false, docQualifier)
+			streams = append(streams, m)
 		}
 	}
+	for _, h := range actorHandles {
+		buf := body(h.StructName)
+		g.keepTemplateError("", generateActorHandle(buf, h, docQualifier))
+		g.generatePingProbe(buf, h.StructName+"ActorHandle", h.StructName)
+		for _, m := range g.streamsOf(h.StructName) {
+			g.generateStream(buf, m, m.Name+"Stream", h.StructName+"ActorHandle", true, docQualifier)
+			streams = append(streams, m)
+		}
+	}
+	shared := names[0]
+	if i := slices.IndexFunc(names, func(name string) bool { return bodies[name].Len() > 0 }); i >= 0 {
+		shared = names[i]
+	}
+	buf = bodies[shared]
+	buf.Write(checksBuf.Bytes())
+	buf.Write(checkpointsBuf.Bytes())
+	if g.opts.OptionBuilders && (len(g.tasks) > 0 || len(g.actor2Methods) > 0) {
+		buf.WriteString(optionBuilderHelpers)
+	}
+	if g.opts.ContextVariants && (len(g.tasks) > 0 || len(g.actor2Methods) > 0) {
+		buf.WriteString(contextVariantHelpers)
+	}
+	if g.opts.Chaining && (len(g.tasks) > 0 || len(g.actor2Methods) > 0) {
+		buf.WriteString(chainHelpers)
+	}
+	if g.opts.LocalVariants && len(g.tasks) > 0 {
+		buf.WriteString(localModeDef)
+	}
+	if g.hasPutThreshold() {
+		buf.WriteString(putHelpers)
+	}
+	if len(g.ioTasks) > 0 && g.backendSelected("tasks") {
+		buf.WriteString(ioAdapterHelpers)
+	}
+	if len(streams) > 0 {
+		g.generateStreamHelpers(buf, slices.ContainsFunc(streams, g.isTaskStream))
+	}
+	g.generateContextMetadata(buf)
+	if len(g.retryDefs) > 0 {
+		buf.WriteString(retryHelpers)
+	}
+	g.generateInvokeHelpers(buf)
+	if g.opts.Pools && len(g.tasks) > 0 {
+		buf.WriteString(poolHelpers)
+	}
+	if len(g.idempotentTasks) > 0 {
+		buf.WriteString(idempotencyKeyHelper)
+	}
+	if g.hasResources() {
+		buf.WriteString(resourcesHelpers)
+	}
+	if len(g.pingActors) > 0 {
+		buf.WriteString(pingHelpers)
+	}
+	g.generateAnonymousTypes(buf)
 	buf.WriteString(g.typeConstraints.buf.String())
-	return buf.String()
+
+	var files []wrappersFile
+	for _, name := range names {
+		if bodies[name].Len() > 0 || len(names) == 1 {
+			files = append(files, wrappersFile{name, header.String() + bodies[name].String()})
+		}
+	}
+	return files
+}
+
+// anyMethod reports whether any task or actor method satisfies f.
+func (g *Generator) anyMethod(f func(Method) bool) bool {
+	if slices.ContainsFunc(g.tasks, f) {
+		return true
+	}
+	for _, methods := range g.actor2Methods {
+		if slices.ContainsFunc(methods, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputFileName returns the file name to write, a _test.go file if the tasks/actors are declared in test files.
+func (g *Generator) outputFileName(name string) string {
+	if g.fromTestFile {
+		return strings.TrimSuffix(name, ".go") + "_test.go"
+	}
+	return name
+}
+
+// writeFile formats the rendered Go code with formatGoSource and writes it.
+// It fails instead with the first error of the templates, see keepTemplateError.
+func (g *Generator) writeFile(code, outputFile string) error {
+	if g.templateErr != nil {
+		return g.templateErr
+	}
+	formatted, err := formatGoSource(outputFile, []byte(code))
+	if err != nil {
+		return err
+	}
+	return g.writeOutput(outputFile, formatted)
 }
 
-func (g *Generator) write(code, packagePath string) error {
-	_ = os.WriteFile("/tmp/out.go", []byte(code), 0o644) // for debug
-	formatted, err := format.Source([]byte(code))
+// formatGoSource formats the rendered Go code of the file like goimports then gofmt: it adds the missing imports
+// and removes the unused ones (see pruneImports), so the templates and the ImportStore don't have to be exact, and the result is
+// always gofmt-clean. A syntax error is a bug of the templates, reported with the offending line.
+func formatGoSource(filename string, src []byte) ([]byte, error) {
+	fixed, err := imports.Process(filename, fixGenerated(filename, src), &imports.Options{Comments: true, TabIndent: true, TabWidth: 8})
+	if err == nil {
+		fixed, err = format.Source(fixed)
+	}
 	if err != nil {
-		log.Printf("[WARN] Could not format generated code: %v", err)
-		formatted = []byte(code)
+		var list scanner.ErrorList
+		if errors.As(err, &list) && len(list) > 0 {
+			lines := strings.Split(string(src), "\n")
+			if n := list[0].Pos.Line; n > 0 && n <= len(lines) {
+				return nil, fmt.Errorf("format generated file %s: %w\n\t%s", filename, err, strings.TrimSpace(lines[n-1]))
+			}
+		}
+		return nil, fmt.Errorf("format generated file %s: %w", filename, err)
 	}
-	outputFile := filepath.Join(packagePath, generatedFileName)
-	formatted, err = imports.Process(outputFile, formatted, nil)
+	return fixed, nil
+}
+
+// fixGenerated fixes the rendered code before goimports, see requalifyImports and pruneImports.
+// The code is returned as is if it doesn't parse, the syntax error being reported by formatGoSource.
+func fixGenerated(filename string, src []byte) []byte {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
 	if err != nil {
-		return fmt.Errorf("auto imports error: %w", err)
+		return src
 	}
-	if err := os.WriteFile(outputFile, formatted, 0o644); err != nil {
-		return err
+	requalifyImports(f)
+	pruneImports(fset, f)
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return src
+	}
+	return buf.Bytes()
+}
+
+// requalifyImports renames the references of the templates to the packages by their names, e.g. `ray.X` or `fmt.Errorf`,
+// to the names of the imports when they're aliased: by -import-alias, or by the ImportStore when a param is named after
+// the package (see ImportStore.Reserve). A name another import has is left as is. The templates don't select on
+// the params, so a selector named after an aliased package refers to the package.
+func requalifyImports(f *ast.File) {
+	renames := make(map[string]string) // the name in the templates -> the name of the import
+	taken := make(map[string]bool)
+	for _, spec := range f.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		if spec.Name == nil {
+			taken[path.Base(p)] = true
+			continue
+		}
+		name := spec.Name.Name
+		taken[name] = true
+		if name != "_" && name != "." && name != path.Base(p) {
+			renames[path.Base(p)] = name
+		}
+	}
+	maps.DeleteFunc(renames, func(from, _ string) bool { return taken[from] })
+	if len(renames) == 0 {
+		return
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && renames[id.Name] != "" {
+				id.Name = renames[id.Name]
+			}
+		}
+		return true
+	})
+}
+
+// pruneImports removes the named and dot imports the rendered code doesn't reference, e.g. the ones of the template
+// branches left out by the options. goimports removes the other unused imports, but keeps the dot imports:
+// one is kept if the file references an identifier it neither declares nor imports, e.g. RemoteFunc of the generic package.
+func pruneImports(fset *token.FileSet, f *ast.File) {
+	referenced := make(map[string]bool) // the identifiers not declared by the file, the package names included
+	for _, id := range f.Unresolved {
+		referenced[id.Name] = true
+	}
+	imported := make(map[string]bool)
+	for _, spec := range f.Imports {
+		if spec.Name != nil {
+			imported[spec.Name.Name] = true
+		} else if p, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imported[path.Base(p)] = true // a guess, which keeps the dot imports if wrong
+		}
+	}
+	dotReferenced := false
+	for name := range referenced {
+		dotReferenced = dotReferenced || !imported[name] && types.Universe.Lookup(name) == nil
+	}
+	for _, spec := range slices.Clone(f.Imports) {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		switch {
+		case spec.Name == nil || spec.Name.Name == "_": // left to goimports, which knows the package names
+		case spec.Name.Name == ".":
+			if !dotReferenced {
+				astutil.DeleteNamedImport(fset, f, ".", p)
+			}
+		case !referenced[spec.Name.Name]:
+			astutil.DeleteNamedImport(fset, f, spec.Name.Name, p)
+		}
 	}
-	log.Printf("[INFO] Write generated wrapper to: %s", outputFile)
-	return nil
 }
 
 type ParameterTypeConstraints struct {
 	buf               bytes.Buffer
 	type2ConstraintId map[string]int
+	refTypes          map[string][]string // result type -> typed result references accepted as the type, nil if chaining is disabled
 }
 
 func (t *ParameterTypeConstraints) RegisterParameter(typeName string) string {
 	const typeConstraintPrefix = "_T"
 	const typeConstraintTpl = `
 type %s%d interface {
-	%s | *Future1[%s] | ray.SharedObject[%s]
+	%s | *Future1[%s] | ray.SharedObject[%s]%s
 }
 	`
 	if id, ok := t.type2ConstraintId[typeName]; ok {
 		return fmt.Sprintf("%s%d", typeConstraintPrefix, id)
 	}
 	typeConstraintId := len(t.type2ConstraintId)
-	typeConstraint := fmt.Sprintf(typeConstraintTpl, typeConstraintPrefix, typeConstraintId, typeName, typeName, typeName)
+	refs := ""
+	for _, ref := range t.refTypes[typeName] {
+		refs += " | " + ref
+	}
+	typeConstraint := fmt.Sprintf(typeConstraintTpl, typeConstraintPrefix, typeConstraintId, typeName, typeName, typeName, refs)
 	t.buf.WriteString(typeConstraint)
 	t.type2ConstraintId[typeName] = typeConstraintId
 	return fmt.Sprintf("%s%d", typeConstraintPrefix, typeConstraintId)
@@ -225,9 +1267,12 @@ func (t *ParameterTypeConstraints) String() string {
 */
 const taskDefTpl = `
 {{.Doc}}
-// original task: [{{.ReceiverType}}.{{.FuncName}}]
+// original task: [{{.DocLink}}]
+{{- if .ResultDoc}}
+// results: {{.ResultDoc}}
+{{- end}}
 func {{.FuncName}} {{.TypeConstraints}} ( {{.ParamList}} ) *RemoteFunc[*Future{{.ResLen}}{{.ResTypes}}] {
-	return NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]("{{.FuncName}}", {{.ArgsStatement}})
+	return NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]("{{.TaskName}}", {{.ArgsStatement}})
 }
 `
 
@@ -237,7 +1282,7 @@ type Actor{{.ActorName}} struct {
 }
 
 {{.Doc}}
-// original actor constructor: [{{.ReceiverType}}.{{.FuncName}}]
+// original actor constructor: [{{.DocLink}}]
 func New{{.ActorName}}{{.TypeConstraints}}({{.ParamList}}) *RemoteActor[Actor{{.ActorName}}] {
 	return NewRemoteActor[Actor{{.ActorName}}]("{{.ActorName}}", {{.ArgsStatement}})
 }
@@ -245,35 +1290,83 @@ func New{{.ActorName}}{{.TypeConstraints}}({{.ParamList}}) *RemoteActor[Actor{{.
 
 const actorMethodDefTpl = `
 {{.Doc}}
-// original actor method: [{{.ReceiverType}}.{{.FuncName}}]
+// original actor method: [{{.DocLink}}]
+{{- if .ResultDoc}}
+// results: {{.ResultDoc}}
+{{- end}}
 func {{.ActorName}}_{{.FuncName}} {{.TypeConstraints}} (_actor *Actor{{.ActorName}}, {{.ParamList}}) *RemoteFunc[*Future{{.ResLen}}{{.ResTypes}}] {
-	return NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]("{{.FuncName}}", {{.ArgsStatement}}, &_actor.ActorHandle)
+	return NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]("{{.TaskName}}", {{.ArgsStatement}}, &_actor.ActorHandle)
 }
 `
 
 type FuncDef struct {
 	FuncName        string
+	TaskName        string // the registered name of the method, FuncName unless versioned with -task-version or taking a context.Context
 	TypeConstraints string
 	ParamList       string
+	SliceParamList  string // ParamList with the variadic param as a slice, e.g. "x int, args []T"
+	CallArgs        string // args to pass the params on, e.g. "x, args..."
 	ResLen          int
 	ResTypes        string
+	ResultDoc       string // the named results, e.g. "quo int64, rem int64", empty if the results are unnamed
 	ArgsStatement   string
 	ReceiverType    string
 
 	ActorName string // only for actor def
 	Doc       string
+	DocLink   string // doc link to the original method, e.g. "pkg.MyTasks.Foo"
+
+	PassContext bool // only for context variant: pass ctx on as the first argument of the original method
+
+	Method Method // the wrapped method, for the overriding templates, see Options.TemplatesDir
 }
 
-func generateWrapperFunction(tpl string, buf *bytes.Buffer, method Method, paramTypeMapper *ParameterTypeConstraints, actorName string) {
+// resultDoc returns the results of the method with their names, e.g. "quo int64, rem int64", empty if none is named.
+// The unnamed results among named ones, i.e. the blank ones, are rendered as "_".
+func resultDoc(method Method) string {
+	if !slices.ContainsFunc(method.Results, func(r Result) bool { return r.Name != "" }) {
+		return ""
+	}
+	results := make([]string, len(method.Results))
+	for i, r := range method.Results {
+		name := r.Name
+		if name == "" {
+			name = "_"
+		}
+		results[i] = name + " " + r.Type
+	}
+	return strings.Join(results, ", ")
+}
+
+// generateWrapperFunction renders the wrapper function template of the method.
+// If paramTypeMapper is nil, parameters keep their concrete types instead of type constraints
+// (e.g. for methods, which can't have type parameters).
+// The template can fail to execute, e.g. an overriding one referring to a missing field, see Generator.generateArtifact.
+func generateWrapperFunction(tpl string, buf *bytes.Buffer, method Method, paramTypeMapper *ParameterTypeConstraints, actorName, docQualifier string) error {
+	return generateWrapperFunctionWith(tpl, buf, method, paramTypeMapper, actorName, docQualifier, nil)
+}
+
+// generateWrapperFunctionWith is like generateWrapperFunction, with customize (if not nil) to adjust the template data.
+func generateWrapperFunctionWith(tpl string, buf *bytes.Buffer, method Method, paramTypeMapper *ParameterTypeConstraints, actorName, docQualifier string, customize func(*FuncDef)) error {
 	paramNames := make([]string, len(method.Params))
 	paramList := make([]string, len(method.Params))
-	typeConstraintList := make([]string, len(method.Params))
+	var typeConstraintList []string
 	for i, param := range method.Params {
 		paramNames[i] = param.Name
 
-		paramTypeName := IdentifiableTypeName(param.Type)
-		paramTypeName = fmt.Sprintf("%s_%d", paramTypeName, i)
-		typeConstraintList[i] = fmt.Sprintf("%s %s", paramTypeName, paramTypeMapper.RegisterParameter(param.Type))
+		paramTypeName := param.Type
+		if i == 0 && method.TakesContext() { // not serialized, its metadata is passed instead
+			paramList[i] = fmt.Sprintf("%s %s", param.Name, param.Type)
+			continue
+		}
+		if paramTypeMapper != nil {
+			identifiable := param.IdentifiableType
+			if identifiable == "" { // e.g. a param added to a generated method
+				identifiable = analysis.IdentifiableIdent(analysis.Identifier(param.Type))
+			}
+			paramTypeName = fmt.Sprintf("%s_%d", identifiable, i)
+			typeConstraintList = append(typeConstraintList, fmt.Sprintf("%s %s", paramTypeName, paramTypeMapper.RegisterParameter(param.Type)))
+		}
 
 		if i == len(method.Params)-1 && method.IsVariadic {
 			// For variadic parameter, we need to remove the [] prefix
@@ -296,33 +1389,57 @@ func generateWrapperFunction(tpl string, buf *bytes.Buffer, method Method, param
 		resTypesStr = fmt.Sprintf("[%s]", strings.Join(resTypes, ", "))
 	}
 
-	argsStatement := fmt.Sprintf("[]any{%s}", strings.Join(paramNames, ", "))
+	// same as paramList but the variadic param is a slice, and the args to pass them on
+	sliceParamList := slices.Clone(paramList)
+	callArgs := slices.Clone(paramNames)
+	if method.IsVariadic && len(paramList) > 0 {
+		last := len(paramList) - 1
+		sliceParamList[last] = strings.Replace(paramList[last], " ...", " []", 1)
+		callArgs[last] += "..."
+	}
+
+	args := slices.Clone(paramNames)
+	if method.TakesContext() {
+		args[0] = fmt.Sprintf("_contextMetadata(%s)", args[0])
+	}
+	argsStatement := fmt.Sprintf("[]any{%s}", strings.Join(args, ", "))
 	if method.IsVariadic {
 		argsStatement = fmt.Sprintf(
 			"ExpandArgs([]any{%s}, %s)",
-			strings.Join(paramNames[:len(paramNames)-1], ", "),
-			paramNames[len(paramNames)-1],
+			strings.Join(args[:len(args)-1], ", "),
+			args[len(args)-1],
 		)
 	}
 
+	if paramTypeMapper != nil && paramTypeMapper.refTypes != nil && len(paramNames) > 0 {
+		argsStatement = fmt.Sprintf("_refArgs(%s)", argsStatement)
+	}
+
 	funcDef := FuncDef{
 		FuncName:        method.Name,
+		TaskName:        remoteName(method),
 		TypeConstraints: typeConstraints,
 		ParamList:       strings.Join(paramList, ", "),
+		SliceParamList:  strings.Join(sliceParamList, ", "),
+		CallArgs:        strings.Join(callArgs, ", "),
 		ResLen:          len(method.Results),
 		ResTypes:        resTypesStr,
+		ResultDoc:       resultDoc(method),
 		ArgsStatement:   argsStatement,
 		ReceiverType:    method.ReceiverType,
 		ActorName:       actorName,
-		Doc:             method.Doc,
+		Doc:             docWithoutDirectives(method.Doc),
+		DocLink:         docQualifier + strings.TrimPrefix(method.ReceiverType, "*") + "." + method.Name,
+		Method:          method,
 	}
 
-	tmpl, err := template.New("funcDef").Parse(tpl)
-	if err != nil {
-		panic(err)
+	if customize != nil {
+		customize(&funcDef)
 	}
-	err = tmpl.Execute(buf, funcDef)
+
+	tmpl, err := template.New("funcDef").Funcs(templateFuncs).Parse(tpl)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return tmpl.Execute(buf, funcDef)
 }
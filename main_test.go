@@ -0,0 +1,305 @@
+package goraygen
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/gg/gmap"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+const goModCentent = `
+module %s
+
+go 1.24
+
+require (
+	"github.com/ray4go/go-ray/ray" v1.0.0
+)
+
+`
+
+func makePkgFromSource(t *testing.T, sources map[string]string, pkgPath string) *packages.Package {
+	t.Helper()
+	pkgDir := t.TempDir()
+	goModFile := pkgDir + "/go.mod"
+	goModContent := fmt.Sprintf(goModCentent, pkgPath)
+	files := gmap.Map(sources, func(filename, content string) (string, []byte) {
+		return fmt.Sprintf("%s/%s.go", pkgDir, filename), []byte(content)
+	})
+	files[goModFile] = []byte(goModContent)
+
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes,
+		Dir:     pkgDir,
+		Overlay: files,
+	}
+
+	pkgs, err := packages.Load(cfg, ".")
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		for _, e := range pkg.Errors {
+			t.Logf("Package error: %v", e)
+		}
+	}
+	return pkg
+}
+
+// generateFromSource runs the collect & codegen phases on the package made of sources,
+// and returns the formatted generated code.
+func generateFromSource(t *testing.T, sources map[string]string, opts Options) string {
+	t.Helper()
+	pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+	g := NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	g.collectActorHandles()
+	g.nameAnonymousTypes()
+	code, err := format.Source([]byte(g.generateCode()))
+	require.NoError(t, err)
+	return string(code)
+}
+
+// buildGenerated writes the package made of sources into a module, runs the generation on it with opts
+// and type-checks the module with the generated files (go vet), against the go-ray stub of testdata/go-ray.
+// It returns the dir of the package.
+func buildGenerated(t *testing.T, sources map[string]string, opts Options) string {
+	t.Helper()
+	stub, err := filepath.Abs("testdata/go-ray")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	goMod := fmt.Sprintf("module example.com/mypkg\n\ngo 1.24\n\nrequire github.com/ray4go/go-ray v0.0.0\n\nreplace github.com/ray4go/go-ray => %s\n", stub)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644))
+	for name, src := range sources {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".go"), []byte(src), 0o644))
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+	require.NoError(t, NewGenerator(opts).Run(dir))
+	out, err := exec.Command("go", "vet", "-C", dir, "./...").CombinedOutput()
+	require.NoError(t, err, "%s", out)
+	return dir
+}
+
+func TestGenerateCode(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Divide divides.
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(n int) int { c.n += n; return c.n }
+`}, Options{})
+
+	require.Contains(t, code, "package mypkg")
+	require.Contains(t, code, "// Divide divides.\n// original task: [Tasks.Divide]\n")
+	require.Contains(t, code, "func Divide[int64_0 _T0, int64_1 _T0](a int64_0, b int64_1) *RemoteFunc[*Future2[int64, int64]] {")
+	require.Contains(t, code, "type ActorCounter struct {")
+	require.Contains(t, code, "func NewCounter[int_0 _T1](n int_0) *RemoteActor[ActorCounter] {")
+	require.Contains(t, code, "func Counter_Incr[int_0 _T1](_actor *ActorCounter, n int_0) *RemoteFunc[*Future1[int]] {")
+}
+
+func TestFormatGoSource(t *testing.T) {
+	src := `package mypkg
+import (
+	"strings"
+	"github.com/ray4go/go-ray/ray"
+)
+func   Hello( name string ) string {
+return fmt.Sprintf("hello %s", name)
+}
+var _ = ray.Init
+`
+	formatted, err := formatGoSource(filepath.Join(t.TempDir(), "ray_wrappers.go"), []byte(src))
+	require.NoError(t, err)
+	require.Equal(t, `package mypkg
+
+import (
+	"fmt"
+
+	"github.com/ray4go/go-ray/ray"
+)
+
+func Hello(name string) string {
+	return fmt.Sprintf("hello %s", name)
+}
+
+var _ = ray.Init
+`, string(formatted))
+	gofmt, err := format.Source(formatted)
+	require.NoError(t, err)
+	require.Equal(t, string(formatted), string(gofmt))
+
+	_, err = formatGoSource("ray_wrappers.go", []byte("package mypkg\n\nfunc Broken( {\n}\n"))
+	require.ErrorContains(t, err, "format generated file ray_wrappers.go: ray_wrappers.go:3:14: expected ')'")
+	require.ErrorContains(t, err, "\n\tfunc Broken( {")
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	sources := map[string]string{
+		"b_tasks": `package mypkg
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+func (Tasks) Seed(b *strings.Builder) time.Duration { return 0 }
+
+func (Tasks) Fetch(u url.URL) string { return u.String() }
+`,
+		"a_tasks": `package mypkg
+
+import "text/template"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Render(t *template.Template) string { return t.Name() }
+`,
+	}
+	code := generateFromSource(t, sources, Options{})
+	for range 2 {
+		require.Equal(t, code, generateFromSource(t, sources, Options{}))
+	}
+	// the methods in source order, by file name then position, and the imports sorted
+	require.Less(t, strings.Index(code, "func Render["), strings.Index(code, "func Seed["))
+	require.Less(t, strings.Index(code, "func Seed["), strings.Index(code, "func Fetch["))
+	require.Contains(t, code, "\t\"net/url\"\n\t\"strings\"\n\t\"text/template\"\n\t\"time\"\n")
+}
+
+func TestImportAliasOfRay(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+`}
+	code := generateFromSource(t, sources, Options{ImportAliases: map[string]string{goRayRepo: "goray"}})
+	formatted, err := formatGoSource("ray_wrappers.go", []byte(code))
+	require.NoError(t, err)
+	require.Contains(t, string(formatted), `goray "github.com/ray4go/go-ray/ray"`)
+	require.Contains(t, string(formatted), "goray.SharedObject[int64]")
+	require.NotContains(t, string(formatted), " ray.")
+	require.NotContains(t, string(formatted), "*ray.")
+
+	_, _, err = ParseImportAlias(goRayRepo + "=go-ray")
+	require.ErrorContains(t, err, "not a valid package name")
+}
+
+func TestReservedParamNames(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "bytes"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Size(bytes *bytes.Buffer, ray int) int { return bytes.Len() + ray }
+`}, Options{})
+	formatted, err := formatGoSource("ray_wrappers.go", []byte(code))
+	require.NoError(t, err)
+	require.Contains(t, string(formatted), `bytes2 "bytes"`)
+	require.Contains(t, string(formatted), `ray2 "github.com/ray4go/go-ray/ray"`)
+	require.Contains(t, string(formatted), "(bytes ptr_bytes2_Buffer_0, ray int_1) *RemoteFunc[*Future1[int]]")
+	require.Contains(t, string(formatted), "ray2.SharedObject[*bytes2.Buffer]")
+	require.NotContains(t, string(formatted), " ray.")
+}
+
+func TestPruneImports(t *testing.T) {
+	src := `package mypkg
+
+import (
+	"fmt"
+	tpl "text/template"
+	otel "go.opentelemetry.io/otel"
+	_ "image/png"
+	. "github.com/ray4go/go-ray/ray/generic"
+)
+
+func Hello(name string) string { return fmt.Sprint(name) }
+
+var _ *tpl.Template
+`
+	formatted, err := formatGoSource("ray_wrappers.go", []byte(src))
+	require.NoError(t, err)
+	// the unused named and dot imports are removed, the blank ones kept
+	require.Contains(t, string(formatted), "import (\n\t\"fmt\"\n\t_ \"image/png\"\n\ttpl \"text/template\"\n)\n")
+
+	// a dot import is kept if the file references an identifier it doesn't declare
+	src = strings.Replace(src, "var _ *tpl.Template", "var _ *RemoteFunc[*Future1[int]]", 1)
+	formatted, err = formatGoSource("ray_wrappers.go", []byte(src))
+	require.NoError(t, err)
+	require.Contains(t, string(formatted), `. "github.com/ray4go/go-ray/ray/generic"`)
+	require.NotContains(t, string(formatted), "otel")
+}
+
+func TestGenerateGenericTypes(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+func (Tasks) Swap(p Pair[string, int]) Pair[int, string] { return Pair[int, string]{p.Value, p.Key} }
+`}, Options{})
+	require.Contains(t, code, "func Swap[Pair_of_string_and_int_0 _T0](p Pair_of_string_and_int_0) *RemoteFunc[*Future1[Pair[int, string]]] {")
+	require.Contains(t, code, "Pair[string, int] | *Future1[Pair[string, int]] | ray.SharedObject[Pair[string, int]]")
+}
+
+func TestReservedParamNamesBuild(t *testing.T) {
+	// the templates referring to fmt, time and context compile with the params named after them
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(fmt, time int64) (int64, error) { return fmt / time, nil }
+
+func (Tasks) Wait(context int) error { return nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(fmt int) (int, error) { return c.n + fmt, nil }
+`}
+	for name, opts := range map[string]Options{
+		"option-builders":  {OptionBuilders: true},
+		"local-variants":   {LocalVariants: true},
+		"map-helpers":      {MapHelpers: true, OptionBuilders: true, ResultRefs: true},
+		"timeout-variants": {TimeoutVariants: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buildGenerated(t, sources, opts)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package goraygen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const taskNameConstPrefix = "TaskName"
+
+/*
+const (
+
+	TaskNameDivide = "Divide"
+
+)
+
+	var TaskManifest = []TaskInfo{
+		{Name: TaskNameDivide, Signature: "Divide(a int64, b int64) (int64, int64)", SignatureHash: "…", Doc: "Divide divides."},
+	}
+*/
+const taskInfoDef = `
+// TaskInfo describes a ray task with generated wrapper.
+type TaskInfo struct {
+	Name          string // the registered task name
+	Signature     string
+	SignatureHash string // changes whenever the signature changes
+	Doc           string
+}
+`
+
+// generateManifest generates the task name constants and the TaskManifest registry of the tasks,
+// taskName returns the registered name of a task.
+func generateManifest(buf *bytes.Buffer, tasks []Method, taskName func(Method) string) {
+	if len(tasks) == 0 {
+		return
+	}
+	buf.WriteString("\n// Names of the ray tasks.\nconst (\n")
+	for _, m := range tasks {
+		fmt.Fprintf(buf, "\t%s%s = %s\n", taskNameConstPrefix, m.Name, strconv.Quote(taskName(m)))
+	}
+	buf.WriteString(")\n")
+
+	buf.WriteString(taskInfoDef)
+	buf.WriteString("\n// TaskManifest lists the ray tasks with generated wrappers, for deployment tooling and runtime registries.\n")
+	buf.WriteString("var TaskManifest = []TaskInfo{\n")
+	for _, m := range tasks {
+		fmt.Fprintf(buf, "\t{Name: %s%s, Signature: %s, SignatureHash: %s, Doc: %s},\n",
+			taskNameConstPrefix, m.Name,
+			strconv.Quote(m.String()),
+			strconv.Quote(signatureHash(m)),
+			strconv.Quote(docText(m.Doc)),
+		)
+	}
+	buf.WriteString("}\n")
+}
+
+// signatureHash returns a short stable hash of the method signature, based on Method.CanonicalSignature.
+func signatureHash(m Method) string {
+	sum := sha256.Sum256([]byte(m.CanonicalSignature()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// docText converts a doc comment ("// line1\n// line2") into plain text.
+func docText(doc string) string {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		line = strings.TrimPrefix(line, "//")
+		line = strings.TrimPrefix(line, " ")
+		lines[i] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
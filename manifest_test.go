@@ -0,0 +1,29 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateManifest(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Divide divides.
+// It returns "quotient" and remainder.
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+`}, Options{Manifest: true})
+
+	require.Contains(t, code, `TaskNameDivide = "Divide"`)
+	require.Contains(t, code, "var TaskManifest = []TaskInfo{")
+	require.Contains(t, code, `{Name: TaskNameDivide, Signature: "Divide(a int64, b int64) (int64, int64)", SignatureHash: "`)
+	require.Contains(t, code, `Doc: "Divide divides.\nIt returns \"quotient\" and remainder."}`)
+}
+
+func TestDocText(t *testing.T) {
+	require.Equal(t, "Foo does something.\n\nIt returns error.", docText("// Foo does something.\n//\n// It returns error."))
+	require.Equal(t, "", docText(""))
+}
@@ -0,0 +1,122 @@
+package goraygen
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+	type DivideInput struct {
+		A int64
+		B int64
+	}
+
+func DivideMap(inputs []DivideInput, opts ...DivideOption) ([]DivideResultRef, error)
+func DivideMapAll(ctx context.Context, inputs []DivideInput, opts ...DivideOption) ([]DivideOutput, error)
+
+The context.Context param of a task isn't an input: DivideMap takes the ctx passed to every call, DivideMapAll passes on its own.
+*/
+const mapHelperTpl = `
+{{- if .InputFields}}
+// {{.Name}}Input holds the arguments of a remote [{{.Name}}] call, see {{.Name}}Map.
+type {{.Name}}Input struct {
+	{{range .InputFields}}{{.Name}} {{.Type}}
+	{{end}}
+}
+{{end}}
+// {{.Name}}Map calls [{{.Name}}] remotely for each of the inputs, the result references are returned in the order of inputs.
+// No call is made if the options are invalid.{{if .Context}} Each call is passed ctx.{{end}}
+func {{.Name}}Map({{if .Context}}ctx context.Context, {{end}}inputs []{{.InputType}}, opts ...{{.Name}}Option) ([]{{.Name}}ResultRef, error) {
+	refs := make([]{{.Name}}ResultRef, len(inputs))
+	for i, in := range inputs {
+		future, err := {{.Name}}Remote({{.CallArgs}}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = New{{.Name}}ResultRef(future)
+	}
+	return refs, nil
+}
+
+// {{.Name}}MapAll is like {{.Name}}Map, and gathers the results of all calls in the order of inputs.
+// It returns on the first failed call.
+func {{.Name}}MapAll(ctx context.Context, inputs []{{.InputType}}, opts ...{{.Name}}Option) ({{if .OutputType}}[]{{.OutputType}}, {{end}}error) {
+	refs, err := {{.Name}}Map({{if .Context}}ctx, {{end}}inputs, opts...)
+	if err != nil {
+		return {{if .OutputType}}nil, {{end}}err
+	}
+	{{- if .OutputType}}
+	outputs := make([]{{.OutputType}}, len(refs))
+	{{- end}}
+	for i, ref := range refs {
+		{{if .OutputType}}{{.Targets "outputs[i]"}}, err = ref.Get(ctx){{else}}err = ref.Wait(ctx){{end}}
+		if err != nil {
+			return {{if .OutputType}}nil, {{end}}fmt.Errorf("{{.Name}} #%d: %w", i, err)
+		}
+	}
+	return {{if .OutputType}}outputs, {{end}}nil
+}
+`
+
+var mapHelperTmpl = template.Must(template.New("mapHelper").Parse(mapHelperTpl))
+
+// MapHelperDef is the template data of mapHelperTpl.
+type MapHelperDef struct {
+	OutputDef
+	InputType   string     // the param type for single param task, otherwise NameInput
+	InputFields []FieldDef // fields of NameInput, empty for single param task
+	CallArgs    string     // args of NameRemote, e.g. "in.A, in.B"
+	Context     bool       // the task takes a context.Context, passed by NameMap instead of being an input
+}
+
+// FieldDef is a struct field in the generated code.
+type FieldDef struct {
+	Name string
+	Type string
+}
+
+// generateMapHelper generates the batch helpers of the task, tasks without params (but a context.Context) are skipped.
+// The helpers are built on the result refs, output struct and option builders of the task.
+func generateMapHelper(buf *bytes.Buffer, m Method) {
+	method, passContext := withoutContext(m)
+	if len(method.Params) == 0 {
+		return
+	}
+	def := MapHelperDef{OutputDef: outputDef(method.Name, method), Context: passContext}
+
+	paramType := func(i int) string {
+		if method.IsVariadic && i == len(method.Params)-1 {
+			return "[]" + method.Params[i].Type
+		}
+		return method.Params[i].Type
+	}
+	if len(method.Params) == 1 {
+		def.InputType = paramType(0)
+		def.CallArgs = "in"
+	} else {
+		def.InputType = method.Name + "Input"
+		args := make([]string, len(method.Params))
+		for i, p := range method.Params {
+			field := exportedFieldName(p.Name)
+			def.InputFields = append(def.InputFields, FieldDef{Name: field, Type: paramType(i)})
+			args[i] = "in." + field
+		}
+		def.CallArgs = strings.Join(args, ", ")
+	}
+	if passContext {
+		def.CallArgs = "ctx, " + def.CallArgs
+	}
+
+	if err := mapHelperTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// exportedFieldName returns the param name with the first letter upper-cased, e.g. "count" -> "Count".
+func exportedFieldName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
@@ -0,0 +1,40 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateMapHelpers(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Square(n int) (int, error) { return n * n, nil }
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Log(prefix string, args ...any) {}
+
+func (Tasks) Ping() string { return "pong" }
+`}, Options{ResultRefs: true, OptionBuilders: true, MapHelpers: true})
+
+	require.Contains(t, code, "func SquareMap(inputs []int, opts ...SquareOption) ([]SquareResultRef, error) {")
+	require.Contains(t, code, "future, err := SquareRemote(in, opts...)")
+	require.Contains(t, code, "refs, err := SquareMap(inputs, opts...)\n\tif err != nil {\n\t\treturn nil, err\n\t}")
+	require.Contains(t, code, "func SquareMapAll(ctx context.Context, inputs []int, opts ...SquareOption) ([]int, error) {")
+	require.Contains(t, code, "outputs[i], err = ref.Get(ctx)")
+
+	require.Contains(t, code, "type DivideInput struct {\n\tA int64\n\tB int64\n}")
+	require.Contains(t, code, "type DivideOutput struct {\n\tR0 int64\n\tR1 int64\n}")
+	require.Contains(t, code, "future, err := DivideRemote(in.A, in.B, opts...)")
+	require.Contains(t, code, "outputs[i].R0, outputs[i].R1, err = ref.Get(ctx)")
+
+	require.Contains(t, code, "type LogInput struct {\n\tPrefix string\n\tArgs   []any\n}")
+	require.Contains(t, code, "func LogMapAll(ctx context.Context, inputs []LogInput, opts ...LogOption) error {")
+	require.Contains(t, code, "err = ref.Wait(ctx)")
+
+	require.NotContains(t, code, "PingMap")
+}
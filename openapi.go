@@ -0,0 +1,272 @@
+package goraygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+/*
+With -http-gateway, the endpoints of the HTTP handler are described by the OpenAPI document embedded as a constant:
+
+	http.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, TasksOpenAPISpec)
+	})
+*/
+const openAPIVersion = "3.0.3"
+
+// openAPISchemas builds the JSON schemas of Go types, as encoded by encoding/json.
+// The named structs are described once in components and referred to, so recursive types are supported.
+type openAPISchemas struct {
+	pkgPath    string // the scanned package, its types are named without the package name
+	components map[string]any
+}
+
+// schema returns the JSON schema of the type.
+func (s *openAPISchemas) schema(typ types.Type) map[string]any {
+	if named, ok := typ.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time" {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if hasMethod(named, "MarshalJSON") {
+			return map[string]any{} // any value
+		}
+		if hasMethod(named, "MarshalText") {
+			return map[string]any{"type": "string"}
+		}
+		if st, ok := named.Underlying().(*types.Struct); ok {
+			name := s.componentName(named)
+			if _, found := s.components[name]; !found {
+				s.components[name] = nil // being described, for recursive types
+				s.components[name] = s.structSchema(st)
+			}
+			return map[string]any{"$ref": "#/components/schemas/" + name}
+		}
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		return basicSchema(t)
+	case *types.Pointer:
+		schema := s.schema(t.Elem())
+		if _, ok := schema["$ref"]; ok {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case *types.Slice:
+		if elem, ok := t.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Byte {
+			return map[string]any{"type": "string", "format": "byte", "nullable": true}
+		}
+		return map[string]any{"type": "array", "items": s.schema(t.Elem()), "nullable": true}
+	case *types.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case *types.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem()), "nullable": true}
+	case *types.Struct:
+		return s.structSchema(t)
+	}
+	return map[string]any{} // interfaces: any value
+}
+
+// structSchema returns the JSON schema of the struct: the object of the exported fields named by their json tags,
+// with the fields of the embedded structs promoted.
+func (s *openAPISchemas) structSchema(st *types.Struct) map[string]any {
+	properties := map[string]any{}
+	s.addFields(properties, st, map[*types.Struct]bool{})
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// addFields adds the properties of the fields of the struct, the structs already promoted being skipped when embedded
+// again, e.g. by `type Node struct{ *Node }`, like encoding/json does.
+func (s *openAPISchemas) addFields(properties map[string]any, st *types.Struct, promoted map[*types.Struct]bool) {
+	if promoted[st] {
+		return
+	}
+	promoted[st] = true
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i)).Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Embedded() && name == "" {
+			typ := field.Type()
+			if ptr, ok := typ.(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			if embedded, ok := typ.Underlying().(*types.Struct); ok {
+				s.addFields(properties, embedded, promoted)
+				continue
+			}
+		}
+		if !field.Exported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name()
+		}
+		if _, found := properties[name]; found {
+			continue // the shallower field wins
+		}
+		if strings.Contains(","+opts+",", ",string,") {
+			properties[name] = map[string]any{"type": "string"}
+			continue
+		}
+		properties[name] = s.schema(field.Type())
+	}
+}
+
+// componentName returns the name of the named type in the components of the document, e.g. "Point" or "otherpkg.Point".
+func (s *openAPISchemas) componentName(named *types.Named) string {
+	obj := named.Obj()
+	name := obj.Name()
+	for i := 0; i < named.TypeArgs().Len(); i++ {
+		name += "_" + strings.NewReplacer("*", "", "[", "", "]", "", " ", "").Replace(types.TypeString(named.TypeArgs().At(i), (*types.Package).Name))
+	}
+	if obj.Pkg() != nil && obj.Pkg().Path() != s.pkgPath {
+		name = obj.Pkg().Name() + "." + name
+	}
+	return name
+}
+
+// durationSchema is the JSON schema of the time.Duration results of the HTTP handler, see httpTimeHelpers.
+var durationSchema = map[string]any{"type": "string", "example": "1m30s"}
+
+// httpTimeSchemas are the JSON schemas of the time params of the HTTP handler by their type in the handler,
+// see httpTimeType.
+var httpTimeSchemas = map[string]map[string]any{
+	"_httpTime": {"oneOf": []any{
+		map[string]any{"type": "string", "format": "date-time"},
+		map[string]any{"type": "number", "description": "The seconds since the Unix epoch."},
+	}},
+	"_httpDuration": {"oneOf": []any{
+		durationSchema,
+		map[string]any{"type": "integer", "format": "int64", "description": "The nanoseconds."},
+	}},
+}
+
+// basicSchema returns the JSON schema of the basic type.
+func basicSchema(t *types.Basic) map[string]any {
+	info := t.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return map[string]any{"type": "boolean"}
+	case info&types.IsString != 0:
+		return map[string]any{"type": "string"}
+	case info&types.IsFloat != 0:
+		if t.Kind() == types.Float32 {
+			return map[string]any{"type": "number", "format": "float"}
+		}
+		return map[string]any{"type": "number", "format": "double"}
+	case info&types.IsInteger != 0:
+		schema := map[string]any{"type": "integer", "format": "int64"}
+		switch t.Kind() {
+		case types.Int8, types.Int16, types.Int32:
+			schema["format"] = "int32"
+		case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Uintptr:
+			schema["minimum"] = 0
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+// hasMethod reports whether the type or its pointer has the method.
+func hasMethod(typ types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(typ, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// openAPISpec returns the OpenAPI document of the endpoints of the HTTP handler, see httpGatewayTpl.
+func (g *Generator) openAPISpec() string {
+	s := &openAPISchemas{pkgPath: g.pkg.PkgPath, components: map[string]any{}}
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/GatewayError"}}},
+		}
+	}
+	paths := map[string]any{}
+	for _, m := range g.httpTasks {
+		params := map[string]any{}
+		cm, _ := withoutContext(m)
+		for _, p := range cm.Params {
+			params[p.Name] = s.schema(p.GoType) // the slice type for variadic param
+			if schema, ok := httpTimeSchemas[httpTimeType(p.GoType)]; ok {
+				params[p.Name] = schema
+			}
+		}
+		results := map[string]any{}
+		var required []string
+		for i, r := range m.Results {
+			if !r.IsError {
+				results[fmt.Sprintf("r%d", i)] = s.schema(r.GoType)
+				if httpTimeType(r.GoType) == "_httpDuration" {
+					results[fmt.Sprintf("r%d", i)] = durationSchema
+				}
+				required = append(required, fmt.Sprintf("r%d", i))
+			}
+		}
+		resultSchema := map[string]any{"type": "object", "properties": results}
+		if len(required) > 0 {
+			resultSchema["required"] = required
+		}
+		operation := map[string]any{
+			"operationId": m.Name,
+			"requestBody": map[string]any{
+				"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": params}}},
+			},
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "The results of the task, keyed by their indexes.",
+					"content":     map[string]any{"application/json": map[string]any{"schema": resultSchema}},
+				},
+				"400": errorResponse("Invalid params."),
+				"500": errorResponse("The task failed."),
+				"502": errorResponse("The remote call failed."),
+			},
+		}
+		if doc := docText(docWithoutDirectives(m.Doc)); doc != "" {
+			operation["description"] = doc
+		}
+		paths["/tasks/"+m.Name] = map[string]any{"post": operation}
+	}
+	s.components["GatewayError"] = map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"error": map[string]any{"type": "string"}},
+		"required":   []string{"error"},
+	}
+	doc := map[string]any{
+		"openapi": openAPIVersion,
+		"info":    map[string]any{"title": "Ray tasks of " + g.pkg.PkgPath, "version": "1.0.0"},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": s.components,
+		},
+	}
+	var spec bytes.Buffer
+	enc := json.NewEncoder(&spec)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		panic(err)
+	}
+	return strings.TrimSpace(spec.String())
+}
+
+// generateOpenAPISpec generates the constant of the OpenAPI document of the HTTP handler.
+func (g *Generator) generateOpenAPISpec(buf *bytes.Buffer) {
+	if len(g.httpTasks) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "\n// %sOpenAPISpec is the OpenAPI %s document (JSON) of the endpoints of New%sHTTPHandler.\n",
+		g.tasksStruct, openAPIVersion, g.tasksStruct)
+	fmt.Fprintf(buf, "const %sOpenAPISpec = `%s`\n", g.tasksStruct, strings.ReplaceAll(g.openAPISpec(), "`", "` + \"`\" + `"))
+}
@@ -0,0 +1,83 @@
+package goraygen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+// Move moves the shape.
+//
+//goray:timeout 5s
+func (Tasks) Move(s *Shape, by [2]float32, tags ...Label) (map[string]uint, error) { return nil, nil }
+
+type Label string
+
+type Base struct {
+	ID int64 ` + "`json:\"id,string\"`" + `
+}
+
+type Shape struct {
+	Base
+	*Shape // promoted once
+	Name   string ` + "`json:\"name,omitempty\"`" + `
+	Skip   int    ` + "`json:\"-\"`" + `
+	Kids   []Shape
+	At     time.Time
+	Data   []byte
+	hidden int
+}
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{HTTPGateway: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.prepareHTTPGateway()
+
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal([]byte(g.openAPISpec()), &spec))
+	require.Equal(t, "3.0.3", spec["openapi"])
+	expected := `{
+	  "post": {
+	    "description": "Move moves the shape.",
+	    "operationId": "Move",
+	    "requestBody": {"content": {"application/json": {"schema": {"type": "object", "properties": {
+	      "s": {"allOf": [{"$ref": "#/components/schemas/Shape"}], "nullable": true},
+	      "by": {"type": "array", "items": {"type": "number", "format": "float"}, "minItems": 2, "maxItems": 2},
+	      "tags": {"type": "array", "items": {"type": "string"}, "nullable": true}
+	    }}}}},
+	    "responses": {
+	      "200": {"description": "The results of the task, keyed by their indexes.", "content": {"application/json": {"schema": {
+	        "type": "object",
+	        "properties": {"r0": {"type": "object", "additionalProperties": {"type": "integer", "format": "int64", "minimum": 0}, "nullable": true}},
+	        "required": ["r0"]
+	      }}}},
+	      "400": {"description": "Invalid params.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GatewayError"}}}},
+	      "500": {"description": "The task failed.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GatewayError"}}}},
+	      "502": {"description": "The remote call failed.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GatewayError"}}}}
+	    }
+	  }
+	}`
+	actual, err := json.Marshal(spec["paths"].(map[string]any)["/tasks/Move"])
+	require.NoError(t, err)
+	require.JSONEq(t, expected, string(actual))
+
+	shape, err := json.Marshal(spec["components"].(map[string]any)["schemas"].(map[string]any)["Shape"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "object", "properties": {
+	  "id": {"type": "string"},
+	  "name": {"type": "string"},
+	  "Kids": {"type": "array", "items": {"$ref": "#/components/schemas/Shape"}, "nullable": true},
+	  "At": {"type": "string", "format": "date-time"},
+	  "Data": {"type": "string", "format": "byte", "nullable": true}
+	}}`, string(shape))
+}
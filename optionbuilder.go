@@ -0,0 +1,190 @@
+package goraygen
+
+/*
+	type DivideOptions struct {
+		CPUs    float64
+		Retries int
+		Name    string
+
+		SchedulingStrategy string
+		PlacementGroup     any
+		BundleIndex        int
+		NodeID             string
+		SoftAffinity       bool
+	}
+
+type DivideOption func(*DivideOptions)
+
+func DivideWithCPUs(cpus float64) DivideOption
+func DivideWithPlacementGroup(pg any, bundleIndex int) DivideOption
+func NewDivideOptions(opts ...DivideOption) (DivideOptions, error)
+func DivideRemote[...](a int64_0, b int64_1, opts ...DivideOption) (*Future2[int64, int64], error)
+
+The scheduling options (placement group, node affinity and scheduling strategy) are only generated for tasks,
+as actor methods run in the actor process.
+*/
+const optionBuilderTpl = `
+{{- $name := .FuncName}}{{if .ActorName}}{{$name = printf "%s_%s" .ActorName .FuncName}}{{end}}
+// {{$name}}Options holds the options of remote [{{$name}}] calls, set by the {{$name}}With* functions.
+type {{$name}}Options struct {
+	CPUs    float64
+	Retries int
+	Name    string
+	{{- if not .ActorName}}
+
+	SchedulingStrategy string // "DEFAULT" or "SPREAD"
+	PlacementGroup     any
+	BundleIndex        int // -1 for any bundle of PlacementGroup
+	NodeID             string
+	SoftAffinity       bool // whether the call may run on another node if NodeID is unavailable
+	{{- end}}
+
+	set _rayOptionSet
+}
+
+// {{$name}}Option configures remote [{{$name}}] calls.
+type {{$name}}Option func(*{{$name}}Options)
+
+// {{$name}}WithCPUs sets the number of CPUs to reserve for the call (ray option "num_cpus").
+func {{$name}}WithCPUs(cpus float64) {{$name}}Option {
+	return func(o *{{$name}}Options) {
+		o.CPUs = cpus
+		o.set.set("num_cpus", cpus)
+	}
+}
+
+// {{$name}}WithRetries sets the max retries of the call on failure (ray option "{{if .ActorName}}max_task_retries{{else}}max_retries{{end}}").
+func {{$name}}WithRetries(retries int) {{$name}}Option {
+	return func(o *{{$name}}Options) {
+		o.Retries = retries
+		o.set.set("{{if .ActorName}}max_task_retries{{else}}max_retries{{end}}", retries)
+	}
+}
+
+// {{$name}}WithName sets the name of the call shown in the ray dashboard (ray option "name").
+func {{$name}}WithName(name string) {{$name}}Option {
+	return func(o *{{$name}}Options) {
+		o.Name = name
+		o.set.set("name", name)
+	}
+}
+
+{{- if not .ActorName}}
+
+// {{$name}}WithSchedulingStrategy sets the scheduling strategy of the call, "DEFAULT" or "SPREAD" (ray option "scheduling_strategy").
+// It's mutually exclusive with {{$name}}WithPlacementGroup and {{$name}}WithNodeAffinity.
+func {{$name}}WithSchedulingStrategy(strategy string) {{$name}}Option {
+	return func(o *{{$name}}Options) {
+		if strategy != "DEFAULT" && strategy != "SPREAD" {
+			o.set.fail(fmt.Errorf("invalid scheduling strategy %q, expect DEFAULT or SPREAD", strategy))
+			return
+		}
+		if o.set.schedule("scheduling strategy") {
+			o.SchedulingStrategy = strategy
+			o.set.set("scheduling_strategy", strategy)
+		}
+	}
+}
+
+// {{$name}}WithPlacementGroup schedules the call in the bundle of the placement group, -1 for any bundle
+// (ray options "placement_group" and "placement_group_bundle_index").
+// It's mutually exclusive with {{$name}}WithSchedulingStrategy and {{$name}}WithNodeAffinity.
+func {{$name}}WithPlacementGroup(pg any, bundleIndex int) {{$name}}Option {
+	return func(o *{{$name}}Options) {
+		if pg == nil || bundleIndex < -1 {
+			o.set.fail(fmt.Errorf("invalid placement group %v with bundle index %d", pg, bundleIndex))
+			return
+		}
+		if o.set.schedule("placement group") {
+			o.PlacementGroup, o.BundleIndex = pg, bundleIndex
+			o.set.set("placement_group", pg)
+			o.set.set("placement_group_bundle_index", bundleIndex)
+		}
+	}
+}
+
+// {{$name}}WithNodeAffinity schedules the call on the node, or on another node if soft and the node is unavailable
+// (ray option "scheduling_strategy", as a map with the "node_id" and "soft" keys).
+// It's mutually exclusive with {{$name}}WithSchedulingStrategy and {{$name}}WithPlacementGroup.
+func {{$name}}WithNodeAffinity(nodeID string, soft bool) {{$name}}Option {
+	return func(o *{{$name}}Options) {
+		if nodeID == "" {
+			o.set.fail(fmt.Errorf("empty node id of node affinity"))
+			return
+		}
+		if o.set.schedule("node affinity") {
+			o.NodeID, o.SoftAffinity = nodeID, soft
+			o.set.set("scheduling_strategy", map[string]any{"node_id": nodeID, "soft": soft})
+		}
+	}
+}
+{{- end}}
+
+// New{{$name}}Options applies the options, returning an error if they are invalid, e.g. mutually exclusive.
+func New{{$name}}Options(opts ...{{$name}}Option) ({{$name}}Options, error) {
+	var o {{$name}}Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o, o.set.err
+}
+
+// {{$name}}Remote calls [{{$name}}] remotely with the typed options, the call isn't made if the options are invalid,
+// see New{{$name}}Options.
+func {{$name}}Remote{{.TypeConstraints}}({{if .ActorName}}_actor *Actor{{.ActorName}}, {{end}}{{.SliceParamList}}{{if .SliceParamList}}, {{end}}opts ...{{$name}}Option) (*Future{{.ResLen}}{{.ResTypes}}, error) {
+	o, err := New{{$name}}Options(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("{{$name}}Remote: %w", err)
+	}
+	return {{$name}}({{if .ActorName}}_actor{{if .CallArgs}}, {{end}}{{end}}{{.CallArgs}}).Remote(_toRayOptions(ray.Option, o.set)...), nil
+}
+`
+
+// optionBuilderHelpers is shared by the option builders of all methods, generated once per file.
+const optionBuilderHelpers = `
+// _rayOptionSet records the ray options set by the typed option builders, in order.
+type _rayOptionSet struct {
+	names  []string
+	values []any
+
+	scheduling string // the kind of the scheduling option set, they are mutually exclusive
+	err        error  // the first invalid option
+}
+
+func (s *_rayOptionSet) set(name string, value any) {
+	for i, n := range s.names {
+		if n == name {
+			s.values[i] = value
+			return
+		}
+	}
+	s.names = append(s.names, name)
+	s.values = append(s.values, value)
+}
+
+// schedule reports whether the scheduling option of kind (e.g. "placement group") can be set,
+// recording an error if a scheduling option of another kind is set.
+func (s *_rayOptionSet) schedule(kind string) bool {
+	if s.scheduling != "" && s.scheduling != kind {
+		s.fail(fmt.Errorf("%s conflicts with %s", kind, s.scheduling))
+		return false
+	}
+	s.scheduling = kind
+	return true
+}
+
+func (s *_rayOptionSet) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// _toRayOptions converts the option set into ray options with newOption, i.e. ray.Option.
+func _toRayOptions[T any](newOption func(string, any) T, s _rayOptionSet) []T {
+	opts := make([]T, len(s.names))
+	for i, name := range s.names {
+		opts[i] = newOption(name, s.values[i])
+	}
+	return opts
+}
+`
@@ -0,0 +1,46 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOptionBuilders(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Echo(prefix string, args ...int) []int { return args }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Get() int { return c.n }
+`}, Options{OptionBuilders: true})
+
+	require.Contains(t, code, "type EchoOptions struct {")
+	require.Contains(t, code, "func EchoWithCPUs(cpus float64) EchoOption {")
+	require.Contains(t, code, "func EchoRemote[string_0 _T0, int_1 _T1](prefix string_0, args []int_1, opts ...EchoOption) (*Future1[[]int], error) {")
+	require.Contains(t, code, "return nil, fmt.Errorf(\"EchoRemote: %w\", err)")
+	require.Contains(t, code, "return Echo(prefix, args...).Remote(_toRayOptions(ray.Option, o.set)...), nil")
+
+	require.Contains(t, code, `o.set.set("max_task_retries", retries)`)
+	require.Contains(t, code, "func Counter_GetRemote(_actor *ActorCounter, opts ...Counter_GetOption) (*Future1[int], error) {")
+	require.Contains(t, code, "return Counter_Get(_actor).Remote(_toRayOptions(ray.Option, o.set)...), nil")
+	require.Contains(t, code, "func _toRayOptions[T any](newOption func(string, any) T, s _rayOptionSet) []T {")
+
+	require.Contains(t, code, "func EchoWithPlacementGroup(pg any, bundleIndex int) EchoOption {")
+	require.Contains(t, code, "func EchoWithNodeAffinity(nodeID string, soft bool) EchoOption {")
+	require.Contains(t, code, "func EchoWithSchedulingStrategy(strategy string) EchoOption {")
+	require.Contains(t, code, `if o.set.schedule("placement group") {`)
+	require.Contains(t, code, "func NewEchoOptions(opts ...EchoOption) (EchoOptions, error) {")
+	require.Contains(t, code, "o, err := NewEchoOptions(opts...)")
+	require.NotContains(t, code, "Counter_GetWithPlacementGroup") // actor methods run in the actor process
+	require.Contains(t, code, "func NewCounter_GetOptions(opts ...Counter_GetOption) (Counter_GetOptions, error) {")
+}
@@ -0,0 +1,441 @@
+package goraygen
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"go/token"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Options holds the user settings of a generation run.
+type Options struct {
+	// BuildTags are passed to the go build system (as -tags) when loading packages,
+	// so files guarded by build constraints are taken into account.
+	BuildTags []string
+	// Env holds extra "KEY=VALUE" entries appended to the process environment
+	// when loading packages, e.g. "GOOS=linux" or "GOFLAGS=-mod=vendor".
+	Env []string
+
+	// IncludeTests makes _test.go files part of the scanned packages,
+	// so test-only tasks/actors get wrappers too (generated into a _test.go file).
+	IncludeTests bool
+
+	// TasksMatcher and ActorsMatcher select the task/actor register structs,
+	// default to the `// raytasks` and `// rayactors` comment markers.
+	TasksMatcher  StructMatcher
+	ActorsMatcher StructMatcher
+	// ActorMatcher selects the actor structs to generate typed handles for, default to the `// rayactor` comment marker.
+	ActorMatcher StructMatcher
+
+	// ReceiverPolicy controls which methods appear in generated code by receiver kind, default is ReceiverBoth.
+	ReceiverPolicy ReceiverPolicy
+	// ExcludeMethods are the methods not generated, by name (e.g. "Close") or receiver type and name (e.g. "Tasks.Close").
+	ExcludeMethods []string
+
+	// ResultRefs enables generating a typed `FooResultRef` per method, see resultRefTpl.
+	ResultRefs bool
+	// ContextVariants enables generating a context-aware `FooContext` caller per method, see contextVariantTpl.
+	ContextVariants bool
+	// Streaming enables generating a `FooStream` iterator per method returning a channel, streaming its values
+	// from the worker, see streamTpl.
+	Streaming bool
+	// WithOtel enables generating the `FooInvoke` caller per method, tracing the calls with OpenTelemetry,
+	// see invokeTpl.
+	WithOtel bool
+	// WithMetrics enables generating the `FooInvoke` caller per method, recording Prometheus metrics of the calls
+	// under MetricsNamespace ("goray" by default).
+	WithMetrics      bool
+	MetricsNamespace string
+	// WithSlog enables generating the `FooInvoke` caller per method, logging the calls with the slog logger
+	// set by WithInvokeConfig.
+	WithSlog bool
+	// Pools enables generating the concurrency-capped `FooPool` caller per task, see poolTpl.
+	Pools bool
+	// TaskVersion, if positive, registers the tasks with names suffixed by the version (e.g. "Divide_v3"),
+	// and the previous TaskVersionAliases versions as aliases, so drivers and workers of consecutive deployments interoperate.
+	TaskVersion        int
+	TaskVersionAliases int
+	// Checkpoints enables generating the Snapshot and Restore methods of the actor structs, serializing their
+	// state fields, with the remote callers of the methods, see checkpointTpl.
+	Checkpoints bool
+	// Codec is the serialization of the types of the package in the task and actor signatures,
+	// empty means CodecMsgpack, the native serialization of go-ray. See codecTpl and the //goray:codec directive.
+	Codec Codec
+	// ResolveAliases renders the type aliases of the signatures as the types they alias, e.g. time.Duration
+	// for `type Timeout = time.Duration`, instead of the aliases, e.g. when an alias isn't importable by the output package.
+	ResolveAliases bool
+	// ImportAliases are the preferred names of the imports of the generated files by import path,
+	// e.g. {"github.com/ray4go/go-ray/ray": "goray"}, see ImportStore.SetAliases.
+	ImportAliases map[string]string
+	// TypeMappings override how the types are rendered, before the ones registered by RegisterTypeMapping.
+	// See TypeMapping.
+	TypeMappings []TypeMapping
+	// Lang is the language of the generated wrappers, empty means LangGo.
+	// With LangPython, the Python stubs are generated instead of the Go wrappers, see pythonStubsTpl.
+	Lang Lang
+	// GobRegister enables generating the gob registration file, registering the concrete types of the task and actor
+	// signatures with encoding/gob, see gobRegistrationTpl.
+	GobRegister bool
+	// Proto enables generating the protobuf schema of the task requests and responses (ray_tasks.proto),
+	// with the `FooProto` task variants taking and returning the serialized messages, see protoTaskTpl.
+	Proto bool
+	// GRPCGateway enables generating the driver-side gRPC service of the tasks with the protobuf variant,
+	// serving the RPCs by calling the tasks, see grpcGatewayTpl. It requires Proto.
+	GRPCGateway bool
+	// HTTPGateway enables generating the driver-side http.Handler serving the tasks with JSON params and results,
+	// see httpGatewayTpl.
+	HTTPGateway bool
+	// ActorPing enables generating the trivial Ping method of the actor structs, with the `Ping(ctx) error`
+	// liveness probe of the actor handles, see pingProbeTpl.
+	ActorPing bool
+	// TimeoutVariants enables generating the `FooWithTimeout` caller per method, see timeoutVariantTpl.
+	// Without it, only the methods with the //goray:timeout directive get the caller.
+	TimeoutVariants bool
+	// Client enables generating the `MyTasksClient` interface covering all tasks with the remote implementation,
+	// see clientTpl.
+	Client bool
+	// Mocks enables generating the `FooCaller` interface per task, with the remote implementation and the mock,
+	// see callerTpl.
+	Mocks bool
+	// LocalVariants enables generating the in-process `FooLocal` variant and the `FooCall` mode switching caller per task,
+	// see localVariantTpl.
+	LocalVariants bool
+	// Chaining enables passing the typed result references of tasks as arguments of other remote calls, see chainHelpers.
+	// It requires ResultRefs.
+	Chaining bool
+	// MapHelpers enables generating the `FooMap` and `FooMapAll` batch helpers per task, see mapHelperTpl.
+	// It requires ResultRefs and OptionBuilders.
+	MapHelpers bool
+	// GatherHelpers enables generating the typed `WaitAllFoo` and `WaitAnyFoo` helpers per method, see gatherTpl.
+	// It requires ResultRefs.
+	GatherHelpers bool
+	// ResultStructs enables generating the `FooOutput` struct of the methods with multiple non-error results,
+	// and `GetFooOutput` waiting for the results of a call into it, see resultStructTpl.
+	ResultStructs bool
+	// OptionBuilders enables generating typed `FooOptions` and functional options per method, see optionBuilderTpl.
+	OptionBuilders bool
+	// Manifest enables generating task name constants and the TaskManifest registry, see generateManifest.
+	Manifest bool
+	// TaskGraph enables generating the dependency graph of the tasks and actor methods as the TasksGraph variable
+	// and the ray_tasks.dot file of the scanned package, see TaskGraph.
+	TaskGraph bool
+	// DeployManifest, if not empty, is the format of the deployment manifest written into the scanned package,
+	// see DeployManifest.
+	DeployManifest ManifestFormat
+	// SignatureChecks enables generating signature assertions and hashes, see generateSignatureChecks.
+	SignatureChecks bool
+	// SplitWorker enables generating the worker-side registration file into the scanned package,
+	// so the wrappers file (possibly in another package, see OutputDir) is driver-side only.
+	SplitWorker bool
+	// GracefulShutdown enables registering the tasks of the worker registration file through a wrapper tracking
+	// the calls in flight, with the Shutdown handler draining them, see drainHelpersTpl. SplitWorker is required.
+	GracefulShutdown bool
+	// GenWorkerMain enables generating a worker main package (cmd/worker/main.go in the module) registering
+	// the tasks and actors of the worker registration file, SplitWorker is required.
+	GenWorkerMain bool
+	// GenTaskCLI enables generating a debug CLI (cmd/taskcli/main.go in the module) calling a task per run,
+	// with the params from flags or JSON, see taskCLITpl.
+	GenTaskCLI bool
+
+	// OutputDir is the directory of the package the generated file is written into,
+	// default is the directory of the scanned package.
+	OutputDir string
+	// OutputPkg, if not empty, is the directory of the package the generated file is written into relative to
+	// the scanned package, e.g. "./raygen", so every package of "./..." gets its own. Exclusive with OutputDir.
+	OutputPkg string
+	// Backends, if not empty, are the names of the backends to run instead of the ones enabled by the options, see Backend.
+	Backends []string
+	// Plugins are the plugin generators run after the backends, as "path[:parameter]", see PluginRequest.
+	Plugins []string
+	// TemplatesDir, if not empty, is the directory of the templates overriding the built-in ones, see templateArtifacts.
+	TemplatesDir string
+	// OutputFileName is the name of the generated file, default is generatedFileName.
+	OutputFileName string
+	// Output, if not empty, is the template of the path of the generated file instead of OutputFileName,
+	// e.g. "{{.Package}}_ray_gen.go" or "{{.Struct}}_gen.go" for a file per target struct, see outputData.
+	Output string
+	// outputClaims are the generated files of the run, shared by the generators to detect the collisions, see runMatrix.
+	outputClaims *outputClaims
+	// BuildConstraint, if not empty, is emitted as the `//go:build` line of the generated Go files of the package
+	// (the wrappers, worker and gob registration files), e.g. "goray" to build them with -tags goray only.
+	BuildConstraint string
+
+	// DiagFormat is the output format of the diagnostics, default is text, the log lines.
+	DiagFormat DiagFormat
+	// diagOutputPath is the file of the JSON and SARIF diagnostics, and diagOutput the opened file, see startDiagnostics.
+	diagOutputPath string
+	diagOutput     io.Writer
+	// MaxErrors caps the errors and warnings written by a command, the others are only counted in its summary.
+	// 0 writes them all.
+	MaxErrors int
+	// Strict reports the warnings as errors, failing the command, e.g. in CI.
+	Strict bool
+	// tally counts the diagnostics of a command across its packages and build tag sets, see startDiagnostics.
+	tally *diagnosticTally
+	// DryRun renders the outputs without writing them, reporting on the log the files that would be written,
+	// whether they change, and the backend generating them.
+	DryRun bool
+	// Stdout, if not nil, receives the content of the outputs instead of writing them, e.g. os.Stdout.
+	Stdout io.Writer
+	// Verbosity is the detail of the progress log: 0 logs the found structs and the written files,
+	// 1 (-v) also the discovered methods and the time of the phases, 2 (-vv) also every method and phase entered.
+	Verbosity int
+	// LogFormat is the format of the progress log and the text diagnostics, default is text.
+	LogFormat LogFormat
+	// Logger, if not nil, receives the progress log and the text diagnostics instead of the standard log output,
+	// e.g. the logger of a build tool. Verbosity and LogFormat don't apply to it.
+	Logger *slog.Logger
+
+	// Hooks are the callbacks of the library API, see OnTargetDiscovered, OnFileRendered and OnWrite.
+	Hooks Hooks
+	// Config is the project config file, its per-package settings override the options of the packages, see Config.
+	Config *Config
+	// headerArgs are the flags of the generation recorded in the header of the generated files, see generatedHeader.
+	headerArgs []string
+	// inMemory renders the outputs through the hooks without writing them nor creating their dirs, see runCheckCommand.
+	inMemory bool
+}
+
+func (o Options) outputFileName() string {
+	if o.OutputFileName != "" {
+		return o.OutputFileName
+	}
+	return generatedFileName
+}
+
+// outputTemplate returns the template of the path of the generated file, Output or the OutputFileName.
+func (o Options) outputTemplate() string {
+	if o.Output != "" {
+		return o.Output
+	}
+	return o.outputFileName()
+}
+
+// ReceiverPolicy selects methods by receiver kind (pointer or value receiver).
+type ReceiverPolicy string
+
+const (
+	ReceiverBoth        ReceiverPolicy = "both"
+	ReceiverPointerOnly ReceiverPolicy = "pointer-only"
+	ReceiverValueOnly   ReceiverPolicy = "value-only"
+)
+
+// ParseReceiverPolicy parses the -receiver-policy flag value, empty means ReceiverBoth.
+func ParseReceiverPolicy(s string) (ReceiverPolicy, error) {
+	switch p := ReceiverPolicy(s); p {
+	case "":
+		return ReceiverBoth, nil
+	case ReceiverBoth, ReceiverPointerOnly, ReceiverValueOnly:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid receiver policy %q, expect %s, %s or %s", s, ReceiverBoth, ReceiverPointerOnly, ReceiverValueOnly)
+}
+
+// Allows reports whether the method is selected by the policy.
+func (p ReceiverPolicy) Allows(m Method) bool {
+	switch p {
+	case ReceiverPointerOnly:
+		return m.HasPointerReceiver()
+	case ReceiverValueOnly:
+		return !m.HasPointerReceiver()
+	}
+	return true
+}
+
+func (o Options) tasksMatcher() StructMatcher {
+	if o.TasksMatcher != nil {
+		return o.TasksMatcher
+	}
+	return CommentMatcher(raytasksComment)
+}
+
+func (o Options) actorsMatcher() StructMatcher {
+	if o.ActorsMatcher != nil {
+		return o.ActorsMatcher
+	}
+	return CommentMatcher(rayactorsComment)
+}
+
+func (o Options) actorMatcher() StructMatcher {
+	if o.ActorMatcher != nil {
+		return o.ActorMatcher
+	}
+	return CommentMatcher(rayactorComment)
+}
+
+// applyTargetFlags sets the struct matchers from "kind=value" flag values, kind is "tasks", "actors" or "actor".
+// A marker value is parsed by analysis.ParseMarker; an explicit struct name takes precedence over markers.
+func (o *Options) applyTargetFlags(markers, structs []string) error {
+	set := func(kv string, parse func(string) (StructMatcher, error)) error {
+		kind, value, ok := strings.Cut(kv, "=")
+		if !ok || value == "" {
+			return fmt.Errorf("invalid value %q, expect tasks=..., actors=... or actor=...", kv)
+		}
+		matcher, err := parse(value)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case "tasks":
+			o.TasksMatcher = matcher
+		case "actors":
+			o.ActorsMatcher = matcher
+		case "actor":
+			o.ActorMatcher = matcher
+		default:
+			return fmt.Errorf("unknown struct kind %q in %q, expect tasks, actors or actor", kind, kv)
+		}
+		return nil
+	}
+	for _, kv := range markers {
+		if err := set(kv, analysis.ParseMarker); err != nil {
+			return err
+		}
+	}
+	for _, kv := range structs {
+		if err := set(kv, func(name string) (StructMatcher, error) { return NameMatcher(name), nil }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packagesConfig returns the config used to load packages in dir.
+func (o Options) packagesConfig(dir string) *packages.Config {
+	cfg := &packages.Config{
+		Dir:   dir,
+		Mode:  analysis.LoadMode,
+		Tests: o.IncludeTests,
+	}
+	if len(o.BuildTags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(o.BuildTags, ",")}
+	}
+	if len(o.Env) > 0 {
+		cfg.Env = append(os.Environ(), o.Env...)
+	}
+	return cfg
+}
+
+// ForTagSet returns a copy of the options that loads packages with the extra build tags
+// and writes to a dedicated output file guarded by the matching build constraint. The constraint excludes
+// the other combinations of the matrix, so that a build matching several of them doesn't compile several files.
+func (o Options) ForTagSet(tags []string, others ...[]string) Options {
+	o.BuildTags = append(append([]string{}, o.BuildTags...), tags...)
+	o.OutputFileName = strings.TrimSuffix(o.outputFileName(), ".go") + "_" + strings.Join(tags, "_") + ".go"
+	if o.Output != "" {
+		o.Output = strings.TrimSuffix(o.Output, ".go") + "_" + strings.Join(tags, "_") + ".go"
+	}
+	expr := strings.Join(tags, " && ")
+	for _, other := range others {
+		if len(other) == 1 {
+			expr += " && !" + other[0]
+		} else {
+			expr += " && !(" + strings.Join(other, " && ") + ")"
+		}
+	}
+	o.BuildConstraint = andBuildConstraints(o.BuildConstraint, expr)
+	return o
+}
+
+// ParseImportAlias parses an import alias like "github.com/ray4go/go-ray/ray=goray".
+func ParseImportAlias(s string) (path, alias string, err error) {
+	path, alias, ok := strings.Cut(s, "=")
+	path, alias = strings.TrimSpace(path), strings.TrimSpace(alias)
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("invalid import alias %q, expect path=alias", s)
+	}
+	if !token.IsIdentifier(alias) || alias == "_" {
+		return "", "", fmt.Errorf("invalid import alias %q, %q is not a valid package name", s, alias)
+	}
+	return path, alias, nil
+}
+
+// newImportStore returns an import store with the preferred aliases of the options, reserving the locals of the templates.
+func (o Options) newImportStore() *analysis.ImportStore {
+	is := analysis.NewImportStore()
+	is.SetAliases(o.ImportAliases)
+	is.ResolveAliases(o.ResolveAliases)
+	is.Reserve(templateLocals...)
+	return is
+}
+
+// ParseBuildConstraint parses the expression of a `//go:build` line, e.g. "goray && !race", and returns it formatted.
+func ParseBuildConstraint(s string) (string, error) {
+	expr, err := constraint.Parse("//go:build " + s)
+	if err != nil {
+		return "", fmt.Errorf("invalid build constraint %q: %w", s, err)
+	}
+	return expr.String(), nil
+}
+
+// andBuildConstraints returns the conjunction of the valid build constraints, one of them if the other is empty.
+func andBuildConstraints(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	x, _ := constraint.Parse("//go:build " + a)
+	y, _ := constraint.Parse("//go:build " + b)
+	return (&constraint.AndExpr{X: x, Y: y}).String()
+}
+
+// parseTagMatrix parses build tag combinations in the form "linux,cgo;darwin".
+// Combinations are separated by ';' and tags in a combination by ','.
+func parseTagMatrix(s string) ([][]string, error) {
+	var matrix [][]string
+	for _, set := range strings.Split(s, ";") {
+		if strings.TrimSpace(set) == "" {
+			continue
+		}
+		var tags []string
+		for _, tag := range strings.Split(set, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				return nil, fmt.Errorf("empty build tag in combination %q", set)
+			}
+			tags = append(tags, tag)
+		}
+		// the file of a combination excludes the other ones, one including another would never build
+		for _, other := range matrix {
+			if includesTags(tags, other) || includesTags(other, tags) {
+				return nil, fmt.Errorf("build tag combinations %q and %q overlap, one includes the other", strings.Join(other, ","), strings.Join(tags, ","))
+			}
+		}
+		matrix = append(matrix, tags)
+	}
+	return matrix, nil
+}
+
+// includesTags reports whether tags include every tag of sub.
+func includesTags(tags, sub []string) bool {
+	return !gslice.Any(sub, func(tag string) bool { return !slices.Contains(tags, tag) })
+}
+
+// stringsFlag is a flag.Value collecting repeated flag values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,113 @@
+package goraygen
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTagMatrix(t *testing.T) {
+	matrix, err := parseTagMatrix("linux, cgo;darwin;")
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"linux", "cgo"}, {"darwin"}}, matrix)
+
+	_, err = parseTagMatrix("linux,,cgo")
+	require.Error(t, err)
+	_, err = parseTagMatrix("linux;linux,cgo")
+	require.ErrorContains(t, err, `build tag combinations "linux" and "linux,cgo" overlap`)
+}
+
+func TestOptionsForTagSet(t *testing.T) {
+	base := Options{BuildTags: []string{"integration"}}
+	opts := base.ForTagSet([]string{"linux", "cgo"})
+	require.Equal(t, []string{"integration", "linux", "cgo"}, opts.BuildTags)
+	require.Equal(t, "ray_workload_wrappers_linux_cgo.go", opts.outputFileName())
+	require.Equal(t, "linux && cgo", opts.BuildConstraint)
+	require.Equal(t, []string{"integration"}, base.BuildTags)
+
+	cfg := opts.packagesConfig("/tmp")
+	require.Equal(t, []string{"-tags=integration,linux,cgo"}, cfg.BuildFlags)
+
+	// the constraint of -build-tags applies to every combination
+	base.BuildConstraint = "goray || ray"
+	require.Equal(t, "(goray || ray) && linux && cgo", base.ForTagSet([]string{"linux", "cgo"}).BuildConstraint)
+
+	// the other combinations of the matrix are excluded
+	require.Equal(t, "linux && !cgo && !(darwin && arm64)",
+		Options{}.ForTagSet([]string{"linux"}, []string{"cgo"}, []string{"darwin", "arm64"}).BuildConstraint)
+}
+
+func TestTagMatrixBuild(t *testing.T) {
+	// a build matching several combinations compiles one of their files at most
+	matrix, err := parseTagMatrix("gpu;fast")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.24\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.go"), []byte("package app\n"), 0o644))
+	for i, tags := range matrix {
+		opts := Options{}.ForTagSet(tags, slices.Delete(slices.Clone(matrix), i, i+1)...)
+		src := fmt.Sprintf("//go:build %s\n\npackage app\n\nfunc Divide() {}\n", opts.BuildConstraint)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, opts.outputFileName()), []byte(src), 0o644))
+	}
+	for _, tags := range []string{"", "gpu", "fast", "gpu,fast"} {
+		out, err := exec.Command("go", "build", "-C", dir, "-tags="+tags, ".").CombinedOutput()
+		require.NoError(t, err, "tags %q: %s", tags, out)
+	}
+}
+
+func TestParseBuildConstraint(t *testing.T) {
+	expr, err := ParseBuildConstraint("goray&&!race")
+	require.NoError(t, err)
+	require.Equal(t, "goray && !race", expr)
+	_, err = ParseBuildConstraint("goray &&")
+	require.Error(t, err)
+}
+
+func TestParseReceiverPolicy(t *testing.T) {
+	policy, err := ParseReceiverPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, ReceiverBoth, policy)
+
+	policy, err = ParseReceiverPolicy("pointer-only")
+	require.NoError(t, err)
+	require.Equal(t, ReceiverPointerOnly, policy)
+
+	_, err = ParseReceiverPolicy("pointer")
+	require.Error(t, err)
+}
+
+func TestReceiverPolicyAllows(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type MyTasks struct{}
+
+func (t *MyTasks) Foo() {}
+
+func (t MyTasks) Bar() {}
+`}, "example.com/mypkg")
+	methods := analysis.FindMethods(pkg, "MyTasks", analysis.NewImportStore())
+	allowed := func(policy ReceiverPolicy) []string {
+		return gslice.Map(gslice.Filter(methods, policy.Allows), func(m Method) string { return m.Name })
+	}
+	require.ElementsMatch(t, []string{"Foo", "Bar"}, allowed(ReceiverBoth))
+	require.Equal(t, []string{"Foo"}, allowed(ReceiverPointerOnly))
+	require.Equal(t, []string{"Bar"}, allowed(ReceiverValueOnly))
+}
+
+func TestApplyTargetFlags(t *testing.T) {
+	var opts Options
+	require.NoError(t, opts.applyTargetFlags([]string{"tasks=// mytasks"}, []string{"actors=MyActors"}))
+	require.Equal(t, CommentMatcher("// mytasks"), opts.tasksMatcher())
+	require.Equal(t, NameMatcher("MyActors"), opts.actorsMatcher())
+
+	require.Error(t, opts.applyTargetFlags([]string{"workers=// x"}, nil))
+	require.Error(t, opts.applyTargetFlags(nil, []string{"tasks"}))
+}
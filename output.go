@@ -0,0 +1,130 @@
+package goraygen
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+/*
+The path of the wrappers file is a template (see Options.Output and the -output flag) of outputData, e.g.:
+
+	-output '{{.Package}}_ray_gen.go'        # mypkg_ray_gen.go in the output dir
+	-output /src/gen/{{.Package}}/ray_gen.go # an absolute path, its dir is the package generated into
+	-output '{{.Struct}}_gen.go'             # a file per target struct: MyTasks_gen.go, MyActors_gen.go...
+
+A relative path is relative to the output dir. {{.Struct}} is only allowed in the file name, the files of the structs
+being in the same package; the code shared by the structs goes to the first of them.
+*/
+
+// outputData is the data of the template of the wrappers file path.
+type outputData struct {
+	Package string // the name of the scanned package
+	Struct  string // the name of the target struct: the tasks or actors struct, or a `// rayactor` struct
+}
+
+// parseOutput parses and checks the template of the wrappers file path: a .go file, with {{.Struct}} in the file name only.
+func parseOutput(s string) (*template.Template, error) {
+	tpl, err := template.New("output").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output %q: %w", s, err)
+	}
+	a, err := renderOutput(tpl, outputData{Package: "mypkg", Struct: "StructA"})
+	if err != nil {
+		return nil, fmt.Errorf("invalid output %q: %w", s, err)
+	}
+	b, err := renderOutput(tpl, outputData{Package: "mypkg", Struct: "StructB"})
+	if err != nil {
+		return nil, fmt.Errorf("invalid output %q: %w", s, err)
+	}
+	switch {
+	case !strings.HasSuffix(a, ".go") || filepath.Base(a) == ".go":
+		return nil, fmt.Errorf("invalid output %q, expect a .go file", s)
+	case filepath.Dir(a) != filepath.Dir(b):
+		return nil, fmt.Errorf("invalid output %q, {{.Struct}} is only allowed in the file name", s)
+	}
+	return tpl, nil
+}
+
+func renderOutput(tpl *template.Template, data outputData) (string, error) {
+	var b strings.Builder
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(b.String()), nil
+}
+
+// resolveOutput parses Options.Output into g.output, and returns the directory of the wrappers file:
+// outputDir, or the dir of the output path if it has one.
+func (g *Generator) resolveOutput(outputDir string) (string, error) {
+	tpl, err := parseOutput(g.opts.outputTemplate())
+	if err != nil {
+		return "", err
+	}
+	g.output = tpl
+	dir := filepath.Dir(g.outputPath(""))
+	if filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	return filepath.Join(outputDir, dir), nil
+}
+
+// outputPath renders the path of the wrappers file of the struct, relative to the output dir unless absolute.
+func (g *Generator) outputPath(structName string) string {
+	path, _ := renderOutput(g.output, outputData{Package: g.pkg.Name, Struct: structName}) // checked by parseOutput
+	return path
+}
+
+// outputName returns the name of the wrappers file of the struct, a _test.go file if the structs are declared in test files.
+func (g *Generator) outputName(structName string) string {
+	return g.outputFileName(filepath.Base(g.outputPath(structName)))
+}
+
+// checkOutputPkg checks the directory of -output-pkg: relative to the scanned package, and exclusive with -output-dir.
+func checkOutputPkg(dir, outputDir string) error {
+	switch {
+	case filepath.IsAbs(dir) || !filepath.IsLocal(filepath.Clean(dir)) || filepath.Clean(dir) == ".":
+		return fmt.Errorf("invalid output package %q, expect a subdirectory of the package like ./raygen", dir)
+	case outputDir != "":
+		return fmt.Errorf("-output-pkg and -output-dir are exclusive")
+	}
+	return nil
+}
+
+// outputStructs returns the target structs, in the order of the wrappers: the tasks struct, the actors struct
+// and the `// rayactor` structs.
+func (g *Generator) outputStructs() []string {
+	var structs []string
+	for _, s := range []string{g.tasksStruct, g.actorsStruct} {
+		if s != "" {
+			structs = append(structs, s)
+		}
+	}
+	for _, h := range g.actorHandles {
+		structs = append(structs, h.StructName)
+	}
+	return structs
+}
+
+// outputClaims are the wrappers files written in a run by the packages and their structs, to detect the collisions
+// of the output paths, e.g. an absolute -output without {{.Package}} for "./...".
+type outputClaims struct {
+	mu     sync.Mutex
+	owners map[string]string // path -> package or package.Struct
+}
+
+// claim claims the file for the owner, failing if another owner already did.
+func (c *outputClaims) claim(path, owner string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.owners == nil {
+		c.owners = make(map[string]string)
+	}
+	if other, ok := c.owners[path]; ok && other != owner {
+		return fmt.Errorf("output %s of %s collides with the one of %s, use {{.Package}} or {{.Struct}} in -output to tell them apart", path, owner, other)
+	}
+	c.owners[path] = owner
+	return nil
+}
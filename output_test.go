@@ -0,0 +1,109 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutput(t *testing.T) {
+	for _, valid := range []string{"ray_wrappers.go", "{{.Package}}_ray_gen.go", "/src/gen/{{.Package}}/ray_gen.go", "{{.Struct}}_gen.go"} {
+		_, err := parseOutput(valid)
+		require.NoError(t, err, valid)
+	}
+	for _, invalid := range []string{"{{.Package", "{{.Module}}.go", "ray_wrappers.txt", "{{.Struct}}/ray_gen.go"} {
+		_, err := parseOutput(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestSplitOutput(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type MyTasks struct{}
+
+func (MyTasks) Divide(a, b int64) int64 { return a / b }
+
+// rayactors
+type MyActors struct{}
+
+func (MyActors) Counter(n int64) *Counter { return &Counter{n} }
+
+type Counter struct{ n int64 }
+
+func (c *Counter) Incr(n int64) int64 { c.n += n; return c.n }
+
+// rayactor
+type Metrics struct{ hits int64 }
+
+func (m *Metrics) Hits() int64 { return m.hits }
+`}
+	generate := func(output string) (map[string]string, error) {
+		written := make(map[string]string)
+		opts := Options{Output: output, inMemory: true}
+		opts.Hooks.Write = append(opts.Hooks.Write, func(path string, content []byte) error {
+			written[filepath.Base(path)] = string(content)
+			return nil
+		})
+		pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+		g := NewGenerator(opts)
+		g.pkg = pkg
+		g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+		outputDir, err := g.resolveOutput(t.TempDir())
+		require.NoError(t, err)
+		g.collectWorkloads()
+		g.collectActorMethods()
+		g.collectActorHandles()
+		return written, g.writeWrappers(outputDir)
+	}
+
+	written, err := generate("{{.Package}}_ray_gen.go")
+	require.NoError(t, err)
+	require.Len(t, written, 1)
+	require.Contains(t, written["mypkg_ray_gen.go"], "func Divide[")
+	require.Contains(t, written["mypkg_ray_gen.go"], "func NewCounter[")
+
+	written, err = generate("{{.Struct}}_gen.go")
+	require.NoError(t, err)
+	require.Len(t, written, 3)
+	tasks, actors := written["MyTasks_gen.go"], written["MyActors_gen.go"]
+	require.Contains(t, written["Metrics_gen.go"], "type MetricsActorHandle struct")
+	require.Contains(t, tasks, "func Divide[")
+	require.NotContains(t, tasks, "func NewCounter[")
+	require.Contains(t, actors, "func NewCounter[")
+	require.Contains(t, actors, "package mypkg")
+	// the type constraints shared by the structs go to the first file
+	require.Contains(t, tasks, "type _T0 interface")
+	require.NotContains(t, actors, "type _T0 interface")
+
+	_, err = generate("{{len .Struct}}_gen.go") // MyTasks and Metrics
+	require.ErrorContains(t, err, "7_gen.go of example.com/mypkg.Metrics collides with the one of example.com/mypkg.MyTasks")
+}
+
+func TestOutputPkg(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type Point struct{ X, Y int }
+
+func (Tasks) Move(p Point) Point { return p }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{OutputPkg: "./raygen"})
+	g.pkg = pkg
+	pkgDir := filepath.Dir(pkg.GoFiles[0])
+	require.NoError(t, g.generate(pkgDir))
+
+	code, err := os.ReadFile(filepath.Join(pkgDir, "raygen", generatedFileName))
+	require.NoError(t, err)
+	require.Contains(t, string(code), "package raygen\n")
+	require.Contains(t, string(code), `"example.com/mypkg"`)
+	require.Contains(t, string(code), "[mypkg.Tasks.Move]\nfunc Move[mypkg_Point_0 _T0](p mypkg_Point_0) *RemoteFunc[*Future1[mypkg.Point]]")
+
+	require.ErrorContains(t, WithOutputPkg("../raygen")(&Options{}), "expect a subdirectory")
+	require.ErrorContains(t, WithOutputPkg("./raygen")(&Options{OutputDir: "client"}), "exclusive")
+}
@@ -0,0 +1,55 @@
+package goraygen
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// unsupportedParam returns why the method can't be called remotely because of a param, with a suggestion,
+// or empty strings if it can: a func, channel, io.Reader or io.Writer param can't be serialized, the callback,
+// the channel or the stream living in the caller process. The tasks get IO adapters for the streams instead, see collectIOTask.
+func unsupportedParam(m Method) (reason, suggestion string) {
+	for i, p := range m.Params {
+		typ := p.GoType
+		if m.IsVariadic && i == len(m.Params)-1 {
+			if s, ok := typ.(*types.Slice); ok {
+				typ = s.Elem()
+			}
+		}
+		if typ == nil {
+			continue
+		}
+		if ioParam(p) != "" {
+			return fmt.Sprintf("param %s of type %s can't be serialized", p.Name, p.Type),
+				"pass the content as []byte, or move the method to the tasks struct to generate its IO adapter"
+		}
+		switch typ.Underlying().(type) {
+		case *types.Signature:
+			return fmt.Sprintf("param %s of func type %s can't be serialized", p.Name, p.Type),
+				"pass the data the callback needs and call it on the caller side, or make the callback a task of its own"
+		case *types.Chan:
+			return fmt.Sprintf("param %s of channel type %s can't be serialized", p.Name, p.Type),
+				"pass the values as a slice, or send them in batches of calls"
+		}
+	}
+	return "", ""
+}
+
+// unsupportedResult returns why the method can't be called remotely because of a result, with a suggestion,
+// or empty strings if it can: a channel result lives in the callee process, its values can't be received by the caller
+// unless streamed, see collectStream.
+func unsupportedResult(m Method) (reason, suggestion string) {
+	for i, r := range m.Results {
+		if r.GoType == nil {
+			continue
+		}
+		if _, ok := r.GoType.Underlying().(*types.Chan); ok {
+			suggestion = "return the values as a slice, or page them in batches of calls; exclude the method with -exclude to keep it local"
+			if _, ok := streamElem(m); ok {
+				suggestion = "use -streaming to stream the values, or " + suggestion
+			}
+			return fmt.Sprintf("result %d of channel type %s can't be received by the caller", i, r.Type), suggestion
+		}
+	}
+	return "", ""
+}
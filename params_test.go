@@ -0,0 +1,85 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipUnsupportedParams(t *testing.T) {
+	var diagnostics []Diagnostic
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "unsupported-param" {
+			diagnostics = append(diagnostics, d)
+		}
+	})
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type Handler func(string) error
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+
+func (Tasks) Each(f func(int)) {}
+
+func (Tasks) Serve(h Handler) {}
+
+func (Tasks) Chain(fs ...func()) {}
+
+func (Tasks) Sum(in <-chan int) int { return 0 }
+`}, opts)
+	require.Contains(t, code, "func Divide[")
+	for _, name := range []string{"Each", "Serve", "Chain", "Sum"} {
+		require.NotContains(t, code, "func "+name+"[")
+	}
+	require.Len(t, diagnostics, 4)
+	require.Equal(t, "Skip method (Tasks).Each: param f of func type func(int) can't be serialized", diagnostics[0].Message)
+	require.Equal(t, 10, diagnostics[0].Line)
+	require.Contains(t, diagnostics[0].Suggestion, "make the callback a task of its own")
+	require.Equal(t, "Skip method (Tasks).Serve: param h of func type Handler can't be serialized", diagnostics[1].Message)
+	require.Equal(t, "Skip method (Tasks).Chain: param fs of func type func() can't be serialized", diagnostics[2].Message)
+	require.Equal(t, "Skip method (Tasks).Sum: param in of channel type <-chan int can't be serialized", diagnostics[3].Message)
+	require.Contains(t, diagnostics[3].Suggestion, "pass the values as a slice")
+}
+
+func TestSkipChannelResults(t *testing.T) {
+	var diagnostics []Diagnostic
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "unsupported-result" {
+			diagnostics = append(diagnostics, d)
+		}
+	})
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Count(n int) <-chan int { return nil }
+
+func (Tasks) Items() (chan []string, error) { return nil, nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Watch() <-chan int { return nil }
+
+func (c *Counter) Get() int { return c.n }
+`}, opts)
+	require.NotContains(t, code, "func Count[")
+	require.NotContains(t, code, "func Items(")
+	require.NotContains(t, code, "func Counter_Watch(")
+	require.Contains(t, code, "func Counter_Get(")
+	require.Len(t, diagnostics, 3)
+	require.Equal(t, SeverityError, diagnostics[0].Severity)
+	require.Equal(t, "Skip method (Tasks).Count: result 0 of channel type <-chan int can't be received by the caller", diagnostics[0].Message)
+	require.Contains(t, diagnostics[0].Suggestion, "return the values as a slice")
+	require.Equal(t, "Skip method (*Counter).Watch: result 0 of channel type <-chan int can't be received by the caller", diagnostics[2].Message)
+}
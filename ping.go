@@ -0,0 +1,135 @@
+package goraygen
+
+import (
+	"bytes"
+	"go/types"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+On the worker, every actor struct gets the trivial remote method:
+
+	func (_a *Counter) Ping() error
+
+and on the driver, every actor handle gets the liveness probe calling it:
+
+	func (_actor *ActorCounter) Ping(ctx context.Context) error
+	func (_actor *CounterActorHandle) Ping(ctx context.Context) error
+*/
+const pingMethodTpl = `
+// Ping is the trivial remote method of [{{.StructName}}] answering the liveness probes of its handles.
+func (_a {{if .Pointer}}*{{end}}{{.StructName}}) Ping() error {
+	return nil
+}
+`
+
+const pingProbeTpl = `
+// Ping probes the liveness of the actor: it returns nil once the actor answered,
+// or an error if the actor is dead or ctx is done first.
+func (_actor *{{.}}) Ping(ctx context.Context) error {
+	return _pingActor(ctx, &_actor.ActorHandle)
+}
+`
+
+// pingHelpers is shared by the liveness probes of all actor handles, generated once per file.
+const pingHelpers = `
+// ActorPinger is implemented by all the actor handles, to probe the liveness of actors uniformly.
+type ActorPinger interface {
+	Ping(ctx context.Context) error
+}
+
+func _pingActor(ctx context.Context, actor *ray.ActorHandle) error {
+	future := NewRemoteFunc[*Future1[error]]("Ping", []any{}, actor).Remote()
+	ch := make(chan error, 1)
+	go func() {
+		err, callErr := future.Get()
+		if callErr != nil {
+			err = callErr
+		}
+		ch <- err
+	}()
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+`
+
+var (
+	pingMethodTmpl = template.Must(template.New("pingMethod").Parse(pingMethodTpl))
+	pingProbeTmpl  = template.Must(template.New("pingProbe").Parse(pingProbeTpl))
+)
+
+// PingActor is an actor struct getting the Ping method, see pingMethodTpl.
+type PingActor struct {
+	StructName string
+	Pointer    bool // pointer receiver, unless the factory creates the actor by value
+}
+
+// collectPingActors sets the actor structs to generate the Ping method for, once per generator.
+func (g *Generator) collectPingActors() {
+	if g.pingActors != nil {
+		return
+	}
+	g.pingActors = []PingActor{}
+	if !g.opts.ActorPing {
+		return
+	}
+	if !g.inPackageCode() {
+		g.warnOutsidePackage("skip-actor-ping", "Skip actor ping: the Ping methods must be generated into package %s", g.pkg.PkgPath)
+		return
+	}
+	add := func(name string, pointer bool) {
+		if gslice.Any(g.pingActors, func(a PingActor) bool { return a.StructName == name }) {
+			return
+		}
+		if _, ok := g.pkg.Types.Scope().Lookup(name).(*types.TypeName); !ok {
+			return // not a type of the package
+		}
+		for _, m := range analysis.Methods(g.pkg, name, g.pkg.PkgPath, g.importStore) {
+			if m.Name == "Ping" {
+				g.warnAt(g.methodPos(m), "skip-actor-ping", "Skip actor ping of %s: it already declares the Ping method", name)
+				return
+			}
+		}
+		g.pingActors = append(g.pingActors, PingActor{StructName: name, Pointer: pointer})
+	}
+	for _, factory := range g.actorFactories {
+		resType := factory.Results[0].Type
+		add(strings.TrimPrefix(strings.TrimPrefix(resType, "*"), g.sourceQualifier()), strings.HasPrefix(resType, "*"))
+	}
+	for _, s := range analysis.FindStructs(g.pkg, g.opts.actorMatcher()) {
+		add(s.Name.Name, true)
+	}
+}
+
+// isPinged reports whether the actor struct gets the Ping method.
+func (g *Generator) isPinged(structName string) bool {
+	return gslice.Any(g.pingActors, func(a PingActor) bool { return a.StructName == structName })
+}
+
+// generatePingMethods generates the worker-side Ping methods of the actors.
+// The generated code lives in the scanned package, as methods can't be declared on types of another package.
+func (g *Generator) generatePingMethods(buf *bytes.Buffer) {
+	for _, a := range g.pingActors {
+		if err := pingMethodTmpl.Execute(buf, a); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// generatePingProbe generates the driver-side Ping method of the actor handle type, if its actor struct gets the Ping method.
+func (g *Generator) generatePingProbe(buf *bytes.Buffer, handleType, structName string) {
+	if !g.isPinged(structName) {
+		return
+	}
+	if err := pingProbeTmpl.Execute(buf, handleType); err != nil {
+		panic(err)
+	}
+}
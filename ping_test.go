@@ -0,0 +1,44 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateActorPing(t *testing.T) {
+	sources := map[string]string{"actors": `package mypkg
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{n: n} }
+
+func (Actors) Timer() Timer { return Timer{} }
+
+func (Actors) Store() *Store { return &Store{} }
+
+type Counter struct{ n int }
+
+type Timer struct{}
+
+type Store struct{}
+
+func (s *Store) Ping() bool { return true }
+
+// rayactor
+type Cache struct{}
+`}
+	code := generateFromSource(t, sources, Options{ActorPing: true})
+
+	require.Contains(t, code, "func (_a *Counter) Ping() error {\n\treturn nil\n}")
+	require.Contains(t, code, "func (_a Timer) Ping() error {") // created by value
+	require.Contains(t, code, "func (_a *Cache) Ping() error {")
+	require.NotContains(t, code, "func (_a *Store) Ping()") // declares its own Ping
+	require.Contains(t, code, "func (_actor *ActorCounter) Ping(ctx context.Context) error {\n\treturn _pingActor(ctx, &_actor.ActorHandle)\n}")
+	require.Contains(t, code, "func (_actor *CacheActorHandle) Ping(ctx context.Context) error {")
+	require.NotContains(t, code, "func (_actor *ActorStore) Ping(")
+	require.Contains(t, code, "type ActorPinger interface {")
+
+	require.NotContains(t, generateFromSource(t, sources, Options{}), "ActorPinger")
+}
@@ -0,0 +1,176 @@
+package goraygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pluginProtocolVersion is the version of PluginRequest and PluginResponse, bumped on incompatible changes.
+const pluginProtocolVersion = 1
+
+/*
+With -plugin=./my-gen:key=value, goraygen runs ./my-gen after the built-in backends, protoc-style:
+the analyzed package is written as a JSON PluginRequest to its stdin, and it writes back a JSON PluginResponse to its stdout
+with the files to generate. Its stderr is passed through, for the logs. A minimal plugin:
+
+	func main() {
+		var req PluginRequest
+		json.NewDecoder(os.Stdin).Decode(&req)
+		var names []string
+		for _, t := range req.Tasks {
+			names = append(names, t.TaskName)
+		}
+		json.NewEncoder(os.Stdout).Encode(PluginResponse{Files: []PluginFile{{Name: "tasks.txt", Content: strings.Join(names, "\n")}}})
+	}
+*/
+
+// PluginRequest is the analyzed package, written to the stdin of the plugins.
+type PluginRequest struct {
+	Version       int             `json:"version"`   // pluginProtocolVersion
+	Parameter     string          `json:"parameter"` // the text after the colon of -plugin, e.g. "key=value"
+	Package       PluginPackage   `json:"package"`   // the scanned package
+	OutputPackage PluginPackage   `json:"outputPackage"`
+	TasksStruct   string          `json:"tasksStruct,omitempty"`
+	ActorsStruct  string          `json:"actorsStruct,omitempty"`
+	Tasks         []PluginTask    `json:"tasks"`
+	Actors        []PluginActor   `json:"actors"`
+	ActorHandles  []PluginHandle  `json:"actorHandles,omitempty"`
+	Options       map[string]bool `json:"options"` // the flags enabling outputs of the wrappers file, e.g. "ResultRefs"
+}
+
+// PluginPackage is a Go package.
+type PluginPackage struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Dir  string `json:"dir"`
+}
+
+// PluginTask is a task, with the types of its params and results as written in the output package.
+type PluginTask struct {
+	Method
+	TaskName string `json:"TaskName"` // the registered name, versioned with -task-version
+}
+
+// PluginActor is an actor with its factory method.
+type PluginActor struct {
+	Name    string   `json:"name"`
+	Factory Method   `json:"factory"`
+	Methods []Method `json:"methods"`
+}
+
+// PluginHandle is a struct annotated with `// rayactor`.
+type PluginHandle struct {
+	Name      string   `json:"name"`
+	ActorName string   `json:"actorName"` // the registered actor name
+	Methods   []Method `json:"methods"`
+}
+
+// PluginResponse is the output of the plugins, read from their stdout.
+type PluginResponse struct {
+	Error string       `json:"error,omitempty"` // the plugin failed, no file is written
+	Files []PluginFile `json:"files"`
+}
+
+// PluginFile is a file generated by a plugin. The Go files are formatted and their imports fixed, like the built-in outputs.
+type PluginFile struct {
+	Name    string `json:"name"` // the path relative to the output dir
+	Content string `json:"content"`
+}
+
+// pluginBackend is the Backend running a plugin.
+type pluginBackend struct {
+	path      string
+	parameter string
+}
+
+// parsePlugin parses the -plugin flag value "path[:parameter]".
+func parsePlugin(s string) pluginBackend {
+	path, parameter, _ := strings.Cut(s, ":")
+	return pluginBackend{path: path, parameter: parameter}
+}
+
+func (p pluginBackend) Name() string           { return "plugin:" + filepath.Base(p.path) }
+func (p pluginBackend) Enabled(_ Options) bool { return true }
+
+func (p pluginBackend) Generate(g *Generator, outputDir string) error {
+	req, err := json.Marshal(g.pluginRequest(p.parameter, outputDir))
+	if err != nil {
+		return err
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command(p.path)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("run plugin %s: %w", p.path, err)
+	}
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return fmt.Errorf("decode the response of plugin %s: %w", p.path, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("plugin %s: %s", p.path, resp.Error)
+	}
+	for _, f := range resp.Files {
+		if !filepath.IsLocal(f.Name) {
+			return fmt.Errorf("plugin %s: invalid file name %q, expect a path in the output dir", p.path, f.Name)
+		}
+	}
+	for _, f := range resp.Files {
+		outputFile := filepath.Join(outputDir, f.Name)
+		if err := g.mkdirAll(filepath.Dir(outputFile)); err != nil {
+			return err
+		}
+		if strings.HasSuffix(f.Name, ".go") {
+			err = g.writeFile(f.Content, outputFile)
+		} else {
+			err = g.writeOutput(outputFile, []byte(f.Content))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pluginRequest returns the request of the plugins, describing the tasks and actors collected by the generator.
+func (g *Generator) pluginRequest(parameter, outputDir string) PluginRequest {
+	g.prepareTaskVersions()
+	absOutputDir, _ := filepath.Abs(outputDir)
+	req := PluginRequest{
+		Version:       pluginProtocolVersion,
+		Parameter:     parameter,
+		Package:       PluginPackage{Name: g.pkg.Name, Path: g.pkg.PkgPath},
+		OutputPackage: PluginPackage{Name: g.outputPkgName, Path: g.outputPkgPath, Dir: absOutputDir},
+		TasksStruct:   g.tasksStruct,
+		ActorsStruct:  g.actorsStruct,
+		Tasks:         []PluginTask{},
+		Actors:        []PluginActor{},
+		Options: map[string]bool{
+			"ResultRefs":      g.opts.ResultRefs,
+			"OptionBuilders":  g.opts.OptionBuilders,
+			"ContextVariants": g.opts.ContextVariants,
+			"Chaining":        g.opts.Chaining,
+			"SplitWorker":     g.splitWorker(),
+		},
+	}
+	if len(g.pkg.GoFiles) > 0 {
+		req.Package.Dir = filepath.Dir(g.pkg.GoFiles[0])
+	}
+	for _, m := range g.tasks {
+		req.Tasks = append(req.Tasks, PluginTask{Method: m, TaskName: g.taskName(m)})
+	}
+	for _, factory := range g.actorFactories {
+		req.Actors = append(req.Actors, PluginActor{Name: factory.Name, Factory: factory, Methods: g.actor2Methods[factory.Name]})
+	}
+	for _, h := range g.actorHandles {
+		req.ActorHandles = append(req.ActorHandles, PluginHandle{Name: h.StructName, ActorName: h.ActorName, Methods: h.Methods})
+	}
+	return req
+}
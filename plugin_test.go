@@ -0,0 +1,59 @@
+package goraygen
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginBackend(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Divide divides.
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+
+	dir := t.TempDir()
+	plugin := filepath.Join(dir, "my-gen")
+	require.NoError(t, os.WriteFile(plugin, []byte(`#!/bin/sh
+cat > "$(dirname "$0")/request.json"
+printf '%s' '{"files": [{"name": "gen/tasks.txt", "content": "Divide"}, {"name": "tasks_gen.go", "content": "package mypkg\nvar TaskCount   = 1"}]}'
+`), 0o755))
+	outputDir := t.TempDir()
+	require.NoError(t, parsePlugin(plugin+":mode=fast").Generate(g, outputDir))
+
+	var req PluginRequest
+	data, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &req))
+	require.Equal(t, pluginProtocolVersion, req.Version)
+	require.Equal(t, "mode=fast", req.Parameter)
+	require.Equal(t, "example.com/mypkg", req.Package.Path)
+	require.Len(t, req.Tasks, 1)
+	require.Equal(t, "Divide", req.Tasks[0].TaskName)
+	require.Equal(t, "int64", req.Tasks[0].Params[1].Type)
+	require.Equal(t, "// Divide divides.", req.Tasks[0].Doc)
+
+	txt, err := os.ReadFile(filepath.Join(outputDir, "gen", "tasks.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "Divide", string(txt))
+	code, err := os.ReadFile(filepath.Join(outputDir, "tasks_gen.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package mypkg\n\nvar TaskCount = 1\n", string(code)) // formatted
+
+	require.NoError(t, os.WriteFile(plugin, []byte("#!/bin/sh\necho '{\"files\": [{\"name\": \"../escape.txt\"}]}'\n"), 0o755))
+	require.ErrorContains(t, parsePlugin(plugin).Generate(g, outputDir), "invalid file name")
+	require.NoError(t, os.WriteFile(plugin, []byte("#!/bin/sh\necho '{\"error\": \"no tasks\"}'\n"), 0o755))
+	require.ErrorContains(t, parsePlugin(plugin).Generate(g, outputDir), "no tasks")
+}
@@ -0,0 +1,104 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+/*
+	type DividePool struct {
+		_callPool
+	}
+
+func NewDividePool(maxConcurrent int) *DividePool
+func (_p *DividePool) Call(ctx context.Context, a int64, b int64) (_r0 int64, _r1 int64, _err error)
+*/
+const poolTpl = `
+// {{.Name}}Pool calls [{{.Name}}] remotely with a bounded number of calls in flight, the other calls wait for a free slot.
+// It's safe for concurrent use.
+type {{.Name}}Pool struct {
+	_callPool
+}
+
+// New{{.Name}}Pool returns a {{.Name}}Pool with at most maxConcurrent calls in flight (at least 1).
+func New{{.Name}}Pool(maxConcurrent int) *{{.Name}}Pool {
+	return &{{.Name}}Pool{_newCallPool(maxConcurrent)}
+}
+
+// Call waits for a free slot until ctx is done, then calls {{.Name}} remotely and waits for the results.
+func (_p *{{.Name}}Pool) Call(ctx context.Context{{if .Params}}, {{.Params}}{{end}}) ({{range .Results}}{{.}}, {{end}}_err error) {
+	if _err = _p.acquire(ctx); _err != nil {
+		return
+	}
+	defer _p.release()
+	{{.CallBody}}
+	return
+}
+`
+
+// poolHelpers is the state shared by the pools of all tasks, generated once per file.
+const poolHelpers = `
+// _callPool bounds the number of calls in flight.
+type _callPool struct {
+	slots chan struct{}
+}
+
+func _newCallPool(maxConcurrent int) _callPool {
+	return _callPool{slots: make(chan struct{}, max(maxConcurrent, 1))}
+}
+
+// acquire waits for a free slot until ctx is done, the slot must be released after the call.
+func (p _callPool) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p _callPool) release() {
+	<-p.slots
+}
+
+// InFlight returns the number of calls in flight.
+func (p _callPool) InFlight() int {
+	return len(p.slots)
+}
+`
+
+var poolTmpl = template.Must(template.New("pool").Parse(poolTpl))
+
+// PoolDef is the template data of poolTpl.
+type PoolDef struct {
+	Name     string
+	Params   string
+	Results  []string // named results without error, e.g. "_r0 int64"
+	CallBody string
+}
+
+// generatePool generates the concurrency-capped caller of the task.
+func (g *Generator) generatePool(buf *bytes.Buffer, m Method) {
+	def := PoolDef{Name: m.Name}
+	cm, passContext := withoutContext(m) // the ctx of the caller is passed on
+	params, callArgs, resultTypes := callSignature(cm)
+	def.Params = params
+	var vars string
+	for i, t := range resultTypes {
+		def.Results = append(def.Results, fmt.Sprintf("_r%d %s", i, t))
+		vars += fmt.Sprintf("_r%d, ", i)
+	}
+	if g.opts.instrumented() {
+		def.CallBody = fmt.Sprintf("%s_err = %sInvoke(%s)", vars, m.Name, strings.TrimSuffix("ctx, "+callArgs, ", "))
+	} else {
+		if passContext {
+			callArgs = strings.TrimSuffix("ctx, "+callArgs, ", ")
+		}
+		def.CallBody = assignCallResults(m, vars, fmt.Sprintf("%s(%s).Remote().Get()", m.Name, callArgs), "\t")
+	}
+	if err := poolTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,31 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePools(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Ping() error { return nil }
+`}
+	code := generateFromSource(t, sources, Options{Pools: true})
+
+	require.Contains(t, code, "type DividePool struct {\n\t_callPool\n}")
+	require.Contains(t, code, "func NewDividePool(maxConcurrent int) *DividePool {")
+	require.Contains(t, code, "func (_p *DividePool) Call(ctx context.Context, a int64, b int64) (_r0 int64, _r1 int64, _err error) {")
+	require.Contains(t, code, "_r0, _r1, _err = Divide(a, b).Remote().Get()")
+	require.Contains(t, code, "func (_p *PingPool) Call(ctx context.Context) (_err error) {")
+	require.Contains(t, code, "_taskErr, _err = Ping().Remote().Get()")
+	require.Contains(t, code, "func (p _callPool) acquire(ctx context.Context) error {")
+
+	code = generateFromSource(t, sources, Options{Pools: true, WithOtel: true})
+	require.Contains(t, code, "_r0, _r1, _err = DivideInvoke(ctx, a, b)")
+}
@@ -0,0 +1,761 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+const protoFileName = "ray_tasks.proto"
+
+/*
+With -proto, the request and response of every task are described as protobuf messages in ray_tasks.proto:
+
+	message DivideRequest {
+	  int64 a = 1;
+	  int64 b = 2;
+	}
+
+and the worker registers a task taking and returning them serialized, for the drivers in other languages, e.g. Python:
+
+	func (_t Tasks) DivideProto(data []byte) ([]byte, error)
+*/
+const protoFileTpl = `// {{.Header}}
+//
+// The request and response messages of the ray tasks of package {{.PkgPath}}.
+// Call the FooProto task with the serialized FooRequest, it returns the serialized FooResponse.
+//
+// To regenerate this file, run:
+//
+//	goraygen -proto <package-path>
+
+syntax = "proto3";
+
+package {{.Package}};
+{{if .Imports}}
+{{range .Imports}}import "{{.}}";
+{{end}}{{end}}
+option go_package = "{{.PkgPath}}";
+{{range .Messages}}
+{{if .Doc}}{{.Doc}}
+{{end}}message {{.Name}} {
+{{- range .Fields}}
+  {{if .Repeated}}repeated {{end}}{{.ProtoType}} {{.Name}} = {{.Number}};
+{{- end}}
+}
+{{end}}
+{{- if .Service}}
+// {{.Service}} serves the tasks through the gRPC gateway, see Register{{.Service}}GRPCGateway.
+service {{.Service}} {
+{{- range .RPCs}}
+  rpc {{.}}({{.}}Request) returns ({{.}}Response);
+{{- end}}
+}
+{{end}}`
+
+const protoTaskTpl = `
+// {{.Name}}Proto is the variant of [{{.StructName}}.{{.Name}}] for the drivers in other languages:
+// it takes the serialized {{.Name}}Request message and returns the serialized {{.Name}}Response message.
+func (_t {{.ReceiverType}}) {{.Name}}Proto(data []byte) ([]byte, error) {
+	var _req {{.Request}}
+	if err := _consumeProto{{.Name}}Request(data, &_req); err != nil {
+		return nil, fmt.Errorf("decode {{.Name}}Request: %w", err)
+	}
+	var _resp {{.Response}}
+	{{if .Results}}{{.Results}} := {{end}}_t.{{.Name}}({{.CallArgs}})
+	{{- if .ReturnsError}}
+	if _err != nil {
+		return nil, _err
+	}
+	{{- end}}
+	{{- range .Assigns}}
+	{{.}}
+	{{- end}}
+	return _appendProto{{.Name}}Response(nil, &_resp), nil
+}
+`
+
+// protoMessageTpl is the Go glue of a message: the Go struct of the task requests and responses,
+// and the functions converting between the Go struct and the serialized message.
+const protoMessageTpl = `
+{{- if .Synthetic}}
+// {{.GoType}} is the Go form of the {{.Name}} message.
+type {{.GoType}} struct {
+	{{- range .Fields}}
+	{{.GoName}} {{.GoType}}
+	{{- end}}
+}
+{{- end}}
+
+// _appendProto{{.Name}} appends v serialized as the {{.Name}} message to b.
+func _appendProto{{.Name}}(_b []byte, _v *{{.GoType}}) []byte {
+	if _v == nil {
+		return _b
+	}
+	{{- range .Fields}}
+	{{.Encode}}
+	{{- end}}
+	return _b
+}
+
+// _consumeProto{{.Name}} parses the serialized {{.Name}} message into v.
+func _consumeProto{{.Name}}(_b []byte, _v *{{.GoType}}) error {
+	for len(_b) > 0 {
+		_num, _typ, _n := protowire.ConsumeTag(_b)
+		if _n < 0 {
+			return protowire.ParseError(_n)
+		}
+		_b = _b[_n:]
+		var _err error
+		switch _num {
+		{{- range .Fields}}
+		case {{.Number}}:
+			{{.Decode}}
+		{{- end}}
+		default:
+			_n = protowire.ConsumeFieldValue(_num, _typ, _b)
+		}
+		if _err != nil {
+			return _err
+		}
+		if _n < 0 {
+			return protowire.ParseError(_n)
+		}
+		_b = _b[_n:]
+	}
+	return nil
+}
+`
+
+// protoHelpers consume the field values by wire type, generated once per file.
+// A value of unexpected wire type is skipped, like the unknown fields.
+const protoHelpers = `
+// _protoVarint consumes a varint field value, or the packed values of a repeated field.
+func _protoVarint(num protowire.Number, typ protowire.Type, b []byte, f func(uint64)) (int, error) {
+	return _protoScalar(num, typ, protowire.VarintType, b, func(b []byte) int {
+		x, n := protowire.ConsumeVarint(b)
+		if n >= 0 {
+			f(x)
+		}
+		return n
+	})
+}
+
+// _protoFixed64 consumes a fixed64 field value, or the packed values of a repeated field.
+func _protoFixed64(num protowire.Number, typ protowire.Type, b []byte, f func(uint64)) (int, error) {
+	return _protoScalar(num, typ, protowire.Fixed64Type, b, func(b []byte) int {
+		x, n := protowire.ConsumeFixed64(b)
+		if n >= 0 {
+			f(x)
+		}
+		return n
+	})
+}
+
+// _protoFixed32 consumes a fixed32 field value, or the packed values of a repeated field.
+func _protoFixed32(num protowire.Number, typ protowire.Type, b []byte, f func(uint32)) (int, error) {
+	return _protoScalar(num, typ, protowire.Fixed32Type, b, func(b []byte) int {
+		x, n := protowire.ConsumeFixed32(b)
+		if n >= 0 {
+			f(x)
+		}
+		return n
+	})
+}
+
+func _protoScalar(num protowire.Number, typ, want protowire.Type, b []byte, consume func([]byte) int) (int, error) {
+	switch typ {
+	case want:
+		return consume(b), nil
+	case protowire.BytesType: // packed
+		packed, n := protowire.ConsumeBytes(b)
+		for n >= 0 && len(packed) > 0 {
+			m := consume(packed)
+			if m < 0 {
+				return m, nil
+			}
+			packed = packed[m:]
+		}
+		return n, nil
+	}
+	return protowire.ConsumeFieldValue(num, typ, b), nil
+}
+
+// _protoBytes consumes a length-delimited field value: a string, bytes or a message.
+func _protoBytes(num protowire.Number, typ protowire.Type, b []byte, f func([]byte) error) (int, error) {
+	if typ != protowire.BytesType {
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	}
+	x, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n, nil
+	}
+	return n, f(x)
+}
+
+// _protoEntry consumes the key and value fields of a map entry with consume.
+func _protoEntry(b []byte, consume func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := consume(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+`
+
+// protoTimeHelpers are the Go glue of the google.protobuf.Timestamp and google.protobuf.Duration messages,
+// the seconds and nanoseconds of a time.Time since the Unix epoch, and of a time.Duration.
+const protoTimeHelpers = `
+// _appendProto_Timestamp appends v serialized as the google.protobuf.Timestamp message to b.
+func _appendProto_Timestamp(_b []byte, _v *time.Time) []byte {
+	return _appendProtoSecondsNanos(_b, _v.Unix(), int64(_v.Nanosecond()))
+}
+
+// _consumeProto_Timestamp parses the serialized google.protobuf.Timestamp message into v, in UTC.
+func _consumeProto_Timestamp(_b []byte, _v *time.Time) error {
+	_secs, _nanos, _err := _consumeProtoSecondsNanos(_b)
+	*_v = time.Unix(_secs, _nanos).UTC()
+	return _err
+}
+
+// _appendProto_Duration appends v serialized as the google.protobuf.Duration message to b.
+func _appendProto_Duration(_b []byte, _v *time.Duration) []byte {
+	return _appendProtoSecondsNanos(_b, int64(*_v/time.Second), int64(*_v%time.Second))
+}
+
+// _consumeProto_Duration parses the serialized google.protobuf.Duration message into v.
+func _consumeProto_Duration(_b []byte, _v *time.Duration) error {
+	_secs, _nanos, _err := _consumeProtoSecondsNanos(_b)
+	*_v = time.Duration(_secs)*time.Second + time.Duration(_nanos)
+	return _err
+}
+
+// _appendProtoSecondsNanos appends the seconds and nanos fields of the Timestamp and Duration messages to b.
+func _appendProtoSecondsNanos(b []byte, secs, nanos int64) []byte {
+	if secs != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(secs))
+	}
+	if nanos != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(nanos))
+	}
+	return b
+}
+
+// _consumeProtoSecondsNanos parses the seconds and nanos fields of the Timestamp and Duration messages.
+func _consumeProtoSecondsNanos(b []byte) (secs, nanos int64, err error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0, 0, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			n, _ = _protoVarint(num, typ, b, func(x uint64) { secs = int64(x) })
+		case 2:
+			n, _ = _protoVarint(num, typ, b, func(x uint64) { nanos = int64(int32(x)) })
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return 0, 0, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return secs, nanos, nil
+}
+`
+
+var (
+	protoFileTmpl    = template.Must(template.New("protoFile").Parse(protoFileTpl))
+	protoTaskTmpl    = template.Must(template.New("protoTask").Parse(protoTaskTpl))
+	protoMessageTmpl = template.Must(template.New("protoMessage").Parse(protoMessageTpl))
+)
+
+// ProtoMessage is a protobuf message with its Go glue, the template data of protoMessageTpl.
+type ProtoMessage struct {
+	Name      string // e.g. "DivideRequest" or "Point"
+	GoType    string // the Go struct, e.g. "_DivideRequest" or "Point"
+	Synthetic bool   // the Go struct is generated, for the task requests and responses
+	Doc       string
+	Fields    []ProtoField
+}
+
+// ProtoField is a field of a protobuf message.
+type ProtoField struct {
+	Name   string // e.g. "user_id"
+	Number int
+	GoName string // the Go struct field
+	GoType string
+	protoValue
+	Encode string // the statements appending the field of _v to _b
+	Decode string // the statements consuming the field value from _b into _v
+}
+
+// protoValue is the protobuf type of a Go type.
+type protoValue struct {
+	ProtoType string // e.g. "int64" or the message name
+	Repeated  bool
+	wire      string      // "varint", "fixed64", "fixed32", "bytes" or "message"
+	elemType  string      // the Go type of the (repeated) values, e.g. "int32" or "Label"
+	pointer   bool        // the message values are pointers
+	glue      string      // the name of the Go glue functions of the message, e.g. "Point" for _appendProtoPoint
+	key, elem *protoValue // the key and value of a map, e.g. map<string, int64>
+}
+
+// ProtoTaskDef is the template data of protoTaskTpl.
+type ProtoTaskDef struct {
+	Name         string
+	StructName   string
+	ReceiverType string
+	Request      string
+	Response     string
+	CallArgs     string
+	Results      string   // the result variables, e.g. "_r0, _err"
+	Assigns      []string // the assignments of the results to _resp
+	ReturnsError bool
+}
+
+// protoWellKnownTypes are the Go types encoded as the well-known protobuf messages, instead of the messages of their fields,
+// with the Go glue of the message, see protoTimeHelpers.
+var protoWellKnownTypes = map[string]struct{ message, glue, file string }{
+	"time.Time":     {"google.protobuf.Timestamp", "_Timestamp", "google/protobuf/timestamp.proto"},
+	"time.Duration": {"google.protobuf.Duration", "_Duration", "google/protobuf/duration.proto"},
+}
+
+// protoSchema collects the messages of the tasks, see prepareProto.
+type protoSchema struct {
+	messages []ProtoMessage
+	structs  map[string]bool // the Go structs of the package with a message, or being added
+}
+
+// prepareProto collects the tasks with a protobuf variant into g.protoTasks and their messages into g.proto,
+// once per generator. The tasks with a type not representable in protobuf are skipped with a warning.
+func (g *Generator) prepareProto() {
+	if g.protoTasks != nil {
+		return
+	}
+	g.protoTasks = []Method{}
+	g.proto = &protoSchema{structs: map[string]bool{}}
+	if !g.backendSelected("proto") || len(g.tasks) == 0 {
+		return
+	}
+	if !g.inPackageCode() {
+		g.warnOutsidePackage("skip-proto", "Skip -proto: the protobuf tasks must be generated into package %s", g.pkg.PkgPath)
+		return
+	}
+	declared := gslice.Map(analysis.Methods(g.pkg, g.tasksStruct, g.pkg.PkgPath, g.importStore), func(m Method) string {
+		return m.Name
+	})
+	for _, m := range g.tasks {
+		if gslice.Contains(declared, m.Name+"Proto") {
+			g.warnAt(g.methodPos(m), "skip-proto", "Skip protobuf variant of %s: %s already declares the %sProto method", m.Name, g.tasksStruct, m.Name)
+			continue
+		}
+		if err := g.addProtoTask(m); err != nil {
+			g.warnAt(g.methodPos(m), "skip-proto", "Skip protobuf variant of %s: %v", m.Name, err)
+			continue
+		}
+		g.protoTasks = append(g.protoTasks, m)
+	}
+}
+
+// addProtoTask adds the request and response messages of the task, and the messages of their struct types.
+// Nothing is added if a type is not representable.
+func (g *Generator) addProtoTask(m Method) error {
+	saved := *g.proto
+	saved.messages = append([]ProtoMessage{}, g.proto.messages...)
+	saved.structs = make(map[string]bool, len(g.proto.structs))
+	for k, v := range g.proto.structs {
+		saved.structs[k] = v
+	}
+	err := g.addProtoTaskMessages(m)
+	if err != nil {
+		*g.proto = saved
+	}
+	return err
+}
+
+func (g *Generator) addProtoTaskMessages(m Method) error {
+	for _, name := range []string{m.Name + "Request", m.Name + "Response"} {
+		if g.pkg.Types.Scope().Lookup(name) != nil {
+			return fmt.Errorf("the %s message conflicts with the %s type of the package", name, name)
+		}
+	}
+	request := ProtoMessage{Name: m.Name + "Request", GoType: "_" + m.Name + "Request", Synthetic: true,
+		Doc: fmt.Sprintf("// %sRequest holds the params of the %s task.", m.Name, m.Name)}
+	for _, p := range m.Params {
+		if err := g.addProtoField(&request, p.Name, exportedFieldName(p.Name), p.GoType); err != nil {
+			return fmt.Errorf("param %s: %w", p.Name, err)
+		}
+	}
+	response := ProtoMessage{Name: m.Name + "Response", GoType: "_" + m.Name + "Response", Synthetic: true,
+		Doc: fmt.Sprintf("// %sResponse holds the results of the %s task.", m.Name, m.Name)}
+	for i, r := range m.Results {
+		if r.IsError {
+			continue
+		}
+		if err := g.addProtoField(&response, fmt.Sprintf("r%d", i), fmt.Sprintf("R%d", i), r.GoType); err != nil {
+			return fmt.Errorf("result %d: %w", i, err)
+		}
+	}
+	g.proto.messages = append(g.proto.messages, request, response)
+	return nil
+}
+
+// addProtoField adds the field of the Go type to the message.
+func (g *Generator) addProtoField(msg *ProtoMessage, name, goName string, typ types.Type) error {
+	value, err := g.protoValueOf(typ)
+	if err != nil {
+		return err
+	}
+	if gslice.Any(msg.Fields, func(f ProtoField) bool { return f.GoName == goName || f.Name == snakeCase(name) }) {
+		return fmt.Errorf("field %s conflicts with another field of message %s", name, msg.Name)
+	}
+	f := ProtoField{
+		Name:       snakeCase(name),
+		Number:     len(msg.Fields) + 1,
+		GoName:     goName,
+		GoType:     analysis.TypeName(typ, g.outputPkgPath, g.importStore),
+		protoValue: value,
+	}
+	f.Encode, f.Decode = f.encode(), f.decode()
+	msg.Fields = append(msg.Fields, f)
+	return nil
+}
+
+// protoValueOf returns the protobuf type of the Go type, adding the messages of the structs of the package.
+func (g *Generator) protoValueOf(typ types.Type) (protoValue, error) {
+	elemType := analysis.TypeName(typ, g.outputPkgPath, g.importStore)
+	if named, ok := types.Unalias(typ).(*types.Named); ok && named.Obj().Pkg() != nil {
+		if wk, ok := protoWellKnownTypes[named.Obj().Pkg().Path()+"."+named.Obj().Name()]; ok {
+			return protoValue{ProtoType: wk.message, wire: "message", elemType: elemType, glue: wk.glue}, nil
+		}
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		return basicProtoValue(t, elemType)
+	case *types.Slice:
+		if elem, ok := t.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Byte {
+			return protoValue{ProtoType: "bytes", wire: "bytes", elemType: elemType}, nil
+		}
+		value, err := g.protoValueOf(t.Elem())
+		if err != nil {
+			return value, err
+		}
+		if value.Repeated || value.key != nil {
+			return value, fmt.Errorf("nested repeated type %s is not supported", elemType)
+		}
+		value.Repeated = true
+		return value, nil
+	case *types.Map:
+		key, err := g.protoValueOf(t.Key())
+		if err != nil {
+			return key, err
+		}
+		if key.wire != "varint" && key.ProtoType != "string" {
+			return protoValue{}, fmt.Errorf("map key type %s is not supported, only the integers, bools and strings are", key.elemType)
+		}
+		elem, err := g.protoValueOf(t.Elem())
+		if err != nil {
+			return elem, err
+		}
+		if elem.Repeated || elem.key != nil {
+			return protoValue{}, fmt.Errorf("map value type %s is not supported, a repeated or map type can't be a map value",
+				analysis.TypeName(t.Elem(), g.outputPkgPath, g.importStore))
+		}
+		return protoValue{ProtoType: fmt.Sprintf("map<%s, %s>", key.ProtoType, elem.ProtoType), wire: "bytes", elemType: elemType,
+			key: &key, elem: &elem}, nil
+	case *types.Pointer:
+		value, err := g.protoValueOf(t.Elem())
+		if err != nil || value.wire != "message" {
+			return value, fmt.Errorf("type %s is not supported, only pointers to structs are", elemType)
+		}
+		value.pointer = true
+		return value, nil
+	case *types.Struct:
+		named, ok := typ.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != g.pkg.PkgPath || named.TypeArgs().Len() > 0 {
+			return protoValue{}, fmt.Errorf("type %s is not supported, only the structs of package %s are", elemType, g.pkg.Name)
+		}
+		return protoValue{ProtoType: named.Obj().Name(), wire: "message", elemType: elemType, glue: named.Obj().Name()}, g.addProtoStruct(named, t)
+	}
+	return protoValue{}, fmt.Errorf("type %s is not supported", elemType)
+}
+
+// basicProtoValue returns the protobuf scalar type of the basic Go type.
+func basicProtoValue(t *types.Basic, elemType string) (protoValue, error) {
+	switch t.Kind() {
+	case types.Bool:
+		return protoValue{ProtoType: "bool", wire: "varint", elemType: elemType}, nil
+	case types.Int, types.Int64:
+		return protoValue{ProtoType: "int64", wire: "varint", elemType: elemType}, nil
+	case types.Int8, types.Int16, types.Int32:
+		return protoValue{ProtoType: "int32", wire: "varint", elemType: elemType}, nil
+	case types.Uint, types.Uint64:
+		return protoValue{ProtoType: "uint64", wire: "varint", elemType: elemType}, nil
+	case types.Uint8, types.Uint16, types.Uint32:
+		return protoValue{ProtoType: "uint32", wire: "varint", elemType: elemType}, nil
+	case types.Float64:
+		return protoValue{ProtoType: "double", wire: "fixed64", elemType: elemType}, nil
+	case types.Float32:
+		return protoValue{ProtoType: "float", wire: "fixed32", elemType: elemType}, nil
+	case types.String:
+		return protoValue{ProtoType: "string", wire: "bytes", elemType: elemType}, nil
+	}
+	return protoValue{}, fmt.Errorf("type %s is not supported", elemType)
+}
+
+// addProtoStruct adds the message of the struct of the package, made of its exported fields.
+func (g *Generator) addProtoStruct(named *types.Named, st *types.Struct) error {
+	name := named.Obj().Name()
+	if g.proto.structs[name] {
+		return nil // added, or being added for recursive structs
+	}
+	g.proto.structs[name] = true
+	msg := ProtoMessage{Name: name, GoType: name}
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if !field.Exported() {
+			continue
+		}
+		if err := g.addProtoField(&msg, field.Name(), field.Name(), field.Type()); err != nil {
+			return fmt.Errorf("field %s.%s: %w", name, field.Name(), err)
+		}
+	}
+	g.proto.messages = append(g.proto.messages, msg)
+	return nil
+}
+
+// encode returns the statements appending the field of _v to _b.
+func (f ProtoField) encode() string {
+	if f.key != nil {
+		return fmt.Sprintf("for _k, _e := range _v.%s {\nvar _m []byte\n%s\n%s\n_b = protowire.AppendTag(_b, %d, protowire.BytesType)\n_b = protowire.AppendBytes(_b, _m)\n}",
+			f.GoName, f.key.appendValue(1, "_k", "_m"), f.elem.appendValue(2, "_e", "_m"), f.Number)
+	}
+	if f.Repeated {
+		return fmt.Sprintf("for _, _e := range _v.%s {\n%s\n}", f.GoName, f.appendValue(f.Number, "_e", "_b"))
+	}
+	return f.appendValue(f.Number, "_v."+f.GoName, "_b")
+}
+
+// appendValue returns the statements appending x as the field num to the buffer b.
+func (v protoValue) appendValue(num int, x, b string) string {
+	switch v.wire {
+	case "varint":
+		value := "uint64(" + x + ")"
+		if v.ProtoType == "bool" {
+			value = "protowire.EncodeBool(bool(" + x + "))"
+		}
+		return fmt.Sprintf("%[1]s = protowire.AppendTag(%[1]s, %[2]d, protowire.VarintType)\n%[1]s = protowire.AppendVarint(%[1]s, %[3]s)", b, num, value)
+	case "fixed64":
+		return fmt.Sprintf("%[1]s = protowire.AppendTag(%[1]s, %[2]d, protowire.Fixed64Type)\n%[1]s = protowire.AppendFixed64(%[1]s, math.Float64bits(float64(%[3]s)))", b, num, x)
+	case "fixed32":
+		return fmt.Sprintf("%[1]s = protowire.AppendTag(%[1]s, %[2]d, protowire.Fixed32Type)\n%[1]s = protowire.AppendFixed32(%[1]s, math.Float32bits(float32(%[3]s)))", b, num, x)
+	case "bytes":
+		value := "[]byte(" + x + ")"
+		if v.ProtoType == "string" {
+			value = "string(" + x + ")"
+		}
+		return fmt.Sprintf("%[1]s = protowire.AppendTag(%[1]s, %[2]d, protowire.BytesType)\n%[1]s = protowire.Append%[3]s(%[1]s, %[4]s)", b, num, exportedFieldName(v.ProtoType), value)
+	}
+	// message
+	value := x
+	if !v.pointer {
+		value = "&" + x
+	}
+	stmt := fmt.Sprintf("%[1]s = protowire.AppendTag(%[1]s, %[2]d, protowire.BytesType)\n%[1]s = protowire.AppendBytes(%[1]s, _appendProto%[3]s(nil, %[4]s))", b, num, v.glue, value)
+	if v.pointer && !v.Repeated {
+		stmt = fmt.Sprintf("if %s != nil {\n%s\n}", x, stmt)
+	}
+	return stmt
+}
+
+// decode returns the statements consuming the value of the field from _b into _v.
+func (f ProtoField) decode() string {
+	if f.key != nil {
+		entry := fmt.Sprintf("func(_num protowire.Number, _typ protowire.Type, _b []byte) (_n int, _err error) {\nswitch _num {\ncase 1:\n%s\ncase 2:\n%s\ndefault:\n_n = protowire.ConsumeFieldValue(_num, _typ, _b)\n}\nreturn\n}",
+			f.key.consumeValue("_k"), f.elem.consumeValue("_e"))
+		elemType := f.elem.elemType
+		if f.elem.pointer {
+			elemType = "*" + elemType
+		}
+		return fmt.Sprintf("_n, _err = _protoBytes(_num, _typ, _b, func(_x []byte) error {\nvar _k %s\nvar _e %s\nif _err := _protoEntry(_x, %s); _err != nil {\nreturn _err\n}\nif _v.%[4]s == nil {\n_v.%[4]s = make(%[5]s)\n}\n_v.%[4]s[_k] = _e\nreturn nil\n})",
+			f.key.elemType, elemType, entry, f.GoName, f.GoType)
+	}
+	return f.consumeValue("_v." + f.GoName)
+}
+
+// consumeValue returns the statements consuming a field value from _b into the target, appended to it if repeated.
+func (v protoValue) consumeValue(target string) string {
+	assign := func(value string) string {
+		if v.Repeated {
+			return fmt.Sprintf("%s = append(%s, %s)", target, target, value)
+		}
+		return fmt.Sprintf("%s = %s", target, value)
+	}
+	switch v.wire {
+	case "varint":
+		value := v.elemType + "(_x)"
+		if v.ProtoType == "bool" {
+			value = v.elemType + "(protowire.DecodeBool(_x))"
+		}
+		return fmt.Sprintf("_n, _err = _protoVarint(_num, _typ, _b, func(_x uint64) { %s })", assign(value))
+	case "fixed64":
+		return fmt.Sprintf("_n, _err = _protoFixed64(_num, _typ, _b, func(_x uint64) { %s })", assign(v.elemType+"(math.Float64frombits(_x))"))
+	case "fixed32":
+		return fmt.Sprintf("_n, _err = _protoFixed32(_num, _typ, _b, func(_x uint32) { %s })", assign(v.elemType+"(math.Float32frombits(_x))"))
+	case "bytes":
+		value := v.elemType + "(_x)"
+		if v.ProtoType == "bytes" {
+			value = v.elemType + "(append([]byte(nil), _x...))"
+		}
+		return fmt.Sprintf("_n, _err = _protoBytes(_num, _typ, _b, func(_x []byte) error {\n%s\nreturn nil\n})", assign(value))
+	}
+	// message
+	var body string
+	switch {
+	case v.Repeated && v.pointer:
+		body = fmt.Sprintf("_e := new(%s)\n%s\nreturn _consumeProto%s(_x, _e)", v.elemType, assign("_e"), v.glue)
+	case v.Repeated:
+		body = fmt.Sprintf("var _e %s\n_err := _consumeProto%s(_x, &_e)\n%s\nreturn _err", v.elemType, v.glue, assign("_e"))
+	case v.pointer:
+		body = fmt.Sprintf("%s = new(%s)\nreturn _consumeProto%s(_x, %s)", target, v.elemType, v.glue, target)
+	default:
+		body = fmt.Sprintf("return _consumeProto%s(_x, &%s)", v.glue, target)
+	}
+	return fmt.Sprintf("_n, _err = _protoBytes(_num, _typ, _b, func(_x []byte) error {\n%s\n})", body)
+}
+
+// snakeCase converts the Go name to the protobuf field name, e.g. "UserID" to "user_id".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && !unicode.IsUpper(runes[i-1]) && runes[i-1] != '_'
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])
+			if prevLower || nextLower {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// generateProtoTasks generates the worker-side protobuf variants of the tasks with the Go glue of their messages,
+// it must be called before dumping imports. Like the tasks, they are methods of the tasks struct in the scanned package.
+func (g *Generator) generateProtoTasks(buf *bytes.Buffer) {
+	if len(g.protoTasks) == 0 {
+		return
+	}
+	for _, pkg := range []string{"fmt", "math", "google.golang.org/protobuf/encoding/protowire"} {
+		g.importStore.AddImport(pkg)
+	}
+	for _, m := range g.protoTasks {
+		def := ProtoTaskDef{
+			Name:         m.Name,
+			StructName:   g.tasksStruct,
+			ReceiverType: m.ReceiverType,
+			Request:      "_" + m.Name + "Request",
+			Response:     "_" + m.Name + "Response",
+			ReturnsError: m.ReturnsError(),
+		}
+		callArgs := gslice.Map(m.Params, func(p Param) string { return "_req." + exportedFieldName(p.Name) })
+		if m.IsVariadic {
+			callArgs[len(callArgs)-1] += "..."
+		}
+		def.CallArgs = strings.Join(callArgs, ", ")
+		var results []string
+		for i, r := range m.Results {
+			if r.IsError {
+				results = append(results, "_err")
+				continue
+			}
+			results = append(results, fmt.Sprintf("_r%d", i))
+			def.Assigns = append(def.Assigns, fmt.Sprintf("_resp.R%d = _r%d", i, i))
+		}
+		def.Results = strings.Join(results, ", ")
+		if err := protoTaskTmpl.Execute(buf, def); err != nil {
+			panic(err)
+		}
+	}
+	for _, msg := range g.proto.messages {
+		if err := protoMessageTmpl.Execute(buf, msg); err != nil {
+			panic(err)
+		}
+	}
+	buf.WriteString(protoHelpers)
+	if len(g.proto.imports()) > 0 {
+		g.importStore.AddImport("time")
+		buf.WriteString(protoTimeHelpers)
+	}
+}
+
+// imports returns the sorted files of the well-known messages used by the messages, see protoWellKnownTypes.
+func (s *protoSchema) imports() []string {
+	var files []string
+	for _, msg := range s.messages {
+		for _, f := range msg.Fields {
+			glue := f.glue
+			if f.elem != nil {
+				glue = f.elem.glue
+			}
+			for _, wk := range protoWellKnownTypes {
+				if glue == wk.glue && !gslice.Contains(files, wk.file) {
+					files = append(files, wk.file)
+				}
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// writeProtoFile generates the protobuf schema of the task messages into the scanned package,
+// after generating their Go glue with generateProtoTasks.
+func (g *Generator) writeProtoFile() error {
+	if len(g.protoTasks) == 0 || len(g.pkg.GoFiles) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	data := struct {
+		Header, Package, PkgPath, Service string
+		Imports                           []string
+		Messages                          []ProtoMessage
+		RPCs                              []string
+	}{Header: g.opts.generatedHeader(), Package: g.pkg.Name, PkgPath: g.pkg.PkgPath, Imports: g.proto.imports(), Messages: g.proto.messages}
+	if g.opts.GRPCGateway {
+		data.Service = g.tasksStruct
+		data.RPCs = gslice.Map(g.protoTasks, func(m Method) string { return m.Name })
+	}
+	err := protoFileTmpl.Execute(&buf, data)
+	if err != nil {
+		return err
+	}
+	outputFile := filepath.Join(filepath.Dir(g.pkg.GoFiles[0]), protoFileName)
+	return g.writeOutput(outputFile, buf.Bytes())
+}
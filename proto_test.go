@@ -0,0 +1,177 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateProto(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Move(p *Point, userID uint8, tags ...Label) (Path, error) { return nil, nil }
+
+func (Tasks) Lookup(m map[float64]int) int { return 0 }
+
+func (Tasks) Save(data []byte) {}
+
+func (Tasks) Load() (string, error) { return "", nil }
+
+func (Tasks) LoadProto() {}
+
+type Point struct {
+	X, Y  float64
+	Next  *Point
+	inner map[string]int
+}
+
+type Path []Point
+
+type Label string
+
+type SaveRequest struct{}
+`}
+	pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+	g := NewGenerator(Options{Proto: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	g.collectActorHandles()
+	code := g.generateCode()
+
+	require.Equal(t, []string{"Move", "LoadProto"}, gslice.Map(g.protoTasks, func(m Method) string { return m.Name }))
+	require.Contains(t, code, "func (_t Tasks) MoveProto(data []byte) ([]byte, error) {")
+	require.Contains(t, code, "_r0, _err := _t.Move(_req.P, _req.UserID, _req.Tags...)")
+	require.Contains(t, code, "_resp.R0 = _r0")
+	require.Contains(t, code, "func _consumeProtoMoveRequest(_b []byte, _v *_MoveRequest) error {")
+	require.Contains(t, code, "func _appendProtoPoint(_b []byte, _v *Point) []byte {")
+	require.Contains(t, code, "_v.Tags = append(_v.Tags, Label(_x))")
+	require.Contains(t, code, "_v.X = float64(math.Float64frombits(_x))")
+	require.Contains(t, code, "func _protoVarint(")
+	require.NotContains(t, code, "func (_t Tasks) LookupProto(") // float map key
+	require.NotContains(t, code, "func (_t Tasks) SaveProto(")   // conflicts with the SaveRequest type
+	require.NotContains(t, code, "func (_t Tasks) LoadProto(")   // declared
+
+	require.NoError(t, g.writeProtoFile())
+	schema, err := os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), protoFileName))
+	require.NoError(t, err)
+	require.Contains(t, string(schema), `option go_package = "example.com/mypkg";`)
+	require.Contains(t, string(schema), "message Point {\n  double x = 1;\n  double y = 2;\n  Point next = 3;\n}")
+	require.Contains(t, string(schema), "message MoveRequest {\n  Point p = 1;\n  uint32 user_id = 2;\n  repeated string tags = 3;\n}")
+	require.Contains(t, string(schema), "message MoveResponse {\n  repeated Point r0 = 1;\n}")
+
+	require.NotContains(t, generateFromSource(t, sources, Options{}), "MoveProto")
+}
+
+func TestProtoTimes(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+type Event struct {
+	At    time.Time
+	Every *time.Duration
+	Seen  []time.Time
+}
+
+func (Tasks) Delay(e Event, d time.Duration) (time.Time, error) { return e.At.Add(d), nil }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{Proto: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	code := g.generateCode()
+
+	require.Contains(t, code, "_b = protowire.AppendBytes(_b, _appendProto_Timestamp(nil, &_v.At))")
+	require.Contains(t, code, "_v.Every = new(time.Duration)\nreturn _consumeProto_Duration(_x, _v.Every)")
+	require.Contains(t, code, "var _e time.Time\n_err := _consumeProto_Timestamp(_x, &_e)")
+	require.Contains(t, code, "func _consumeProtoSecondsNanos(b []byte) (secs, nanos int64, err error) {")
+
+	require.NoError(t, g.writeProtoFile())
+	schema, err := os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), protoFileName))
+	require.NoError(t, err)
+	require.Contains(t, string(schema), "package mypkg;\n\nimport \"google/protobuf/duration.proto\";\nimport \"google/protobuf/timestamp.proto\";\n\noption")
+	require.Contains(t, string(schema), "message Event {\n  google.protobuf.Timestamp at = 1;\n  google.protobuf.Duration every = 2;\n  repeated google.protobuf.Timestamp seen = 3;\n}")
+	require.Contains(t, string(schema), "message DelayRequest {\n  Event e = 1;\n  google.protobuf.Duration d = 2;\n}")
+}
+
+func TestProtoMaps(t *testing.T) {
+	var warnings []string
+	opts := Options{Proto: true}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "skip-proto" {
+			warnings = append(warnings, d.Message)
+		}
+	})
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+type Label string
+
+type Point struct{ X, Y float64 }
+
+type Index struct {
+	Counts map[Label]int
+	Points map[int32]*Point
+	Seen   map[string]time.Time
+}
+
+func (Tasks) Merge(idx Index, flags map[bool]string) (map[uint64]Point, error) { return nil, nil }
+
+func (Tasks) Group(m map[string][]int) {}
+
+func (Tasks) Nest(m []map[string]int) {}
+`}, "example.com/mypkg")
+	g := NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	code := g.generateCode()
+
+	require.Equal(t, []string{"Merge"}, gslice.Map(g.protoTasks, func(m Method) string { return m.Name }))
+	require.Contains(t, code, "for _k, _e := range _v.Counts {\nvar _m []byte\n_m = protowire.AppendTag(_m, 1, protowire.BytesType)\n_m = protowire.AppendString(_m, string(_k))")
+	require.Contains(t, code, "_m = protowire.AppendTag(_m, 2, protowire.VarintType)\n_m = protowire.AppendVarint(_m, uint64(_e))")
+	require.Contains(t, code, "var _k Label\nvar _e int\nif _err := _protoEntry(_x, ")
+	require.Contains(t, code, "_v.Points = make(map[int32]*Point)")
+	require.Contains(t, code, "_e = new(Point)\nreturn _consumeProtoPoint(_x, _e)")
+	require.Contains(t, code, "func _protoEntry(b []byte, consume func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {")
+	require.Equal(t, []string{
+		"Skip protobuf variant of Group: param m: map value type []int is not supported, a repeated or map type can't be a map value",
+		"Skip protobuf variant of Nest: param m: nested repeated type []map[string]int is not supported",
+	}, warnings)
+
+	require.NoError(t, g.writeProtoFile())
+	schema, err := os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), protoFileName))
+	require.NoError(t, err)
+	require.Contains(t, string(schema), "import \"google/protobuf/timestamp.proto\";")
+	require.Contains(t, string(schema), "message Index {\n  map<string, int64> counts = 1;\n  map<int32, Point> points = 2;\n  map<string, google.protobuf.Timestamp> seen = 3;\n}")
+	require.Contains(t, string(schema), "message MergeRequest {\n  Index idx = 1;\n  map<bool, string> flags = 2;\n}")
+	require.Contains(t, string(schema), "message MergeResponse {\n  map<uint64, Point> r0 = 1;\n}")
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"a":         "a",
+		"userID":    "user_id",
+		"UserID":    "user_id",
+		"HTTPProxy": "http_proxy",
+		"r0":        "r0",
+		"max_size":  "max_size",
+	} {
+		require.Equal(t, want, snakeCase(name), name)
+	}
+}
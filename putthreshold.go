@@ -0,0 +1,108 @@
+package goraygen
+
+import (
+	"fmt"
+	"go/types"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+	//goray:put-threshold 1Mi
+	func (Tasks) Checksum(data []byte) uint32
+
+generates the wrapper putting the []byte args of at least 1 MiB in the object store, passing their references:
+
+	return NewRemoteFunc[*Future1[uint32]]("Checksum", _putLarge(1048576, []any{data}))
+
+so the bytes are sent once to the object store instead of inlined in the task spec, e.g. for the retries.
+*/
+
+// putHelpers is shared by the wrappers with the //goray:put-threshold directive, generated once per file.
+const putHelpers = `
+// _putLarge replaces the []byte args of at least threshold bytes with their references in the object store,
+// the args which can't be put are passed as is.
+func _putLarge(threshold int, args []any) []any {
+	for i, arg := range args {
+		if b, ok := arg.([]byte); ok && len(b) >= threshold {
+			if obj, err := ray.Put(b); err == nil {
+				args[i] = obj
+			}
+		}
+	}
+	return args
+}
+`
+
+const putThresholdDirective = "put-threshold"
+
+// putThreshold returns the size in bytes of the //goray:put-threshold directive of the method, whose wrapper function
+// is named name, false if there is none or it's invalid.
+func (g *Generator) putThreshold(name string, m Method) (int64, bool) {
+	d, ok := m.Directive(putThresholdDirective)
+	if !ok {
+		return 0, false
+	}
+	if len(d.Args) != 1 || len(d.Params) > 0 {
+		g.warnAt(g.directivePos(m, putThresholdDirective), "invalid-directive", "%s: invalid //goray:put-threshold, it should be a size like `//goray:put-threshold 1Mi`", name)
+		return 0, false
+	}
+	threshold, err := analysis.ParseMemory(d.Args[0])
+	if err != nil {
+		g.warnAt(g.directivePos(m, putThresholdDirective), "invalid-directive", "%s: invalid //goray:put-threshold size %q, it should be a number of bytes with an optional unit like 1Mi or 500K", name, d.Args[0])
+		return 0, false
+	}
+	if !hasByteSliceParam(m) {
+		g.warnAt(g.directivePos(m, putThresholdDirective), "invalid-directive", "%s: //goray:put-threshold is ignored, no param is a []byte", name)
+		return 0, false
+	}
+	return threshold, true
+}
+
+// putLargeArgs passes the args of the wrapper of the method through _putLarge, if the method has the
+// //goray:put-threshold directive.
+func (g *Generator) putLargeArgs(d *FuncDef, name string, m Method) {
+	if threshold, ok := g.putThreshold(name, m); ok {
+		d.ArgsStatement = fmt.Sprintf("_putLarge(%d, %s)", threshold, d.ArgsStatement)
+	}
+}
+
+// hasPutThreshold reports whether any task, actor factory or actor method has the //goray:put-threshold directive.
+func (g *Generator) hasPutThreshold() bool {
+	methods := append(append([]Method{}, g.tasks...), g.actorFactories...)
+	for _, factory := range g.actorFactories {
+		methods = append(methods, g.actor2Methods[factory.Name]...)
+	}
+	for _, m := range methods {
+		if _, ok := m.Directive(putThresholdDirective); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasByteSliceParam reports whether a param of the method is a []byte, or a variadic ...[]byte.
+// The params of named byte slice types aren't put, their references having another type.
+func hasByteSliceParam(m Method) bool {
+	bytesType := types.NewSlice(types.Typ[types.Byte])
+	for i, p := range m.Params {
+		typ := types.Unalias(p.GoType)
+		if s, ok := typ.(*types.Slice); ok && m.IsVariadic && i == len(m.Params)-1 {
+			typ = types.Unalias(s.Elem())
+		}
+		if types.Identical(typ, bytesType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isByteSlice reports whether the underlying type is []byte.
+func isByteSlice(typ types.Type) bool {
+	s, ok := typ.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	elem, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && elem.Kind() == types.Byte
+}
@@ -0,0 +1,62 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutThreshold(t *testing.T) {
+	var warnings []string
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "invalid-directive" {
+			warnings = append(warnings, d.Message)
+		}
+	})
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type Blob []byte
+
+//goray:put-threshold 1Mi
+func (Tasks) Checksum(name string, data []byte) uint32 { return 0 }
+
+//goray:put-threshold 64K
+func (Tasks) Concat(chunks ...[]byte) []byte { return nil }
+
+//goray:put-threshold 1Mi
+func (Tasks) Store(b Blob) {}
+
+// rayactors
+type Actors struct{}
+
+//goray:put-threshold 1Mi
+func (Actors) Cache(seed []byte) *Cache { return &Cache{} }
+
+type Cache struct{}
+
+//goray:put-threshold 1X
+func (c *Cache) Add(key string, value []byte) {}
+`}, opts)
+
+	require.Contains(t, code, `return NewRemoteFunc[*Future1[uint32]]("Checksum", _putLarge(1048576, []any{name, data}))`)
+	require.Contains(t, code, `return NewRemoteFunc[*Future1[[]byte]]("Concat", _putLarge(64000, ExpandArgs([]any{}, chunks)))`)
+	require.Contains(t, code, `return NewRemoteActor[ActorCache]("Cache", _putLarge(1048576, []any{seed}))`)
+	require.Contains(t, code, `return NewRemoteFunc[*Future0]("Add", []any{key, value}, &_actor.ActorHandle)`)
+	require.Contains(t, code, "func _putLarge(threshold int, args []any) []any {")
+	require.Equal(t, []string{
+		"Store: //goray:put-threshold is ignored, no param is a []byte",
+		`Cache_Add: invalid //goray:put-threshold size "1X", it should be a number of bytes with an optional unit like 1Mi or 500K`,
+	}, warnings)
+
+	require.NotContains(t, generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Checksum(data []byte) uint32 { return 0 }
+`}, Options{}), "_putLarge")
+}
@@ -0,0 +1,273 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+)
+
+// Lang is the language of the generated wrappers, see the -lang flag.
+type Lang string
+
+const (
+	LangGo     Lang = "go"
+	LangPython Lang = "python" // stubs calling the Go tasks and actors through the cross-language API of go-ray
+)
+
+// ParseLang parses the -lang flag value, empty means LangGo.
+func ParseLang(s string) (Lang, error) {
+	switch l := Lang(s); l {
+	case "":
+		return LangGo, nil
+	case LangGo, LangPython:
+		return l, nil
+	}
+	return "", fmt.Errorf("invalid lang %q, expect %s or %s", s, LangGo, LangPython)
+}
+
+/*
+With -lang=python, the wrappers are Python stubs with the names of the Go wrappers:
+
+	ref = Divide(16, 5, num_cpus=2)
+	res, remainder = ray.get(ref)
+
+	counter = NewCounter(1)
+	ray.get(counter.Incr(2))
+*/
+const pythonStubsTpl = `# {{.Header}}
+#
+# This file was generated by goray.
+# It contains the Python stubs of the ray tasks and actors of the Go package {{.PkgPath}},
+# calling them through the cross-language API of go-ray. The ray options are passed as keyword arguments.
+#
+# To regenerate this file, run:
+#
+#	goraygen -lang=python <package-path>
+from __future__ import annotations
+
+from typing import Any, Optional
+
+import goray
+import ray
+
+
+def _task(name: str) -> Any:
+    return goray.golang_task(name)
+
+
+def _actor_class(name: str) -> Any:
+    return goray.golang_actor_class(name)
+{{- range .Tasks}}
+
+
+def {{.Name}}({{.Params}}) -> ray.ObjectRef[{{.ResultType}}]:
+    """{{.Doc}}"""
+    return _task("{{.TaskName}}").options(**ray_options).remote({{.CallArgs}})
+{{- end}}
+{{- range .Actors}}
+
+
+class Actor{{.Name}}:
+    """{{.Doc}}"""
+
+    def __init__(self, handle: Any) -> None:
+        self.handle = handle
+    {{- range .Methods}}
+
+    def {{.Name}}({{.Params}}) -> ray.ObjectRef[{{.ResultType}}]:
+        """{{.Doc}}"""
+        return self.handle.{{.TaskName}}.options(**ray_options).remote({{.CallArgs}})
+    {{- end}}
+
+
+def New{{.Name}}({{.Factory.Params}}) -> Actor{{.Name}}:
+    """{{.Factory.Doc}}"""
+    return Actor{{.Name}}(_actor_class("{{.Name}}").options(**ray_options).remote({{.Factory.CallArgs}}))
+{{- end}}
+`
+
+var pythonStubsTmpl = template.Must(template.New("pythonStubs").Parse(pythonStubsTpl))
+
+// PythonFuncDef is a Python stub function or method, the template data of pythonStubsTpl.
+type PythonFuncDef struct {
+	Name       string
+	TaskName   string
+	Params     string // including the **ray_options keyword arguments
+	CallArgs   string
+	ResultType string
+	Doc        string
+}
+
+// PythonActorDef is a Python actor handle class with its factory function.
+type PythonActorDef struct {
+	Name    string
+	Doc     string
+	Factory PythonFuncDef
+	Methods []PythonFuncDef
+}
+
+// pythonKeywords are the reserved words of Python, not reserved in Go.
+var pythonKeywords = []string{
+	"False", "None", "True", "and", "as", "assert", "async", "await", "class", "def", "del", "elif", "except",
+	"finally", "from", "global", "in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise", "try", "while", "with", "yield",
+}
+
+// pythonName returns the Python name of the Go param at the index.
+func pythonName(name string, index int) string {
+	if name == "_" {
+		return fmt.Sprintf("arg%d", index)
+	}
+	if gslice.Contains(pythonKeywords, name) {
+		return name + "_"
+	}
+	return name
+}
+
+// pythonType returns the Python type hint of the Go type, as the value decoded by the Python side.
+func pythonType(typ types.Type) string {
+	if named, ok := typ.(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" {
+		return "Any"
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		info := t.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return "bool"
+		case info&types.IsInteger != 0:
+			return "int"
+		case info&types.IsFloat != 0:
+			return "float"
+		case info&types.IsString != 0:
+			return "str"
+		}
+	case *types.Pointer:
+		return "Optional[" + pythonType(t.Elem()) + "]"
+	case *types.Slice:
+		if elem, ok := t.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Byte {
+			return "bytes"
+		}
+		return "list[" + pythonType(t.Elem()) + "]"
+	case *types.Array:
+		return "list[" + pythonType(t.Elem()) + "]"
+	case *types.Map:
+		return "dict[" + pythonType(t.Key()) + ", " + pythonType(t.Elem()) + "]"
+	case *types.Struct:
+		return "dict[str, Any]"
+	}
+	return "Any"
+}
+
+// pythonFunc returns the Python stub of the Go method, called with the name.
+func pythonFunc(m Method, name string, self bool) PythonFuncDef {
+	var params, args []string
+	indent := 1
+	if self {
+		params = append(params, "self")
+		indent = 2
+	}
+	if m.TakesContext() { // called with the empty metadata of the ctx, see contextTask
+		args = append(args, "{}")
+	}
+	for i, p := range m.Params {
+		if i == 0 && m.TakesContext() {
+			continue
+		}
+		pyName := pythonName(p.Name, i)
+		if m.IsVariadic && i == len(m.Params)-1 {
+			params = append(params, fmt.Sprintf("*%s: %s", pyName, pythonType(p.GoType.(*types.Slice).Elem())))
+			args = append(args, "*"+pyName)
+			continue
+		}
+		params = append(params, fmt.Sprintf("%s: %s", pyName, pythonType(p.GoType)))
+		args = append(args, pyName)
+	}
+	params = append(params, "**ray_options: Any")
+	var results []string
+	for _, r := range m.Results {
+		if !r.IsError {
+			results = append(results, pythonType(r.GoType))
+		}
+	}
+	resultType := "None"
+	switch len(results) {
+	case 0:
+	case 1:
+		resultType = results[0]
+	default:
+		resultType = "tuple[" + strings.Join(results, ", ") + "]"
+	}
+	doc := docText(docWithoutDirectives(m.Doc))
+	if doc != "" {
+		doc += "\n\n"
+	}
+	doc += fmt.Sprintf("Calls the Go method %s.", m.String())
+	if m.ReturnsError() {
+		doc += " Its error result is raised by ray.get."
+	}
+	return PythonFuncDef{
+		Name:       name,
+		TaskName:   name,
+		Params:     strings.Join(params, ", "),
+		CallArgs:   strings.Join(args, ", "),
+		ResultType: resultType,
+		Doc:        pythonDocString(doc, indent),
+	}
+}
+
+// pythonDocString escapes the quotes ending the docstring, and indents the text by the indentation levels of the docstring.
+func pythonDocString(text string, indent int) string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, `\`, `\\`), `"""`, `\"\"\"`)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 && line != "" {
+			lines[i] = strings.Repeat("    ", indent) + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// generatePythonStubs generates the Python stubs of the tasks and actors.
+func (g *Generator) generatePythonStubs() string {
+	g.prepareTaskVersions()
+	var tasks []PythonFuncDef
+	for _, m := range g.tasks {
+		def := pythonFunc(m, m.Name, false)
+		def.TaskName = g.taskName(m)
+		tasks = append(tasks, def)
+	}
+	var actors []PythonActorDef
+	for _, factory := range g.actorFactories {
+		actor := PythonActorDef{Name: factory.Name, Factory: pythonFunc(factory, "New"+factory.Name, false)}
+		actor.Doc = pythonDocString(fmt.Sprintf("Handle of the Go actor %s, created by New%s.", factory.Name, factory.Name), 1)
+		actor.Factory.Doc = pythonDocString(fmt.Sprintf("Creates the Go actor %s by %s.", factory.Name, factory.String()), 1)
+		for _, am := range g.actor2Methods[factory.Name] {
+			def := pythonFunc(am, am.Name, true)
+			def.TaskName = remoteName(am)
+			actor.Methods = append(actor.Methods, def)
+		}
+		actors = append(actors, actor)
+	}
+	var buf bytes.Buffer
+	err := pythonStubsTmpl.Execute(&buf, struct {
+		Header, PkgPath string
+		Tasks           []PythonFuncDef
+		Actors          []PythonActorDef
+	}{g.opts.generatedHeader(), g.pkg.PkgPath, tasks, actors})
+	if err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
+
+// writePythonStubs generates the Python stubs into outputDir, next to where the Go wrappers would be.
+func (g *Generator) writePythonStubs(outputDir string) error {
+	structs := append(g.outputStructs(), "")
+	outputFile := filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(g.outputPath(structs[0])), ".go")+".py")
+	return g.writeOutput(outputFile, []byte(g.generatePythonStubs()))
+}
@@ -0,0 +1,77 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLang(t *testing.T) {
+	for s, want := range map[string]Lang{"": LangGo, "go": LangGo, "python": LangPython} {
+		lang, err := ParseLang(s)
+		require.NoError(t, err)
+		require.Equal(t, want, lang)
+	}
+	_, err := ParseLang("rust")
+	require.Error(t, err)
+}
+
+func TestGeneratePythonStubs(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Divide divides.
+//
+//goray:timeout 5s
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Notify(from string, to ...*Point) error { return nil }
+
+func (Tasks) Load(_ int, data []byte) map[string][]float64 { return nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter(n int) *Counter { return &Counter{} }
+
+type Counter struct{}
+
+// Incr says """hi""".
+func (c *Counter) Incr(n uint8) bool { return true }
+
+type Point struct{ X, Y int }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{Lang: LangPython, TaskVersion: 2})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	code := g.generatePythonStubs()
+
+	require.Contains(t, code, `# It contains the Python stubs of the ray tasks and actors of the Go package example.com/mypkg,`)
+	require.Contains(t, code, `
+def Divide(a: int, b: int, **ray_options: Any) -> ray.ObjectRef[tuple[int, int]]:
+    """Divide divides.
+
+    Calls the Go method Divide(a int64, b int64) (int64, int64)."""
+    return _task("Divide_v2").options(**ray_options).remote(a, b)
+`)
+	require.Contains(t, code, `def Notify(from_: str, *to: Optional[dict[str, Any]], **ray_options: Any) -> ray.ObjectRef[None]:`)
+	require.Contains(t, code, `Its error result is raised by ray.get.`)
+	require.Contains(t, code, `.remote(from_, *to)`)
+	require.Contains(t, code, `def Load(arg0: int, data: bytes, **ray_options: Any) -> ray.ObjectRef[dict[str, list[float]]]:`)
+	require.Contains(t, code, `
+class ActorCounter:
+    """Handle of the Go actor Counter, created by NewCounter."""
+`)
+	require.Contains(t, code, `
+    def Incr(self, n: int, **ray_options: Any) -> ray.ObjectRef[bool]:
+        """Incr says \"\"\"hi\"\"\".
+
+        Calls the Go method Incr(n uint8) (bool)."""
+        return self.handle.Incr.options(**ray_options).remote(n)
+`)
+	require.Contains(t, code, `def NewCounter(n: int, **ray_options: Any) -> ActorCounter:`)
+}
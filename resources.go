@@ -0,0 +1,164 @@
+package goraygen
+
+import (
+	"bytes"
+	"sort"
+	"text/template"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+	//goray:resources cpu=2 gpu=1 memory=4Gi ssd=1
+	func (Tasks) Train(epochs int) float64
+
+generates:
+
+	const (
+		TrainCPUs   = 2
+		TrainGPUs   = 1
+		TrainMemory = 4294967296 // 4Gi
+	)
+
+	var TrainResources = RayResources{CPUs: TrainCPUs, GPUs: TrainGPUs, Memory: TrainMemory, Custom: map[string]float64{"ssd": 1}}
+
+to use as Train(10).Remote(ResourceOptions(ray.Option, TrainResources)...)
+*/
+const resourcesTpl = `
+{{- if or .CPUs .GPUs .Memory}}
+// Resource requirements of [{{.Name}}], see //goray:resources.
+const (
+	{{- if .CPUs}}
+	{{.Name}}CPUs = {{.CPUs}}
+	{{- end}}
+	{{- if .GPUs}}
+	{{.Name}}GPUs = {{.GPUs}}
+	{{- end}}
+	{{- if .Memory}}
+	{{.Name}}Memory = {{.Memory}} // {{.MemoryText}}
+	{{- end}}
+)
+{{- end}}
+
+// {{.Name}}Resources are the resource requirements of [{{.Name}}], pass ResourceOptions(ray.Option, {{.Name}}Resources) to Remote.
+var {{.Name}}Resources = RayResources{
+	{{- if .CPUs}}CPUs: {{.Name}}CPUs, {{end}}
+	{{- if .GPUs}}GPUs: {{.Name}}GPUs, {{end}}
+	{{- if .Memory}}Memory: {{.Name}}Memory, {{end}}
+	{{- if .Custom}}Custom: map[string]float64{ {{- range $i, $r := .Custom}}{{if $i}}, {{end}}{{printf "%q" $r.Name}}: {{$r.Amount}}{{end}}}{{end -}}
+}
+`
+
+// resourcesHelpers is shared by the resource requirements of all methods, generated once per file.
+const resourcesHelpers = `
+// RayResources are the resource requirements of a ray task or actor.
+type RayResources struct {
+	CPUs   float64            // ray option "num_cpus"
+	GPUs   float64            // ray option "num_gpus"
+	Memory int64              // in bytes, ray option "memory"
+	Custom map[string]float64 // custom resources, ray option "resources"
+}
+
+// ResourceOptions converts the resource requirements into ray options with newOption, i.e. ray.Option:
+//
+//	Train(10).Remote(ResourceOptions(ray.Option, TrainResources)...)
+func ResourceOptions[T any](newOption func(string, any) T, r RayResources) []T {
+	var opts []T
+	if r.CPUs > 0 {
+		opts = append(opts, newOption("num_cpus", r.CPUs))
+	}
+	if r.GPUs > 0 {
+		opts = append(opts, newOption("num_gpus", r.GPUs))
+	}
+	if r.Memory > 0 {
+		opts = append(opts, newOption("memory", r.Memory))
+	}
+	if len(r.Custom) > 0 {
+		opts = append(opts, newOption("resources", r.Custom))
+	}
+	return opts
+}
+`
+
+var resourcesTmpl = template.Must(template.New("resources").Parse(resourcesTpl))
+
+const resourcesDirective = "resources"
+
+// ResourcesDef is the template data of resourcesTpl.
+type ResourcesDef struct {
+	Name       string // wrapper function name, e.g. "Train" or the actor name for actor factories
+	CPUs       string // number literals, empty if not required
+	GPUs       string
+	Memory     int64
+	MemoryText string // the memory as written in the directive, e.g. "4Gi"
+	Custom     []CustomResource
+}
+
+// CustomResource is a custom resource requirement.
+type CustomResource struct {
+	Name   string
+	Amount string
+}
+
+// resourcesDef parses the //goray:resources directive of the method, false if there is none or it's invalid.
+func (g *Generator) resourcesDef(name string, m Method) (ResourcesDef, bool) {
+	d, ok := m.Directive(resourcesDirective)
+	if !ok {
+		return ResourcesDef{}, false
+	}
+	def := ResourcesDef{Name: name}
+	keys := make([]string, 0, len(d.Params))
+	for key := range d.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // deterministic order of the custom resources
+	for _, key := range keys {
+		var err error
+		switch value := d.Params[key]; key {
+		case "cpu":
+			def.CPUs, err = analysis.ParseAmount(value)
+		case "gpu":
+			def.GPUs, err = analysis.ParseAmount(value)
+		case "memory":
+			def.Memory, err = analysis.ParseMemory(value)
+			def.MemoryText = value
+		default:
+			var amount string
+			amount, err = analysis.ParseAmount(value)
+			def.Custom = append(def.Custom, CustomResource{Name: key, Amount: amount})
+		}
+		if err != nil {
+			g.warnAt(g.directivePos(m, resourcesDirective), "invalid-directive", "%s: invalid //goray:resources %s: %v", name, key, err)
+			return ResourcesDef{}, false
+		}
+	}
+	if len(keys) == 0 || len(d.Args) > 0 {
+		g.warnAt(g.directivePos(m, resourcesDirective), "invalid-directive", "%s: invalid //goray:resources, it should be like `//goray:resources cpu=2 gpu=1 memory=4Gi`", name)
+		return ResourcesDef{}, false
+	}
+	return def, true
+}
+
+// generateResources generates the resource requirements of the task or actor factory with the //goray:resources directive,
+// whose wrapper function (or actor) is named name.
+func (g *Generator) generateResources(buf *bytes.Buffer, name string, m Method) {
+	def, ok := g.resourcesDef(name, m)
+	if !ok {
+		return
+	}
+	if err := resourcesTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// hasResources reports whether any task or actor factory has the //goray:resources directive.
+func (g *Generator) hasResources() bool {
+	for _, methods := range [][]Method{g.tasks, g.actorFactories} {
+		for _, m := range methods {
+			if _, ok := m.Directive(resourcesDirective); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateResources(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+// Train trains.
+//
+//goray:resources cpu=2 gpu=0.5 memory=4Gi
+func (Tasks) Train(epochs int) float64 { return 0 }
+
+//goray:resources ssd=1 accel=0.25
+func (Tasks) Store(data []byte) {}
+
+//goray:resources memory=lots
+func (Tasks) Invalid() {}
+
+// rayactors
+type Actors struct{}
+
+//goray:resources memory=512M
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{}
+`}, Options{})
+
+	require.Contains(t, code, "const (\n\tTrainCPUs   = 2\n\tTrainGPUs   = 0.5\n\tTrainMemory = 4294967296 // 4Gi\n)")
+	require.Contains(t, code, "var TrainResources = RayResources{CPUs: TrainCPUs, GPUs: TrainGPUs, Memory: TrainMemory}")
+	require.Contains(t, code, `var StoreResources = RayResources{Custom: map[string]float64{"accel": 0.25, "ssd": 1}}`)
+	require.NotContains(t, code, "StoreCPUs")
+	require.NotContains(t, code, "InvalidResources")
+	require.Contains(t, code, "CounterMemory = 512000000 // 512M")
+	require.Contains(t, code, "func ResourceOptions[T any](newOption func(string, any) T, r RayResources) []T {")
+}
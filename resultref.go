@@ -0,0 +1,114 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+/*
+	type DivideResultRef struct {
+		*Future2[int64, int64]
+	}
+
+func (r DivideResultRef) Get(ctx context.Context) (int64, int64, error)
+func (r DivideResultRef) Wait(ctx context.Context) error
+
+For methods following the error-last convention, the returned error is surfaced from Get instead of being a result:
+
+	func (r OpenResultRef) Get(ctx context.Context) (*File, error)
+*/
+const resultRefTpl = `
+// {{.Name}}ResultRef is the typed reference to the result of a remote [{{.Name}}] call.
+type {{.Name}}ResultRef struct {
+	*{{.FutureType}}
+}
+
+// New{{.Name}}ResultRef wraps the future returned by {{.Name}}(...).Remote().
+func New{{.Name}}ResultRef(future *{{.FutureType}}) {{.Name}}ResultRef {
+	return {{.Name}}ResultRef{future}
+}
+
+// Get waits for the result of the remote call until it's ready or ctx is done.
+func (r {{.Name}}ResultRef) Get(ctx context.Context) ({{range .ResTypes}}{{.}}, {{end}}error) {
+	type result struct {
+		{{range $i, $t := .ResTypes}}r{{$i}} {{$t}}
+		{{end}}err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		var res result
+		{{- if .ReturnsError}}
+		var taskErr error
+		{{range $i, $t := .ResTypes}}res.r{{$i}}, {{end}}taskErr, res.err = r.{{.FutureField}}.Get()
+		if res.err == nil {
+			res.err = taskErr
+		}
+		{{- else}}
+		{{range $i, $t := .ResTypes}}res.r{{$i}}, {{end}}res.err = r.{{.FutureField}}.Get()
+		{{- end}}
+		ch <- res
+	}()
+	select {
+	case res := <-ch:
+		return {{range $i, $t := .ResTypes}}res.r{{$i}}, {{end}}res.err
+	case <-ctx.Done():
+		{{- if .ResTypes}}
+		var res result
+		{{- end}}
+		return {{range $i, $t := .ResTypes}}res.r{{$i}}, {{end}}ctx.Err()
+	}
+}
+
+// Wait blocks until the remote call finishes or ctx is done, the results are discarded.
+func (r {{.Name}}ResultRef) Wait(ctx context.Context) error {
+	{{range .ResTypes}}_, {{end}}err := r.Get(ctx)
+	return err
+}
+`
+
+var resultRefTmpl = template.Must(template.New("resultRef").Parse(resultRefTpl))
+
+// ResultRefDef is the template data of resultRefTpl.
+type ResultRefDef struct {
+	Name        string   // wrapper function name, e.g. "Divide" or "Counter_Incr"
+	FutureType  string   // e.g. "Future2[int64, int64]"
+	FutureField string   // embedded field name, e.g. "Future2"
+	ResTypes    []string // result types returned by Get, without the error of the error-last convention
+	// ReturnsError is set if the last result of the method is an error, which is merged into the error of Get.
+	ReturnsError bool
+}
+
+// generateResultRef generates the typed result reference of the method, whose wrapper function is named name.
+func generateResultRef(buf *bytes.Buffer, name string, method Method) {
+	resTypes := make([]string, len(method.Results))
+	for i, res := range method.Results {
+		resTypes[i] = res.Type
+	}
+	def := ResultRefDef{
+		Name:         name,
+		FutureType:   futureType(method),
+		FutureField:  fmt.Sprintf("Future%d", len(resTypes)),
+		ResTypes:     resTypes,
+		ReturnsError: method.ReturnsError(),
+	}
+	if def.ReturnsError {
+		def.ResTypes = resTypes[:len(resTypes)-1]
+	}
+	if err := resultRefTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// futureType returns the type of the future of a remote call of the method, e.g. "Future2[int64, int64]".
+func futureType(method Method) string {
+	if len(method.Results) == 0 { // Future0 has no generic type
+		return "Future0"
+	}
+	resTypes := make([]string, len(method.Results))
+	for i, res := range method.Results {
+		resTypes[i] = res.Type
+	}
+	return fmt.Sprintf("Future%d[%s]", len(resTypes), strings.Join(resTypes, ", "))
+}
@@ -0,0 +1,110 @@
+package goraygen
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateResultRef(t *testing.T) {
+	for _, tc := range []struct {
+		method     Method
+		expectGet  string
+		expectBody string
+	}{
+		{
+			method:     Method{Name: "Divide", Results: []Result{{Type: "int64"}, {Type: "int64"}}},
+			expectGet:  "func (r DivideResultRef) Get(ctx context.Context) (int64, int64, error) {",
+			expectBody: "\t\tvar res result\n\t\tres.r0, res.r1, res.err = r.Future2.Get()\n\t\tch <- res\n",
+		},
+		{
+			method:    Method{Name: "Open", Results: []Result{{Type: "*os.File"}, {Type: "error", IsError: true}}},
+			expectGet: "func (r OpenResultRef) Get(ctx context.Context) (*os.File, error) {",
+			// the error of the task is returned if the remote call succeeded
+			expectBody: "\t\tvar taskErr error\n\t\tres.r0, taskErr, res.err = r.Future2.Get()\n" +
+				"\t\tif res.err == nil {\n\t\t\tres.err = taskErr\n\t\t}\n\t\tch <- res\n",
+		},
+		{
+			method:     Method{Name: "Check", Results: []Result{{Type: "error", IsError: true}}},
+			expectGet:  "func (r CheckResultRef) Get(ctx context.Context) error {",
+			expectBody: "\t\tvar taskErr error\n\t\ttaskErr, res.err = r.Future1.Get()\n\t\tif res.err == nil {\n\t\t\tres.err = taskErr\n\t\t}\n",
+		},
+		{
+			method:     Method{Name: "Ping"},
+			expectGet:  "func (r PingResultRef) Get(ctx context.Context) error {",
+			expectBody: "\t\tvar res result\n\t\tres.err = r.Future0.Get()\n",
+		},
+	} {
+		var buf bytes.Buffer
+		buf.WriteString("package demo\n")
+		generateResultRef(&buf, tc.method.Name, tc.method)
+		code, err := format.Source(buf.Bytes())
+		require.NoError(t, err, buf.String())
+		require.Contains(t, string(code), tc.expectGet)
+		require.Contains(t, string(code), tc.expectBody)
+	}
+}
+
+func TestGenerateErrorLastVariants(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Open(name string) (int, error) {
+	if name == "" {
+		return 0, openError{}
+	}
+	return len(name), nil
+}
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{}
+
+func (c *Counter) Check() error { return nil }
+
+type openError struct{}
+
+func (openError) Error() string { return "no name" }
+`}
+	opts := Options{LocalVariants: true, TimeoutVariants: true, ResultRefs: true}
+	code := generateFromSource(t, sources, opts)
+
+	// the error of the task is the error of the call, not one of its results
+	require.Contains(t, code, "func OpenLocal(name string) (_r0 int, _err error) {")
+	require.Contains(t, code, "\t_r0, _err = new(Tasks).Open(name)\n\treturn _r0, _err\n}")
+	require.Contains(t, code, "func OpenCall(name string) (int, error) {")
+	require.Contains(t, code, "\t_r0, _taskErr, _err := Open(name).Remote().Get()\n\tif _err == nil {\n\t\t_err = _taskErr\n\t}\n")
+	require.Contains(t, code, "func OpenWithTimeout(_timeout time.Duration, name string) (int, error) {")
+	require.Contains(t, code, "\t\tres.r0, taskErr, res.err = _future.Get()\n\t\tif res.err == nil {\n\t\t\tres.err = taskErr\n\t\t}\n")
+	require.Contains(t, code, "func Counter_CheckWithTimeout(_timeout time.Duration, _actor *ActorCounter) error {")
+	require.Contains(t, code, "\t\ttaskErr, res.err = _future.Get()\n\t\tif res.err == nil {\n\t\t\tres.err = taskErr\n\t\t}\n")
+	require.NotContains(t, code, "(int, error, error)")
+
+	dir := buildGenerated(t, sources, opts)
+	test := `package mypkg
+
+import "testing"
+
+func TestOpenLocal(t *testing.T) {
+	if n, err := OpenLocal(""); n != 0 || err == nil || err.Error() != "no name" {
+		t.Fatalf("OpenLocal(%q) = %v, %v", "", n, err)
+	}
+	if n, err := OpenLocal("a"); n != 1 || err != nil {
+		t.Fatalf("OpenLocal(%q) = %v, %v", "a", n, err)
+	}
+}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "local_test.go"), []byte(test), 0o644))
+	out, err := exec.Command("go", "test", "-C", dir, "./...").CombinedOutput()
+	require.NoError(t, err, "%s", out)
+}
@@ -0,0 +1,81 @@
+package goraygen
+
+import (
+	"bytes"
+	"go/types"
+	"text/template"
+)
+
+/*
+With -result-structs, the results of a method returning more than one non-error value are gathered in its FooOutput
+struct (see outputStructTpl), instead of being destructured from the future by position:
+
+	func GetStatOutput(future *Future3[int64, bool, error]) (StatOutput, error)
+
+	output, err := GetStatOutput(Stat("a.txt").Remote())
+*/
+const resultStructTpl = `
+// Get{{.Name}}Output waits for the results of the remote [{{.Name}}] call into a {{.Name}}Output.
+{{- if .ReturnsError}}
+// The error returned by the call is returned as the error.
+{{- end}}
+func Get{{.Name}}Output(future *{{.FutureType}}) ({{.Name}}Output, error) {
+	var output {{.Name}}Output
+	var err error
+	{{- if .ReturnsError}}
+	var taskErr error
+	{{.Targets "output"}}, taskErr, err = future.Get()
+	if err == nil {
+		err = taskErr
+	}
+	{{- else}}
+	{{.Targets "output"}}, err = future.Get()
+	{{- end}}
+	return output, err
+}
+`
+
+var resultStructTmpl = template.Must(template.New("resultStruct").Parse(resultStructTpl))
+
+// ResultStructDef is the template data of resultStructTpl.
+type ResultStructDef struct {
+	OutputDef
+	FutureType   string // e.g. "Future3[int64, bool, error]"
+	ReturnsError bool   // the last result of the method is an error, which is merged into the error of GetFooOutput
+}
+
+// generateResultStruct generates the GetNameOutput function of the method, whose wrapper function is named name,
+// if it has multiple non-error results. The NameOutput struct is generated by generateOutputStruct.
+func generateResultStruct(buf *bytes.Buffer, name string, method Method) {
+	def := ResultStructDef{OutputDef: outputDef(name, method), FutureType: futureType(method), ReturnsError: method.ReturnsError()}
+	if len(def.Fields) == 0 {
+		return
+	}
+	if err := resultStructTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// typeFieldName returns the field name of a result of the type in the output struct, e.g. File for *os.File
+// and Bytes for []byte, empty if the type has no name, e.g. a struct literal.
+func typeFieldName(typ types.Type) string {
+	name := func(typ types.Type) string {
+		switch t := types.Unalias(typ).(type) {
+		case *types.Named:
+			return exportedFieldName(t.Obj().Name())
+		case *types.Basic:
+			return exportedFieldName(t.Name())
+		}
+		return ""
+	}
+	if p, ok := types.Unalias(typ).(*types.Pointer); ok {
+		typ = p.Elem()
+	}
+	if s, ok := types.Unalias(typ).(*types.Slice); ok {
+		if elem := name(s.Elem()); elem != "" {
+			return elem + "s"
+		}
+		return ""
+	}
+	return name(typ)
+}
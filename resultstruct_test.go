@@ -0,0 +1,45 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateResultStructs(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "os"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Open(name string) (*os.File, []byte, error) { return nil, nil, nil }
+
+func (Tasks) Size(name string) (int64, error) { return 0, nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Stats() (hits, misses int) { return 0, 0 }
+`}, Options{ResultStructs: true})
+
+	// the fields of the results of the same type are named by position
+	require.Contains(t, code, "type DivideOutput struct {\n\tR0 int64\n\tR1 int64\n}")
+	require.Contains(t, code, "func GetDivideOutput(future *Future2[int64, int64]) (DivideOutput, error) {\n\tvar output DivideOutput\n\tvar err error\n\toutput.R0, output.R1, err = future.Get()\n\treturn output, err\n}")
+
+	require.Contains(t, code, "type OpenOutput struct {\n\tFile  *os.File\n\tBytes []byte\n}")
+	require.Contains(t, code, "func GetOpenOutput(future *Future3[*os.File, []byte, error]) (OpenOutput, error) {")
+	require.Contains(t, code, "output.File, output.Bytes, taskErr, err = future.Get()\n\tif err == nil {\n\t\terr = taskErr\n\t}")
+
+	require.NotContains(t, code, "SizeOutput")
+	require.Contains(t, code, "type Counter_StatsOutput struct {\n\tHits   int\n\tMisses int\n}")
+	require.Contains(t, code, "func GetCounter_StatsOutput(future *Future2[int, int]) (Counter_StatsOutput, error) {")
+	require.NotContains(t, code, "WaitAll")
+}
@@ -0,0 +1,220 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/*
+	//goray:retry max=5 backoff=exponential base=100ms
+	//goray:retryable ErrTimeout *TimeoutError
+	func (Tasks) Divide(a, b int64) (int64, int64)
+
+generates:
+
+	func DivideRetry(ctx context.Context, a int64, b int64) (_r0 int64, _r1 int64, _err error)
+*/
+const retryTpl = `
+// {{.Name}}Retry calls [{{.Name}}] remotely and waits for the results, retrying the failed calls
+// up to {{.Max}} times with {{.Backoff}} backoff from {{.Base}}{{if .Retryable}}, if the error is retryable{{end}} (see //goray:retry).
+func {{.Name}}Retry(ctx context.Context, {{if .ActorName}}_actor *Actor{{.ActorName}}{{if .Params}}, {{end}}{{end}}{{.Params}}) ({{range .Results}}{{.}}, {{end}}_err error) {
+	for _attempt := 1; ; _attempt++ {
+		{{.CallBody}}
+		if _err == nil || _attempt > {{.Max}}{{if .Retryable}} || !({{.Retryable}}){{end}} {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			_err = ctx.Err()
+			return
+		case <-time.After(_retryDelay("{{.Backoff}}", {{.BaseExpr}}, _attempt)):
+		}
+	}
+}
+`
+
+// retryHelpers is shared by the retry callers, generated once per file.
+const retryHelpers = `
+// _retryDelay returns the delay before the retry attempt (from 1) with the backoff policy.
+func _retryDelay(backoff string, base time.Duration, attempt int) time.Duration {
+	switch backoff {
+	case "constant":
+		return base
+	case "linear":
+		return base * time.Duration(attempt)
+	default: // exponential
+		return base << (attempt - 1)
+	}
+}
+`
+
+var retryTmpl = template.Must(template.New("retry").Parse(retryTpl))
+
+const (
+	retryDirective     = "retry"
+	retryableDirective = "retryable"
+)
+
+// RetryDef is the template data of retryTpl.
+type RetryDef struct {
+	Name      string // wrapper function name, e.g. "Divide" or "Counter_Incr"
+	ActorName string
+	Params    string
+	Results   []string // named results without error, e.g. "_r0 int64"
+	CallBody  string   // statements assigning the results and _err of a remote call
+	Max       int
+	Backoff   string // constant, linear or exponential
+	Base      time.Duration
+	BaseExpr  string
+	Retryable string // condition of retryable _err, empty if all errors are retryable
+}
+
+// prepareRetries collects the retry callers of the methods with the //goray:retry directive into g.retryDefs.
+// It runs before generating the imports, as the retryable errors may need imports.
+func (g *Generator) prepareRetries() {
+	g.retryDefs = make(map[string]RetryDef)
+	prepare := func(name, actorName string, m Method) {
+		if def, ok := g.retryDef(name, actorName, m); ok {
+			g.retryDefs[name] = def
+		}
+	}
+	for _, m := range g.tasks {
+		prepare(m.Name, "", m)
+	}
+	for _, factory := range g.actorFactories {
+		for _, am := range g.actor2Methods[factory.Name] {
+			prepare(factory.Name+"_"+am.Name, factory.Name, am)
+		}
+	}
+	if len(g.retryDefs) > 0 {
+		for _, pkg := range []string{"context", "time"} {
+			g.importStore.AddImport(pkg)
+		}
+	}
+}
+
+func (g *Generator) retryDef(name, actorName string, m Method) (RetryDef, bool) {
+	d, ok := m.Directive(retryDirective)
+	if !ok {
+		return RetryDef{}, false
+	}
+	def := RetryDef{Name: name, ActorName: actorName, Max: 3, Backoff: "exponential", Base: 100 * time.Millisecond}
+	if v, ok := d.Params["max"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			def.Max = n
+		} else {
+			g.warnAt(g.directivePos(m, retryDirective), "invalid-directive", "%s: invalid max=%s of //goray:retry, use %d", name, v, def.Max)
+		}
+	}
+	if v, ok := d.Params["backoff"]; ok {
+		switch v {
+		case "constant", "linear", "exponential":
+			def.Backoff = v
+		default:
+			g.warnAt(g.directivePos(m, retryDirective), "invalid-directive", "%s: invalid backoff=%s of //goray:retry, use %s", name, v, def.Backoff)
+		}
+	}
+	if v, ok := d.Params["base"]; ok {
+		if base, err := time.ParseDuration(v); err == nil && base >= 0 {
+			def.Base = base
+		} else {
+			g.warnAt(g.directivePos(m, retryDirective), "invalid-directive", "%s: invalid base=%s of //goray:retry, use %s", name, v, def.Base)
+		}
+	}
+	def.BaseExpr = durationExpr(def.Base)
+
+	if d, ok := m.Directive(retryableDirective); ok {
+		var conds []string
+		for _, errName := range d.Args {
+			if cond, ok := g.retryableCond(errName); ok {
+				conds = append(conds, cond)
+			} else {
+				g.warnAt(g.directivePos(m, retryableDirective), "invalid-directive", "%s: retryable error %s not found, it should be an error variable or type of the package or its imports", name, errName)
+			}
+		}
+		if len(conds) > 0 {
+			g.importStore.AddImport("errors")
+			def.Retryable = strings.Join(conds, " || ")
+		}
+	}
+
+	cm, passContext := withoutContext(m) // the ctx of the caller is passed on
+	params, callArgs, resultTypes := callSignature(cm)
+	def.Params = params
+	var vars string
+	for i, t := range resultTypes {
+		def.Results = append(def.Results, fmt.Sprintf("_r%d %s", i, t))
+		vars += fmt.Sprintf("_r%d, ", i)
+	}
+	wrapperArgs := callArgs
+	if passContext {
+		wrapperArgs = strings.TrimSuffix("ctx, "+callArgs, ", ")
+	}
+	if actorName != "" {
+		callArgs = strings.TrimSuffix("_actor, "+callArgs, ", ")
+		wrapperArgs = strings.TrimSuffix("_actor, "+wrapperArgs, ", ")
+	}
+	call := fmt.Sprintf("%s(%s).Remote().Get()", name, wrapperArgs)
+	if g.opts.instrumented() { // instrument every attempt
+		def.CallBody = fmt.Sprintf("%s_err = %sInvoke(%s)", vars, name, strings.TrimSuffix("_withAttempt(ctx, _attempt), "+callArgs, ", "))
+	} else {
+		def.CallBody = assignCallResults(m, vars, call, "\t\t")
+	}
+	return def, true
+}
+
+// retryableCond returns the condition of _err matching the retryable error, which is an error variable
+// (matched by errors.Is) or an error type (matched by errors.As) of the package, or of its imports, e.g. "io.EOF".
+func (g *Generator) retryableCond(errName string) (string, bool) {
+	pointer := strings.HasPrefix(errName, "*")
+	name := strings.TrimPrefix(errName, "*")
+
+	var obj types.Object
+	qualifier := ""
+	if pkgName, objName, ok := strings.Cut(name, "."); ok {
+		for _, imp := range g.pkg.Types.Imports() {
+			if imp.Name() == pkgName {
+				obj = imp.Scope().Lookup(objName)
+				if obj != nil {
+					qualifier = g.importStore.AddPackage(imp) + "."
+				}
+				break
+			}
+		}
+		name = objName
+	} else {
+		obj = g.pkg.Types.Scope().Lookup(name)
+		if obj != nil {
+			qualifier = g.sourceQualifier()
+		}
+	}
+
+	switch obj.(type) {
+	case *types.Var:
+		return fmt.Sprintf("errors.Is(_err, %s%s)", qualifier, name), !pointer
+	case *types.TypeName:
+		typ := qualifier + name
+		if pointer {
+			typ = "*" + typ
+		}
+		return fmt.Sprintf("errors.As(_err, new(%s))", typ), true
+	}
+	return "", false
+}
+
+// generateRetry generates the retry caller of the method, whose wrapper function is named name, if it has the
+// //goray:retry directive.
+func (g *Generator) generateRetry(buf *bytes.Buffer, name string) {
+	def, ok := g.retryDefs[name]
+	if !ok {
+		return
+	}
+	if err := retryTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
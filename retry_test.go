@@ -0,0 +1,63 @@
+package goraygen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ray4go/goraygen/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDirectives(t *testing.T) {
+	directives := analysis.ParseDirectives("// Divide divides.\n//\n//goray:retry max=5 backoff=linear\n//goray:retryable ErrBusy, *BusyError\n// goray:retry max=1")
+	require.Equal(t, []Directive{
+		{Name: "retry", Params: map[string]string{"max": "5", "backoff": "linear"}},
+		{Name: "retryable", Args: []string{"ErrBusy", "*BusyError"}, Params: map[string]string{}},
+	}, directives)
+}
+
+func TestDurationExpr(t *testing.T) {
+	require.Equal(t, "100 * time.Millisecond", durationExpr(100*time.Millisecond))
+	require.Equal(t, "90 * time.Second", durationExpr(90*time.Second))
+	require.Equal(t, "time.Duration(0)", durationExpr(0))
+}
+
+func TestGenerateRetry(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "errors"
+
+var ErrBusy = errors.New("busy")
+
+type BusyError struct{}
+
+func (*BusyError) Error() string { return "busy" }
+
+// raytasks
+type Tasks struct{}
+
+// Divide divides.
+//
+//goray:retry max=5 backoff=linear base=2s
+//goray:retryable ErrBusy *BusyError ErrMissing
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+//goray:retry
+func (Tasks) Open(name string) (int, error) { return 0, nil }
+
+func (Tasks) Ping() {}
+`}, Options{})
+
+	require.Contains(t, code, "func DivideRetry(ctx context.Context, a int64, b int64) (_r0 int64, _r1 int64, _err error) {")
+	require.Contains(t, code, "_r0, _r1, _err = Divide(a, b).Remote().Get()")
+	require.Contains(t, code, "if _err == nil || _attempt > 5 || !(errors.Is(_err, ErrBusy) || errors.As(_err, new(*BusyError))) {")
+	require.Contains(t, code, `case <-time.After(_retryDelay("linear", 2*time.Second, _attempt)):`)
+
+	// defaults, with the error-last result retried
+	require.Contains(t, code, "_r0, _taskErr, _err = Open(name).Remote().Get()")
+	require.Contains(t, code, "if _err == nil || _attempt > 3 {")
+	require.Contains(t, code, `_retryDelay("exponential", 100*time.Millisecond, _attempt)`)
+
+	require.NotContains(t, code, "PingRetry")
+	require.Contains(t, code, "// Divide divides.\n// original task: [Tasks.Divide]\nfunc Divide[")
+}
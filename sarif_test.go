@@ -0,0 +1,45 @@
+package goraygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, writeSARIF(&buf, []Diagnostic{
+		{Severity: SeverityWarning, Code: "near-miss-marker", File: filepath.Join(wd, "tasks.go"), Line: 3, Column: 1,
+			Message: "struct Tasks has comment '//raytask'", Suggestion: "did you mean '// raytasks'?"},
+		{Severity: SeverityWarning, Code: "near-miss-marker", File: "/elsewhere/actors.go", Line: 7, Column: 1, Message: "struct Actors has comment '//rayactor'"},
+		{Severity: SeverityInfo, Code: "excluded-method", Message: "Skip method (Tasks).Close: excluded"},
+	}))
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	require.Equal(t, "goraygen", run.Tool.Driver.Name)
+	require.Equal(t, []sarifRule{{ID: "near-miss-marker"}, {ID: "excluded-method"}}, run.Tool.Driver.Rules)
+	require.Len(t, run.Results, 3)
+
+	require.Equal(t, sarifResult{
+		RuleID:  "near-miss-marker",
+		Level:   "warning",
+		Message: sarifMessage{Text: "struct Tasks has comment '//raytask', did you mean '// raytasks'?"},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: "tasks.go", URIBaseID: "%SRCROOT%"},
+			Region:           &sarifRegion{StartLine: 3, StartColumn: 1},
+		}}},
+	}, run.Results[0])
+	require.Equal(t, "file:///elsewhere/actors.go", run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	require.Equal(t, "note", run.Results[2].Level)
+	require.Empty(t, run.Results[2].Locations)
+}
@@ -0,0 +1,268 @@
+package goraygen
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ray4go/goraygen/analysis"
+)
+
+// codecMarshalers are the methods a codec calls instead of encoding the fields of a type by reflection,
+// the types declaring one being opaque to checkSerializable.
+var codecMarshalers = map[Codec][]string{
+	CodecMsgpack: {"EncodeMsgpack", "MarshalMsgpack", "MarshalBinary", "MarshalText", "ProtoReflect"},
+	CodecGob:     {"GobEncode", "MarshalBinary", "MarshalText", "ProtoReflect"},
+	CodecJSON:    {"MarshalJSON", "MarshalText", "ProtoReflect"},
+}
+
+// checkSerializable reports a warning (an error with -strict) for every param or result of the targets
+// whose value the codec can't carry, naming the nested field responsible, e.g. `field p.Items[i].Done of
+// func type func()`: the func, channel and unsafe.Pointer values, the interfaces with methods the receiver
+// can't decode into, the unexported fields dropped by the codec, and with the json codec the complex numbers
+// and the map keys other than strings and integers. The types with a marshaler method or a type mapping
+// are carried as such and not walked, the //goray:codec directive of a type switches the codec of its fields.
+func (g *Generator) checkSerializable() {
+	codec := g.opts.Codec
+	if codec == "" {
+		codec = CodecMsgpack
+	}
+	checked := make(map[string]bool) // the methods of the actor handles may be the ones of the actors too
+	s := &serializability{g: g, mappings: g.opts.typeMappings(), walked: make(map[walkKey]walkResult), walking: make(map[walkKey]bool)}
+	check := func(m Method, results bool) {
+		key := m.ReceiverType + "." + m.Name
+		if checked[key] {
+			return
+		}
+		checked[key] = true
+		report := func(what, name string, typ types.Type) {
+			if typ == nil {
+				return
+			}
+			path, reason := s.check(typ, codec, name)
+			if reason == "" {
+				return
+			}
+			if path != name {
+				reason = fmt.Sprintf("field %s %s", path, reason)
+			}
+			g.report(g.at(g.methodPos(m), Diagnostic{
+				Severity:   SeverityWarning,
+				Code:       "unserializable-type",
+				Message:    fmt.Sprintf("%s of (%s).%s: %s", what, m.ReceiverType, m.Name, reason),
+				Suggestion: "change the type of the field, or implement a marshaler (e.g. MarshalBinary) on the type declaring it",
+			}))
+		}
+		params, _ := withoutContext(m) // the ctx isn't serialized, see generateContextTasks
+		for _, p := range params.Params {
+			report("param "+p.Name, p.Name, p.GoType)
+		}
+		if !results {
+			return
+		}
+		for i, r := range m.Results {
+			if r.IsError && i == len(m.Results)-1 {
+				continue
+			}
+			report(fmt.Sprintf("result %d", i), fmt.Sprintf("result%d", i), r.GoType)
+		}
+	}
+	for _, m := range g.tasks {
+		check(m, true)
+	}
+	for _, factory := range g.actorFactories {
+		check(factory, false) // the actor stays on the worker
+		for _, m := range g.actor2Methods[factory.Name] {
+			check(m, true)
+		}
+	}
+	for _, h := range g.actorHandles {
+		for _, m := range h.Methods {
+			check(m, true)
+		}
+	}
+	for _, m := range g.streams {
+		elem, _ := streamElem(m)
+		m.Results = slices.Clone(m.Results)
+		m.Results[0].GoType = elem // the values of the channel are sent, see collectStream
+		check(m, true)
+	}
+}
+
+// serializability walks the types for checkSerializable, once per named type and codec: the recursive types,
+// e.g. `type Node struct{ Next *Node }`, terminate and the types shared by several signatures are walked once.
+type serializability struct {
+	g        *Generator
+	mappings analysis.TypeMappings
+	walked   map[walkKey]walkResult
+	walking  map[walkKey]bool // the named types being walked, reached again through a cycle
+	visited  []walkKey        // the named types walked by the current check
+}
+
+type walkKey struct {
+	named *types.Named
+	codec Codec
+}
+
+// walkResult is the problem found in a named type, its path being relative to the value of the type.
+type walkResult struct {
+	path, reason string
+}
+
+// check walks the type of the value at path, see walk. If the type is serializable, so are the named types walked,
+// which are remembered. A problem is remembered by the named types it's found in, the walk stopping there.
+func (s *serializability) check(typ types.Type, codec Codec, path string) (string, string) {
+	s.visited = s.visited[:0]
+	path, reason := s.walk(typ, codec, path)
+	if reason == "" {
+		for _, key := range s.visited {
+			s.walked[key] = walkResult{}
+		}
+	}
+	return path, reason
+}
+
+// walk returns the path of the first value of the type the codec can't carry, from the path of the value,
+// with the reason, or an empty reason if the type is serializable. A named type reached again through a cycle
+// is assumed serializable, its problems being found by the walk in progress.
+func (s *serializability) walk(typ types.Type, codec Codec, path string) (string, string) {
+	typ = types.Unalias(typ)
+	if _, ok := s.mappings.Lookup(typ); ok {
+		return "", ""
+	}
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return s.walkUnderlying(typ, codec, path)
+	}
+	key := walkKey{named, codec}
+	if r, ok := s.walked[key]; ok {
+		if r.reason == "" {
+			return "", ""
+		}
+		return path + r.path, r.reason
+	}
+	if s.walking[key] {
+		return "", ""
+	}
+	s.walking[key] = true
+	s.visited = append(s.visited, key)
+	p, reason := s.walkNamed(named, codec, path)
+	delete(s.walking, key)
+	if reason != "" {
+		s.walked[key] = walkResult{path: strings.TrimPrefix(p, path), reason: reason}
+	}
+	return p, reason
+}
+
+// walkNamed walks the named type, carried as such by the codec if it declares a marshaler.
+func (s *serializability) walkNamed(named *types.Named, codec Codec, path string) (string, string) {
+	for _, method := range codecMarshalers[codec] {
+		if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, named.Obj().Pkg(), method); obj != nil {
+			if _, ok := obj.(*types.Func); ok {
+				return "", ""
+			}
+		}
+	}
+	if c, ok := s.directiveCodec(named); ok {
+		codec = c
+	}
+	return s.walkUnderlying(named, codec, path)
+}
+
+// walkUnderlying walks the underlying type of the type, see walk.
+func (s *serializability) walkUnderlying(typ types.Type, codec Codec, path string) (string, string) {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Kind() == types.UnsafePointer:
+			return path, fmt.Sprintf("of type %s can't be serialized", s.typeString(typ))
+		case codec == CodecJSON && t.Info()&types.IsComplex != 0:
+			return path, fmt.Sprintf("of complex type %s isn't supported by the json codec", s.typeString(typ))
+		}
+	case *types.Pointer:
+		return s.walk(t.Elem(), codec, path)
+	case *types.Slice:
+		return s.walk(t.Elem(), codec, path+"[i]")
+	case *types.Array:
+		return s.walk(t.Elem(), codec, path+"[i]")
+	case *types.Map:
+		if codec == CodecJSON && !jsonMapKey(t.Key()) {
+			return path, fmt.Sprintf("of type %s has keys of type %s, the json codec only supports string, integer and encoding.TextMarshaler keys", s.typeString(typ), s.typeString(t.Key()))
+		}
+		if p, reason := s.walk(t.Key(), codec, path+"[key]"); reason != "" {
+			return p, reason
+		}
+		return s.walk(t.Elem(), codec, path+"[k]")
+	case *types.Struct:
+		for i := range t.NumFields() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(reflect.StructTag(t.Tag(i)).Get("json"), ",")
+			switch {
+			case codec == CodecJSON && name == "-":
+				continue
+			case codec == CodecGob && isFuncOrChan(f.Type()): // ignored like the unexported fields
+				continue
+			case !f.Exported() && !f.Embedded():
+				return path + "." + f.Name(), fmt.Sprintf("is unexported, its value is dropped by the %s codec", codec)
+			}
+			if p, reason := s.walk(f.Type(), codec, path+"."+f.Name()); reason != "" {
+				return p, reason
+			}
+		}
+	case *types.Signature:
+		return path, fmt.Sprintf("of func type %s can't be serialized", s.typeString(typ))
+	case *types.Chan:
+		return path, fmt.Sprintf("of channel type %s can't be serialized", s.typeString(typ))
+	case *types.Interface:
+		if !t.Empty() {
+			return path, fmt.Sprintf("of interface type %s can't be decoded, the receiver doesn't know its dynamic type", s.typeString(typ))
+		}
+	}
+	return "", ""
+}
+
+// typeString renders the type with the package paths, but the one of the scanned package.
+func (s *serializability) typeString(typ types.Type) string {
+	return types.TypeString(typ, types.RelativeTo(s.g.pkg.Types))
+}
+
+// directiveCodec returns the codec of the //goray:codec directive of the type of the scanned package, if any.
+// The invalid directives are reported by prepareCodecs.
+func (s *serializability) directiveCodec(named *types.Named) (Codec, bool) {
+	if named.Obj().Pkg() != s.g.pkg.Types {
+		return "", false
+	}
+	for _, d := range analysis.ParseDirectives(analysis.TypeDoc(s.g.pkg, named.Obj().Name())) {
+		if d.Name != codecDirective || len(d.Args) != 1 {
+			continue
+		}
+		if codec, err := ParseCodec(d.Args[0]); err == nil {
+			return codec, true
+		}
+	}
+	return "", false
+}
+
+// jsonMapKey reports whether encoding/json supports the map key type: a string, an integer or an encoding.TextMarshaler.
+func jsonMapKey(key types.Type) bool {
+	if basic, ok := key.Underlying().(*types.Basic); ok && basic.Info()&(types.IsString|types.IsInteger) != 0 {
+		return true
+	}
+	if named, ok := types.Unalias(key).(*types.Named); ok {
+		obj, _, _ := types.LookupFieldOrMethod(named, true, named.Obj().Pkg(), "MarshalText")
+		_, ok := obj.(*types.Func)
+		return ok
+	}
+	return false
+}
+
+// isFuncOrChan reports whether the underlying type is a func or channel type.
+func isFuncOrChan(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Signature, *types.Chan:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSerializable(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+import (
+	"sync"
+	"time"
+)
+
+// raytasks
+type Tasks struct{}
+
+type Node struct {
+	Value int
+	Next  *Node
+}
+
+type Job struct {
+	Name  string
+	Steps []Step
+	At    time.Time
+}
+
+type Step struct {
+	Labels map[Point]string
+	Done   func()
+}
+
+type Point struct{ X, Y int }
+
+type Cache struct {
+	Items map[string]int
+	mu    sync.Mutex
+}
+
+type Event struct {
+	Source Sink
+	Err    error
+}
+
+type Sink interface{ String() string }
+
+//goray:codec gob
+type Batch struct {
+	Notify chan int
+	Size   complex128
+}
+
+func (Tasks) Walk(n *Node) int { return 0 }
+
+func (Tasks) Run(job Job) error { return nil }
+
+func (Tasks) Load() (Cache, error) { return Cache{}, nil }
+
+func (Tasks) Emit(events []Event) {}
+
+func (Tasks) Send(b Batch) {}
+`}
+	check := func(codec Codec) []Diagnostic {
+		var diagnostics []Diagnostic
+		opts := Options{Codec: codec}
+		opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+			if d.Code == "unserializable-type" {
+				diagnostics = append(diagnostics, d)
+			}
+		})
+		pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+		g := NewGenerator(opts)
+		g.pkg = pkg
+		g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+		g.collectWorkloads()
+		g.checkSerializable()
+		return diagnostics
+	}
+
+	diagnostics := check("")
+	require.Len(t, diagnostics, 3)
+	require.Equal(t, "param job of (Tasks).Run: field job.Steps[i].Done of func type func() can't be serialized", diagnostics[0].Message)
+	require.Equal(t, 49, diagnostics[0].Line)
+	require.Equal(t, "result 0 of (Tasks).Load: field result0.mu is unexported, its value is dropped by the msgpack codec", diagnostics[1].Message)
+	require.Equal(t, "param events of (Tasks).Emit: field events[i].Source of interface type Sink can't be decoded, the receiver doesn't know its dynamic type", diagnostics[2].Message)
+
+	// the fields of func and channel types are ignored by gob, the map keys of struct types aren't supported by json
+	diagnostics = check(CodecJSON)
+	require.Len(t, diagnostics, 3)
+	require.Contains(t, diagnostics[0].Message, "field job.Steps[i].Labels of type map[Point]string has keys of type Point, the json codec only supports")
+	require.Contains(t, diagnostics[1].Message, "dropped by the json codec")
+	diagnostics = check(CodecGob)
+	require.Len(t, diagnostics, 2)
+	require.Contains(t, diagnostics[0].Message, "result 0 of (Tasks).Load")
+}
+
+func TestCheckSerializableRecursive(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type Tree struct {
+	Kids   []*Tree
+	Parent *Tree
+	Index  map[string]Tree
+	Visit  func(*Tree)
+}
+
+type A struct{ B *B }
+
+type B struct {
+	As  []A
+	Err chan error
+}
+
+type List[T any] struct {
+	Head T
+	Tail *List[T]
+}
+
+func (Tasks) Prune(t Tree) Tree { return t }
+
+func (Tasks) Link(a A, b B) {}
+
+func (Tasks) Sum(l List[int], r *List[List[string]]) int { return 0 }
+`}, "example.com/mypkg")
+	var messages []string
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "unserializable-type" {
+			messages = append(messages, d.Message)
+		}
+	})
+	g := NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.checkSerializable()
+
+	// the problems of the types walked once are reported from every value of the types
+	require.Equal(t, []string{
+		"param t of (Tasks).Prune: field t.Visit of func type func(*Tree) can't be serialized",
+		"result 0 of (Tasks).Prune: field result0.Visit of func type func(*Tree) can't be serialized",
+		"param a of (Tasks).Link: field a.B.Err of channel type chan error can't be serialized",
+		"param b of (Tasks).Link: field b.Err of channel type chan error can't be serialized",
+	}, messages)
+}
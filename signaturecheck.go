@@ -0,0 +1,117 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const checkSignatureHashesDef = `
+// CheckSignatureHashes compares SignatureHashes with the ones of another binary (e.g. reported by the workers),
+// and returns an error listing the tasks/actors whose signature changed. Names missing in remote are ignored.
+func CheckSignatureHashes(remote map[string]string) error {
+	var changed []string
+	for name, hash := range SignatureHashes {
+		if h, ok := remote[name]; ok && h != hash {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("signature of %s changed, the generated wrappers are stale", strings.Join(changed, ", "))
+	}
+	return nil
+}
+`
+
+type signatureEntry struct {
+	key    string // "task:$name", "actor:$name" or "actor:$name.$method"
+	method Method
+}
+
+// signatureEntries returns the tasks, actor factories and actor methods to check signatures of.
+func (g *Generator) signatureEntries() []signatureEntry {
+	var entries []signatureEntry
+	for _, m := range g.tasks {
+		entries = append(entries, signatureEntry{"task:" + m.Name, m})
+	}
+	for _, factory := range g.actorFactories {
+		entries = append(entries, signatureEntry{"actor:" + factory.Name, factory})
+		for _, am := range g.actor2Methods[factory.Name] {
+			entries = append(entries, signatureEntry{"actor:" + factory.Name + "." + am.Name, am})
+		}
+	}
+	return entries
+}
+
+// generateSignatureAssertions generates compile-time assertions of the original method signatures,
+// so the package fails to build as soon as a method changes and the generated code is stale.
+func (g *Generator) generateSignatureAssertions(buf *bytes.Buffer) {
+	entries := g.signatureEntries()
+	if len(entries) == 0 {
+		return
+	}
+	buf.WriteString("\n// Signature checks: the build fails here if a task/actor method changed after this file was generated,\n")
+	buf.WriteString("// re-run goraygen to update it.\nvar (\n")
+	for _, e := range entries {
+		fmt.Fprintf(buf, "\t_ %s = %s\n", g.methodFuncType(e.method), g.methodExpr(e.method))
+	}
+	buf.WriteString(")\n")
+}
+
+// generateSignatureHashes generates the SignatureHashes registry and CheckSignatureHashes,
+// to detect drift between binaries at runtime.
+func (g *Generator) generateSignatureHashes(buf *bytes.Buffer) {
+	entries := g.signatureEntries()
+	if len(entries) == 0 {
+		return
+	}
+	for _, pkg := range []string{"fmt", "sort", "strings"} {
+		g.importStore.AddImport(pkg)
+	}
+	buf.WriteString("\n// SignatureHashes maps the tasks (\"task:$name\") and actors (\"actor:$name\", \"actor:$name.$method\")\n")
+	buf.WriteString("// to the stable hashes of their signatures.\nvar SignatureHashes = map[string]string{\n")
+	for _, e := range entries {
+		fmt.Fprintf(buf, "\t%s: %s,\n", strconv.Quote(e.key), strconv.Quote(signatureHash(e.method)))
+	}
+	buf.WriteString("}\n")
+	buf.WriteString(checkSignatureHashesDef)
+}
+
+// methodExpr returns the method expression of the original method, e.g. "(*Counter).Incr".
+func (g *Generator) methodExpr(m Method) string {
+	recv := g.qualifiedReceiverType(m)
+	if strings.HasPrefix(recv, "*") {
+		recv = "(" + recv + ")"
+	}
+	return recv + "." + m.Name
+}
+
+// methodFuncType returns the type of the method expression, e.g. "func(*Counter, int) int".
+func (g *Generator) methodFuncType(m Method) string {
+	params := []string{g.qualifiedReceiverType(m)}
+	for i, p := range m.Params {
+		if m.IsVariadic && i == len(m.Params)-1 {
+			params = append(params, "..."+p.Type)
+		} else {
+			params = append(params, g.anonymousLiteral(p.Type))
+		}
+	}
+	results := make([]string, len(m.Results))
+	for i, r := range m.Results {
+		results[i] = r.Type
+	}
+	resultsStr := strings.Join(results, ", ")
+	if len(results) > 1 {
+		resultsStr = "(" + resultsStr + ")"
+	}
+	return strings.TrimSpace(fmt.Sprintf("func(%s) %s", strings.Join(params, ", "), resultsStr))
+}
+
+func (g *Generator) qualifiedReceiverType(m Method) string {
+	if name, ok := strings.CutPrefix(m.ReceiverType, "*"); ok {
+		return "*" + g.sourceQualifier() + name
+	}
+	return g.sourceQualifier() + m.ReceiverType
+}
@@ -0,0 +1,59 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/ray4go/goraygen/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalSignature(t *testing.T) {
+	code := `package mypkg
+
+import "bytes"
+
+// raytasks
+type MyTasks struct{}
+
+func (t *MyTasks) Foo(buf *bytes.Buffer, args ...int) (map[string]MyTasks, error) { return nil, nil }
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+	methods := analysis.FindMethods(pkg, "MyTasks", analysis.NewImportStore())
+	require.Len(t, methods, 1)
+	require.Equal(t, "Foo(*bytes.Buffer,...[]int)(map[string]example.com/mypkg.MyTasks,error)", methods[0].CanonicalSignature())
+
+	renamed := methods[0]
+	renamed.Params = append([]Param{}, renamed.Params...)
+	renamed.Params[0].Name = "b"
+	renamed.Params[0].Type = "bytes2.Buffer"
+	require.Equal(t, signatureHash(methods[0]), signatureHash(renamed))
+
+	renamed.IsVariadic = false
+	require.NotEqual(t, signatureHash(methods[0]), signatureHash(renamed))
+}
+
+func TestGenerateSignatureChecks(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr(n ...int) {}
+`}, Options{SignatureChecks: true})
+
+	require.Contains(t, code, "_ func(Tasks, int64, int64) (int64, int64) = Tasks.Divide")
+	require.Contains(t, code, "_ func(Actors) *Counter")
+	require.Contains(t, code, "_ func(*Counter, ...int)")
+	require.Contains(t, code, "= (*Counter).Incr")
+	require.Contains(t, code, `"actor:Counter.Incr": "`)
+	require.Contains(t, code, "func CheckSignatureHashes(remote map[string]string) error {")
+}
@@ -0,0 +1,381 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+With -streaming, the channel result of
+
+	func (Tasks) Count(n int) <-chan int
+	func (c *Counter) Watch() <-chan int
+
+is streamed from the worker, the values being received by the caller in batches as they are sent:
+
+	func CountStream(_actor *ActorTaskStreams, n int, options ...*ray.RayOption) *ResultStream[int]
+	func Counter_WatchStream(_actor *ActorCounter, options ...*ray.RayOption) *ResultStream[int]
+
+The channel lives in an actor: the method is called by the worker-side FooStreamOpen method, keeping the channel,
+whose values are received by the FooStreamNext method. The actor methods stream from their actor, the tasks from
+a TaskStreams actor created by a factory of the rayactors struct:
+
+	func (_s *_taskStreams) CountStreamOpen(n int) int64
+	func (_s *_taskStreams) CountStreamNext(_id int64) ([]int, bool)
+	func (_s *Counter) WatchStreamOpen() int64
+	func (_s *Counter) WatchStreamNext(_id int64) ([]int, bool)
+*/
+const streamMethodTpl = `
+// {{.Name}}StreamOpen calls [{{.DocName}}] and opens the stream of its channel result, received by {{.Name}}StreamNext.
+func (_s {{.ReceiverType}}) {{.Name}}StreamOpen({{.Params}}) ({{if .ReturnsError}}int64, error{{else}}int64{{end}}) {
+	{{- if .ReturnsError}}
+	_ch, _err := {{.Call}}
+	if _err != nil {
+		return 0, _err
+	}
+	return _openStream[{{.ElemType}}](_ch), nil
+	{{- else}}
+	return _openStream[{{.ElemType}}]({{.Call}})
+	{{- end}}
+}
+
+// {{.Name}}StreamNext receives the next values of the stream opened by {{.Name}}StreamOpen, see _nextStream.
+func (_s {{.ReceiverType}}) {{.Name}}StreamNext(_id int64) ([]{{.ElemType}}, bool) {
+	return _nextStream[{{.ElemType}}](_id)
+}
+`
+
+const streamTpl = `
+// {{.FuncName}} calls [{{.DocName}}] on the {{if .Task}}TaskStreams {{end}}actor and iterates over the values of its channel result,
+// received in batches as they are sent. The actor serves no other call while it waits for the next values.
+func {{.Receiver}}{{.FuncName}}({{.DriverParams}}) *ResultStream[{{.ElemType}}] {
+	{{- if .ReturnsError}}
+	_id, _taskErr, _err := NewRemoteFunc[*Future2[int64, error]]("{{.Name}}StreamOpen", {{.ArgsStatement}}, &_actor.ActorHandle).Remote(options...).Get()
+	if _err == nil {
+		_err = _taskErr
+	}
+	{{- else}}
+	_id, _err := NewRemoteFunc[*Future1[int64]]("{{.Name}}StreamOpen", {{.ArgsStatement}}, &_actor.ActorHandle).Remote(options...).Get()
+	{{- end}}
+	return &ResultStream[{{.ElemType}}]{err: _err, next: func() ([]{{.ElemType}}, bool, error) {
+		return NewRemoteFunc[*Future2[[]{{.ElemType}}, bool]]("{{.Name}}StreamNext", []any{_id}, &_actor.ActorHandle).Remote().Get()
+	}}
+}
+`
+
+// taskStreamsActorTpl is the worker-side TaskStreams actor of the streams of the tasks, generated once per file.
+const taskStreamsActorTpl = `
+// _taskStreams is the TaskStreams actor, streaming the channel results of the tasks of [{{.TasksStruct}}].
+type _taskStreams struct{}
+
+// TaskStreams creates a TaskStreams actor, streaming the channel results of the tasks of [{{.TasksStruct}}].
+func ({{.ActorsStruct}}) TaskStreams() *_taskStreams {
+	return &_taskStreams{}
+}
+`
+
+// streamWorkerHelpers is shared by the worker-side methods of the streams, generated once per file.
+const streamWorkerHelpers = `
+// _streamBatchSize is the max number of values received by a call of the FooStreamNext methods.
+const _streamBatchSize = 64
+
+// _streams are the channels of the streams opened on the worker, by id.
+var _streams struct {
+	sync.Mutex
+	lastID int64
+	chans  map[int64]any // <-chan T
+}
+
+// _openStream keeps the channel of a stream until it's closed, returning the id of the stream.
+// A nil channel is an empty stream.
+func _openStream[T any](ch <-chan T) int64 {
+	if ch == nil {
+		return 0
+	}
+	_streams.Lock()
+	defer _streams.Unlock()
+	if _streams.chans == nil {
+		_streams.chans = make(map[int64]any)
+	}
+	_streams.lastID++
+	_streams.chans[_streams.lastID] = ch
+	return _streams.lastID
+}
+
+// _nextStream waits for the next value of the stream, and receives the values already sent with it,
+// up to _streamBatchSize. more is false once the channel is closed, the stream being closed.
+func _nextStream[T any](id int64) (values []T, more bool) {
+	_streams.Lock()
+	ch, ok := _streams.chans[id].(<-chan T)
+	_streams.Unlock()
+	if !ok {
+		return nil, false
+	}
+	v, ok := <-ch
+	for ok {
+		values = append(values, v)
+		if len(values) == _streamBatchSize {
+			return values, true
+		}
+		select {
+		case v, ok = <-ch:
+		default:
+			return values, true
+		}
+	}
+	_streams.Lock()
+	delete(_streams.chans, id)
+	_streams.Unlock()
+	return values, false
+}
+`
+
+// streamHelpersTpl is shared by the driver-side streams, generated once per file.
+const streamHelpersTpl = `
+// ResultStream iterates over the values of the channel result of a task or actor method streamed from the worker,
+// see the FooStream functions. It isn't safe for concurrent use.
+type ResultStream[T any] struct {
+	next   func() ([]T, bool, error)
+	values []T
+	done   bool
+	err    error
+}
+
+// Next returns the next value of the stream, waiting for the worker to send it. ok is false once the channel is
+// closed and all its values are received, or the stream failed: the error of the remote call is returned by every
+// later call of Next.
+func (s *ResultStream[T]) Next() (value T, ok bool, err error) {
+	for len(s.values) == 0 {
+		if s.err != nil || s.done {
+			return value, false, s.err
+		}
+		var more bool
+		s.values, more, s.err = s.next()
+		s.done = !more
+	}
+	value, s.values = s.values[0], s.values[1:]
+	return value, true, nil
+}
+{{- if .TaskStreams}}
+
+// ActorTaskStreams is a TaskStreams actor, streaming the channel results of the tasks, see NewTaskStreams.
+type ActorTaskStreams struct {
+	ray.ActorHandle
+}
+
+// NewTaskStreams creates a TaskStreams actor to call the FooStream functions of the tasks with.
+// An actor streams one call at a time: create one per stream read concurrently.
+func NewTaskStreams() *RemoteActor[ActorTaskStreams] {
+	return NewRemoteActor[ActorTaskStreams]("TaskStreams", []any{})
+}
+{{- end}}
+`
+
+var (
+	streamMethodTmpl     = template.Must(template.New("streamMethod").Parse(streamMethodTpl))
+	streamTmpl           = template.Must(template.New("stream").Parse(streamTpl))
+	taskStreamsActorTmpl = template.Must(template.New("taskStreamsActor").Parse(taskStreamsActorTpl))
+	streamHelpersTmpl    = template.Must(template.New("streamHelpers").Parse(streamHelpersTpl))
+)
+
+// taskStreamsActor is the name of the factory of the TaskStreams actor, see taskStreamsActorTpl.
+const taskStreamsActor = "TaskStreams"
+
+// StreamDef is the template data of streamMethodTpl and streamTpl.
+type StreamDef struct {
+	Name         string // the name of the method, e.g. "Watch"
+	DocName      string // e.g. "Counter.Watch", qualified out of the package
+	ElemType     string // the element type of the channel, e.g. "int"
+	ReturnsError bool
+	// worker-side methods
+	ReceiverType string // e.g. "*Counter", or "*_taskStreams" for a task
+	Params       string
+	Call         string // the call of the method, e.g. "_s.Watch()" or "(&Tasks{}).Count(n)"
+	// driver-side stream
+	Task          bool
+	Receiver      string // the receiver of the method of an actor handle, e.g. "(_actor *CounterActorHandle) "
+	FuncName      string // e.g. "Counter_WatchStream"
+	DriverParams  string // e.g. "_actor *ActorCounter, options ...*ray.RayOption"
+	ArgsStatement string
+}
+
+// streamElem returns the element type of the channel result of the method if it can be streamed: its only result,
+// or the first of two following the error-last convention, is a channel the caller can receive from.
+func streamElem(m Method) (types.Type, bool) {
+	if len(m.Results) != 1 && (len(m.Results) != 2 || !m.ReturnsError()) || m.Results[0].GoType == nil {
+		return nil, false
+	}
+	ch, ok := m.Results[0].GoType.Underlying().(*types.Chan)
+	if !ok || ch.Dir() == types.SendOnly {
+		return nil, false
+	}
+	return ch.Elem(), true
+}
+
+// collectStream collects the method with a channel result into g.streams, to generate its stream instead of the wrappers.
+// The methods the stream can't be generated for are skipped with a warning.
+func (g *Generator) collectStream(m Method) {
+	if slices.ContainsFunc(g.streams, func(s Method) bool { return s.ReceiverType == m.ReceiverType && s.Name == m.Name }) {
+		return // the methods of an actor struct with a factory and a handle are discovered twice
+	}
+	structName := strings.TrimPrefix(m.ReceiverType, "*")
+	declared := g.declaredMethods(structName)
+	reason := ""
+	if m.TakesContext() {
+		reason = "a stream can't pass a context.Context"
+	} else if i := slices.IndexFunc(declared, func(name string) bool {
+		return gslice.Contains([]string{m.Name + "Stream", m.Name + "StreamOpen", m.Name + "StreamNext"}, name)
+	}); i >= 0 {
+		reason = fmt.Sprintf("%s already declares the %s method of its stream", structName, declared[i])
+	}
+	if reason == "" && !g.inPackageCode() {
+		g.report(g.at(g.methodPos(m), Diagnostic{
+			Severity:   SeverityWarning,
+			Code:       "skip-stream",
+			Message:    fmt.Sprintf("Skip method (%s).%s: the stream must be generated into package %s", m.ReceiverType, m.Name, g.pkg.PkgPath),
+			Suggestion: "use -split-worker with -output-dir",
+		}))
+		g.skip(m, "the stream must be generated into the scanned package")
+		return
+	}
+	if reason != "" {
+		g.skipStream(m, reason)
+		return
+	}
+	g.streams = append(g.streams, m)
+}
+
+// checkTaskStreams drops the streams of the tasks if their TaskStreams actor can't be registered,
+// its factory being a method of the rayactors struct, with a warning.
+func (g *Generator) checkTaskStreams() {
+	reason := ""
+	switch {
+	case g.actorsStruct == "":
+		reason = "a task stream needs a rayactors struct to register the TaskStreams actor"
+	case gslice.Contains(g.declaredMethods(g.actorsStruct), taskStreamsActor):
+		reason = fmt.Sprintf("%s already declares the %s actor factory of the task streams", g.actorsStruct, taskStreamsActor)
+	default:
+		return
+	}
+	g.streams = gslice.Filter(g.streams, func(m Method) bool {
+		if !g.isTaskStream(m) {
+			return true
+		}
+		g.skipStream(m, reason)
+		return false
+	})
+}
+
+// skipStream skips the method with a channel result, with a warning.
+func (g *Generator) skipStream(m Method, reason string) {
+	g.report(g.at(g.methodPos(m), Diagnostic{
+		Severity:   SeverityWarning,
+		Code:       "skip-stream",
+		Message:    fmt.Sprintf("Skip method (%s).%s: %s", m.ReceiverType, m.Name, reason),
+		Suggestion: "return the values as a slice",
+	}))
+	g.skip(m, reason)
+}
+
+// declaredMethods returns the names of the methods declared by the struct, including the filtered out ones.
+func (g *Generator) declaredMethods(structName string) []string {
+	return gslice.Map(analysis.Methods(g.pkg, structName, g.pkg.PkgPath, g.importStore), func(m Method) string {
+		return m.Name
+	})
+}
+
+// isTaskStream reports whether the stream is the one of a task, streamed by the TaskStreams actor.
+func (g *Generator) isTaskStream(m Method) bool {
+	return g.tasksStruct != "" && strings.TrimPrefix(m.ReceiverType, "*") == g.tasksStruct
+}
+
+// streamsOf returns the streams of the methods of the struct.
+func (g *Generator) streamsOf(structName string) []Method {
+	return gslice.Filter(g.streams, func(m Method) bool { return strings.TrimPrefix(m.ReceiverType, "*") == structName })
+}
+
+// streamDef returns the template data of the worker-side methods and the driver-side stream of the method.
+func (g *Generator) streamDef(m Method, docQualifier string) StreamDef {
+	elem, _ := streamElem(m)
+	structName := strings.TrimPrefix(m.ReceiverType, "*")
+	def := StreamDef{
+		Name:         m.Name,
+		DocName:      docQualifier + structName + "." + m.Name,
+		ElemType:     analysis.TypeName(elem, g.outputPkgPath, g.importStore),
+		ReturnsError: m.ReturnsError(),
+		ReceiverType: m.ReceiverType,
+		Task:         g.isTaskStream(m),
+	}
+	params, callArgs, _ := callSignature(m)
+	def.Params = params
+	def.Call = fmt.Sprintf("_s.%s(%s)", m.Name, callArgs)
+	if def.Task {
+		def.ReceiverType = "*_taskStreams"
+		def.Call = fmt.Sprintf("(&%s{}).%s(%s)", g.tasksStruct, m.Name, callArgs)
+	}
+	var driverParams, args []string
+	for i, p := range m.Params {
+		if m.IsVariadic && i == len(m.Params)-1 {
+			driverParams = append(driverParams, fmt.Sprintf("%s []%s", p.Name, p.Type))
+		} else {
+			driverParams = append(driverParams, p.Name+" "+p.Type)
+			args = append(args, p.Name)
+		}
+	}
+	def.ArgsStatement = fmt.Sprintf("[]any{%s}", strings.Join(args, ", "))
+	if m.IsVariadic {
+		def.ArgsStatement = fmt.Sprintf("ExpandArgs(%s, %s)", def.ArgsStatement, m.Params[len(m.Params)-1].Name)
+	}
+	def.DriverParams = strings.Join(append(driverParams, "options ...*ray.RayOption"), ", ")
+	return def
+}
+
+// generateStreamMethods generates the worker-side methods of the streams, it must be called before dumping imports.
+// They are methods of the actor structs in the scanned package, and of the TaskStreams actor for the tasks.
+func (g *Generator) generateStreamMethods(buf *bytes.Buffer) {
+	if len(g.streams) == 0 {
+		return
+	}
+	g.importStore.AddImport("sync")
+	for _, m := range g.streams {
+		if err := streamMethodTmpl.Execute(buf, g.streamDef(m, "")); err != nil {
+			panic(err)
+		}
+	}
+	if len(g.streamsOf(g.tasksStruct)) > 0 {
+		data := struct{ TasksStruct, ActorsStruct string }{g.tasksStruct, g.actorsStruct}
+		if err := taskStreamsActorTmpl.Execute(buf, data); err != nil {
+			panic(err)
+		}
+	}
+	buf.WriteString(streamWorkerHelpers)
+}
+
+// generateStream generates the driver-side stream of the method: a function taking the actor,
+// named like the wrapper of the method with a Stream suffix, or a method of the actor handle if handle is set.
+func (g *Generator) generateStream(buf *bytes.Buffer, m Method, funcName, actorType string, handle bool, docQualifier string) {
+	def := g.streamDef(m, docQualifier)
+	def.FuncName = funcName
+	if handle {
+		def.Receiver = fmt.Sprintf("(_actor *%s) ", actorType)
+	} else {
+		def.DriverParams = fmt.Sprintf("_actor *%s, %s", actorType, def.DriverParams)
+	}
+	if err := streamTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
+
+// generateStreamHelpers generates the helpers shared by the driver-side streams, if any.
+func (g *Generator) generateStreamHelpers(buf *bytes.Buffer, taskStreams bool) {
+	if err := streamHelpersTmpl.Execute(buf, struct{ TaskStreams bool }{taskStreams}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,198 @@
+package goraygen
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const streamSources = `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Count(n int) <-chan int { return nil }
+
+func (Tasks) Scan(dir string, exts ...string) (chan []string, error) { return nil, scanError{} }
+
+func (Tasks) Sink() chan<- int { return nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+// rayactor
+type Counter struct{ n int }
+
+type Events <-chan int
+
+func (c *Counter) Watch() Events { return nil }
+
+func (c *Counter) Get() int { return c.n }
+
+type scanError struct{}
+
+func (scanError) Error() string { return "no scan" }
+`
+
+func TestStreams(t *testing.T) {
+	var diagnostics []string
+	opts := Options{Streaming: true}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Severity != SeverityInfo {
+			diagnostics = append(diagnostics, d.Code+": "+d.Message)
+		}
+	})
+	code := generateFromSource(t, map[string]string{"tasks": streamSources}, opts)
+
+	// the worker-side methods
+	require.Contains(t, code, "func (_s *_taskStreams) CountStreamOpen(n int) int64 {\n\treturn _openStream[int]((&Tasks{}).Count(n))\n}")
+	require.Contains(t, code, "func (_s *_taskStreams) CountStreamNext(_id int64) ([]int, bool) {\n\treturn _nextStream[int](_id)\n}")
+	require.Contains(t, code, "func (_s *_taskStreams) ScanStreamOpen(dir string, exts ...string) (int64, error) {\n\t_ch, _err := (&Tasks{}).Scan(dir, exts...)")
+	require.Contains(t, code, "func (Actors) TaskStreams() *_taskStreams {")
+	require.Contains(t, code, "func (_s *Counter) WatchStreamOpen() int64 {\n\treturn _openStream[int](_s.Watch())\n}")
+	require.Contains(t, code, "func _nextStream[T any](id int64) (values []T, more bool) {")
+	// the driver-side streams
+	require.Contains(t, code, "func CountStream(_actor *ActorTaskStreams, n int, options ...*ray.RayOption) *ResultStream[int] {")
+	require.Contains(t, code, `_id, _err := NewRemoteFunc[*Future1[int64]]("CountStreamOpen", []any{n}, &_actor.ActorHandle).Remote(options...).Get()`)
+	require.Contains(t, code, `return NewRemoteFunc[*Future2[[]int, bool]]("CountStreamNext", []any{_id}, &_actor.ActorHandle).Remote().Get()`)
+	require.Contains(t, code, "func ScanStream(_actor *ActorTaskStreams, dir string, exts []string, options ...*ray.RayOption) *ResultStream[[]string] {")
+	require.Contains(t, code, `_id, _taskErr, _err := NewRemoteFunc[*Future2[int64, error]]("ScanStreamOpen", ExpandArgs([]any{dir}, exts), &_actor.ActorHandle).Remote(options...).Get()`)
+	require.Contains(t, code, "func Counter_WatchStream(_actor *ActorCounter, options ...*ray.RayOption) *ResultStream[int] {")
+	require.Contains(t, code, "func (_actor *CounterActorHandle) WatchStream(options ...*ray.RayOption) *ResultStream[int] {")
+	require.Contains(t, code, "func NewTaskStreams() *RemoteActor[ActorTaskStreams] {")
+	require.Contains(t, code, "func (s *ResultStream[T]) Next() (value T, ok bool, err error) {")
+	// the streamed methods have no plain wrappers
+	require.NotContains(t, code, "func Count[")
+	require.NotContains(t, code, "func Counter_Watch(")
+	require.Contains(t, code, "func Counter_Get(")
+	require.Equal(t, []string{"unsupported-result: Skip method (Tasks).Sink: result 0 of channel type chan<- int can't be received by the caller"}, diagnostics)
+
+	require.NotContains(t, generateFromSource(t, map[string]string{"tasks": streamSources}, Options{}), "Stream")
+}
+
+func TestSkipStreams(t *testing.T) {
+	var warnings []string
+	opts := Options{Streaming: true}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "skip-stream" {
+			warnings = append(warnings, d.Message)
+		}
+	})
+	code := generateFromSource(t, map[string]string{"tasks": `package mypkg
+
+import "context"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Count(n int) <-chan int { return nil }
+
+func (Tasks) Follow(ctx context.Context) <-chan int { return nil }
+
+func (Tasks) Items() <-chan string { return nil }
+
+func (Tasks) ItemsStreamNext() {}
+`}, opts)
+	require.NotContains(t, code, "Stream(")
+	require.Equal(t, []string{
+		"Skip method (Tasks).Follow: a stream can't pass a context.Context",
+		"Skip method (Tasks).Items: Tasks already declares the ItemsStreamNext method of its stream",
+		"Skip method (Tasks).Count: a task stream needs a rayactors struct to register the TaskStreams actor",
+	}, warnings)
+}
+
+func TestStreamsOutsidePackage(t *testing.T) {
+	var warnings []string
+	opts := Options{Streaming: true}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "skip-stream" {
+			warnings = append(warnings, fmt.Sprintf("%d: %s", d.Line, d.Message))
+		}
+	})
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Count(n int) <-chan int { return nil }
+`}, "example.com/mypkg")
+	g := NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = "out", "example.com/out"
+	g.collectWorkloads()
+	require.Empty(t, g.streams)
+	require.Empty(t, g.tasks)
+	require.Equal(t, []string{"6: Skip method (Tasks).Count: the stream must be generated into package example.com/mypkg"}, warnings)
+}
+
+func TestStreamsTargetList(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": streamSources}, "example.com/mypkg")
+	g := NewGenerator(Options{Streaming: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+	g.collectActorHandles()
+
+	list := g.targetList()
+	require.Contains(t, list.Structs[0].Methods, targetMethod{Name: "Count", Signature: "Count(n int) (<-chan int)", Stream: "CountStream"})
+	require.Contains(t, list.Structs[2].Methods, targetMethod{Name: "Watch", Signature: "Watch() (Events)", Stream: "Counter_WatchStream"})
+	require.Contains(t, list.Structs[3].Methods, targetMethod{Name: "Watch", Signature: "Watch() (Events)", Stream: "WatchStream"})
+}
+
+func TestStreamsBuild(t *testing.T) {
+	buildGenerated(t, map[string]string{"tasks": streamSources}, Options{Streaming: true, SplitWorker: true})
+	dir := buildGenerated(t, map[string]string{"tasks": streamSources}, Options{Streaming: true})
+
+	// the worker-side helpers stream the values of the channel in batches
+	test := `package mypkg
+
+import "testing"
+
+func TestStream(t *testing.T) {
+	ch := make(chan int, 2*_streamBatchSize)
+	for i := range 2*_streamBatchSize - 1 {
+		ch <- i
+	}
+	close(ch)
+	id := _openStream[int](ch)
+	next := func() ([]int, bool, error) {
+		values, more := _nextStream[int](id)
+		return values, more, nil
+	}
+	var batches int
+	stream := &ResultStream[int]{next: func() ([]int, bool, error) { batches++; return next() }}
+	for want := range 2*_streamBatchSize - 1 {
+		v, ok, err := stream.Next()
+		if v != want || !ok || err != nil {
+			t.Fatalf("Next() = %v, %v, %v, want %v", v, ok, err, want)
+		}
+	}
+	if _, ok, err := stream.Next(); ok || err != nil {
+		t.Fatalf("Next() after the end = %v, %v", ok, err)
+	}
+	if batches != 2 {
+		t.Fatalf("received in %d batches, want 2", batches)
+	}
+	if _, ok := _streams.chans[id]; ok {
+		t.Fatal("the closed stream is kept")
+	}
+	if values, more := _nextStream[int](_openStream[int](nil)); values != nil || more {
+		t.Fatal("a nil channel isn't an empty stream")
+	}
+	id, err := (&_taskStreams{}).ScanStreamOpen("dir")
+	if id != 0 || err == nil || err.Error() != "no scan" {
+		t.Fatalf("ScanStreamOpen() = %v, %v", id, err)
+	}
+}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stream_test.go"), []byte(test), 0o644))
+	out, err := exec.Command("go", "test", "-C", dir, "./...").CombinedOutput()
+	require.NoError(t, err, "%s", out)
+}
@@ -0,0 +1,293 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+const taskCLIDir = "cmd/taskcli"
+
+/*
+With -gen-task-cli, cmd/taskcli of the module is a driver calling one task per run, e.g.
+
+	taskcli Divide -a 16 -b 5 -option num_cpus=2
+	taskcli Divide -json '{"a": 16, "b": 5}'
+
+printing the results as a JSON object keyed by their indexes: {"r0": 3, "r1": 1}.
+*/
+const taskCLITpl = `// {{.Header}}
+
+// Command taskcli calls a ray task of {{.PkgPath}} and prints its results, for poking at a cluster from a terminal:
+//
+//	taskcli <task> [-<param> value]... [-json '{"<param>": value}'] [-option name=value]...
+//
+// The param values are JSON, except for the string params taking the raw value.
+// Run taskcli without arguments to list the tasks.
+//
+// To regenerate this file, run:
+//
+//	goraygen -gen-task-cli <package-path>
+package main
+
+{{.Imports}}
+// _taskCommand is the subcommand calling a task.
+type _taskCommand struct {
+	signature string
+	run       func(args []string, options []*ray.RayOption) (any, error)
+}
+
+var _taskCommands = map[string]_taskCommand{
+	{{- range .Tasks}}
+	"{{.Name}}": {
+		signature: {{printf "%q" .Signature}},
+		run: func(args []string, options []*ray.RayOption) (any, error) {
+			var _req struct{ {{- if .Params}}
+				{{- range .Params}}
+				{{.Field}} {{.Type}} ` + "`json:\"{{.Name}}\"`" + `
+				{{- end}}
+			{{end -}} }
+			fs := _newFlagSet("{{.Name}}", &_req, &options)
+			{{- range .Params}}
+			{{- if .Raw}}
+			fs.Func("{{.Name}}", "param {{.Name}} {{.GoType}}", func(s string) error {
+				_req.{{.Field}} = {{.Type}}(s)
+				return nil
+			})
+			{{- else}}
+			fs.Func("{{.Name}}", "param {{.Name}} {{.GoType}}, as JSON", func(s string) error {
+				return json.Unmarshal([]byte(s), &_req.{{.Field}})
+			})
+			{{- end}}
+			{{- end}}
+			if err := fs.Parse(args); err != nil {
+				return nil, err
+			}
+			{{.Results}} := {{$.Wrappers}}{{.Name}}({{.CallArgs}}).Remote(options...).Get()
+			if _err != nil {
+				return nil, _err
+			}
+			{{- if .ReturnsError}}
+			if _taskErr != nil {
+				return nil, _taskErr
+			}
+			{{- end}}
+			return struct{ {{- if .ResultTypes}}
+				{{- range $i, $t := .ResultTypes}}
+				R{{$i}} {{$t}} ` + "`json:\"r{{$i}}\"`" + `
+				{{- end}}
+			{{end -}} }{ {{- .ResultVars -}} }, nil
+		},
+	},
+	{{- end}}
+}
+
+// _newFlagSet returns the flags of the task: -json setting all the params, and -option adding the ray options.
+func _newFlagSet(name string, req any, options *[]*ray.RayOption) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Func("json", "all the params as a JSON object, the param flags after it override its values", func(s string) error {
+		return json.Unmarshal([]byte(s), req)
+	})
+	fs.Func("option", "ray option name=value, the value is JSON or a raw string (repeatable)", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("invalid option %q, expect name=value", s)
+		}
+		var v any = value
+		if json.Valid([]byte(value)) {
+			_ = json.Unmarshal([]byte(value), &v)
+		}
+		*options = append(*options, ray.Option(name, v))
+		return nil
+	})
+	return fs
+}
+
+func init() {
+	ray.Init({{.RegisterTasks}}, {{.RegisterActors}}, driver)
+}
+
+func driver() int {
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		_usage()
+		return 2
+	}
+	cmd, ok := _taskCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown task %q\n", args[0])
+		_usage()
+		return 2
+	}
+	result, err := cmd.run(args[1:], nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: encode results: %v\n", args[0], err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
+
+func _usage() {
+	fmt.Fprintln(os.Stderr, "Usage: taskcli <task> [-<param> value]... [-json '{\"<param>\": value}'] [-option name=value]...\n\nTasks:")
+	names := make([]string, 0, len(_taskCommands))
+	for name := range _taskCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", _taskCommands[name].signature)
+	}
+}
+
+func main() {}
+`
+
+var taskCLITmpl = template.Must(template.New("taskCLI").Parse(taskCLITpl))
+
+// TaskCLIDef is a subcommand of the task CLI, the template data of taskCLITpl.
+type TaskCLIDef struct {
+	HTTPTaskDef
+	Signature string
+	Params    []TaskCLIParamDef
+}
+
+// TaskCLIParamDef is a param flag of the subcommand.
+type TaskCLIParamDef struct {
+	HTTPParamDef
+	GoType string // the type in the usage
+	Raw    bool   // the flag value is the string, not JSON
+}
+
+// writeTaskCLI generates the task CLI main package into cmd/taskcli of the module of the scanned package.
+// Like the worker, it registers the tasks and actors, as go-ray runs the same library on the driver and the workers.
+func (g *Generator) writeTaskCLI() error {
+	if g.pkg.Name == "main" {
+		return fmt.Errorf("can't generate task CLI for package main %s, move the tasks/actors into a library package", g.pkg.PkgPath)
+	}
+	if g.opts.Lang == LangPython {
+		return fmt.Errorf("can't generate task CLI with -lang=python, it calls the Go wrappers")
+	}
+	if g.fromTestFile {
+		return fmt.Errorf("can't generate task CLI for the tasks declared in test files of %s", g.pkg.PkgPath)
+	}
+	if g.outputPkgPath == "" {
+		return fmt.Errorf("can't generate task CLI: unknown import path of the output package")
+	}
+	if g.tasksStruct == "" {
+		g.warnf("skip-task-cli", "Skip task CLI: no tasks in package %s", g.pkg.PkgPath)
+		return nil
+	}
+	rootDir := filepath.Dir(g.pkg.GoFiles[0])
+	if g.pkg.Module != nil && g.pkg.Module.Dir != "" {
+		rootDir = g.pkg.Module.Dir
+	}
+	outputDir := filepath.Join(rootDir, taskCLIDir)
+	if err := g.mkdirAll(outputDir); err != nil {
+		return fmt.Errorf("create task CLI dir error: %w", err)
+	}
+	code, err := g.generateTaskCLI()
+	if err != nil {
+		return err
+	}
+	return g.writeFile(code, filepath.Join(outputDir, "main.go"))
+}
+
+// generateTaskCLI generates the main package of the task CLI.
+func (g *Generator) generateTaskCLI() (string, error) {
+	importStore := g.newImportStore()
+	for _, pkg := range []string{goRayRepo, "encoding/json", "flag", "fmt", "os", "sort", "strings"} {
+		importStore.AddImport(pkg)
+	}
+	// the types as rendered by the wrappers
+	g.mapSignatureTypes(importStore, g.outputPkgPath)
+	cliPkgPath := g.pkg.PkgPath + "/" + taskCLIDir // not the actual path, only needs to differ from the other packages
+	workloads := importStore.AddPackage(g.pkg.Types)
+	registerTasks, registerActors := "nil", "nil"
+	if g.splitWorker() {
+		registerTasks = workloads + ".RayTasks"
+		if g.actorsStruct != "" {
+			registerActors = workloads + ".RayActors"
+		}
+	} else {
+		if !token.IsExported(g.tasksStruct) || (g.actorsStruct != "" && !token.IsExported(g.actorsStruct)) {
+			return "", fmt.Errorf("can't generate task CLI: the tasks/actors structs of %s must be exported, or use -split-worker", g.pkg.PkgPath)
+		}
+		registerTasks = registerValue(workloads+"."+g.tasksStruct, g.tasks)
+		if g.actorsStruct != "" {
+			registerActors = registerValue(workloads+"."+g.actorsStruct, g.actorFactories)
+		}
+	}
+	wrappers := importStore.AddPackage(types.NewPackage(g.outputPkgPath, g.outputPkgName)) + "."
+
+	var tasks []TaskCLIDef
+	for _, m := range g.tasks {
+		cm, passContext := withoutContext(m) // called with the background context
+		if !gslice.All(cm.Params, func(p Param) bool { return jsonEncodable(p.GoType) && !g.unexportedAnonymous(p) }) ||
+			!gslice.All(m.Results, func(r Result) bool { return r.IsError || jsonEncodable(r.GoType) }) {
+			g.warnAt(g.methodPos(m), "skip-task-cli", "Skip task CLI command of %s: its params or results are not encodable in JSON", m.Name)
+			continue
+		}
+		fields := gslice.Map(cm.Params, func(p Param) string { return exportedFieldName(p.Name) })
+		if len(gslice.Uniq(fields)) != len(fields) || gslice.Any(cm.Params, func(p Param) bool { return p.Name == "json" || p.Name == "option" }) {
+			g.warnAt(g.methodPos(m), "skip-task-cli", "Skip task CLI command of %s: its params can't be named as flags", m.Name)
+			continue
+		}
+		def := TaskCLIDef{HTTPTaskDef: HTTPTaskDef{Name: m.Name, ReturnsError: m.ReturnsError()}, Signature: m.String()}
+		var callArgs []string
+		if passContext {
+			importStore.AddImport("context")
+			callArgs = append(callArgs, "context.Background()")
+		}
+		for i, p := range cm.Params {
+			typ := analysis.TypeName(p.GoType, cliPkgPath, importStore)
+			pd := TaskCLIParamDef{HTTPParamDef: HTTPParamDef{Name: p.Name, Field: exportedFieldName(p.Name), Type: typ}, GoType: typ}
+			basic, ok := p.GoType.Underlying().(*types.Basic)
+			pd.Raw = ok && basic.Info()&types.IsString != 0
+			arg := "_req." + pd.Field
+			if _, ok := g.anonymousTypes[p.Type]; ok { // the wrapper takes the named type, see nameAnonymousTypes
+				arg = fmt.Sprintf("%s%s(%s)", wrappers, p.Type, arg)
+			}
+			if cm.IsVariadic && i == len(cm.Params)-1 {
+				arg += "..."
+			}
+			def.Params = append(def.Params, pd)
+			callArgs = append(callArgs, arg)
+		}
+		def.CallArgs = strings.Join(callArgs, ", ")
+		var results, vars []string
+		for i, r := range m.Results {
+			if r.IsError {
+				results = append(results, "_taskErr")
+				continue
+			}
+			results = append(results, fmt.Sprintf("_r%d", i))
+			vars = append(vars, fmt.Sprintf("_r%d", i))
+			def.ResultTypes = append(def.ResultTypes, analysis.TypeName(r.GoType, cliPkgPath, importStore))
+		}
+		def.Results = strings.Join(append(results, "_err"), ", ")
+		def.ResultVars = strings.Join(vars, ", ")
+		tasks = append(tasks, def)
+	}
+	var buf bytes.Buffer
+	err := taskCLITmpl.Execute(&buf, struct {
+		Header, PkgPath, Wrappers     string
+		RegisterTasks, RegisterActors string
+		Imports                       string
+		Tasks                         []TaskCLIDef
+	}{g.opts.generatedHeader(), g.pkg.PkgPath, wrappers, registerTasks, registerActors, importStore.ImportBlock(g.modulePath()), tasks})
+	return buf.String(), err
+}
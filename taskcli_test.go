@@ -0,0 +1,49 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTaskCLI(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Greet(name Name, others ...string) error { return nil }
+
+func (Tasks) Each(f func(int)) {}
+
+type Name string
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{GenTaskCLI: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	require.NoError(t, g.writeTaskCLI())
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), taskCLIDir, "main.go"))
+	require.NoError(t, err)
+	code := string(data)
+	require.Contains(t, code, "package main")
+	require.Contains(t, code, "ray.Init(mypkg.Tasks{}, nil, driver)")
+	require.Contains(t, code, `fs.Func("a", "param a int64, as JSON", func(s string) error {`)
+	require.Contains(t, code, "_r0, _r1, _err := mypkg.Divide(_req.A, _req.B).Remote(options...).Get()")
+	require.Contains(t, code, "_req.Name = mypkg.Name(s)")
+	require.Contains(t, code, "_taskErr, _err := mypkg.Greet(_req.Name, _req.Others...).Remote(options...).Get()")
+	require.NotContains(t, code, `"Each": {`) // func param
+
+	g = NewGenerator(Options{SplitWorker: true, GenTaskCLI: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	code, err = g.generateTaskCLI()
+	require.NoError(t, err)
+	require.Contains(t, code, "ray.Init(mypkg.RayTasks, nil, driver)")
+}
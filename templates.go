@@ -0,0 +1,184 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+)
+
+/*
+With -templates=dir, the files <artifact>.tmpl of dir override the built-in templates of the artifacts:
+
+	task.tmpl          the caller wrapper of a task, e.g. Divide(a, b)             data: FuncDef
+	actor.tmpl         the actor handle type and its factory wrapper NewCounter    data: FuncDef
+	actor_method.tmpl  the caller wrapper of an actor method, e.g. Counter_Incr    data: FuncDef
+	options.tmpl       the option builders of -option-builders, e.g. DivideOptions data: FuncDef
+	registration.tmpl  the register structs of the worker file of -split-worker    data: RegistrationDef
+
+FuncDef.Method is the scanned method (params, results and doc), e.g. a task.tmpl logging the calls:
+
+	// {{.FuncName}} calls the {{.TaskName}} task with {{len .Method.Params}} params.
+	func {{.FuncName}} {{.TypeConstraints}} ( {{.ParamList}} ) *RemoteFunc[*Future{{.ResLen}}{{.ResTypes}}] {
+		log.Printf("calling %s", {{quote .TaskName}})
+		return NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]({{quote .TaskName}}, {{.ArgsStatement}})
+	}
+
+The imports of the generated file are fixed by goimports.
+*/
+
+// templateArtifacts are the names of the artifacts whose templates can be overridden, see Options.TemplatesDir.
+var templateArtifacts = []string{"task", "actor", "actor_method", "options", "registration"}
+
+// templateFuncs are the functions available in the templates, extended by RegisterTemplateFuncs.
+var templateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"title":      exportedFieldName,
+	"snake":      snakeCase,
+	"quote":      strconv.Quote,
+	"join":       strings.Join,
+	"hasPrefix":  strings.HasPrefix,
+	"trimPrefix": strings.TrimPrefix,
+	"docText":    docText,
+}
+
+// RegisterTemplateFuncs adds the functions to the ones available in the templates, the built-in and the overriding ones,
+// replacing the functions of the same names.
+func RegisterTemplateFuncs(funcs template.FuncMap) {
+	for name, f := range funcs {
+		templateFuncs[name] = f
+	}
+}
+
+/*
+var (
+
+	RayTasks  = Tasks{}
+	RayActors = Actors{}
+
+)
+*/
+const registrationTpl = `
+// The register structs of the ray tasks and actors in this package, register them on the worker side with ray.Init.
+var (
+	{{- if .TasksStruct}}
+	RayTasks = {{.TasksValue}}
+	{{- end}}
+	{{- if .ActorsStruct}}
+	RayActors = {{.ActorsValue}}
+	{{- end}}
+)
+`
+
+// RegistrationDef is the template data of registrationTpl.
+type RegistrationDef struct {
+	TasksStruct    string // empty if there are no tasks
+	ActorsStruct   string // empty if there are no actors
+	TasksValue     string // the expression of the register struct value, e.g. "Tasks{}" or "&Tasks{}"
+	ActorsValue    string
+	Tasks          []Method
+	ActorFactories []Method
+}
+
+// loadTemplates loads the templates overriding the built-in ones from Options.TemplatesDir, once per generator.
+// They are parsed upfront, so the syntax errors are reported with their files.
+func (g *Generator) loadTemplates() error {
+	if g.opts.TemplatesDir == "" || g.templates != nil {
+		return nil
+	}
+	files, err := filepath.Glob(g.templateFile("*"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no *.tmpl files in templates dir %s", g.opts.TemplatesDir)
+	}
+	g.templates = make(map[string]string)
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		if !gslice.Contains(templateArtifacts, name) {
+			return fmt.Errorf("unknown template %s, expect one of %s", file, strings.Join(templateArtifacts, ", "))
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := template.New(name).Funcs(templateFuncs).Parse(string(data)); err != nil {
+			return fmt.Errorf("parse template %s: %w", file, err)
+		}
+		g.templates[name] = string(data)
+		g.logger.Debug("Use template", "path", file)
+	}
+	return nil
+}
+
+// template returns the template of the artifact: the overriding one if any, otherwise builtin.
+func (g *Generator) template(artifact, builtin string) string {
+	if tpl, ok := g.templates[artifact]; ok {
+		return tpl
+	}
+	return builtin
+}
+
+// templateFile returns the path of the template overriding the artifact, see loadTemplates.
+func (g *Generator) templateFile(artifact string) string {
+	return filepath.Join(g.opts.TemplatesDir, artifact+".tmpl")
+}
+
+// keepTemplateError keeps the first error executing the template of the artifact, if not nil, to fail the writes of
+// the generated files with (see writeFile): against the file of the overriding template if any, e.g. for a missing field,
+// otherwise it's a bug of the built-in one. The artifact is empty for the templates which can't be overridden.
+func (g *Generator) keepTemplateError(artifact string, err error) {
+	if err == nil || g.templateErr != nil {
+		return
+	}
+	if _, ok := g.templates[artifact]; ok {
+		g.templateErr = fmt.Errorf("execute template %s: %w", g.templateFile(artifact), err)
+	} else {
+		g.templateErr = fmt.Errorf("execute built-in template: %w", err)
+	}
+}
+
+// generateArtifact renders the template of the artifact (see template) for the method, like generateWrapperFunction.
+func (g *Generator) generateArtifact(artifact, builtin string, buf *bytes.Buffer, method Method, paramTypeMapper *ParameterTypeConstraints, actorName, docQualifier string) {
+	g.generateArtifactWith(artifact, builtin, buf, method, paramTypeMapper, actorName, docQualifier, nil)
+}
+
+// generateArtifactWith is like generateArtifact, with customize (if not nil) to adjust the template data.
+func (g *Generator) generateArtifactWith(artifact, builtin string, buf *bytes.Buffer, method Method, paramTypeMapper *ParameterTypeConstraints, actorName, docQualifier string, customize func(*FuncDef)) {
+	g.keepTemplateError(artifact, generateWrapperFunctionWith(g.template(artifact, builtin), buf, method, paramTypeMapper, actorName, docQualifier, customize))
+}
+
+// generateRegistration generates the register structs of the tasks and actors to pass to ray.Init.
+func (g *Generator) generateRegistration(buf *bytes.Buffer) {
+	if g.tasksStruct == "" && g.actorsStruct == "" {
+		return
+	}
+	def := RegistrationDef{
+		TasksStruct:    g.tasksStruct,
+		ActorsStruct:   g.actorsStruct,
+		Tasks:          g.tasks,
+		ActorFactories: g.actorFactories,
+	}
+	if g.tasksStruct != "" {
+		def.TasksValue = registerValue(g.tasksStruct, slices.Concat(g.tasks, g.ioTasks))
+		if g.drainsTasks() {
+			def.TasksValue = fmt.Sprintf("_drainTasks{&%s{}}", g.tasksStruct)
+		}
+	}
+	if g.actorsStruct != "" {
+		def.ActorsValue = registerValue(g.actorsStruct, g.actorFactories)
+	}
+	tmpl, err := template.New("registration").Funcs(templateFuncs).Parse(g.template("registration", registrationTpl))
+	if err == nil {
+		err = tmpl.Execute(buf, def)
+	}
+	g.keepTemplateError("registration", err)
+}
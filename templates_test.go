@@ -0,0 +1,76 @@
+package goraygen
+
+import (
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate := func(name, tpl string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".tmpl"), []byte(tpl), 0o644))
+	}
+	writeTemplate("task", `
+// {{.FuncName}} calls {{quote .TaskName}} with {{len .Method.Params}} params.
+func {{.FuncName}} {{.TypeConstraints}} ( {{.ParamList}} ) *RemoteFunc[*Future{{.ResLen}}{{.ResTypes}}] {
+	return NewRemoteFunc[*Future{{.ResLen}}{{.ResTypes}}]("{{snake .TaskName}}", {{.ArgsStatement}})
+}
+`)
+	writeTemplate("registration", `
+var RayTasks = {{.TasksValue}} // {{len .Tasks}} tasks
+`)
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) DivideBy(a, b int64) (int64, int64) { return a / b, a % b }
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{TemplatesDir: dir, SplitWorker: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	require.NoError(t, g.loadTemplates())
+	g.collectWorkloads()
+	g.collectActorMethods()
+
+	code, err := format.Source([]byte(g.generateCode()))
+	require.NoError(t, err)
+	require.Contains(t, string(code), "// DivideBy calls \"DivideBy\" with 2 params.")
+	require.Contains(t, string(code), `NewRemoteFunc[*Future2[int64, int64]]("divide_by", []any{a, b})`)
+	require.Contains(t, g.generateWorkerCode(), "var RayTasks = Tasks{} // 1 tasks")
+
+	writeTemplate("caller", "")
+	require.ErrorContains(t, NewGenerator(Options{TemplatesDir: dir}).loadTemplates(), "unknown template")
+	require.NoError(t, os.Remove(filepath.Join(dir, "caller.tmpl")))
+	writeTemplate("actor", "{{.FuncName")
+	require.ErrorContains(t, NewGenerator(Options{TemplatesDir: dir}).loadTemplates(), "parse template")
+}
+
+func TestTemplateExecuteError(t *testing.T) {
+	for artifact, tpl := range map[string]string{
+		"task":         "{{.NoSuchField}}",
+		"registration": "{{.NoSuchField}}",
+	} {
+		t.Run(artifact, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, artifact+".tmpl")
+			require.NoError(t, os.WriteFile(file, []byte(tpl), 0o644))
+			pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) int64 { return a / b }
+`}, "example.com/mypkg")
+			g := NewGenerator(Options{TemplatesDir: dir, SplitWorker: true})
+			g.pkg = pkg
+			err := g.generate(filepath.Join(t.TempDir(), "client"))
+			require.ErrorContains(t, err, "execute template "+file)
+			require.ErrorContains(t, err, "NoSuchField")
+		})
+	}
+}
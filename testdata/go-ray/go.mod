@@ -0,0 +1,3 @@
+module github.com/ray4go/go-ray
+
+go 1.24
@@ -0,0 +1,108 @@
+// Package generic is a stub of the typed go-ray API referenced by the generated code, see package ray.
+package generic
+
+import "github.com/ray4go/go-ray/ray"
+
+// RemoteFunc is a remote call of a task or actor method, whose future is F.
+type RemoteFunc[F any] struct{}
+
+// NewRemoteFunc returns the remote call of the task or actor method name with the args.
+func NewRemoteFunc[F any](name string, args []any, actor ...*ray.ActorHandle) *RemoteFunc[F] {
+	return &RemoteFunc[F]{}
+}
+
+// Remote submits the call.
+func (f *RemoteFunc[F]) Remote(options ...*ray.RayOption) F {
+	var future F
+	return future
+}
+
+// RemoteActor is the remote creation of an actor, whose handle is T.
+type RemoteActor[T any] struct{}
+
+// NewRemoteActor returns the remote creation of the actor name with the args.
+func NewRemoteActor[T any](name string, args []any) *RemoteActor[T] {
+	return &RemoteActor[T]{}
+}
+
+// Remote creates the actor.
+func (a *RemoteActor[T]) Remote(options ...*ray.RayOption) *T {
+	return new(T)
+}
+
+// ExpandArgs appends the variadic args to args.
+func ExpandArgs[T any](args []any, variadic []T) []any {
+	for _, v := range variadic {
+		args = append(args, v)
+	}
+	return args
+}
+
+// Future0 is the future of a call without results.
+type Future0 struct{}
+
+// Get waits for the call.
+func (*Future0) Get() error { return nil }
+
+// ObjectRef returns the reference to the results.
+func (*Future0) ObjectRef() ray.ObjectRef { return ray.ObjectRef{} }
+
+// Future1 is the future of a call with 1 results.
+type Future1[T0 any] struct{}
+
+// Get waits for the results.
+func (*Future1[T0]) Get() (r0 T0, err error) { return r0, nil }
+
+// ObjectRef returns the reference to the results.
+func (*Future1[T0]) ObjectRef() ray.ObjectRef { return ray.ObjectRef{} }
+
+// Future2 is the future of a call with 2 results.
+type Future2[T0, T1 any] struct{}
+
+// Get waits for the results.
+func (*Future2[T0, T1]) Get() (r0 T0, r1 T1, err error) { return r0, r1, nil }
+
+// ObjectRef returns the reference to the results.
+func (*Future2[T0, T1]) ObjectRef() ray.ObjectRef { return ray.ObjectRef{} }
+
+// Future3 is the future of a call with 3 results.
+type Future3[T0, T1, T2 any] struct{}
+
+// Get waits for the results.
+func (*Future3[T0, T1, T2]) Get() (r0 T0, r1 T1, r2 T2, err error) { return r0, r1, r2, nil }
+
+// ObjectRef returns the reference to the results.
+func (*Future3[T0, T1, T2]) ObjectRef() ray.ObjectRef { return ray.ObjectRef{} }
+
+// Future4 is the future of a call with 4 results.
+type Future4[T0, T1, T2, T3 any] struct{}
+
+// Get waits for the results.
+func (*Future4[T0, T1, T2, T3]) Get() (r0 T0, r1 T1, r2 T2, r3 T3, err error) {
+	return r0, r1, r2, r3, nil
+}
+
+// ObjectRef returns the reference to the results.
+func (*Future4[T0, T1, T2, T3]) ObjectRef() ray.ObjectRef { return ray.ObjectRef{} }
+
+// Future5 is the future of a call with 5 results.
+type Future5[T0, T1, T2, T3, T4 any] struct{}
+
+// Get waits for the results.
+func (*Future5[T0, T1, T2, T3, T4]) Get() (r0 T0, r1 T1, r2 T2, r3 T3, r4 T4, err error) {
+	return r0, r1, r2, r3, r4, nil
+}
+
+// ObjectRef returns the reference to the results.
+func (*Future5[T0, T1, T2, T3, T4]) ObjectRef() ray.ObjectRef { return ray.ObjectRef{} }
+
+// Future6 is the future of a call with 6 results.
+type Future6[T0, T1, T2, T3, T4, T5 any] struct{}
+
+// Get waits for the results.
+func (*Future6[T0, T1, T2, T3, T4, T5]) Get() (r0 T0, r1 T1, r2 T2, r3 T3, r4 T4, r5 T5, err error) {
+	return r0, r1, r2, r3, r4, r5, nil
+}
+
+// ObjectRef returns the reference to the results.
+func (*Future6[T0, T1, T2, T3, T4, T5]) ObjectRef() ray.ObjectRef { return ray.ObjectRef{} }
@@ -0,0 +1,34 @@
+// Package ray is a stub of the go-ray API referenced by the generated code, to type-check it in the tests
+// without the go-ray runtime, see buildGenerated.
+package ray
+
+// Init registers the tasks and actors, and runs driver.
+func Init(tasks, actors any, driver func() int) {}
+
+// RayOption is an option of a remote call, see Option.
+type RayOption struct {
+	Name  string
+	Value any
+}
+
+// Option returns the ray option name with the value.
+func Option(name string, value any) *RayOption {
+	return &RayOption{Name: name, Value: value}
+}
+
+// ObjectRef is the reference to the result of a remote call.
+type ObjectRef struct{}
+
+// Cancel cancels the remote call.
+func (ObjectRef) Cancel() error { return nil }
+
+// ActorHandle is the handle of a remote actor.
+type ActorHandle struct{}
+
+// SharedObject is a value put into the object store, see Put.
+type SharedObject[T any] struct{}
+
+// Put puts the value into the object store.
+func Put[T any](v T) (SharedObject[T], error) {
+	return SharedObject[T]{}, nil
+}
@@ -0,0 +1,129 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/*
+	//goray:timeout 30s
+	func (Tasks) Divide(a, b int64) (int64, int64)
+
+generates:
+
+	func DivideWithTimeout(_timeout time.Duration, a int64, b int64) (int64, int64, error)
+*/
+const timeoutVariantTpl = `
+// {{.Name}}WithTimeout calls [{{.Name}}] remotely and waits for the results, the call is cancelled
+// if it doesn't finish within _timeout{{if .DefaultExpr}} ({{.Default}} if _timeout is 0, see //goray:timeout){{end}}
+{{- if .Context}} or when {{.Context}} is done.
+// The task is passed the earlier of the deadlines.
+{{- else}}.
+{{- end}}
+func {{.Name}}WithTimeout(_timeout time.Duration, {{if .ActorName}}_actor *Actor{{.ActorName}}{{if .Params}}, {{end}}{{end}}{{.Params}}) ({{range .ResultTypes}}{{.}}, {{end}}error) {
+	{{- if .DefaultExpr}}
+	if _timeout == 0 {
+		_timeout = {{.DefaultExpr}}
+	}
+	{{- end}}
+	{{- if .Context}}
+	{{.Context}}, _cancel := context.WithTimeoutCause({{.Context}}, _timeout, fmt.Errorf("{{.Name}} timed out after %s: %w", _timeout, context.DeadlineExceeded))
+	defer _cancel()
+	{{- end}}
+	_future := {{.Name}}({{.CallArgs}}).Remote()
+	type result struct {
+		{{range $i, $t := .ResultTypes}}r{{$i}} {{$t}}
+		{{end}}err error
+	}
+	_ch := make(chan result, 1)
+	go func() {
+		var res result
+		{{- if .ReturnsError}}
+		var taskErr error
+		{{range $i, $t := .ResultTypes}}res.r{{$i}}, {{end}}taskErr, res.err = _future.Get()
+		if res.err == nil {
+			res.err = taskErr
+		}
+		{{- else}}
+		{{range $i, $t := .ResultTypes}}res.r{{$i}}, {{end}}res.err = _future.Get()
+		{{- end}}
+		_ch <- res
+	}()
+	{{- if not .Context}}
+	_timer := time.NewTimer(_timeout)
+	defer _timer.Stop()
+	{{- end}}
+	select {
+	case res := <-_ch:
+		return {{range $i, $t := .ResultTypes}}res.r{{$i}}, {{end}}res.err
+	{{- if .Context}}
+	case <-{{.Context}}.Done():
+	{{- else}}
+	case <-_timer.C:
+	{{- end}}
+		_future.ObjectRef().Cancel()
+		{{- if .ResultTypes}}
+		var res result
+		{{- end}}
+		return {{range $i, $t := .ResultTypes}}res.r{{$i}}, {{end}}
+		{{- if .Context}}context.Cause({{.Context}}){{else}}fmt.Errorf("{{.Name}} timed out after %s: %w", _timeout, context.DeadlineExceeded){{end}}
+	}
+}
+`
+
+var timeoutVariantTmpl = template.Must(template.New("timeoutVariant").Parse(timeoutVariantTpl))
+
+const timeoutDirective = "timeout"
+
+// TimeoutVariantDef is the template data of timeoutVariantTpl.
+type TimeoutVariantDef struct {
+	Name         string // wrapper function name, e.g. "Divide" or "Counter_Incr"
+	ActorName    string
+	Params       string
+	CallArgs     string
+	ResultTypes  []string // without the error-last result, which is merged into the error
+	ReturnsError bool
+	Context      string // the name of the context.Context param, if any, whose deadline is shortened to the timeout
+	Default      time.Duration
+	DefaultExpr  string // empty if there is no default timeout
+}
+
+// hasTimeoutVariant reports whether the timeout variant is generated for the method:
+// for all methods with -timeout-variants, otherwise for the methods with the //goray:timeout directive.
+func (g *Generator) hasTimeoutVariant(m Method) bool {
+	_, ok := m.Directive(timeoutDirective)
+	return ok || g.opts.TimeoutVariants
+}
+
+// generateTimeoutVariant generates the timeout variant of the method, whose wrapper function is named name.
+func (g *Generator) generateTimeoutVariant(buf *bytes.Buffer, name, actorName string, m Method) {
+	if !g.hasTimeoutVariant(m) {
+		return
+	}
+	def := TimeoutVariantDef{Name: name, ActorName: actorName, ReturnsError: m.ReturnsError()}
+	if m.TakesContext() {
+		def.Context = m.Params[0].Name
+	}
+	def.Params, def.CallArgs, def.ResultTypes = callSignature(m)
+	if actorName != "" {
+		def.CallArgs = strings.TrimSuffix("_actor, "+def.CallArgs, ", ")
+	}
+	if d, ok := m.Directive(timeoutDirective); ok {
+		timeout, err := time.Duration(0), fmt.Errorf("missing timeout")
+		if len(d.Args) > 0 {
+			timeout, err = time.ParseDuration(d.Args[0])
+		}
+		if err != nil || timeout <= 0 {
+			g.warnAt(g.directivePos(m, timeoutDirective), "invalid-directive", "%s: invalid //goray:timeout, it should be a positive duration like `//goray:timeout 30s`", name)
+		} else {
+			def.Default = timeout
+			def.DefaultExpr = durationExpr(timeout)
+		}
+	}
+	if err := timeoutVariantTmpl.Execute(buf, def); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,41 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTimeoutVariants(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+//goray:timeout 1m30s
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (Tasks) Open(name string) (int, error) { return 0, nil }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{ n int }
+
+func (c *Counter) Get() int { return c.n }
+`}
+	code := generateFromSource(t, sources, Options{})
+	require.Contains(t, code, "func DivideWithTimeout(_timeout time.Duration, a int64, b int64) (int64, int64, error) {")
+	require.Contains(t, code, "_timeout = 90 * time.Second")
+	require.Contains(t, code, "_future.ObjectRef().Cancel()")
+	require.Contains(t, code, `return res.r0, res.r1, fmt.Errorf("Divide timed out after %s: %w", _timeout, context.DeadlineExceeded)`)
+	require.NotContains(t, code, "OpenWithTimeout")
+
+	code = generateFromSource(t, sources, Options{TimeoutVariants: true})
+	require.Contains(t, code, "func OpenWithTimeout(_timeout time.Duration, name string) (int, error) {")
+	require.Contains(t, code, "res.r0, taskErr, res.err = _future.Get()")
+	require.Contains(t, code, "func Counter_GetWithTimeout(_timeout time.Duration, _actor *ActorCounter) (int, error) {")
+	require.Contains(t, code, "_future := Counter_Get(_actor).Remote()")
+}
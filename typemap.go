@@ -0,0 +1,63 @@
+package goraygen
+
+import (
+	"fmt"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+With a mapping of a type, like:
+
+	goraygen.RegisterTypeMapping(goraygen.TypeMapping{Type: "example.com/ids.UserID", Name: "string"})
+	goraygen.RegisterTypeMapping(goraygen.TypeMapping{
+		Type: "time.Time", Name: "int64", ToWire: "%s.Unix()", FromWire: "time.Unix(%s, 0)", Imports: []string{"time"},
+	})
+
+the code generated into another package than the scanned one (see Options.OutputDir) renders the params and results
+of the mapped types without conversions as their mappings, which the remote calls carry the same:
+
+	func GetUser(id string) *RemoteFunc[*Future1[User]]
+
+and the codecs (see codecTpl) encode the fields of the mapped types as their mappings, converted by the expressions:
+
+	type _EventWire struct {
+		Name string
+		At   int64
+	}
+*/
+
+// registeredTypeMappings are the mappings registered by RegisterTypeMapping.
+var registeredTypeMappings analysis.TypeMappings
+
+// RegisterTypeMapping registers the mapping for all the generators, after the ones of Options.TypeMappings.
+// It panics if the mapping is invalid or its type is already registered.
+func RegisterTypeMapping(m TypeMapping) {
+	if err := m.Validate(); err != nil {
+		panic(err)
+	}
+	if gslice.Any(registeredTypeMappings, func(r TypeMapping) bool { return r.Type == m.Type }) {
+		panic(fmt.Sprintf("type mapping of %s already registered", m.Type))
+	}
+	registeredTypeMappings = append(registeredTypeMappings, m)
+}
+
+// typeMappings returns Options.TypeMappings then the registered mappings, so the options override them.
+func (o Options) typeMappings() analysis.TypeMappings {
+	return append(append(analysis.TypeMappings{}, o.TypeMappings...), registeredTypeMappings...)
+}
+
+// signatureTypeMappings returns the mappings rendered in the task and actor signatures: the ones without conversions,
+// as the remote calls carry the values as they are.
+func (o Options) signatureTypeMappings() analysis.TypeMappings {
+	return gslice.Filter(o.typeMappings(), func(m TypeMapping) bool { return !m.Converts() })
+}
+
+// mapSignatureTypes applies the signature type mappings to the import store, if the code is generated into another package.
+// The code of the scanned package keeps the types, as it calls the methods with them.
+func (g *Generator) mapSignatureTypes(importStore *ImportStore, pkgPath string) {
+	if pkgPath != g.pkg.PkgPath {
+		importStore.MapTypes(g.opts.signatureTypeMappings())
+	}
+}
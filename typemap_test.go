@@ -0,0 +1,117 @@
+package goraygen
+
+import (
+	"go/format"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const typeMappingSource = `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Rename(id UserID, name string) UserID { return id }
+
+func (Tasks) Record(e Event, at time.Time) error { return nil }
+
+type UserID string
+
+//goray:codec json
+type Event struct {
+	Name   string
+	At     time.Time ` + "`json:\"at\"`" + `
+	Author UserID
+	seq    int
+}
+`
+
+var typeMappings = []TypeMapping{
+	{Type: "example.com/mypkg.UserID", Name: "string"},
+	{Type: "time.Time", Name: "int64", ToWire: "%s.UnixMilli()", FromWire: "time.UnixMilli(%s)", Imports: []string{"time"}},
+}
+
+func TestGenerateTypeMappingsInAnotherPackage(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": typeMappingSource}, "example.com/mypkg")
+	g := NewGenerator(Options{TypeMappings: typeMappings, LocalVariants: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = "client", "example.com/mypkg/client"
+	g.mapSignatureTypes(g.importStore, g.outputPkgPath)
+	g.collectWorkloads()
+	code, err := format.Source([]byte(g.generateCode()))
+	require.NoError(t, err)
+
+	// the mappings without conversions are rendered in the signatures
+	require.Contains(t, string(code), "func Rename[string_0 _T0, string_1 _T0](id string_0, name string_1) *RemoteFunc[*Future1[string]] {")
+	require.Contains(t, string(code), "func RenameLocal(id string, name string) (_r0 string, _err error) {")
+	require.Contains(t, string(code), "_o0 := new(mypkg.Tasks).Rename(mypkg.UserID(id), name)\n\treturn string(_o0), nil")
+	// the ones with conversions only in the fields encoded by the codecs
+	require.Contains(t, string(code), "func RecordLocal(e mypkg.Event, at time.Time) (_err error) {")
+	require.NotContains(t, string(code), "MarshalBinary") // the codecs are generated into the scanned package
+}
+
+func TestGenerateCodecsWithTypeMappings(t *testing.T) {
+	code := generateFromSource(t, map[string]string{"tasks": typeMappingSource}, Options{TypeMappings: typeMappings, Codec: CodecGob})
+
+	// the signatures of the scanned package keep the types
+	require.Contains(t, code, "func Rename[UserID_0 _T0, string_1 _T1](id UserID_0, name string_1) *RemoteFunc[*Future1[UserID]] {")
+	require.Contains(t, code, "type _EventWire struct {\n\tName   string\n\tAt     int64 `json:\"at\"`\n\tAuthor string\n}")
+	require.Contains(t, code, "_w := _EventWire{\n\t\tName:   _v.Name,\n\t\tAt:     _v.At.UnixMilli(),\n\t\tAuthor: string(_v.Author),\n\t}")
+	require.Contains(t, code, "if err := json.Unmarshal(data, &_w); err != nil {")
+	require.Contains(t, code, "\t_v.At = time.UnixMilli(_w.At)\n\t_v.Author = UserID(_w.Author)\n")
+	// UserID is carried as a string, not by the gob codec of the signature types
+	require.NotContains(t, code, "func (_v UserID) MarshalBinary()")
+}
+
+func TestRegisterTypeMapping(t *testing.T) {
+	defer func(registered []TypeMapping) { registeredTypeMappings = registered }(registeredTypeMappings)
+
+	RegisterTypeMapping(TypeMapping{Type: "example.com/ids.UserID", Name: "string"})
+	require.Panics(t, func() { RegisterTypeMapping(TypeMapping{Type: "example.com/ids.UserID", Name: "int64"}) })
+	require.Panics(t, func() { RegisterTypeMapping(TypeMapping{Type: "UserID", Name: "string"}) })
+
+	opts := Options{TypeMappings: []TypeMapping{{Type: "example.com/ids.UserID", Name: "[]byte"}, {Type: "time.Time", Name: "int64", ToWire: "%s.Unix()"}}}
+	mappings := opts.typeMappings()
+	require.Len(t, mappings, 3)
+	require.Equal(t, "[]byte", mappings[0].Name) // the options override the registered mappings
+	require.Len(t, opts.signatureTypeMappings(), 2)
+}
+
+func TestGenerateTypeAliases(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+import "time"
+
+// raytasks
+type Tasks struct{}
+
+type Timeout = time.Duration
+
+type Names = []string
+
+func (Tasks) Wait(t Timeout, names Names) any { return nil }
+`}
+	generate := func(opts Options) string {
+		pkg := makePkgFromSource(t, sources, "example.com/mypkg")
+		g := NewGenerator(opts)
+		g.pkg = pkg
+		g.outputPkgName, g.outputPkgPath = "client", "example.com/mypkg/client"
+		g.collectWorkloads()
+		code, err := format.Source([]byte(g.generateCode()))
+		require.NoError(t, err)
+		return string(code)
+	}
+
+	// the aliases are kept, qualified by their package
+	code := generate(Options{})
+	require.Contains(t, code, "func Wait[mypkg_Timeout_0 _T0, mypkg_Names_1 _T1](t mypkg_Timeout_0, names mypkg_Names_1) *RemoteFunc[*Future1[any]] {")
+	require.NotContains(t, code, `"time"`)
+
+	// or resolved to the types they alias
+	code = generate(Options{ResolveAliases: true})
+	require.Contains(t, code, "func Wait[time_Duration_0 _T0, slice_string_1 _T1](t time_Duration_0, names slice_string_1) *RemoteFunc[*Future1[any]] {")
+	require.Contains(t, code, `"time"`)
+}
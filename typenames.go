@@ -0,0 +1,53 @@
+package goraygen
+
+import (
+	"fmt"
+)
+
+// checkIdentifiableTypes reports an error for every two param types of the targets encoded as the same identifier
+// (see analysis.IdentifiableTypeName), which names the type parameters of the wrappers. The encoding is injective
+// for the types rendered differently, so it's a safety net, e.g. for the names set by the target hooks.
+func (g *Generator) checkIdentifiableTypes() error {
+	seen := make(map[string]string) // identifiable type -> type
+	var collisions int
+	check := func(m Method) {
+		for _, p := range m.Params {
+			if p.IdentifiableType == "" {
+				continue
+			}
+			typ, ok := seen[p.IdentifiableType]
+			if !ok {
+				seen[p.IdentifiableType] = p.Type
+				continue
+			}
+			if typ == p.Type {
+				continue
+			}
+			collisions++
+			g.report(g.at(g.methodPos(m), Diagnostic{
+				Severity:   SeverityError,
+				Code:       "type-name-collision",
+				Message:    fmt.Sprintf("param %s of (%s).%s: types %s and %s are both encoded as %s", p.Name, m.ReceiverType, m.Name, typ, p.Type, p.IdentifiableType),
+				Suggestion: "rename one of the types",
+			}))
+		}
+	}
+	for _, m := range g.tasks {
+		check(m)
+	}
+	for _, factory := range g.actorFactories {
+		check(factory)
+		for _, m := range g.actor2Methods[factory.Name] {
+			check(m)
+		}
+	}
+	for _, h := range g.actorHandles {
+		for _, m := range h.Methods {
+			check(m)
+		}
+	}
+	if collisions > 0 {
+		return withExitCode(exitAnalysis, fmt.Errorf("%d param types collide in the type parameter names of the wrappers", collisions))
+	}
+	return nil
+}
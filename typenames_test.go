@@ -0,0 +1,51 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIdentifiableTypes(t *testing.T) {
+	var diagnostics []Diagnostic
+	opts := Options{}
+	opts.Hooks.Diagnostic = append(opts.Hooks.Diagnostic, func(d Diagnostic) {
+		if d.Code == "type-name-collision" {
+			diagnostics = append(diagnostics, d)
+		}
+	})
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+type mapaTob int
+
+type a int
+
+type b int
+
+func (Tasks) Get(m map[a]b) mapaTob { return 0 }
+
+func (Tasks) Put(v mapaTob, w []*a) {}
+
+func (Tasks) Sum(vs ...a) a { return 0 }
+`}, "example.com/mypkg")
+	g := NewGenerator(opts)
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	require.Equal(t, "map_a_to_b", g.tasks[0].Params[0].IdentifiableType)
+	require.Equal(t, "mapaTob", g.tasks[1].Params[0].IdentifiableType)
+	require.Equal(t, "slice_ptr_a", g.tasks[1].Params[1].IdentifiableType)
+	require.Equal(t, "a", g.tasks[2].Params[0].IdentifiableType) // the element of the variadic param
+	require.NoError(t, g.checkIdentifiableTypes())
+	require.Empty(t, diagnostics)
+
+	g.tasks[1].Params[0].IdentifiableType = "map_a_to_b" // e.g. set by a hook
+	err := g.checkIdentifiableTypes()
+	require.ErrorContains(t, err, "1 param types collide in the type parameter names of the wrappers")
+	require.Len(t, diagnostics, 1)
+	require.Equal(t, "param v of (Tasks).Put: types map[a]b and mapaTob are both encoded as map_a_to_b", diagnostics[0].Message)
+	require.Equal(t, 14, diagnostics[0].Line)
+}
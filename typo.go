@@ -0,0 +1,97 @@
+package goraygen
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/ray4go/goraygen/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// maxMarkerTypoDistance is the max edit distance between a comment and a marker to be considered a typo.
+const maxMarkerTypoDistance = 2
+
+// NearMiss is a struct doc comment that looks like a mistyped marker.
+type NearMiss struct {
+	Pos     token.Position
+	Struct  string
+	Comment string
+}
+
+// FindNearMissMarkers collects the struct doc comments that are close to but not equal to the marker,
+// e.g. "// raytask" or "//raytasks " for the "// raytasks" marker.
+func FindNearMissMarkers(pkg *packages.Package, marker string) []NearMiss {
+	var nearMisses []NearMiss
+	for _, file := range analysis.SourceFiles(pkg) {
+		ast.Inspect(file, func(n ast.Node) bool {
+			genDecl, ok := n.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				return true
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				if _, ok := typeSpec.Type.(*ast.StructType); !ok {
+					continue
+				}
+				for _, doc := range []*ast.CommentGroup{genDecl.Doc, typeSpec.Doc} {
+					if doc == nil {
+						continue
+					}
+					for _, comment := range doc.List {
+						if isMarkerTypo(comment.Text, marker) {
+							nearMisses = append(nearMisses, NearMiss{
+								Pos:     pkg.Fset.Position(comment.Pos()),
+								Struct:  typeSpec.Name.Name,
+								Comment: comment.Text,
+							})
+						}
+					}
+				}
+			}
+			return false
+		})
+	}
+	return nearMisses
+}
+
+// isMarkerTypo reports whether the comment is not the marker but close to it,
+// ignoring case and spaces, or within a small edit distance.
+func isMarkerTypo(comment, marker string) bool {
+	if strings.TrimSpace(comment) == marker {
+		return false
+	}
+	normalize := func(s string) string {
+		s = strings.TrimPrefix(strings.TrimSpace(s), "//")
+		return strings.ToLower(strings.Join(strings.Fields(s), ""))
+	}
+	c, m := normalize(comment), normalize(marker)
+	if c == "" || m == "" {
+		return false
+	}
+	return c == m || (len(m) > maxMarkerTypoDistance*2 && editDistance(c, m) <= maxMarkerTypoDistance)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,36 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/ray4go/goraygen/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMarkerTypo(t *testing.T) {
+	for _, comment := range []string{"// raytask", "//raytasks ", "// RayTasks", "// ray tasks", "// raytaks"} {
+		require.True(t, isMarkerTypo(comment, raytasksComment), comment)
+	}
+	for _, comment := range []string{"// raytasks", "// rayactors", "// tasks", "// Foo does something."} {
+		require.False(t, isMarkerTypo(comment, raytasksComment), comment)
+	}
+}
+
+func TestFindNearMissMarkers(t *testing.T) {
+	code := `package mypkg
+
+//raytask
+type Tasks struct{}
+
+// rayactors
+type Actors struct{}
+`
+	pkg := makePkgFromSource(t, map[string]string{"tasks": code}, "example.com/mypkg")
+	require.Nil(t, analysis.FindStruct(pkg, CommentMatcher(raytasksComment)))
+
+	nearMisses := FindNearMissMarkers(pkg, raytasksComment)
+	require.Len(t, nearMisses, 1)
+	require.Equal(t, "Tasks", nearMisses[0].Struct)
+	require.Equal(t, "//raytask", nearMisses[0].Comment)
+	require.Equal(t, 3, nearMisses[0].Pos.Line)
+}
@@ -0,0 +1,127 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/bytedance/gg/gslice"
+	"github.com/ray4go/goraygen/analysis"
+)
+
+/*
+With -task-version 3 -task-version-aliases 1, the Divide wrapper calls the task registered as "Divide_v3",
+and the worker registers:
+
+	func (_t Tasks) Divide_v3(a int64, b int64) (int64, int64)
+	func (_t Tasks) Divide_v2(a int64, b int64) (int64, int64)
+*/
+const versionedTaskTpl = `
+// {{.TaskName}} is the registered name of [{{.StructName}}.{{.Name}}] at task version {{.Version}}{{if .Alias}}, kept as alias for the drivers of the previous deployment{{end}}.
+func (_t {{.ReceiverType}}) {{.TaskName}}({{.Params}}) ({{.ResultTypes}}) {
+	{{if .ResultTypes}}return {{end}}_t.{{.Target}}({{.CallArgs}})
+}
+`
+
+var versionedTaskTmpl = template.Must(template.New("versionedTask").Parse(versionedTaskTpl))
+
+// VersionedTaskDef is the template data of versionedTaskTpl.
+type VersionedTaskDef struct {
+	Name         string
+	TaskName     string // e.g. "Divide_v3"
+	Target       string // the method called, Name or its context variant, see contextTask
+	StructName   string
+	ReceiverType string
+	Params       string
+	CallArgs     string
+	ResultTypes  string
+	Version      int
+	Alias        bool
+}
+
+// versionedTaskName returns the registered name of the task at the version, e.g. "Divide_v3".
+func versionedTaskName(name string, version int) string {
+	return fmt.Sprintf("%s_v%d", name, version)
+}
+
+// taskVersions returns the versions the tasks are registered with on the worker: the latest first,
+// followed by the previous versions kept as aliases.
+func (o Options) taskVersions() []int {
+	if o.TaskVersion <= 0 {
+		return nil
+	}
+	versions := []int{o.TaskVersion}
+	for v := o.TaskVersion - 1; v > 0 && len(versions) <= o.TaskVersionAliases; v-- {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// prepareTaskVersions collects the tasks registered with versioned names into g.versionedTasks, once per generator.
+// The tasks whose versioned names conflict with a declared method keep their plain name, with a warning.
+func (g *Generator) prepareTaskVersions() {
+	if g.versionedTasks != nil {
+		return
+	}
+	g.versionedTasks = []Method{}
+	versions := g.opts.taskVersions()
+	if len(versions) == 0 || len(g.tasks) == 0 {
+		return
+	}
+	if !g.inPackageCode() {
+		g.warnOutsidePackage("skip-task-version", "Skip -task-version: the versioned tasks must be generated into package %s", g.pkg.PkgPath)
+		return
+	}
+	declared := gslice.Map(analysis.Methods(g.pkg, g.tasksStruct, g.pkg.PkgPath, g.importStore), func(m Method) string {
+		return m.Name
+	})
+	for _, m := range g.tasks {
+		conflict, found := gslice.Find(versions, func(v int) bool {
+			return gslice.Contains(declared, versionedTaskName(m.Name, v))
+		}).Get()
+		if found {
+			g.warnAt(g.methodPos(m), "skip-task-version", "Task %s keeps its unversioned name: %s already declares the %s method", m.Name, g.tasksStruct, versionedTaskName(m.Name, conflict))
+			continue
+		}
+		g.versionedTasks = append(g.versionedTasks, m)
+	}
+}
+
+// taskName returns the name the task is registered with, versioned with -task-version.
+func (g *Generator) taskName(m Method) string {
+	if gslice.Any(g.versionedTasks, func(t Method) bool { return t.Name == m.Name }) {
+		return versionedTaskName(m.Name, g.opts.TaskVersion)
+	}
+	return remoteName(m)
+}
+
+// generateVersionedTasks generates the worker-side tasks registered with the versioned names, delegating to the tasks.
+// Like the tasks, they are methods of the tasks struct in the scanned package.
+func (g *Generator) generateVersionedTasks(buf *bytes.Buffer) {
+	for _, m := range g.versionedTasks {
+		target := m
+		if m.TakesContext() { // called with the metadata of the ctx, like the context variant
+			target = contextTask(m)
+		}
+		params, callArgs, _ := callSignature(target)
+		resultTypes := gslice.Map(m.Results, func(r Result) string { return r.Type })
+		for i, v := range g.opts.taskVersions() {
+			def := VersionedTaskDef{
+				Name:         m.Name,
+				TaskName:     versionedTaskName(m.Name, v),
+				Target:       target.Name,
+				StructName:   g.tasksStruct,
+				ReceiverType: m.ReceiverType,
+				Params:       params,
+				CallArgs:     callArgs,
+				ResultTypes:  strings.Join(resultTypes, ", "),
+				Version:      v,
+				Alias:        i > 0,
+			}
+			if err := versionedTaskTmpl.Execute(buf, def); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
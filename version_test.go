@@ -0,0 +1,40 @@
+package goraygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateVersionedTasks(t *testing.T) {
+	sources := map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+func (*Tasks) Notify(users ...string) {}
+
+func (Tasks) Ping() error { return nil }
+
+func (Tasks) Ping_v2() error { return nil }
+`}
+	code := generateFromSource(t, sources, Options{TaskVersion: 3, TaskVersionAliases: 2, Manifest: true})
+
+	require.Contains(t, code, `NewRemoteFunc[*Future2[int64, int64]]("Divide_v3", []any{a, b})`)
+	require.Contains(t, code, "func (_t Tasks) Divide_v3(a int64, b int64) (int64, int64) {\n\treturn _t.Divide(a, b)\n}")
+	require.Contains(t, code, "func (_t Tasks) Divide_v2(a int64, b int64) (int64, int64) {")
+	require.Contains(t, code, "func (_t Tasks) Divide_v1(a int64, b int64) (int64, int64) {")
+	require.Contains(t, code, "func (_t *Tasks) Notify_v3(users ...string) {\n\t_t.Notify(users...)\n}")
+	require.Regexp(t, `TaskNameDivide\s+= "Divide_v3"`, code)
+	// Ping_v2 is declared, Ping keeps its name
+	require.Contains(t, code, `NewRemoteFunc[*Future1[error]]("Ping", []any{})`)
+	require.NotContains(t, code, "Ping_v3")
+
+	code = generateFromSource(t, sources, Options{TaskVersion: 1, TaskVersionAliases: 2})
+	require.Contains(t, code, "func (_t Tasks) Divide_v1(")
+	require.NotContains(t, code, "Divide_v0")
+
+	require.NotContains(t, generateFromSource(t, sources, Options{}), "Divide_v")
+}
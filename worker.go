@@ -0,0 +1,101 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+const workerFileName = "ray_workload_registration.go"
+
+const workerCommentsTPL = `
+// %s
+//
+// This file was generated by goray.
+// It contains the worker-side registration of the ray tasks and actors in this package.
+//
+// To regenerate this file, run:
+//	  goraygen -split-worker <package-path>
+`
+
+// writeWorker generates the worker-side registration file into the scanned package.
+func (g *Generator) writeWorker() error {
+	if len(g.pkg.GoFiles) == 0 {
+		return fmt.Errorf("no go files in package %s", g.pkg.PkgPath)
+	}
+	wg := g
+	if g.outputPkgPath != g.pkg.PkgPath {
+		// the types in signatures need to be rendered as seen from the scanned package
+		wg = NewGenerator(g.opts)
+		wg.pkg = g.pkg
+		wg.templates = g.templates
+		wg.backend = g.backend
+		wg.outputPkgName, wg.outputPkgPath = g.pkg.Name, g.pkg.PkgPath
+		wg.collectWorkloads()
+		wg.collectActorMethods()
+		wg.collectActorHandles()
+		if err := wg.runTargetHooks(); err != nil {
+			return err
+		}
+	}
+	sourceDir := filepath.Dir(g.pkg.GoFiles[0])
+	if err := wg.writeFile(wg.generateWorkerCode(), filepath.Join(sourceDir, wg.outputFileName(workerFileName))); err != nil {
+		return err
+	}
+	if wg.backendSelected("proto") {
+		return wg.writeProtoFile()
+	}
+	return nil
+}
+
+// generateWorkerCode generates the worker-side code: the register structs of the tasks and actors
+// to pass to ray.Init, and the signature assertions, checkpoint, ping and codec methods, idempotent, IO, context, versioned and protobuf tasks, the stream methods and the shutdown handler if enabled.
+// It doesn't contain any driver-side wrappers, so the implementation is not linked into driver binaries.
+func (g *Generator) generateWorkerCode() string {
+	var buf bytes.Buffer
+	if g.opts.BuildConstraint != "" {
+		fmt.Fprintf(&buf, "//go:build %s\n", g.opts.BuildConstraint)
+	}
+	fmt.Fprintf(&buf, workerCommentsTPL, g.opts.generatedHeader())
+	fmt.Fprintf(&buf, "package %s\n\n", g.outputPkgName)
+
+	var body bytes.Buffer
+	if g.opts.SignatureChecks {
+		g.generateSignatureAssertions(&body)
+	}
+	g.generateCheckpoints(&body)
+	g.generatePingMethods(&body)
+	g.prepareCodecs()
+	g.generateCodecs(&body)
+	g.prepareIdempotency()
+	g.generateIdempotentTasks(&body)
+	g.generateIOTasks(&body)
+	g.generateStreamMethods(&body)
+	g.generateContextTasks(&body)
+	g.prepareTaskVersions()
+	g.generateVersionedTasks(&body)
+	g.prepareProto()
+	g.generateProtoTasks(&body)
+	g.generateDrain(&body)
+	buf.WriteString(g.importStore.ImportBlock(g.modulePath()))
+
+	g.generateRegistration(&buf)
+	buf.Write(body.Bytes())
+	return buf.String()
+}
+
+// inPackageCode reports whether code can be generated in the scanned package, e.g. methods of the scanned types:
+// into the worker file with SplitWorker, otherwise into the wrappers file if it's in the scanned package.
+func (g *Generator) inPackageCode() bool {
+	return g.splitWorker() || g.outputPkgPath == g.pkg.PkgPath
+}
+
+// registerValue returns the expression of the register struct value, a pointer if any method has a pointer receiver.
+func registerValue(structName string, methods []Method) string {
+	for _, m := range methods {
+		if m.HasPointerReceiver() {
+			return "&" + structName + "{}"
+		}
+	}
+	return structName + "{}"
+}
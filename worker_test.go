@@ -0,0 +1,42 @@
+package goraygen
+
+import (
+	"go/format"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWorkerCode(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (*Tasks) Divide(a, b int64) (int64, int64) { return a / b, a % b }
+
+// rayactors
+type Actors struct{}
+
+func (Actors) Counter() *Counter { return &Counter{} }
+
+type Counter struct{}
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{SplitWorker: true, SignatureChecks: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	g.collectActorMethods()
+
+	client, err := format.Source([]byte(g.generateCode()))
+	require.NoError(t, err)
+	require.Contains(t, string(client), "var SignatureHashes = map[string]string{")
+	require.NotContains(t, string(client), "(*Tasks).Divide")
+
+	worker, err := format.Source([]byte(g.generateWorkerCode()))
+	require.NoError(t, err)
+	require.Contains(t, string(worker), "RayTasks  = &Tasks{}")
+	require.Contains(t, string(worker), "RayActors = Actors{}")
+	require.Contains(t, string(worker), "= (*Tasks).Divide")
+	require.NotContains(t, string(worker), "func Divide")
+}
@@ -0,0 +1,95 @@
+package goraygen
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+)
+
+const workerMainDir = "cmd/worker"
+
+const workerMainTpl = `// {{.Header}}
+
+// Command worker serves the ray tasks and actors of {{.PkgPath}}.
+//
+// Build it with:
+//
+//	go build -buildmode=c-shared -o worker.so ./{{.Dir}}
+//
+// To regenerate this file, run:
+//
+//	goraygen -gen-worker-main <package-path>
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	{{- if .Drain}}
+	"time"
+	{{- end}}
+
+	"{{.RayPkgPath}}"
+	workloads "{{.PkgPath}}"
+)
+
+{{- if .Drain}}
+
+var drainTimeout = flag.Duration("drain-timeout", 30*time.Second, "max time to wait for the task calls in flight on SIGTERM/SIGINT")
+{{- end}}
+
+func init() {
+	ray.Init({{if .HasTasks}}workloads.RayTasks{{else}}nil{{end}}, {{if .HasActors}}workloads.RayActors{{else}}nil{{end}}, driver)
+}
+
+// driver only serves the tasks and actors: the worker doesn't submit any work itself.
+// Flags and environment variables (e.g. RAY_ADDRESS) are read by the go-ray runtime.
+func driver() int {
+	flag.Parse()
+	{{- if .Drain}}
+	workloads.ShutdownOnSignal(*drainTimeout)
+	{{- end}}
+	log.Printf("worker of {{.PkgPath}} started, pid %d", os.Getpid())
+	return 0
+}
+
+func main() {}
+`
+
+var workerMainTmpl = template.Must(template.New("workerMain").Parse(workerMainTpl))
+
+// writeWorkerMain generates the worker main package into cmd/worker of the module of the scanned package.
+// It registers the RayTasks and RayActors of the worker registration file, see generateWorkerCode.
+func (g *Generator) writeWorkerMain() error {
+	if g.pkg.Name == "main" {
+		return fmt.Errorf("can't generate worker main for package main %s, move the tasks/actors into a library package", g.pkg.PkgPath)
+	}
+	rootDir := filepath.Dir(g.pkg.GoFiles[0])
+	if g.pkg.Module != nil && g.pkg.Module.Dir != "" {
+		rootDir = g.pkg.Module.Dir
+	}
+	outputDir := filepath.Join(rootDir, workerMainDir)
+	if err := g.mkdirAll(outputDir); err != nil {
+		return fmt.Errorf("create worker main dir error: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err := workerMainTmpl.Execute(&buf, struct {
+		Header, PkgPath, RayPkgPath, Dir string
+		HasTasks, HasActors              bool
+		Drain                            bool // call ShutdownOnSignal, see drainHelpersTpl
+	}{
+		Header:     g.opts.generatedHeader(),
+		PkgPath:    g.pkg.PkgPath,
+		RayPkgPath: goRayRepo,
+		Dir:        workerMainDir,
+		HasTasks:   g.tasksStruct != "",
+		HasActors:  g.actorsStruct != "",
+		Drain:      g.drainsTasks(),
+	})
+	if err != nil {
+		return err
+	}
+	return g.writeFile(buf.String(), filepath.Join(outputDir, "main.go"))
+}
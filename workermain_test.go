@@ -0,0 +1,40 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteWorkerMain(t *testing.T) {
+	pkg := makePkgFromSource(t, map[string]string{"tasks": `package mypkg
+
+// raytasks
+type Tasks struct{}
+
+func (Tasks) Ping() {}
+`}, "example.com/mypkg")
+	g := NewGenerator(Options{SplitWorker: true, GenWorkerMain: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	require.NoError(t, g.writeWorkerMain())
+
+	code, err := os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), workerMainDir, "main.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(code), "package main")
+	require.Contains(t, string(code), `workloads "example.com/mypkg"`)
+	require.Contains(t, string(code), "ray.Init(workloads.RayTasks, nil, driver)")
+	require.NotContains(t, string(code), "ShutdownOnSignal")
+
+	g = NewGenerator(Options{SplitWorker: true, GenWorkerMain: true, GracefulShutdown: true})
+	g.pkg = pkg
+	g.outputPkgName, g.outputPkgPath = pkg.Name, pkg.PkgPath
+	g.collectWorkloads()
+	require.NoError(t, g.writeWorkerMain())
+	code, err = os.ReadFile(filepath.Join(filepath.Dir(pkg.GoFiles[0]), workerMainDir, "main.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(code), "workloads.ShutdownOnSignal(*drainTimeout)")
+}
@@ -0,0 +1,103 @@
+package goraygen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ray4go/goraygen/analysis"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+const goWorkFileName = "go.work"
+
+// IsWorkspaceRoot reports whether dir is the root of a Go workspace (contains a go.work file).
+func IsWorkspaceRoot(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, goWorkFileName))
+	return err == nil && !info.IsDir()
+}
+
+// RunWorkspace generates wrappers for every package that contains annotated structs,
+// across all modules listed in the go.work file of the workspace root.
+// Each module is loaded from its own directory, so packages resolve with the correct module context.
+// A failed module doesn't stop the others, the errors are joined.
+func RunWorkspace(root string, opts Options) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("get abs path of workspace error: %w", err)
+	}
+	moduleDirs, err := workspaceModuleDirs(absRoot)
+	if err != nil {
+		return err
+	}
+	opts.logger().Info("Found modules in workspace", "count", len(moduleDirs), "root", absRoot)
+
+	var errs []error
+	for _, moduleDir := range moduleDirs {
+		pkgs, err := analysis.Load(opts.packagesConfig(moduleDir), "./...")
+		if err != nil {
+			errs = append(errs, withExitCode(exitLoad, fmt.Errorf("load module %s error: %w", moduleDir, err)))
+			continue
+		}
+		if err := generatePackages(context.Background(), pkgs, opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// generatePackages generates wrappers next to every package that contains annotated structs, the others are skipped.
+// A failed package doesn't stop the others, the errors are joined. It stops before the next package once ctx is done.
+func generatePackages(ctx context.Context, pkgs []*packages.Package, opts Options) error {
+	var errs []error
+	for _, pkg := range pkgs {
+		pkgOpts, err := opts.forPackage(pkg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(pkg.GoFiles) == 0 || !hasAnnotatedStruct(pkg, pkgOpts) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		opts.logger().Info("Generating for package", "package", pkg.PkgPath)
+		g := NewGenerator(pkgOpts)
+		g.pkg = pkg
+		g.reportPackageErrors()
+		if err := g.generate(filepath.Dir(pkg.GoFiles[0])); err != nil {
+			errs = append(errs, fmt.Errorf("generate for package %s error: %w", pkg.PkgPath, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// workspaceModuleDirs parses the go.work file in root and returns the absolute directories of the used modules.
+func workspaceModuleDirs(root string) ([]string, error) {
+	workFile := filepath.Join(root, goWorkFileName)
+	data, err := os.ReadFile(workFile)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := modfile.ParseWork(workFile, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s error: %w", workFile, err)
+	}
+	dirs := make([]string, 0, len(wf.Use))
+	for _, use := range wf.Use {
+		dir := use.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs, nil
+}
+
+func hasAnnotatedStruct(pkg *packages.Package, opts Options) bool {
+	return analysis.FindStruct(pkg, opts.tasksMatcher()) != nil || analysis.FindStruct(pkg, opts.actorsMatcher()) != nil
+}
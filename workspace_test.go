@@ -0,0 +1,27 @@
+package goraygen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceModuleDirs(t *testing.T) {
+	root := t.TempDir()
+	goWork := `go 1.24
+
+use (
+	./tasks
+	./actors
+)
+`
+	require.NoError(t, os.WriteFile(filepath.Join(root, goWorkFileName), []byte(goWork), 0o644))
+	require.True(t, IsWorkspaceRoot(root))
+	require.False(t, IsWorkspaceRoot(t.TempDir()))
+
+	dirs, err := workspaceModuleDirs(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(root, "tasks"), filepath.Join(root, "actors")}, dirs)
+}